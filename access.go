@@ -0,0 +1,304 @@
+package nestext
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// --- Access by path --------------------------------------------------------
+
+// Get returns the value at path expression `path` within `tree`, a result of Parse.
+// Path expressions are described for ParsePath; wildcards and globs are not allowed. If there
+// is no value at `path`, an error of code ErrCodeSchema is returned.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func Get(tree interface{}, path string) (interface{}, error) {
+	segments, err := accessPath(path)
+	if err != nil {
+		return nil, err
+	}
+	node := tree
+	for i, seg := range segments {
+		child, ok := childAt(node, seg)
+		if !ok {
+			return nil, accessError(segments[:i+1], "no such item")
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// Set sets the value at path expression `path` within `tree`, a result of Parse, to
+// `value`. Missing dicts on the way to `path` are created, as is the last item of
+// `path`. Created dicts are ordered maps if the dict containing them is one. List items
+// are addressed by index; an index equal to the length of a list appends to it, except
+// for a top-level list, which cannot grow in place.
+//
+//     err := nestext.Set(config, "president.phone.cell", "1-210-555-5297")
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func Set(tree interface{}, path string, value interface{}) error {
+	segments, err := accessPath(path)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return MakeNestedTextError(ErrCodeUsage, "cannot set top-level value")
+	}
+	if tree == nil {
+		return MakeNestedTextError(ErrCodeUsage, "cannot set value within nil tree")
+	}
+	if l, ok := tree.([]interface{}); ok && segments[0].IsIndex && segments[0].Index >= len(l) {
+		return accessError(segments[:1], "cannot append to top-level list")
+	}
+	_, err = setAt(tree, segments, 0, value, isOrdered(tree))
+	return err
+}
+
+// Delete removes the item at path expression `path` from `tree`, a result of Parse.
+// Deleting a non-existing item is not an error. Items of a top-level list cannot be
+// deleted, as the list cannot shrink in place.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func Delete(tree interface{}, path string) error {
+	segments, err := accessPath(path)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return MakeNestedTextError(ErrCodeUsage, "cannot delete top-level value")
+	}
+	if _, ok := tree.([]interface{}); ok && len(segments) == 1 {
+		return accessError(segments, "cannot delete from top-level list")
+	}
+	parent, err := Get(tree, JoinPath(segments[:len(segments)-1]))
+	if err != nil {
+		return nil // nothing to delete
+	}
+	last := segments[len(segments)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if !last.IsIndex {
+			delete(p, last.Key)
+		}
+	case *OrderedMap:
+		if !last.IsIndex {
+			p.Delete(last.Key)
+		}
+	case []interface{}:
+		if !last.IsIndex || last.Index >= len(p) {
+			return nil
+		}
+		// lists are values of their containers, so we have to store the shortened list
+		l := append(p[:last.Index:last.Index], p[last.Index+1:]...)
+		_, err = setAt(tree, segments[:len(segments)-1], 0, l, isOrdered(tree))
+		return err
+	}
+	return nil
+}
+
+// --- Typed access ----------------------------------------------------------
+
+// NestedText stores all scalar values as strings. The following getters retrieve
+// values at a path like Get and convert them to Go types, reporting the path and the
+// offending value if conversion fails.
+
+// GetString returns the string at path expression `path` within `tree`.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func GetString(tree interface{}, path string) (string, error) {
+	v, err := Get(tree, path)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", MakeNestedTextError(ErrCodeSchema,
+			fmt.Sprintf("path %q: expected string, have %s", path, kindOf(v)))
+	}
+	return s, nil
+}
+
+// GetInt returns the integer at path expression `path` within `tree`. Numbers are
+// converted by strconv.ParseInt, with base prefixes like "0x" allowed.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func GetInt(tree interface{}, path string) (int, error) {
+	s, err := GetString(tree, path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(s, 0, 0)
+	if err != nil {
+		return 0, conversionError(path, s, "int", err)
+	}
+	return int(n), nil
+}
+
+// GetBool returns the boolean value at path expression `path` within `tree`.
+// Accepted values are the ones understood by strconv.ParseBool.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func GetBool(tree interface{}, path string) (bool, error) {
+	s, err := GetString(tree, path)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, conversionError(path, s, "bool", err)
+	}
+	return b, nil
+}
+
+// GetDuration returns the duration at path expression `path` within `tree`, in the
+// format understood by time.ParseDuration, e.g. "1m30s".
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func GetDuration(tree interface{}, path string) (time.Duration, error) {
+	s, err := GetString(tree, path)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, conversionError(path, s, "duration", err)
+	}
+	return d, nil
+}
+
+// GetStringSlice returns the list of strings at path expression `path` within `tree`.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func GetStringSlice(tree interface{}, path string) ([]string, error) {
+	v, err := Get(tree, path)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil, MakeNestedTextError(ErrCodeSchema,
+			fmt.Sprintf("path %q: expected list, have %s", path, kindOf(v)))
+	}
+	strings := make([]string, len(l))
+	for i, item := range l {
+		if strings[i], ok = item.(string); !ok {
+			return nil, MakeNestedTextError(ErrCodeSchema,
+				fmt.Sprintf("path %q: expected list of strings, item %d is %s", path, i, kindOf(item)))
+		}
+	}
+	return strings, nil
+}
+
+func conversionError(path string, s string, typ string, err error) error {
+	return WrapError(ErrCodeSchema, fmt.Sprintf("path %q: cannot convert %q to %s", path, s, typ), err)
+}
+
+// accessPath parses a path expression for Get, Set and Delete, rejecting wildcards
+// and globs.
+func accessPath(path string) ([]PathSegment, error) {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, seg := range segments {
+		if seg.IsPattern() {
+			return nil, MakeNestedTextError(ErrCodeUsage,
+				fmt.Sprintf("path %q: wildcards not allowed", path))
+		}
+	}
+	return segments, nil
+}
+
+// childAt returns the item of dict or list `node` selected by `seg`.
+func childAt(node interface{}, seg PathSegment) (interface{}, bool) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if !seg.IsIndex {
+			v, ok := n[seg.Key]
+			return v, ok
+		}
+	case *OrderedMap:
+		if !seg.IsIndex {
+			return n.Get(seg.Key)
+		}
+	case []interface{}:
+		if seg.IsIndex && seg.Index < len(n) {
+			return n[seg.Index], true
+		}
+	}
+	return nil, false
+}
+
+// setAt stores `value` at `segments[at:]` below `node` and returns the node, which
+// differs from the original one if node is nil (and has been created) or is a list
+// which has grown. `ordered` tells if dicts to create should be ordered maps.
+func setAt(node interface{}, segments []PathSegment, at int, value interface{}, ordered bool) (interface{}, error) {
+	if at == len(segments) {
+		return value, nil
+	}
+	seg := segments[at]
+	if node == nil {
+		if seg.IsIndex {
+			return nil, accessError(segments[:at+1], "no such list item")
+		}
+		if ordered {
+			node = NewOrderedMap()
+		} else {
+			node = map[string]interface{}{}
+		}
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if seg.IsIndex {
+			return nil, accessError(segments[:at+1], "dict has no list items")
+		}
+		child, err := setAt(n[seg.Key], segments, at+1, value, false)
+		if err != nil {
+			return nil, err
+		}
+		n[seg.Key] = child
+	case *OrderedMap:
+		if seg.IsIndex {
+			return nil, accessError(segments[:at+1], "dict has no list items")
+		}
+		v, _ := n.Get(seg.Key)
+		child, err := setAt(v, segments, at+1, value, true)
+		if err != nil {
+			return nil, err
+		}
+		n.Set(seg.Key, child)
+	case []interface{}:
+		if !seg.IsIndex {
+			return nil, accessError(segments[:at+1], "list has no keys")
+		}
+		if seg.Index > len(n) {
+			return nil, accessError(segments[:at+1], "list index out of range")
+		}
+		var v interface{}
+		if seg.Index < len(n) {
+			v = n[seg.Index]
+		}
+		child, err := setAt(v, segments, at+1, value, ordered)
+		if err != nil {
+			return nil, err
+		}
+		if seg.Index == len(n) {
+			return append(n, child), nil
+		}
+		n[seg.Index] = child
+	default:
+		return nil, accessError(segments[:at], "not a dict or list")
+	}
+	return node, nil
+}
+
+func isOrdered(tree interface{}) bool {
+	_, ok := tree.(*OrderedMap)
+	return ok
+}
+
+func accessError(segments []PathSegment, msg string) error {
+	return MakeNestedTextError(ErrCodeSchema, "path "+strconv.Quote(JoinPath(segments))+": "+msg)
+}
@@ -0,0 +1,119 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetSetDelete(t *testing.T) {
+	input := "president:\n  name: Katheryn\n  roles:\n    - board member\n    - treasurer\n"
+	tree, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Get(tree, "president.roles[1]"); err != nil || v != "treasurer" {
+		t.Errorf("expected treasurer, have %v, %v", v, err)
+	}
+	if _, err := Get(tree, "president.phone"); err == nil || err.(NestedTextError).Code != ErrCodeSchema {
+		t.Errorf("expected schema error for missing item, have %v", err)
+	}
+	if err := Set(tree, "president.phone.cell", "123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(tree, "president.roles[2]", "secretary"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete(tree, "president.roles[0]"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete(tree, "president.name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete(tree, "president.email"); err != nil {
+		t.Errorf("expected deleting a missing item to succeed, have %v", err)
+	}
+	expected := map[string]interface{}{
+		"president": map[string]interface{}{
+			"phone": map[string]interface{}{"cell": "123"},
+			"roles": []interface{}{"treasurer", "secretary"},
+		},
+	}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("expected %v, have %v", expected, tree)
+	}
+}
+
+func TestSetOrderedDicts(t *testing.T) {
+	tree, err := Parse(strings.NewReader("b: 1\n"), OrderedDicts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(tree, "a.x", "2"); err != nil {
+		t.Fatal(err)
+	}
+	m := tree.(*OrderedMap)
+	if a, _ := m.Get("a"); !reflect.DeepEqual(m.Keys(), []string{"b", "a"}) || a.(*OrderedMap).Len() != 1 {
+		t.Errorf("expected ordered dict to be created, have %v", m)
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	tree := map[string]interface{}{"a": "x", "l": []interface{}{"y"}}
+	inputs := []struct {
+		path string
+		code int
+	}{
+		{"", ErrCodeUsage},
+		{"l.*", ErrCodeUsage},
+		{"a.b", ErrCodeSchema},
+		{"l.b", ErrCodeSchema},
+		{"l[2]", ErrCodeSchema},
+		{"[0]", ErrCodeSchema},
+	}
+	for _, input := range inputs {
+		err := Set(tree, input.path, "v")
+		if nterr, ok := err.(NestedTextError); !ok || nterr.Code != input.code {
+			t.Errorf("expected code %d for path %q, have %v", input.code, input.path, err)
+		}
+	}
+	if err := Set([]interface{}{"a"}, "[1]", "b"); err == nil {
+		t.Errorf("expected appending to top-level list to fail")
+	}
+}
+
+func TestTypedGetters(t *testing.T) {
+	input := "port: 0x1F90\ndebug: true\ntimeout: 1m30s\nhosts:\n  - a\n  - b\nname: x\nnested:\n  -\n    - a\n"
+	tree, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := GetInt(tree, "port"); err != nil || n != 8080 {
+		t.Errorf("expected 8080, have %d, %v", n, err)
+	}
+	if b, err := GetBool(tree, "debug"); err != nil || !b {
+		t.Errorf("expected true, have %v, %v", b, err)
+	}
+	if d, err := GetDuration(tree, "timeout"); err != nil || d != 90*time.Second {
+		t.Errorf("expected 1m30s, have %v, %v", d, err)
+	}
+	if l, err := GetStringSlice(tree, "hosts"); err != nil || !reflect.DeepEqual(l, []string{"a", "b"}) {
+		t.Errorf("expected [a b], have %v, %v", l, err)
+	}
+	if s, err := GetString(tree, "hosts[1]"); err != nil || s != "b" {
+		t.Errorf("expected b, have %q, %v", s, err)
+	}
+	for _, path := range []string{"name", "hosts", "missing"} {
+		if _, err := GetInt(tree, path); err == nil || err.(NestedTextError).Code != ErrCodeSchema {
+			t.Errorf("expected schema error for %q, have %v", path, err)
+		}
+	}
+	_, err = GetBool(tree, "name")
+	if err == nil || err.Error() != `[0,0] path "name": cannot convert "x" to bool` {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := GetStringSlice(tree, "nested"); err == nil {
+		t.Error("expected list of lists to be rejected")
+	}
+}
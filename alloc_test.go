@@ -0,0 +1,117 @@
+package nestext
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Allocation bounds for the happy path of Parse. The bounds leave some headroom over
+// the current counts; if a change exceeds them, either the change is too expensive or
+// the bounds have to be raised deliberately.
+var allocationBounds = []struct {
+	name     string
+	input    string
+	maxAlloc float64
+}{
+	{"dict", "a: 1\n", 40},
+	{"inline", "- [a, b]\n- {k: v}\n", 45},
+	{"nested", "a: 1\nb:\n  - x\n  - y\nc:\n  > l1\n  > l2\n", 120},
+}
+
+func skipAllocationTests(t *testing.T) {
+	if tracing {
+		t.Skip("trace output allocates")
+	}
+	if testing.CoverMode() != "" {
+		t.Skip("coverage instrumentation allocates")
+	}
+}
+
+func TestParseAllocations(t *testing.T) {
+	skipAllocationTests(t)
+	for _, b := range allocationBounds {
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := Parse(strings.NewReader(b.input)); err != nil {
+				t.Fatal(err)
+			}
+		})
+		t.Logf("%s: %.0f allocations", b.name, allocs)
+		if allocs > b.maxAlloc {
+			t.Errorf("%s: expected at most %.0f allocations, have %.0f", b.name, b.maxAlloc, allocs)
+		}
+	}
+}
+
+func TestErrorAllocations(t *testing.T) {
+	skipAllocationTests(t)
+	token := &parserToken{LineNo: 3, ColNo: 4}
+	var err NestedTextError
+	allocs := testing.AllocsPerRun(100, func() {
+		err = MakeNestedTextError(ErrCodeFormat, "format error")
+		err = WrapError(ErrCodeIO, "I/O error", nil)
+		err = makeParsingError(token, ErrCodeFormat, "format error")
+	})
+	if allocs != 0 {
+		t.Errorf("expected constructing errors not to allocate, have %.0f allocations", allocs)
+	}
+	if err.Line != 3 || err.Column != 4 {
+		t.Errorf("expected error at [3,4], have %v", err)
+	}
+}
+
+// lineSource generates a document of `n` list items without holding it in memory.
+type lineSource struct {
+	n, i int
+	line []byte
+}
+
+func (s *lineSource) Read(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		if len(s.line) == 0 {
+			if s.i == s.n {
+				break
+			}
+			s.i++
+			s.line = []byte("- item with some text\n")
+		}
+		k := copy(p[written:], s.line)
+		s.line, written = s.line[k:], written+k
+	}
+	if written == 0 {
+		return 0, io.EOF
+	}
+	return written, nil
+}
+
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func TestParseEventsMemory(t *testing.T) {
+	skipAllocationTests(t)
+	const lines = 200000
+	var early, late uint64
+	h := &Handler{
+		OnListItem: func(index int, line int) error {
+			switch index {
+			case 1000:
+				early = heapInUse()
+			case lines - 1:
+				late = heapInUse()
+			}
+			return nil
+		},
+	}
+	if err := ParseEvents(&lineSource{n: lines}, h); err != nil {
+		t.Fatal(err)
+	}
+	if late > early && late-early > 1<<20 {
+		t.Errorf("expected streaming to use constant memory, heap grew by %d bytes", late-early)
+	}
+}
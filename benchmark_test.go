@@ -0,0 +1,100 @@
+package nestext_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntenc"
+)
+
+// Benchmarks comparing parsing of NestedText with parsing of equivalent JSON
+// documents, for documents of different sizes. Compare results across releases with
+// benchstat:
+//
+//     go test -run=NONE -bench=Compare -count=10 . > new.txt
+//
+// A comparison with YAML is left out, to keep the module free of dependencies.
+
+// benchmarkDocument creates a tree of `n` address book entries, similar to the
+// examples of the NestedText spec.
+func benchmarkDocument(n int) interface{} {
+	entries := make([]interface{}, n)
+	for i := range entries {
+		entries[i] = map[string]interface{}{
+			"name":  fmt.Sprintf("Person %d", i),
+			"email": fmt.Sprintf("person%d@example.com", i),
+			"address": strings.Join([]string{
+				fmt.Sprintf("%d Almond Street", 100+i),
+				"Topeka, Kansas 20697",
+			}, "\n"),
+			"phone": map[string]interface{}{
+				"cell": "1-210-555-5297",
+				"home": "1-210-555-8470",
+			},
+			"roles": []interface{}{"member", "board member"},
+		}
+	}
+	return map[string]interface{}{"entries": entries}
+}
+
+var benchmarkSizes = []int{1, 100, 10000}
+
+func BenchmarkCompareParse(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		tree := benchmarkDocument(n)
+		var nt bytes.Buffer
+		if _, err := ntenc.Encode(tree, &nt); err != nil {
+			b.Fatal(err)
+		}
+		js, err := json.Marshal(tree)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("nestext/%d", n), func(b *testing.B) {
+			b.SetBytes(int64(nt.Len()))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := nestext.Parse(bytes.NewReader(nt.Bytes())); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("json/%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(js)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var v interface{}
+				if err := json.Unmarshal(js, &v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCompareEncode(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		tree := benchmarkDocument(n)
+		b.Run(fmt.Sprintf("nestext/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ntenc.Encode(tree, ioutil.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("json/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(tree); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package nestext
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- Bidi control characters -----------------------------------------------
+
+// KeepLegacyBidi requests the parser to keep Unicode bidi control characters, i.e.
+// LTR and RTL marks, embeddings and overrides, and directional isolates.
+//
+// By default, the parser strips them from all lines of the input, including keys, values
+// and comments, and reports a warning for every line stripped (see ReportWarnings).
+// Bidi control characters make text appear in a different order than the order the
+// characters are stored in, which may be used to disguise the content of a document
+// (so-called "Trojan Source" attacks). For security reasons, applications should treat
+// them cautiously when reading from external sources; code hosting sites, e.g. GitHub,
+// warn about them as well. Column numbers of errors refer to the stripped lines.
+//
+// Use as:
+//     // documents in Arabic or Hebrew may rely on bidi control characters
+//     result, err := nestext.Parse(reader, nestext.KeepLegacyBidi(true))
+//
+func KeepLegacyBidi(keep bool) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.keepBidi = keep
+		return nil
+	}
+}
+
+// isBidiControl is a predicate for the Unicode bidi control characters: ALM, LRM and
+// RLM, the embeddings and overrides LRE, RLE, PDF, LRO and RLO, and the isolates LRI,
+// RLI, FSI and PDI.
+func isBidiControl(r rune) bool {
+	return r == 0x061c || r == 0x200e || r == 0x200f || r >= 0x202a && r <= 0x202e ||
+		r >= 0x2066 && r <= 0x2069
+}
+
+// stripBidi is a Rewrite callback for the line buffer, removing bidi control characters
+// from line number `line`. A warning is reported at the column of the first character
+// removed.
+func (p *nestedTextParser) stripBidi(line int, text string) string {
+	first := strings.IndexFunc(text, isBidiControl)
+	if first < 0 {
+		return text
+	}
+	removed := 0
+	stripped := strings.Map(func(r rune) rune {
+		if isBidiControl(r) {
+			removed++
+			return -1
+		}
+		return r
+	}, text)
+	w := MakeNestedTextError(ErrCodeFormat, fmt.Sprintf("removed %d bidi control character(s)", removed))
+	w.Line, w.Column = line, utf8.RuneCountInString(text[:first])
+	p.warning(w)
+	return stripped
+}
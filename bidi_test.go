@@ -0,0 +1,69 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStripBidi(t *testing.T) {
+	inputs := []struct {
+		text     string
+		result   interface{}
+		warnings []int // lines of warnings
+	}{
+		{"a\u202e: b\u202c\n", map[string]interface{}{"a": "b"}, []int{1}},
+		{"- x\n- \u2067y\u2069\n", []interface{}{"x", "y"}, []int{2}},
+		{"k:\n  > l\u200f1\n  > l2\n", map[string]interface{}{"k": "l1\nl2"}, []int{2}},
+		{"[a\u200e, b]\n", []interface{}{"a", "b"}, []int{1}},
+		{"# \u202e comment\na: b\n", map[string]interface{}{"a": "b"}, []int{1}},
+		{"a: \u05d0\u05d1\n", map[string]interface{}{"a": "\u05d0\u05d1"}, nil},
+	}
+	for i, input := range inputs {
+		var lines []int
+		result, err := Parse(strings.NewReader(input.text),
+			ReportWarnings(func(w NestedTextError) { lines = append(lines, w.Line) }))
+		if err != nil {
+			t.Errorf("%d: expected no error, have %v", i, err)
+		}
+		if !reflect.DeepEqual(result, input.result) {
+			t.Errorf("%d: expected %#v, have %#v", i, input.result, result)
+		}
+		if !reflect.DeepEqual(lines, input.warnings) {
+			t.Errorf("%d: expected warnings at lines %v, have %v", i, input.warnings, lines)
+		}
+	}
+}
+
+func TestParseStripBidiWarning(t *testing.T) {
+	var warnings []NestedTextError
+	_, err := Parse(strings.NewReader("a: 1\nkey: \u00e4\u202ex\u202c\n"),
+		ReportWarnings(func(w NestedTextError) { warnings = append(warnings, w) }))
+	if err != nil || len(warnings) != 1 {
+		t.Fatalf("expected a single warning, have %v and %v", err, warnings)
+	}
+	w := warnings[0]
+	if w.Severity != SeverityWarning || w.Line != 2 || w.Column != 6 ||
+		w.Message != "removed 2 bidi control character(s)" {
+		t.Errorf("unexpected warning %v", w)
+	}
+}
+
+func TestKeepLegacyBidi(t *testing.T) {
+	input := "a\u202e: b\u202c\n"
+	expected := map[string]interface{}{"a\u202e": "b\u202c"}
+	warned := false
+	result, err := Parse(strings.NewReader(input), KeepLegacyBidi(true),
+		ReportWarnings(func(w NestedTextError) { warned = true }))
+	if err != nil || warned || !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %#v without warnings, have %#v (warned: %v), %v", expected, result, warned, err)
+	}
+	var keys []string
+	h := &Handler{
+		OnKey:   func(key string, line int) error { keys = append(keys, key); return nil },
+		OnValue: func(value string, line int) error { keys = append(keys, value); return nil },
+	}
+	if err = ParseEvents(strings.NewReader(input), h); err != nil || !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("expected events to be stripped, have %q, %v", keys, err)
+	}
+}
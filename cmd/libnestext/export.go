@@ -0,0 +1,40 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+// #include <stdlib.h>
+import "C"
+
+import "unsafe"
+
+//export ParseToJSON
+func ParseToJSON(doc *C.char, err **C.char) *C.char {
+	js, e := parseToJSON(C.GoString(doc))
+	return result(js, e, err)
+}
+
+//export EncodeFromJSON
+func EncodeFromJSON(js *C.char, err **C.char) *C.char {
+	nt, e := encodeFromJSON(C.GoString(js))
+	return result(nt, e, err)
+}
+
+//export FreeString
+func FreeString(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// result converts the result of a facade function for C callers.
+func result(s string, e error, err **C.char) *C.char {
+	if e != nil {
+		if err != nil {
+			*err = C.CString(e.Error())
+		}
+		return nil
+	}
+	if err != nil {
+		*err = nil
+	}
+	return C.CString(s)
+}
@@ -0,0 +1,97 @@
+// Command libnestext builds a C shared library, making the NestedText parser and
+// encoder of this module available to programs written in other languages, e.g.
+// Python (ctypes, cffi) or Rust (FFI):
+//
+//     go build -buildmode=c-shared -o libnestext.so ./cmd/libnestext
+//
+// This creates libnestext.so together with a header file libnestext.h, declaring
+//
+//     char* ParseToJSON(char* doc, char** err);
+//     char* EncodeFromJSON(char* json, char** err);
+//     void FreeString(char* s);
+//
+// ParseToJSON parses a NestedText document and returns the result as JSON.
+// EncodeFromJSON encodes a JSON document as NestedText. JSON numbers, booleans and
+// null are converted to strings ("null" to an empty string), as NestedText knows
+// strings only.
+//
+// Both functions return NULL if an error occurs, and set *err to the error message
+// (if err is not NULL). Strings returned, including error messages, are allocated by
+// the library and have to be released with FreeString. Input strings have to be
+// UTF-8 and remain owned by the caller.
+//
+// A Python example:
+//
+//     lib = ctypes.CDLL("./libnestext.so")
+//     lib.ParseToJSON.restype = ctypes.c_void_p
+//     err = ctypes.c_char_p()
+//     p = lib.ParseToJSON(b"a: 1\n", ctypes.byref(err))
+//     print(json.loads(ctypes.string_at(p)))  # {'a': '1'}
+//     lib.FreeString(ctypes.c_void_p(p))
+//
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntenc"
+)
+
+// main is required for building with -buildmode=c-shared, but is never called.
+func main() {}
+
+// parseToJSON parses NestedText document `doc` and returns the result as JSON.
+// Ordered dicts keep the order of keys of the document.
+func parseToJSON(doc string) (string, error) {
+	tree, err := nestext.Parse(strings.NewReader(doc), nestext.OrderedDicts())
+	if err != nil {
+		return "", err
+	}
+	js, err := json.Marshal(tree)
+	if err != nil {
+		return "", nestext.WrapError(nestext.ErrCodeUsage, "cannot convert result to JSON", err)
+	}
+	return string(js), nil
+}
+
+// encodeFromJSON encodes JSON document `js` as NestedText.
+func encodeFromJSON(js string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(js))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return "", nestext.WrapError(nestext.ErrCodeFormat, "invalid JSON input", err)
+	}
+	var out bytes.Buffer
+	if _, err := ntenc.Encode(stringsOnly(tree), &out); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// stringsOnly converts the scalars of a tree decoded from JSON to strings.
+func stringsOnly(tree interface{}) interface{} {
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		for k, v := range t {
+			t[k] = stringsOnly(v)
+		}
+	case []interface{}:
+		for i, v := range t {
+			t[i] = stringsOnly(v)
+		}
+	case json.Number:
+		return t.String()
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	case nil:
+		return ""
+	}
+	return tree
+}
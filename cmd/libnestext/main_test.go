@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseToJSON(t *testing.T) {
+	js, err := parseToJSON("b: 1\na:\n  - x\n  -\n    > multi\n    > line\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"b":"1","a":["x","multi\nline"]}`; js != want {
+		t.Errorf("expected %s, have %s", want, js)
+	}
+	if _, err = parseToJSON("a: 1\n  b: 2\n"); err == nil {
+		t.Error("expected invalid document to produce an error; didn't")
+	}
+}
+
+func TestEncodeFromJSON(t *testing.T) {
+	nt, err := encodeFromJSON(`{"name": "x", "port": 8080, "debug": true, "tags": [null, 1.5]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := parseToJSON(nt)
+	if err != nil {
+		t.Fatalf("cannot parse encoded document: %v\n%s", err, nt)
+	}
+	if want := `{"debug":"true","name":"x","port":"8080","tags":["","1.5"]}`; js != want {
+		t.Errorf("expected %s, have %s", want, js)
+	}
+	if _, err = encodeFromJSON(`{"a": `); err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("expected error for invalid JSON, have %v", err)
+	}
+}
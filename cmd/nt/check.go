@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntlint"
+)
+
+const lintConfigFile = ".ntlint.nt"
+
+// check implements sub-command `check`. It returns false if any errors have been found.
+func check(args []string) bool {
+	flags := newFlagSet("check")
+	lint := flags.Bool("lint", false, "apply lint rules")
+	config := flags.String("config", lintConfigFile, "lint configuration file")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usage()
+	}
+	var cfg *ntlint.Config
+	if *lint {
+		var err error
+		if cfg, err = loadLintConfig(*config); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", *config, err.Error())
+			return false
+		}
+	}
+	ok := true
+	for _, path := range flags.Args() {
+		ok = checkFile(path, cfg, os.Stdout) && ok
+	}
+	return ok
+}
+
+// checkFile parses a file and, if cfg is non-nil, lints it. Problems are reported to w.
+func checkFile(path string, cfg *ntlint.Config, w io.Writer) bool {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %s\n", path, err.Error())
+		return false
+	}
+	ok := true
+	if _, err = nestext.Parse(bytes.NewReader(src)); err != nil {
+		fmt.Fprintf(w, "%s: %s\n", path, err.Error())
+		ok = false
+	}
+	if cfg == nil {
+		return ok
+	}
+	for _, f := range ntlint.Lint(src, cfg) {
+		fmt.Fprintf(w, "%s:%s\n", path, f)
+		ok = ok && f.Severity < ntlint.Error
+	}
+	return ok
+}
+
+// loadLintConfig loads a lint configuration. A missing default configuration
+// file is not an error.
+func loadLintConfig(path string) (*ntlint.Config, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && path == lintConfigFile {
+		return ntlint.DefaultConfig(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ntlint.LoadConfig(f)
+}
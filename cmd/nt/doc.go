@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/npillmayer/nestext/ntdoc"
+)
+
+// doc implements sub-command `doc`. It returns false if any errors have occured.
+//
+// Options of an annotated example configuration are documented by package ntdoc, as
+// a Markdown table written to stdout.
+//
+func doc(args []string) bool {
+	flags := newFlagSet("doc")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	path := flags.Arg(0)
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+		return false
+	}
+	defer f.Close()
+	entries, err := ntdoc.Collect(f, nil)
+	if err == nil {
+		err = ntdoc.Markdown(os.Stdout, entries)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/npillmayer/nestext/ntfmt"
+	"github.com/npillmayer/nestext/ntlint"
+)
+
+// format implements sub-command `fmt`. It returns false if any errors have occured.
+//
+// Files are formatted by package ntfmt. With flag --fix, lint findings which carry a
+// safe fix (trailing whitespace, indentation, final newline) are fixed beforehand.
+// The result is written to stdout, or back to the file with flag -w.
+//
+func format(args []string) bool {
+	flags := newFlagSet("fmt")
+	fix := flags.Bool("fix", false, "apply fixes for lint findings")
+	write := flags.Bool("w", false, "write result to the source file instead of stdout")
+	config := flags.String("config", lintConfigFile, "lint configuration file")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usage()
+	}
+	cfg, err := loadLintConfig(*config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *config, err.Error())
+		return false
+	}
+	ok := true
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+			ok = false
+			continue
+		}
+		if *fix {
+			src, _ = ntlint.FixAll(src, cfg)
+		}
+		if src, err = ntfmt.Format(src); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+			ok = false
+			continue
+		}
+		if *write {
+			err = ioutil.WriteFile(path, src, 0644)
+		} else {
+			_, err = os.Stdout.Write(src)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+			ok = false
+		}
+	}
+	return ok
+}
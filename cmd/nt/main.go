@@ -0,0 +1,78 @@
+// Command nt is a command line tool for working with NestedText documents.
+//
+// Usage:
+//
+//     nt check [--lint] [--config .ntlint.nt] file…
+//     nt fmt [--fix] [-w] [--config .ntlint.nt] file…
+//     nt doc file
+//     nt snippet [--ordered] [--toplevel list|dict] file
+//     nt version
+//
+// Sub-command check parses each file and reports format errors. With flag --lint,
+// lint rules of package ntlint are applied as well. Lint rules are configured by
+// file `.ntlint.nt` in the current directory, if present.
+//
+// Sub-command fmt formats files in the canonical layout of package ntfmt. With flag
+// --fix, it applies safe fixes for lint findings first, like removing trailing
+// whitespace. With flag -w, files are overwritten with the result, otherwise it is
+// written to stdout.
+//
+// Sub-command doc writes a Markdown table documenting the options of an annotated
+// example configuration file, described by the comments preceding them (see package
+// ntdoc).
+//
+// Sub-command snippet writes a Go Playground snippet parsing the file with this version
+// of package nestext, to be shared as a runnable reproduction in bug reports.
+//
+// Sub-command version (or flag --version) prints the version of the tool, the version
+// of the NestedText specification it supports and the features of the accepted dialect.
+//
+// The exit code is 1 if errors have been found, 2 for usage errors.
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var ok bool
+	switch os.Args[1] {
+	case "check":
+		ok = check(os.Args[2:])
+	case "fmt":
+		ok = format(os.Args[2:])
+	case "doc":
+		ok = doc(os.Args[2:])
+	case "snippet":
+		ok = snippet(os.Args[2:])
+	case "version", "--version", "-version":
+		ok = version()
+	default:
+		usage()
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: nt check [--lint] [--config file] file…\n")
+	fmt.Fprintf(os.Stderr, "       nt fmt [--fix] [-w] [--config file] file…\n")
+	fmt.Fprintf(os.Stderr, "       nt doc file\n")
+	fmt.Fprintf(os.Stderr, "       nt snippet [--ordered] [--toplevel list|dict] file\n")
+	fmt.Fprintf(os.Stderr, "       nt version\n")
+	os.Exit(2)
+}
+
+// newFlagSet creates a flag set for a sub-command.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = usage
+	return flags
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	gofmt "go/format"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/npillmayer/nestext"
+)
+
+// snippet implements sub-command `snippet`. It returns false if any errors have
+// occured.
+//
+// The snippet is a Go program in txtar format, as understood by the Go Playground
+// (https://go.dev/play/): a file prog.go parsing the document with the options given,
+// followed by a go.mod pinning this version of package nestext. Users may thus share
+// a runnable reproduction of a parsing issue.
+//
+func snippet(args []string) bool {
+	flags := newFlagSet("snippet")
+	ordered := flags.Bool("ordered", false, "parse with option OrderedDicts")
+	toplevel := flags.String("toplevel", "", "parse with option TopLevel, either list or dict")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	path := flags.Arg(0)
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+		return false
+	}
+	var opts []string
+	if *ordered {
+		opts = append(opts, "nestext.OrderedDicts()")
+	}
+	if *toplevel != "" {
+		opts = append(opts, "nestext.TopLevel("+strconv.Quote(*toplevel)+")")
+	}
+	out, err := playground(src, opts, nestext.Version())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+		return false
+	}
+	os.Stdout.Write(out)
+	return true
+}
+
+// playground creates a Go Playground snippet parsing document `src` with parser options
+// `opts` (Go expressions), using version `version` of package nestext.
+func playground(src []byte, opts []string, version string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("// Reproduction of a NestedText parsing issue, generated by `nt snippet`.\n")
+	b.WriteString("// Paste into https://go.dev/play/ to run it.\n")
+	b.WriteString("package main\n\n")
+	b.WriteString("import (\n\"fmt\"\n\"strings\"\n\n\"github.com/npillmayer/nestext\"\n)\n\n")
+	doc := string(src)
+	if strings.ContainsAny(doc, "`\r") || !utf8Printable(doc) {
+		fmt.Fprintf(&b, "const document = %s\n\n", strconv.Quote(doc))
+	} else {
+		fmt.Fprintf(&b, "const document = `%s`\n\n", doc)
+	}
+	b.WriteString("func main() {\n")
+	args := append([]string{"strings.NewReader(document)"}, opts...)
+	fmt.Fprintf(&b, "tree, err := nestext.Parse(%s)\n", strings.Join(args, ", "))
+	b.WriteString("if err != nil {\nfmt.Println(\"error:\", err)\nreturn\n}\n")
+	b.WriteString("fmt.Printf(\"%#v\\n\", tree)\n}\n")
+	code, err := gofmt.Source(b.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.Write(code)
+	out.WriteString("-- go.mod --\nmodule play\n")
+	if version != "" && version != "(devel)" { // otherwise the playground resolves the latest version
+		fmt.Fprintf(&out, "\nrequire github.com/npillmayer/nestext %s\n", version)
+	}
+	return out.Bytes(), nil
+}
+
+// utf8Printable checks if a document may be written as a raw string literal without
+// loss, i.e. it is valid UTF-8 without control characters other than tab and newline.
+func utf8Printable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' && r != '\t' && r != '\n' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/npillmayer/nestext"
+)
+
+// version implements sub-command `version` and flag --version.
+func version() bool {
+	fmt.Printf("nt %s, NestedText specification %s\n", nestext.Version(), nestext.SpecVersionSupported())
+	features := nestext.Features()
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		state := "off"
+		if features[name] {
+			state = "on"
+		}
+		fmt.Printf("  %-20s %s\n", name, state)
+	}
+	return true
+}
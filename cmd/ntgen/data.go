@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntschema"
+)
+
+// dataCommand implements `ntgen data`, generating a Go variable holding the parsed tree
+// of a document.
+func dataCommand(args []string) {
+	flags := flag.NewFlagSet("ntgen data", flag.ExitOnError)
+	output := flags.String("o", "", "output file name; default is <file>_nt.go")
+	pkg := flags.String("pkg", os.Getenv("GOPACKAGE"), "package name; default is $GOPACKAGE or main")
+	name := flags.String("var", "Data", "name of the variable")
+	schemaFile := flags.String("schema", "", "schema file (see package ntschema) to validate the document against")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ntgen data [-o output.go] [-pkg name] [-var Name] [-schema schema.nt] file.nt\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *pkg == "" {
+		*pkg = "main"
+	}
+	input := flags.Arg(0)
+	src, err := ioutil.ReadFile(input)
+	if err != nil {
+		fatal(err)
+	}
+	var schema *ntschema.Schema
+	if *schemaFile != "" {
+		s, err := ioutil.ReadFile(*schemaFile)
+		if err != nil {
+			fatal(err)
+		}
+		if schema, err = ntschema.Parse(bytes.NewReader(s)); err != nil {
+			fatal(fmt.Errorf("%s: %w", *schemaFile, err))
+		}
+	}
+	code, err := generateData(filepath.Base(input), src, schema, *pkg, *name)
+	if err != nil {
+		fatal(err)
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(input, filepath.Ext(input)) + "_nt.go"
+	}
+	if err = ioutil.WriteFile(*output, code, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+// generateData parses document `src`, read from file `filename`, and generates a
+// variable `name` of package `pkg`, initialized with the parsed tree. Dicts are written
+// as map[string]interface{}, lists as []interface{}. If `schema` is given, the document
+// has to conform to it.
+func generateData(filename string, src []byte, schema *ntschema.Schema, pkg, name string) ([]byte, error) {
+	tree, err := nestext.Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if schema != nil {
+		if violations := schema.Validate(tree); len(violations) > 0 {
+			msgs := make([]string, len(violations))
+			for i, v := range violations {
+				msgs[i] = filename + ": " + v.String()
+			}
+			return nil, fmt.Errorf("%s", strings.Join(msgs, "\n"))
+		}
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by ntgen from %s. DO NOT EDIT.\n\npackage %s\n\n", filename, pkg)
+	fmt.Fprintf(&out, "// %s holds the content of %s.\nvar %s interface{} = ", name, filename, name)
+	writeLiteral(&out, tree)
+	out.WriteString("\n")
+	return format.Source(out.Bytes())
+}
+
+// writeLiteral writes a Go composite literal for a tree.
+func writeLiteral(out *bytes.Buffer, tree interface{}) {
+	switch t := tree.(type) {
+	case nil:
+		out.WriteString("nil")
+	case string:
+		out.WriteString(strconv.Quote(t))
+	case []interface{}:
+		out.WriteString("[]interface{}{\n")
+		for _, item := range t {
+			writeLiteral(out, item)
+			out.WriteString(",\n")
+		}
+		out.WriteString("}")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out.WriteString("map[string]interface{}{\n")
+		for _, k := range keys {
+			out.WriteString(strconv.Quote(k) + ": ")
+			writeLiteral(out, t[k])
+			out.WriteString(",\n")
+		}
+		out.WriteString("}")
+	}
+}
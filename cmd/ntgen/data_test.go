@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext/ntschema"
+)
+
+func TestGenerateData(t *testing.T) {
+	src := "port: 8080\nhosts:\n  - \"a\"\n  - b\nmotd:\n  > Welcome!\n  > `ok`\n"
+	code, err := generateData("defaults.nt", []byte(src), nil, "config", "Defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "var Defaults interface{} = map[string]interface{}{\n" +
+		"\t\"hosts\": []interface{}{\n\t\t\"\\\"a\\\"\",\n\t\t\"b\",\n\t},\n" +
+		"\t\"motd\": \"Welcome!\\n`ok`\",\n" +
+		"\t\"port\": \"8080\",\n}\n"
+	if !strings.Contains(string(code), expected) || !strings.HasPrefix(string(code), "// Code generated") {
+		t.Errorf("unexpected code\n%s", code)
+	}
+	if _, err = generateData("defaults.nt", []byte("a: 1\n  b: 2\n"), nil, "config", "Defaults"); err == nil {
+		t.Error("expected invalid document to be rejected")
+	}
+	schema, err := ntschema.Parse(strings.NewReader("type: dict\nkeys:\n  port:\n    type: int\n    max: 1024\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = generateData("defaults.nt", []byte(src), schema, "config", "Defaults")
+	if err == nil || !strings.HasPrefix(err.Error(), "defaults.nt: port") {
+		t.Errorf("expected schema violation for port, have %v", err)
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// annotation marks struct types to generate a codec for.
+const annotation = "//ntgen:codec"
+
+// --- Type model ------------------------------------------------------------
+
+// Field types are classified into a small number of kinds, each of which is
+// handled by a code template of its own.
+const (
+	kString int = iota
+	kBool
+	kInt
+	kUint
+	kFloat
+	kSlice
+	kMap
+	kNamed // type implementing nestext.Marshaler and nestext.Unmarshaler
+)
+
+type fieldType struct {
+	kind int
+	bits int        // bit size for numeric kinds
+	expr string     // Go source of the type
+	elem *fieldType // element type for slices and maps
+}
+
+type structField struct {
+	name      string // Go name of the field
+	key       string // NestedText key
+	omitEmpty bool
+	typ       *fieldType
+}
+
+type structType struct {
+	name   string
+	fields []structField
+}
+
+var builtins = map[string]fieldType{
+	"string":  {kind: kString},
+	"bool":    {kind: kBool},
+	"int":     {kind: kInt},
+	"int8":    {kind: kInt, bits: 8},
+	"int16":   {kind: kInt, bits: 16},
+	"int32":   {kind: kInt, bits: 32},
+	"int64":   {kind: kInt, bits: 64},
+	"uint":    {kind: kUint},
+	"uint8":   {kind: kUint, bits: 8},
+	"uint16":  {kind: kUint, bits: 16},
+	"uint32":  {kind: kUint, bits: 32},
+	"uint64":  {kind: kUint, bits: 64},
+	"float32": {kind: kFloat, bits: 32},
+	"float64": {kind: kFloat, bits: 64},
+}
+
+// --- Collecting struct types -----------------------------------------------
+
+// generate parses Go source `src` and returns the source of a file containing
+// codec methods for all annotated struct types. If `types` is non-empty, codecs are
+// generated for the struct types named in it instead, regardless of annotations.
+// If `keys` is non-nil, it maps the names of fields without a tag to keys.
+func generate(filename string, src []byte, types []string, keys func(string) string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var structs []structType
+	selected := map[string]bool{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			tspec := spec.(*ast.TypeSpec)
+			if len(types) > 0 {
+				if !contains(types, tspec.Name.Name) {
+					continue
+				}
+				selected[tspec.Name.Name] = true
+			} else if !isAnnotated(gen.Doc) && !isAnnotated(tspec.Doc) {
+				continue
+			}
+			st, ok := tspec.Type.(*ast.StructType)
+			if !ok {
+				return nil, fmt.Errorf("%s: type %s is selected, but is not a struct",
+					fset.Position(tspec.Pos()), tspec.Name.Name)
+			}
+			s, err := collectFields(fset, tspec.Name.Name, st, keys)
+			if err != nil {
+				return nil, err
+			}
+			structs = append(structs, s)
+		}
+	}
+	for _, name := range types {
+		if !selected[name] {
+			return nil, fmt.Errorf("%s: type %s not found", filename, name)
+		}
+	}
+	if len(structs) == 0 {
+		return nil, fmt.Errorf("%s: no struct type annotated with %q", filename, annotation)
+	}
+	return emit(file.Name.Name, structs)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isAnnotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+func collectFields(fset *token.FileSet, name string, st *ast.StructType, keys func(string) string) (structType, error) {
+	s := structType{name: name}
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return s, fmt.Errorf("%s: embedded fields are not supported", fset.Position(f.Pos()))
+		}
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			t, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(t)
+		}
+		key, opts := tag.Get("nt"), ""
+		if i := strings.IndexByte(key, ','); i >= 0 {
+			key, opts = key[:i], key[i+1:]
+		}
+		if key == "-" {
+			continue
+		}
+		typ, err := classify(f.Type)
+		if err != nil {
+			return s, fmt.Errorf("%s: %s", fset.Position(f.Pos()), err.Error())
+		}
+		for _, ident := range f.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			field := structField{name: ident.Name, key: key, typ: typ}
+			if field.key == "" {
+				field.key = ident.Name
+				if keys != nil {
+					field.key = keys(ident.Name)
+				}
+			}
+			for _, opt := range strings.Split(opts, ",") {
+				field.omitEmpty = field.omitEmpty || opt == "omitempty"
+			}
+			s.fields = append(s.fields, field)
+		}
+	}
+	return s, nil
+}
+
+func classify(expr ast.Expr) (*fieldType, error) {
+	src := exprString(expr)
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if b, ok := builtins[t.Name]; ok {
+			b.expr = src
+			return &b, nil
+		}
+		return &fieldType{kind: kNamed, expr: src}, nil
+	case *ast.SelectorExpr:
+		return &fieldType{kind: kNamed, expr: src}, nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			break // arrays are not supported
+		}
+		elem, err := classify(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kSlice, expr: src, elem: elem}, nil
+	case *ast.MapType:
+		if k, ok := t.Key.(*ast.Ident); !ok || k.Name != "string" {
+			return nil, fmt.Errorf("map keys must be of type string: %s", src)
+		}
+		elem, err := classify(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kMap, expr: src, elem: elem}, nil
+	}
+	return nil, fmt.Errorf("unsupported field type %s", src)
+}
+
+func exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	format.Node(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
+
+// --- Emitting code ---------------------------------------------------------
+
+// generator collects the output source and keeps track of imports needed.
+type generator struct {
+	buf      bytes.Buffer
+	strconv  bool // strconv is imported
+	varCount int  // used for naming local variables
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) local(prefix string) string {
+	g.varCount++
+	return fmt.Sprintf("%s%d", prefix, g.varCount)
+}
+
+func emit(pkg string, structs []structType) ([]byte, error) {
+	g := &generator{}
+	for _, s := range structs {
+		g.emitMarshal(s)
+		g.emitUnmarshal(s)
+	}
+	body := g.buf.Bytes()
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by ntgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	if g.strconv {
+		out.WriteString("\t\"strconv\"\n\n")
+	}
+	out.WriteString("\t\"github.com/npillmayer/nestext/ntcodec\"\n)\n")
+	out.Write(body)
+	return format.Source(out.Bytes())
+}
+
+func (g *generator) emitMarshal(s structType) {
+	g.printf("\n// MarshalNestedText implements interface nestext.Marshaler.\n")
+	g.printf("func (x %s) MarshalNestedText() (interface{}, error) {\n", s.name)
+	g.printf("m := make(map[string]interface{}, %d)\n", len(s.fields))
+	for _, f := range s.fields {
+		src := "x." + f.name
+		if cond := nonZero(f.typ, src); f.omitEmpty && cond != "" {
+			g.printf("if %s {\n", cond)
+		} else {
+			g.printf("{\n")
+		}
+		v := g.local("v")
+		g.printf("var %s interface{}\n", v)
+		g.encode(f.typ, src, v, f.key)
+		g.printf("m[%q] = %s\n}\n", f.key, v)
+	}
+	g.printf("return m, nil\n}\n")
+}
+
+// encode emits statements converting Go value `src` of type `t` into a NestedText
+// value, assigned to variable `dst`.
+func (g *generator) encode(t *fieldType, src, dst, key string) {
+	switch t.kind {
+	case kString:
+		g.printf("%s = string(%s)\n", dst, src)
+	case kBool:
+		g.strconv = true
+		g.printf("%s = strconv.FormatBool(bool(%s))\n", dst, src)
+	case kInt:
+		g.strconv = true
+		g.printf("%s = strconv.FormatInt(int64(%s), 10)\n", dst, src)
+	case kUint:
+		g.strconv = true
+		g.printf("%s = strconv.FormatUint(uint64(%s), 10)\n", dst, src)
+	case kFloat:
+		g.strconv = true
+		g.printf("%s = strconv.FormatFloat(float64(%s), 'g', -1, %d)\n", dst, src, t.bits)
+	case kSlice:
+		l, i, e, v := g.local("l"), g.local("i"), g.local("e"), g.local("v")
+		g.printf("var %s []interface{}\n", l)
+		g.printf("if %s != nil {\n%s = make([]interface{}, len(%s))\n}\n", src, l, src)
+		g.printf("for %s, %s := range %s {\n", i, e, src)
+		g.printf("var %s interface{}\n", v)
+		g.encode(t.elem, e, v, key)
+		g.printf("%s[%s] = %s\n}\n", l, i, v)
+		g.printf("%s = %s\n", dst, l)
+	case kMap:
+		m, k, e, v := g.local("m"), g.local("k"), g.local("e"), g.local("v")
+		g.printf("var %s map[string]interface{}\n", m)
+		g.printf("if %s != nil {\n%s = make(map[string]interface{}, len(%s))\n}\n", src, m, src)
+		g.printf("for %s, %s := range %s {\n", k, e, src)
+		g.printf("var %s interface{}\n", v)
+		g.encode(t.elem, e, v, key)
+		g.printf("%s[%s] = %s\n}\n", m, k, v)
+		g.printf("%s = %s\n", dst, m)
+	case kNamed:
+		g.printf("if t, err := %s.MarshalNestedText(); err != nil {\n", src)
+		g.printf("return nil, ntcodec.Field(%q, err)\n", key)
+		g.printf("} else {\n%s = t\n}\n", dst)
+	}
+}
+
+// nonZero returns a condition testing `src` for a non-zero value, or "" if
+// omitting empty values is not supported for type `t`.
+func nonZero(t *fieldType, src string) string {
+	switch t.kind {
+	case kString:
+		return src + ` != ""`
+	case kBool:
+		return src
+	case kInt, kUint, kFloat:
+		return src + " != 0"
+	case kSlice, kMap:
+		return "len(" + src + ") > 0"
+	}
+	return ""
+}
+
+func (g *generator) emitUnmarshal(s structType) {
+	g.printf("\n// UnmarshalNestedText implements interface nestext.Unmarshaler.\n")
+	g.printf("func (x *%s) UnmarshalNestedText(tree interface{}) error {\n", s.name)
+	if len(s.fields) == 0 {
+		g.printf("_, err := ntcodec.Dict(tree)\nreturn err\n}\n")
+		return
+	}
+	g.printf("m, err := ntcodec.Dict(tree)\nif err != nil {\nreturn err\n}\n")
+	for _, f := range s.fields {
+		v := g.local("v")
+		g.printf("if %s, ok := m[%q]; ok {\n", v, f.key)
+		g.decode(f.typ, v, "x."+f.name, f.key)
+		g.printf("}\n")
+	}
+	g.printf("return nil\n}\n")
+}
+
+// decode emits statements converting NestedText value `src` into a Go value
+// of type `t`, assigned to `dst`.
+func (g *generator) decode(t *fieldType, src, dst, key string) {
+	onError := fmt.Sprintf("if err != nil {\nreturn ntcodec.Field(%q, err)\n}\n", key)
+	switch t.kind {
+	case kString:
+		g.printf("{\ns, err := ntcodec.String(%s)\n%s%s = %s(s)\n}\n", src, onError, dst, t.expr)
+	case kBool:
+		g.printf("{\nb, err := ntcodec.Bool(%s)\n%s%s = %s(b)\n}\n", src, onError, dst, t.expr)
+	case kInt:
+		g.printf("{\nn, err := ntcodec.Int(%s, %d)\n%s%s = %s(n)\n}\n", src, t.bits, onError, dst, t.expr)
+	case kUint:
+		g.printf("{\nn, err := ntcodec.Uint(%s, %d)\n%s%s = %s(n)\n}\n", src, t.bits, onError, dst, t.expr)
+	case kFloat:
+		g.printf("{\nf, err := ntcodec.Float(%s, %d)\n%s%s = %s(f)\n}\n", src, t.bits, onError, dst, t.expr)
+	case kSlice:
+		l, s, i, e := g.local("l"), g.local("s"), g.local("i"), g.local("e")
+		g.printf("%s, err := ntcodec.List(%s)\n%s", l, src, onError)
+		g.printf("%s := make(%s, len(%s))\n", s, t.expr, l)
+		g.printf("for %s, %s := range %s {\n", i, e, l)
+		g.decode(t.elem, e, s+"["+i+"]", key)
+		g.printf("}\n%s = %s\n", dst, s)
+	case kMap:
+		d, m, k, e := g.local("d"), g.local("m"), g.local("k"), g.local("e")
+		g.printf("%s, err := ntcodec.Dict(%s)\n%s", d, src, onError)
+		g.printf("%s := make(%s, len(%s))\n", m, t.expr, d)
+		g.printf("for %s, %s := range %s {\n", k, e, d)
+		v := g.local("v")
+		g.printf("var %s %s\n", v, t.elem.expr)
+		g.decode(t.elem, e, v, key)
+		g.printf("%s[%s] = %s\n}\n%s = %s\n", m, k, v, dst, m)
+	case kNamed:
+		v := g.local("v")
+		g.printf("var %s %s\n", v, t.expr)
+		g.printf("if err := %s.UnmarshalNestedText(%s); err != nil {\n", v, src)
+		g.printf("return ntcodec.Field(%q, err)\n}\n%s = %s\n", key, dst, v)
+	}
+}
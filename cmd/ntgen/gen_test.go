@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+// The generated code in package ntcodec is used for testing the runtime
+// support functions. It has to be kept in sync with the generator.
+func TestGeneratedCodeUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..", "ntcodec")
+	src, err := ioutil.ReadFile(filepath.Join(dir, "types_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := generate("types_test.go", src, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing, err := ioutil.ReadFile(filepath.Join(dir, "types_ntgen_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, existing) {
+		t.Error("generated code differs from ntcodec/types_ntgen_test.go; run go generate")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	inputs := []string{
+		"package x\n",
+		"package x\n//ntgen:codec\ntype X int\n",
+		"package x\n//ntgen:codec\ntype X struct { P *int }\n",
+		"package x\n//ntgen:codec\ntype X struct { M map[int]string }\n",
+	}
+	for i, input := range inputs {
+		if _, err := generate("x.go", []byte(input), nil, nil); err == nil {
+			t.Errorf("[%d] expected generation to fail, didn't", i)
+		} else {
+			t.Logf("[%d] got expected error: %v", i, err)
+		}
+	}
+}
+
+func TestGenerateSelectedTypes(t *testing.T) {
+	src := "package x\ntype A struct { N int }\ntype B struct { S string }\n"
+	code, err := generate("x.go", []byte(src), []string{"B"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(code, []byte("func (x B) MarshalNestedText()")) ||
+		bytes.Contains(code, []byte("func (x A)")) {
+		t.Errorf("expected codec for B only, have:\n%s", code)
+	}
+	if _, err = generate("x.go", []byte(src), []string{"C"}, nil); err == nil {
+		t.Errorf("expected generation for unknown type to fail, didn't")
+	}
+}
+
+func TestGenerateOmitEmpty(t *testing.T) {
+	src := "package x\n//ntgen:codec\ntype A struct {\n\tN int `nt:\"n,inline,omitempty\"`\n\tM int `nt:\"m,inline\"`\n}\n"
+	code, err := generate("x.go", []byte(src), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(code, []byte("if x.N != 0 {")) || bytes.Contains(code, []byte("if x.M != 0 {")) {
+		t.Errorf("expected N only to be omitted if empty, have:\n%s", code)
+	}
+}
+
+func TestGenerateMappedKeys(t *testing.T) {
+	src := "package x\n//ntgen:codec\ntype A struct {\n\tMaxConns int\n\tLogLevel string `nt:\"LogLevel\"`\n}\n"
+	code, err := generate("x.go", []byte(src), nil, nestext.SnakeCase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(code, []byte(`m["max_conns"]`)) || !bytes.Contains(code, []byte(`m["LogLevel"]`)) {
+		t.Errorf("expected names of untagged fields only to be mapped, have:\n%s", code)
+	}
+}
@@ -0,0 +1,117 @@
+// Command ntgen generates reflection-free NestedText codecs for Go structs.
+//
+// For every struct type annotated with a comment line
+//
+//     //ntgen:codec
+//
+// or, alternatively, for every struct type listed with flag -type, ntgen generates methods `MarshalNestedText` and `UnmarshalNestedText`, implementing
+// interfaces nestext.Marshaler and nestext.Unmarshaler. Generated code relies on
+// package `ntcodec` for conversions and does not use reflection, thus it is suitable
+// for hot paths and for targets like TinyGo.
+//
+// Usage:
+//
+//     ntgen [-o output.go] [-type T1,T2,…] [-keys snake_case|kebab-case] file.go
+//
+// or, from within a Go source file:
+//
+//     //go:generate ntgen $GOFILE
+//
+// The default output file for `config.go` is `config_ntgen.go`.
+//
+// Subcommand `types` bootstraps Go types with `nt` tags from an example document,
+// or from a schema written in NestedText (see package ntschema, flag -schema):
+//
+//     ntgen types [-o output.go] [-pkg name] [-name Type] [-schema] file.nt
+//
+// Subcommand `data` compiles a document into a variable holding its parsed tree, thus
+// applications may ship built-in defaults without reading files at run time. The
+// document is checked when generating the code, optionally against a schema:
+//
+//     //go:generate ntgen data -var Defaults -schema defaults.schema.nt defaults.nt
+//
+// The default output file for `defaults.nt` is `defaults_nt.go`.
+//
+// Struct fields are mapped to keys by a struct tag `nt:"key"`, defaulting to the
+// name of the field. With flag -keys, names of fields without a tag are mapped like
+// nestext.SnakeCase or nestext.KebabCase do, matching option FieldNameMapper of
+// nestext.Decoder and ntenc. Tag `nt:"-"` excludes a field, option `omitempty` omits
+// fields with zero values from the output. Supported field types are strings,
+// booleans, integers, floats, slices and maps (with string keys) thereof, and named
+// types which implement the NestedText codec interfaces themselves (usually by
+// being annotated as well).
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "types" {
+		typesCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "data" {
+		dataCommand(os.Args[2:])
+		return
+	}
+	output := flag.String("o", "", "output file name; default is <file>_ntgen.go")
+	typeNames := flag.String("type", "", "comma-separated list of struct types; default is annotated types")
+	keyStyle := flag.String("keys", "", "style of keys for fields without tag: snake_case or kebab-case; default is field names")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ntgen [-o output.go] [-type T1,T2,…] [-keys style] file.go\n")
+		fmt.Fprintf(os.Stderr, "       ntgen types [-o output.go] [-pkg name] [-name Type] [-schema] file.nt\n")
+		fmt.Fprintf(os.Stderr, "       ntgen data [-o output.go] [-pkg name] [-var Name] [-schema schema.nt] file.nt\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	input := flag.Arg(0)
+	if input == "" {
+		input = os.Getenv("GOFILE")
+	}
+	if input == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	src, err := ioutil.ReadFile(input)
+	if err != nil {
+		fatal(err)
+	}
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+	}
+	var keys func(string) string
+	switch *keyStyle {
+	case "":
+	case "snake_case":
+		keys = nestext.SnakeCase
+	case "kebab-case":
+		keys = nestext.KebabCase
+	default:
+		fatal(fmt.Errorf("unknown style of keys %q", *keyStyle))
+	}
+	code, err := generate(filepath.Base(input), src, types, keys)
+	if err != nil {
+		fatal(err)
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(input, ".go") + "_ntgen.go"
+	}
+	if err = ioutil.WriteFile(*output, code, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "ntgen: %s\n", err.Error())
+	os.Exit(1)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntschema"
+)
+
+// typesCommand implements `ntgen types`, generating Go types from a schema or from an
+// example document.
+func typesCommand(args []string) {
+	flags := flag.NewFlagSet("ntgen types", flag.ExitOnError)
+	output := flags.String("o", "", "output file name; default is standard output")
+	pkg := flags.String("pkg", os.Getenv("GOPACKAGE"), "package name; default is $GOPACKAGE or main")
+	name := flags.String("name", "Config", "name of the top-level type")
+	isSchema := flags.Bool("schema", false, "input is a schema (see package ntschema), not an example document")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ntgen types [-o output.go] [-pkg name] [-name Type] [-schema] file.nt\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *pkg == "" {
+		*pkg = "main"
+	}
+	src, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	code, err := generateTypes(src, *isSchema, *pkg, *name)
+	if err != nil {
+		fatal(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err = ioutil.WriteFile(*output, code, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+// generateTypes generates Go types for a schema or an example document `src`.
+func generateTypes(src []byte, isSchema bool, pkg, name string) ([]byte, error) {
+	if isSchema {
+		schema, err := ntschema.Parse(bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		return schema.GoTypes(pkg, name)
+	}
+	tree, err := nestext.Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	return ntschema.FromExample(tree).GoTypes(pkg, name)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateTypes(t *testing.T) {
+	code, err := generateTypes([]byte("name: app\nport: 8080\n"), false, "config", "Settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), "type Settings struct {\n\tName string `nt:\"name\"`\n\tPort int    `nt:\"port\"`\n}") {
+		t.Errorf("unexpected code for example\n%s", code)
+	}
+	schema := "type: dict\nkeys:\n  ratio:\n    type: float\n    description: Sampling ratio\n"
+	code, err = generateTypes([]byte(schema), true, "config", "Config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), "\t// Sampling ratio\n\tRatio float64 `nt:\"ratio\"`\n") {
+		t.Errorf("unexpected code for schema\n%s", code)
+	}
+	if _, err = generateTypes([]byte("type: number\n"), true, "config", "Config"); err == nil {
+		t.Error("expected invalid schema to be rejected")
+	}
+}
@@ -0,0 +1,224 @@
+package nestext
+
+import (
+	"sort"
+	"strings"
+)
+
+// --- Comment and layout preservation ---------------------------------------
+
+// Comments holds the comments of a document, as captured by option CaptureComments.
+// Comments are attached to the item following them. Items are identified by their
+// path, which uses the syntax of JSON pointers (RFC 6901): the keys of dict items and
+// the indices of list items, each prefixed by '/'. For example, "/president/roles/0"
+// identifies the first item of the list of roles of the president. The top-level
+// value has the empty path "", but comments are attached to it only if it is a
+// string or an inline item; otherwise they are attached to its first item.
+//
+// Comment texts are the contents of comment lines following the '#', with trailing
+// white space removed.
+//
+type Comments struct {
+	Items    map[string][]string // comment lines preceding an item, by path of the item
+	Trailing []string            // comment lines following the last item of the document
+}
+
+// AppendPath appends the key of a dict item or the index of a list item to `path`,
+// the path of the containing item. Characters '~' and '/' in key are escaped.
+//
+// Use as:
+//     path := nestext.AppendPath(nestext.AppendPath("", "president"), "address")
+//     // path == "/president/address"
+//
+func AppendPath(path string, key string) string {
+	if strings.ContainsAny(key, "~/") {
+		key = strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+	}
+	return path + "/" + key
+}
+
+// CaptureComments requests the parser to store the comments of the document into `c`,
+// after the document has been parsed successfully. Comments may be written back
+// by ntenc.Encode, using encoder option ntenc.WithComments.
+// Paths refer to the document as parsed, i.e. are not affected by option TopLevel.
+// ParseEvents does not support this option.
+//
+// Use as:
+//     var comments nestext.Comments
+//     config, err := nestext.Parse(reader, nestext.CaptureComments(&comments))
+//     …
+//     ntenc.Encode(config, w, ntenc.WithComments(&comments))
+//
+func CaptureComments(c *Comments) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.comments = c
+		return nil
+	}
+}
+
+// Layout holds comments, blank lines and spacing of a document, as captured by
+// option CaptureLayout. Together with the values of the document, it allows to
+// re-create the document with minimal differences to the original source.
+// Keys of dicts are still sorted when encoding. Of indentation, only the width of the
+// first nested value is recorded.
+//
+// Blank lines are recorded by their position relative to the comments of an item:
+// a blank line at position i precedes the i-th comment line of the item, or the
+// item itself if i is the number of comments of the item. Multiple blank lines at
+// the same position are represented by repeated entries.
+//
+type Layout struct {
+	Comments
+	BlankLines         map[string][]int  // positions of blank lines preceding an item, by path
+	TrailingBlankLines []int             // positions of blank lines relative to trailing comments
+	KeyPadding         map[string]string // white space between key and ':' of dict items, by path
+	Indent             int               // indentation width, as reported by Metadata.Indent
+}
+
+// CaptureLayout requests the parser to store the comments, blank lines and key padding
+// of the document into `l`, after the document has been parsed successfully. See
+// CaptureComments for identification of items. The layout may be re-applied by
+// ntenc.Encode, using encoder option ntenc.WithLayout. ParseEvents does not support
+// this option.
+//
+// Use as:
+//     var layout nestext.Layout
+//     config, err := nestext.Parse(reader, nestext.CaptureLayout(&layout))
+//     …  // bump a version number
+//     ntenc.Encode(config, w, ntenc.WithLayout(&layout))
+//
+func CaptureLayout(l *Layout) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.comments, p.layout = &l.Comments, l
+		return nil
+	}
+}
+
+// SkippedLineFn is a callback type for reporting comment lines and blank lines, which
+// the parser skips. It receives the line number and the text of the line, without the
+// line terminator.
+type SkippedLineFn func(line int, text string)
+
+// ReportSkippedLines installs a callback which will be called for every comment line
+// and blank line of the input, in order of lines. Auditing tools may thus check for
+// headers like license banners or "do not edit" notices while parsing a document,
+// without reading it a second time.
+//
+// Use as:
+//     var header []string
+//     config, err := nestext.Parse(reader, nestext.ReportSkippedLines(func(line int, text string) {
+//         if line == len(header)+1 {
+//             header = append(header, text)
+//         }
+//     }))
+//
+// Lines are reported as they are read, thus the callback may be called for lines
+// following an error. If the input is dedented by option AutoDedent, the callback
+// receives the dedented lines.
+//
+func ReportSkippedLines(f SkippedLineFn) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.onSkipped = f
+		return nil
+	}
+}
+
+// sourceComment is a comment line or a blank line of the input.
+type sourceComment struct {
+	line  int
+	text  string
+	blank bool
+}
+
+// skipped records a blank line or comment line of the input, as reported by the line buffer.
+func (p *nestedTextParser) skipped(line int, text string) {
+	if p.positions != nil && line <= len(p.lineSpans) {
+		p.lineSpans[line-1].skipped = true
+	}
+	if p.onSkipped != nil {
+		p.onSkipped(line, text)
+	}
+	if p.comments == nil {
+		return
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		if p.layout != nil {
+			p.commentLines = append(p.commentLines, sourceComment{line: line, blank: true})
+		}
+		return
+	}
+	p.commentLines = append(p.commentLines, sourceComment{line: line, text: text[1:]})
+}
+
+// commentAnchor is the line of an item comments may be attached to.
+type commentAnchor struct {
+	line int
+	path string
+}
+
+// anchor records the line of an item with key or index `key` and value `value` of the
+// current container, and its position, if requested. It returns the path of the item.
+func (p *nestedTextParser) anchor(line, indent int, key string, value interface{}) string {
+	path := AppendPath(p.path, key)
+	if p.comments != nil {
+		p.anchors = append(p.anchors, commentAnchor{line: line, path: path})
+	}
+	if p.positions != nil {
+		p.recordPosition(path, line, indent, value)
+	}
+	return path
+}
+
+// parseNested parses the nested value of an item with key or index `key`, indented by
+// `indent`, for an item at indentation `parent`.
+func (p *nestedTextParser) parseNested(key string, parent, indent int) (interface{}, error) {
+	if p.indentWidth == 0 {
+		p.indentWidth = indent - parent
+	}
+	if p.validators != nil {
+		p.enterNested(key)
+		defer func() { p.segments = p.segments[:len(p.segments)-1] }()
+	}
+	if !p.tracking() && p.validKey == nil { // otherwise keep track of the path
+		return p.parseAny(indent)
+	}
+	if p.positions != nil {
+		start := p.token.LineNo
+		defer func() { p.markNested(start, indent) }()
+	}
+	outer := p.path
+	p.path = AppendPath(p.path, key)
+	defer func() { p.path = outer }()
+	return p.parseAny(indent)
+}
+
+// attachComments assigns every comment line to the first item following it.
+func (p *nestedTextParser) attachComments() {
+	sort.SliceStable(p.anchors, func(i, j int) bool {
+		return p.anchors[i].line < p.anchors[j].line
+	})
+	*p.comments = Comments{Items: make(map[string][]string)}
+	if p.layout != nil {
+		p.layout.BlankLines = make(map[string][]int)
+		p.layout.Indent = p.indentWidth
+	}
+	a := 0
+	for _, c := range p.commentLines {
+		for a < len(p.anchors) && p.anchors[a].line < c.line {
+			a++
+		}
+		switch {
+		case a == len(p.anchors) && c.blank:
+			p.layout.TrailingBlankLines = append(p.layout.TrailingBlankLines, len(p.comments.Trailing))
+		case a == len(p.anchors):
+			p.comments.Trailing = append(p.comments.Trailing, c.text)
+		case c.blank:
+			path := p.anchors[a].path
+			p.layout.BlankLines[path] = append(p.layout.BlankLines[path], len(p.comments.Items[path]))
+		default:
+			path := p.anchors[a].path
+			p.comments.Items[path] = append(p.comments.Items[path], c.text)
+		}
+	}
+}
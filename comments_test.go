@@ -0,0 +1,102 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCaptureComments(t *testing.T) {
+	input := `# Contact information
+# of the president
+president:
+  name: Katheryn McDaniel
+  # current roles
+  roles:
+    - board member
+    # since 2021
+    -
+      > treasurer
+  # multi-line key
+  : a/b
+  : ~c
+    > x
+# end of document
+`
+	var comments Comments
+	if _, err := Parse(strings.NewReader(input), CaptureComments(&comments)); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"/president":           {" Contact information", " of the president"},
+		"/president/roles":     {" current roles"},
+		"/president/roles/1":   {" since 2021"},
+		"/president/a~1b\n~0c": {" multi-line key"},
+	}
+	if !reflect.DeepEqual(comments.Items, expected) {
+		t.Errorf("expected comments %#v, have %#v", expected, comments.Items)
+	}
+	if !reflect.DeepEqual(comments.Trailing, []string{" end of document"}) {
+		t.Errorf("unexpected trailing comments %#v", comments.Trailing)
+	}
+}
+
+func TestCaptureCommentsTopLevel(t *testing.T) {
+	var comments Comments
+	if _, err := Parse(strings.NewReader("#a\n> x\n#b\n> y\n"), CaptureComments(&comments)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(comments.Items, map[string][]string{"": {"a"}}) {
+		t.Errorf("expected comment attached to top-level string, have %#v", comments.Items)
+	}
+	if !reflect.DeepEqual(comments.Trailing, []string{"b"}) {
+		t.Errorf("expected comment within string to trail, have %#v", comments.Trailing)
+	}
+}
+
+func TestAppendPath(t *testing.T) {
+	if p := AppendPath(AppendPath("", "a/b"), "~"); p != "/a~1b/~0" {
+		t.Errorf("unexpected path %q", p)
+	}
+}
+
+func TestCaptureLayout(t *testing.T) {
+	input := "\n# header\n\na   : 1\n\n\nb:\n  - x\n\n  # y\n  - y\n\n# end\n\n"
+	var layout Layout
+	if _, err := Parse(strings.NewReader(input), CaptureLayout(&layout)); err != nil {
+		t.Fatal(err)
+	}
+	blank := map[string][]int{"/a": {0, 1}, "/b": {0, 0}, "/b/1": {0}}
+	if !reflect.DeepEqual(layout.BlankLines, blank) {
+		t.Errorf("expected blank lines %v, have %v", blank, layout.BlankLines)
+	}
+	if !reflect.DeepEqual(layout.TrailingBlankLines, []int{0, 1}) {
+		t.Errorf("unexpected trailing blank lines %v", layout.TrailingBlankLines)
+	}
+	if !reflect.DeepEqual(layout.KeyPadding, map[string]string{"/a": "   "}) {
+		t.Errorf("unexpected key padding %#v", layout.KeyPadding)
+	}
+	if !reflect.DeepEqual(layout.Items["/b/1"], []string{" y"}) {
+		t.Errorf("unexpected comments %#v", layout.Items)
+	}
+}
+
+func TestReportSkippedLines(t *testing.T) {
+	input := "# Copyright 2021\n# DO NOT EDIT\n\na: 1\n  # nested\nb:\n  - x\n# end"
+	var lines []int
+	var texts []string
+	_, err := Parse(strings.NewReader(input), ReportSkippedLines(func(line int, text string) {
+		lines = append(lines, line)
+		texts = append(texts, text)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lines, []int{1, 2, 3, 5, 8}) {
+		t.Errorf("unexpected line numbers %v", lines)
+	}
+	expected := []string{"# Copyright 2021", "# DO NOT EDIT", "", "  # nested", "# end"}
+	if !reflect.DeepEqual(texts, expected) {
+		t.Errorf("expected skipped lines %q, have %q", expected, texts)
+	}
+}
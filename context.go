@@ -0,0 +1,97 @@
+package nestext
+
+import (
+	"context"
+	"io"
+)
+
+// --- Context support -------------------------------------------------------
+
+// ParseContext is like Parse, but aborts as soon as ctx is canceled or its deadline
+// expires. In this case, an error of code ErrCodeIO is returned, wrapping ctx.Err().
+//
+// Parsing is done in the calling goroutine, while reads from r are done in a separate
+// goroutine, so ParseContext returns promptly even if the reader is blocked. A read
+// pending at that time is abandoned; its goroutine ends as soon as the read returns.
+// Thus no option or callback is used after ParseContext has returned.
+//
+// Use as:
+//     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//     defer cancel()
+//     result, err := nestext.ParseContext(ctx, resp.Body)
+//
+func ParseContext(ctx context.Context, r io.Reader, opts ...Option) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, abortError(err)
+	}
+	tree, err := Parse(&asyncReader{ctx: ctx, r: r}, opts...)
+	if ctxErr := ctx.Err(); ctxErr != nil && err != nil {
+		return nil, abortError(ctxErr)
+	}
+	return tree, err
+}
+
+// asyncReader reads from r in a separate goroutine, so that reading fails as soon as
+// ctx is done, even if r is blocked. Data is read into a buffer of its own, as the
+// buffer passed to Read may not be written to after Read has returned.
+type asyncReader struct {
+	ctx     context.Context
+	r       io.Reader
+	buf     []byte          // buffer for reads of r
+	results chan readResult // results of reads of r
+	pending bool            // is a read of r in progress?
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+func (ar *asyncReader) Read(p []byte) (int, error) {
+	if err := ar.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if !ar.pending {
+		if cap(ar.buf) < len(p) {
+			ar.buf = make([]byte, len(p))
+		}
+		if ar.results == nil {
+			ar.results = make(chan readResult, 1)
+		}
+		buf := ar.buf[:len(p)]
+		go func() {
+			n, err := ar.r.Read(buf)
+			ar.results <- readResult{n, err}
+		}()
+		ar.pending = true
+	}
+	select {
+	case res := <-ar.results:
+		ar.pending = false
+		return copy(p, ar.buf[:res.n]), res.err
+	case <-ar.ctx.Done():
+		return 0, ar.ctx.Err()
+	}
+}
+
+func abortError(err error) error {
+	return WrapError(ErrCodeIO, "aborted: "+err.Error(), err)
+}
+
+// ContextReader wraps a reader so that reading fails as soon as ctx is canceled or
+// its deadline expires. The check is done before each call to the underlying reader.
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return ctxReader{ctx: ctx, r: r}
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
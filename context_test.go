@@ -0,0 +1,57 @@
+package nestext
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseContext(t *testing.T) {
+	result, err := ParseContext(context.Background(), strings.NewReader("a: b\n"))
+	if err != nil || result.(map[string]interface{})["a"] != "b" {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+	// a reader which never returns
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = ParseContext(ctx, pr)
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline to be exceeded, have %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("parsing has not been aborted promptly")
+	}
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeIO {
+		t.Errorf("expected error of code ErrCodeIO, have %#v", err)
+	}
+}
+
+func TestParseContextReturnsAfterParsing(t *testing.T) {
+	// options and callbacks must not be used after ParseContext has returned
+	pr, pw := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	warnings := 0
+	var pos Positions
+	go func() {
+		pw.Write([]byte("a: b\n"))
+		cancel()
+	}()
+	_, err := ParseContext(ctx, pr, WarnDuplicateItems(), ReportPositions(&pos),
+		ReportWarnings(func(w NestedTextError) { warnings++ }))
+	if err == nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected parsing to be canceled, have %v", err)
+	}
+	// data arriving late must not reach the parser
+	go pw.Write([]byte("a: c\n"))
+	time.Sleep(10 * time.Millisecond)
+	if warnings != 0 || len(pos) != 0 {
+		t.Errorf("expected no warnings and no positions, have %d, %v", warnings, pos)
+	}
+	pw.Close()
+}
@@ -0,0 +1,92 @@
+package nestext
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- Control characters ----------------------------------------------------
+
+// ControlPolicy is a policy for control characters in keys and values.
+type ControlPolicy int
+
+// Policies for option OnControlChars
+const (
+	ControlKeep   ControlPolicy = iota // keep control characters (default)
+	ControlReject                      // fail with an error of code ErrCodeFormat
+	ControlEscape                      // replace control characters by escapes, e.g. "\x1b"
+)
+
+// OnControlChars sets the policy for C0 and C1 control characters, other than tab, in
+// keys and values. Control characters are invisible, and values flowing into logs or
+// terminals may use them to disguise output or to issue terminal commands (e.g. ESC
+// sequences). By default, they are kept. Policy ControlReject flags the first control
+// character of every item line with an error. Policy ControlEscape replaces them by
+// visible escapes, as strconv.Quote does: "\x1b" for C0 characters and DEL, "\u0085"
+// for C1 characters, reporting a warning for every line changed (see ReportWarnings).
+// Column numbers of errors refer to the escaped lines. Comments are left unchanged.
+//
+// Use as:
+//     nestext.Parse(reader, nestext.OnControlChars(nestext.ControlReject))
+//
+func OnControlChars(policy ControlPolicy) Option {
+	return func(p *nestedTextParser) (err error) {
+		if policy < ControlKeep || policy > ControlEscape {
+			return MakeNestedTextError(ErrCodeUsage, fmt.Sprintf("invalid control character policy %d", policy))
+		}
+		p.controls = policy
+		return nil
+	}
+}
+
+// isControl is a predicate for C0 control characters other than tab, DEL and C1 control
+// characters.
+func isControl(r rune) bool {
+	return r < 0x20 && r != '\t' || r >= 0x7f && r <= 0x9f
+}
+
+// escapeControl returns the escape for control character `r`.
+func escapeControl(r rune) string {
+	if r < 0x80 {
+		return fmt.Sprintf(`\x%02x`, r)
+	}
+	return fmt.Sprintf(`\u%04x`, r)
+}
+
+// checkControls is the Check callback for the scanner with policy ControlReject. It
+// returns an error for the first control character of item line number `line`.
+func checkControls(line int, text string) error {
+	i := strings.IndexFunc(text, isControl)
+	if i < 0 {
+		return nil
+	}
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	err := MakeNestedTextError(ErrCodeFormat, fmt.Sprintf("control character %q not allowed", r))
+	err.Line, err.Column = line, utf8.RuneCountInString(text[:i])
+	return err
+}
+
+// escapeControls is a Rewrite callback for the line buffer with policy ControlEscape.
+// It replaces control characters of line number `line` by escapes, except for comment
+// lines.
+func (p *nestedTextParser) escapeControls(line int, text string) string {
+	first := strings.IndexFunc(text, isControl)
+	if first < 0 || strings.HasPrefix(strings.TrimLeft(text, " \t"), "#") {
+		return text
+	}
+	var b strings.Builder
+	escaped := 0
+	for _, r := range text {
+		if !isControl(r) {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteString(escapeControl(r))
+		escaped++
+	}
+	w := MakeNestedTextError(ErrCodeFormat, fmt.Sprintf("escaped %d control character(s)", escaped))
+	w.Line, w.Column = line, utf8.RuneCountInString(text[:first])
+	p.warning(w)
+	return b.String()
+}
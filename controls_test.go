@@ -0,0 +1,62 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRejectControls(t *testing.T) {
+	inputs := []struct {
+		text         string
+		line, column int // position of the error, line 0 for no error
+	}{
+		{"a: b\tc\n", 0, 0},
+		{"# \x1b[2J\na: b\n", 0, 0},
+		{"a: \x1b[31mred\n", 1, 3},
+		{"a:\n  > x\n  > \u00e4\u0085\n", 3, 5},
+		{"k\x00: v\n", 1, 1},
+		{"- [a, b\x7f]\n", 1, 7},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text), OnControlChars(ControlReject))
+		eerr := ParseEvents(strings.NewReader(input.text), &Handler{}, OnControlChars(ControlReject))
+		for _, err := range []error{err, eerr} {
+			if input.line == 0 {
+				if err != nil {
+					t.Errorf("%d: expected no error, have %v", i, err)
+				}
+				continue
+			}
+			nterr, ok := err.(NestedTextError)
+			if !ok || nterr.Code != ErrCodeFormat || nterr.Line != input.line || nterr.Column != input.column {
+				t.Errorf("%d: expected error at [%d,%d], have %v", i, input.line, input.column, err)
+			}
+		}
+	}
+	_, err := Parse(strings.NewReader("a: \x07\nb: \x07\n"), OnControlChars(ControlReject), ContinueOnError())
+	if errs, ok := err.(ErrorList); !ok || len(errs) != 2 {
+		t.Errorf("expected 2 errors, have %v", err)
+	}
+}
+
+func TestParseEscapeControls(t *testing.T) {
+	var warnings []NestedTextError
+	result, err := Parse(strings.NewReader("a: \x1b[31mred\x1b[0m\nb:\n  > x\u0085\n# \x07\nc: \tt\n"),
+		OnControlChars(ControlEscape),
+		ReportWarnings(func(w NestedTextError) { warnings = append(warnings, w) }))
+	expected := map[string]interface{}{"a": `\x1b[31mred\x1b[0m`, "b": `x\u0085`, "c": "\tt"}
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %#v, have %#v, %v", expected, result, err)
+	}
+	if len(warnings) != 2 || warnings[0].Line != 1 || warnings[0].Column != 3 || warnings[1].Line != 3 {
+		t.Errorf("expected warnings for lines 1 and 3, have %v", warnings)
+	}
+	result, err = Parse(strings.NewReader("a: \x1b\n"))
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"a": "\x1b"}) {
+		t.Errorf("expected control characters to be kept by default, have %#v, %v", result, err)
+	}
+	if _, err = Parse(strings.NewReader("a: b\n"), OnControlChars(ControlPolicy(7))); err == nil {
+		t.Error("expected invalid policy to produce an error; didn't")
+	}
+}
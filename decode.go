@@ -0,0 +1,716 @@
+package nestext
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// --- Decoder ---------------------------------------------------------------
+
+// A Decoder reads and decodes NestedText documents from an input stream.
+// It mirrors the API of `encoding/json`'s Decoder, so it may be plugged into
+// codec abstractions expecting this shape.
+//
+// Use as:
+//     dec := nestext.NewDecoder(reader)
+//     var config Config
+//     if err := dec.Decode(&config); err != nil {
+//         …
+//     }
+//
+type Decoder struct {
+	r               io.Reader
+	opts            []Option
+	disallowUnknown bool
+	foreign         bool
+	done            bool
+	delimiter       string         // delimiter line for multi-document streams
+	lines           *rawLineReader // line reader for multi-document streams
+	lineNo          int            // count of lines consumed from a multi-document stream
+	keys            *FieldKeys     // keys of struct fields, as set by option FieldNameMapper
+}
+
+// NewDecoder returns a new decoder that reads from r, applying parser options
+// `opts` for every call to Decode.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	return &Decoder{r: r, opts: opts, keys: fieldKeys(opts)}
+}
+
+// Reset discards the state of the decoder and makes it read from r, keeping options
+// and settings. This allows re-using decoders, e.g. with a sync.Pool.
+func (dec *Decoder) Reset(r io.Reader) {
+	dec.r, dec.done = r, false
+	dec.lines, dec.lineNo = nil, 0
+}
+
+// SetOptions appends parser options to be used for subsequent calls to Decode.
+func (dec *Decoder) SetOptions(opts ...Option) {
+	dec.opts = append(dec.opts, opts...)
+	dec.keys = fieldKeys(dec.opts)
+}
+
+// DisallowUnknownKeys causes the Decoder to return an error when the destination
+// is a struct and the input contains dict keys which do not match any
+// non-ignored, exported fields in the destination.
+func (dec *Decoder) DisallowUnknownKeys() {
+	dec.disallowUnknown = true
+}
+
+// UseForeignUnmarshalers causes the Decoder to initialize types implementing
+// json.Unmarshaler or a yaml.v2-style UnmarshalYAML method from the NestedText values,
+// allowing existing custom unmarshalers to be re-used. See type YAMLUnmarshaler.
+func (dec *Decoder) UseForeignUnmarshalers() {
+	dec.foreign = true
+}
+
+// FieldNameMapper sets a function to map the names of struct fields to dict keys,
+// e.g., to snake_case, when decoding into structs (see Decoder.Decode and
+// UnmarshalAt). Fields with a tag `nt:"key"` are not affected. Matching of keys
+// still prefers an exact match over a case-insensitive one. Option
+// ntenc.FieldNameMapper is the counterpart for encoding.
+//
+// Use as:
+//     dec := nestext.NewDecoder(reader, nestext.FieldNameMapper(nestext.SnakeCase))
+//
+// Parse ignores this option.
+//
+func FieldNameMapper(mapper func(goName string) string) Option {
+	keys := NewFieldKeys(mapper)
+	return func(p *nestedTextParser) (err error) {
+		if mapper == nil {
+			return MakeNestedTextError(ErrCodeUsage, "option FieldNameMapper requires a function")
+		}
+		p.fieldKeys = keys
+		return nil
+	}
+}
+
+// SnakeCase maps a Go name to snake_case, keeping acronyms together, e.g.,
+// "MaxHTTPRetries" to "max_http_retries". It is intended for option FieldNameMapper.
+func SnakeCase(goName string) string {
+	return separateWords(goName, '_')
+}
+
+// KebabCase maps a Go name to kebab-case, like SnakeCase does for snake_case.
+func KebabCase(goName string) string {
+	return separateWords(goName, '-')
+}
+
+// separateWords lower-cases a camel-case name and separates its words by `sep`.
+func separateWords(name string, sep byte) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte(sep)
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// fieldKeys returns the keys of struct fields set by options, if any.
+func fieldKeys(opts []Option) *FieldKeys {
+	p := newParser()
+	for _, opt := range opts {
+		if opt(p) != nil {
+			return nil
+		}
+	}
+	return p.fieldKeys
+}
+
+// DocumentDelimiter is the conventional delimiter line between documents of a
+// multi-document stream. It is not valid NestedText, so it cannot be mistaken for
+// content.
+const DocumentDelimiter = "---"
+
+// SetDelimiter switches the Decoder to reading a stream of multiple documents,
+// which are separated by lines consisting of `delim` (usually DocumentDelimiter),
+// possibly followed by whitespace. Every call to Decode will decode the next
+// document of the stream. A delimiter at the start or at the end of the stream
+// does not start an additional document.
+//
+// SetDelimiter has to be called before the first call to Decode.
+func (dec *Decoder) SetDelimiter(delim string) {
+	dec.delimiter = delim
+}
+
+// Decode parses a NestedText document from its input and stores the result in
+// the value pointed to by v.
+//
+// If v is a pointer to an empty interface, the parse result is stored unchanged.
+// Otherwise the result is converted to the type of v:
+//
+// - types implementing Unmarshaler are initialized by calling UnmarshalNestedText
+//
+// - strings are converted to booleans and numbers as needed; types implementing
+//   encoding.TextUnmarshaler are initialized from strings
+//
+// - lists are stored into slices and arrays
+//
+// - dicts are stored into maps with string keys or into structs. Struct fields are
+//   matched by a field tag `nt:"key"`, or by the field's name (preferring an exact
+//   match over a case-insensitive one). Tag `nt:"-"` excludes a field. Embedded
+//   structs without a tag are treated as if their fields were part of the outer struct.
+//   Option FieldNameMapper changes the keys of fields without a tag.
+//
+// After the document has been decoded (or the last document of a multi-document
+// stream, see SetDelimiter), subsequent calls return io.EOF.
+// Otherwise, if a non-nil error is returned, it will be of type NestedTextError.
+//
+func (dec *Decoder) Decode(v interface{}) error {
+	if dec.done {
+		return io.EOF
+	}
+	var tree interface{}
+	var err error
+	if dec.delimiter == "" {
+		dec.done = true
+		tree, err = Parse(dec.r, dec.opts...)
+	} else {
+		tree, err = dec.nextDocument()
+	}
+	if err != nil {
+		return err
+	}
+	return unmarshalTree(tree, v, valueDecoder{
+		disallowUnknown: dec.disallowUnknown,
+		foreign:         dec.foreign,
+		keys:            dec.keys,
+	})
+}
+
+// nextDocument parses the next document of a multi-document stream.
+func (dec *Decoder) nextDocument() (interface{}, error) {
+	if dec.lines == nil {
+		dec.lines = newRawLineReader(dec.r, maxLineLength(dec.opts))
+	}
+	var doc bytes.Buffer
+	start := dec.lineNo // lines preceding the document
+	found := false      // has a delimiter or a content line been found?
+	for dec.lines.next() {
+		dec.lineNo++
+		if strings.TrimRight(dec.lines.line, " \t") == dec.delimiter {
+			if dec.lineNo > 1 { // no document preceding an initial delimiter
+				found = true
+				break
+			}
+			start++
+			continue
+		}
+		found = true
+		doc.WriteString(dec.lines.line)
+		doc.WriteByte('\n')
+	}
+	if dec.lines.err != nil {
+		return nil, readError(dec.lines.err, dec.lineNo+1, dec.lines.maxLine)
+	}
+	if !found {
+		dec.done = true
+		return nil, io.EOF
+	}
+	tree, err := Parse(&doc, dec.opts...)
+	if err != nil {
+		return nil, shiftErrorPosition(err, start)
+	}
+	return tree, nil
+}
+
+// DecodeAll parses all documents of a multi-document stream, separated by
+// delimiter lines (see Decoder.SetDelimiter). Every document is returned as the
+// result of Parse would be.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func DecodeAll(r io.Reader, delim string, opts ...Option) ([]interface{}, error) {
+	dec := NewDecoder(r, opts...)
+	dec.SetDelimiter(delim)
+	var docs []interface{}
+	for {
+		var tree interface{}
+		if err := dec.Decode(&tree); err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return docs, err
+		}
+		docs = append(docs, tree)
+	}
+}
+
+// UnmarshalAt parses a NestedText document and decodes the value at path expression
+// `path` (see ParsePath) into the value pointed to by v, like Decoder.Decode does for
+// complete documents. This allows components to share a single configuration file:
+//
+//     var db DatabaseConfig
+//     err := nestext.UnmarshalAt(reader, "database.primary", &db)
+//
+// Paths must not contain wildcards. Paths without list indices are passed to option
+// SelectPaths, thus other parts of the document are skipped. Error messages refer to
+// values by their path within the document.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func UnmarshalAt(r io.Reader, path string, v interface{}, opts ...Option) error {
+	segments, err := accessPath(path)
+	if err != nil {
+		return err
+	}
+	selectable := len(segments) > 0
+	for _, seg := range segments {
+		selectable = selectable && !seg.IsIndex
+	}
+	if selectable {
+		opts = append(opts[:len(opts):len(opts)], SelectPaths(path))
+	}
+	p := newParser()
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return err
+		}
+	}
+	tree, err := p.Parse(r)
+	if err != nil {
+		return err
+	}
+	if tree, err = Get(tree, path); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return MakeNestedTextError(ErrCodeUsage,
+			fmt.Sprintf("cannot decode into non-pointer or nil value of type %T", v))
+	}
+	return valueDecoder{keys: p.fieldKeys}.decode(tree, rv.Elem(), path)
+}
+
+// --- Decoding into Go values -----------------------------------------------
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// unmarshalTree stores a tree of NestedText values in the value pointed to by v.
+func unmarshalTree(tree interface{}, v interface{}, d valueDecoder) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return MakeNestedTextError(ErrCodeUsage,
+			fmt.Sprintf("cannot decode into non-pointer or nil value of type %T", v))
+	}
+	return d.decode(tree, rv.Elem(), "")
+}
+
+// valueDecoder assigns NestedText values to Go values, using reflection.
+type valueDecoder struct {
+	disallowUnknown bool       // unknown keys for structs are an error
+	foreign         bool       // use JSON and YAML unmarshalers
+	keys            *FieldKeys // if set, maps names of untagged fields to keys
+}
+
+func (d valueDecoder) decode(tree interface{}, v reflect.Value, path string) error {
+	if tree == nil { // nothing to decode, e.g. empty document
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		if err := v.Addr().Interface().(Unmarshaler).UnmarshalNestedText(tree); err != nil {
+			msg := err.Error()
+			if nterr, ok := err.(NestedTextError); ok {
+				msg = nterr.Message
+			}
+			return d.error(path, msg, err)
+		}
+		return nil
+	}
+	if s, ok := tree.(string); ok && v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return d.error(path, err.Error(), err)
+		}
+		return nil
+	}
+	if d.foreign {
+		if ok, err := d.decodeForeign(tree, v, path); ok {
+			return err
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(tree, v.Elem(), path)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			break
+		}
+		v.Set(reflect.ValueOf(tree))
+		return nil
+	case reflect.Slice, reflect.Array:
+		return d.decodeList(tree, v, path)
+	case reflect.Map:
+		return d.decodeMap(tree, v, path)
+	case reflect.Struct:
+		return d.decodeStruct(tree, v, path)
+	default:
+		return d.decodeScalar(tree, v, path)
+	}
+	return d.mismatch(path, tree, v.Type())
+}
+
+func (d valueDecoder) decodeScalar(tree interface{}, v reflect.Value, path string) error {
+	s, ok := tree.(string)
+	if !ok {
+		return d.mismatch(path, tree, v.Type())
+	}
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(s, 0, v.Type().Bits()); err == nil {
+			v.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		if n, err = strconv.ParseUint(s, 0, v.Type().Bits()); err == nil {
+			v.SetUint(n)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+			return nil
+		}
+	default:
+		return d.mismatch(path, tree, v.Type())
+	}
+	return d.error(path, fmt.Sprintf("cannot convert %q to %s", s, v.Type()), err)
+}
+
+func (d valueDecoder) decodeList(tree interface{}, v reflect.Value, path string) error {
+	list, ok := tree.([]interface{})
+	if !ok {
+		return d.mismatch(path, tree, v.Type())
+	}
+	if v.Kind() == reflect.Array {
+		if v.Len() != len(list) {
+			return d.error(path, fmt.Sprintf("cannot store list of %d items into %s",
+				len(list), v.Type()), nil)
+		}
+	} else {
+		v.Set(reflect.MakeSlice(v.Type(), len(list), len(list)))
+	}
+	for i, item := range list {
+		if err := d.decode(item, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d valueDecoder) decodeMap(tree interface{}, v reflect.Value, path string) error {
+	dict, ok := asDict(tree)
+	if !ok || v.Type().Key().Kind() != reflect.String {
+		return d.mismatch(path, tree, v.Type())
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(dict)))
+	}
+	for key, item := range dict {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decode(item, elem, joinPath(path, key)); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+	}
+	return nil
+}
+
+func (d valueDecoder) decodeStruct(tree interface{}, v reflect.Value, path string) error {
+	dict, ok := asDict(tree)
+	if !ok {
+		return d.mismatch(path, tree, v.Type())
+	}
+	fields := d.keys.structFields(v.Type())
+	for key, item := range dict {
+		f := fields.lookup(key)
+		if f == nil {
+			if d.disallowUnknown {
+				return d.error(joinPath(path, key), "unknown key", nil)
+			}
+			continue
+		}
+		fv, err := fieldByIndex(v, f.index)
+		if err != nil {
+			return d.error(joinPath(path, key), err.Error(), err)
+		}
+		if err := d.decode(item, fv, joinPath(path, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the nested field of a struct, allocating embedded struct
+// pointers as needed.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return v, fmt.Errorf("cannot set embedded pointer to unexported struct %s",
+						v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func (d valueDecoder) mismatch(path string, tree interface{}, t reflect.Type) error {
+	return d.error(path, fmt.Sprintf("cannot store %s into value of type %s", kindOf(tree), t), nil)
+}
+
+func (d valueDecoder) error(path string, msg string, err error) error {
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	return WrapError(ErrCodeSchema, msg, err)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// kindOf names the NestedText type of a tree node.
+func kindOf(tree interface{}) string {
+	switch tree.(type) {
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}, *OrderedMap:
+		return "dict"
+	}
+	return fmt.Sprintf("%T", tree)
+}
+
+// --- Struct fields ---------------------------------------------------------
+
+// field describes a struct field which may be decoded from a dict entry.
+type field struct {
+	name   string // key in NestedText dict
+	index  []int  // index sequence for reflect.Value.FieldByIndex
+	tagged bool   // name is taken from a field tag
+}
+
+type fieldList []field
+
+// lookup finds the field for a dict key, preferring an exact match over a
+// case-insensitive one.
+func (fl fieldList) lookup(key string) *field {
+	var fold *field
+	for i := range fl {
+		if fl[i].name == key {
+			return &fl[i]
+		}
+		if fold == nil && strings.EqualFold(fl[i].name, key) {
+			fold = &fl[i]
+		}
+	}
+	return fold
+}
+
+// FieldKeys maps the fields of struct types to dict keys, as Decoder.Decode matches
+// them: by tag `nt:"key"`, or by the name of the field, optionally mapped by a
+// function (see FieldNameMapper). Keys are computed once per struct type. FieldKeys
+// is safe for concurrent use; a nil *FieldKeys uses the names of fields unchanged.
+type FieldKeys struct {
+	mapper  func(string) string // maps names of untagged fields, nil for identity
+	fields  sync.Map            // maps struct types to their mapped fieldList
+	encoded sync.Map            // maps struct types to their fields to encode
+}
+
+// NewFieldKeys creates a FieldKeys mapping the names of fields without tag by
+// `mapper`, which may be nil to keep names unchanged.
+func NewFieldKeys(mapper func(goName string) string) *FieldKeys {
+	return &FieldKeys{mapper: mapper}
+}
+
+// Dict returns the exported fields of struct `v`, or of the struct `v` points to, as a
+// dict, in the order of the fields. Values are not converted. Fields of nil embedded
+// pointers are left out, as are fields hidden by fields of the outer struct.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func (fk *FieldKeys) Dict(v interface{}) (*OrderedMap, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, MakeNestedTextError(ErrCodeUsage, fmt.Sprintf("value of type %T is not a struct", v))
+	}
+	dict := NewOrderedMap()
+	for _, f := range fk.encodedFields(rv.Type()) {
+		if fv, ok := fieldValue(rv, f.index); ok {
+			dict.Set(f.name, fv.Interface())
+		}
+	}
+	return dict, nil
+}
+
+// structFields returns the fields of a struct type, with the names of untagged fields
+// mapped. The result must not be modified.
+func (fk *FieldKeys) structFields(t reflect.Type) fieldList {
+	if fk == nil || fk.mapper == nil {
+		return structFields(t)
+	}
+	if fields, ok := fk.fields.Load(t); ok {
+		return fields.(fieldList)
+	}
+	fields, _ := fk.fields.LoadOrStore(t, structFields(t).mapped(fk.mapper))
+	return fields.(fieldList)
+}
+
+// encodedFields returns the fields of a struct type to encode, in the order of their
+// declaration. Fields hidden by fields of the same name closer to the outer struct are
+// left out.
+func (fk *FieldKeys) encodedFields(t reflect.Type) fieldList {
+	if fields, ok := fk.encoded.Load(t); ok {
+		return fields.(fieldList)
+	}
+	seen := make(map[string]bool)
+	var fields fieldList
+	for _, f := range fk.structFields(t) { // outer fields come first
+		if !seen[f.name] {
+			seen[f.name] = true
+			fields = append(fields, f)
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i].index, fields[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	stored, _ := fk.encoded.LoadOrStore(t, fields)
+	return stored.(fieldList)
+}
+
+// fieldValue returns the nested field of a struct, and false if an embedded struct
+// pointer on the way is nil.
+func fieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, v.CanInterface()
+}
+
+// mapped returns a copy of the field list, with the names of untagged fields
+// mapped by `fn`.
+func (fl fieldList) mapped(fn func(string) string) fieldList {
+	m := make(fieldList, len(fl))
+	copy(m, fl)
+	for i := range m {
+		if !m[i].tagged {
+			m[i].name = fn(m[i].name)
+		}
+	}
+	return m
+}
+
+// fieldCache maps struct types to their fieldList.
+var fieldCache sync.Map
+
+// structFields returns the decodable fields of a struct type, including fields
+// of embedded structs. Results are cached per type.
+func structFields(t reflect.Type) fieldList {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.(fieldList)
+	}
+	fields, _ := fieldCache.LoadOrStore(t, collectStructFields(t))
+	return fields.(fieldList)
+}
+
+// collectStructFields collects the decodable fields of a struct type. As with
+// encoding/json, an embedded struct which embeds one of its enclosing structs, e.g.
+// `type T struct{ *T }`, is not expanded again.
+func collectStructFields(t reflect.Type) fieldList {
+	var fields fieldList
+	visiting := map[reflect.Type]bool{} // structs being collected, to break cycles
+	var collect func(t reflect.Type, index []int)
+	collect = func(t reflect.Type, index []int) {
+		visiting[t] = true
+		defer delete(visiting, t)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("nt")
+			if j := strings.IndexByte(tag, ','); j >= 0 {
+				tag = tag[:j]
+			}
+			if tag == "-" {
+				continue
+			}
+			idx := make([]int, len(index)+1)
+			copy(idx, index)
+			idx[len(index)] = i
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+				if !visiting[ft] {
+					collect(ft, idx)
+				}
+				continue
+			}
+			if sf.PkgPath != "" { // unexported
+				continue
+			}
+			f := field{name: tag, index: idx, tagged: tag != ""}
+			if tag == "" {
+				f.name = sf.Name
+			}
+			fields = append(fields, f)
+		}
+	}
+	collect(t, nil)
+	// fields of the outer struct take precedence over fields of embedded structs
+	sort.SliceStable(fields, func(i, j int) bool {
+		return len(fields[i].index) < len(fields[j].index)
+	})
+	return fields
+}
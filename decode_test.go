@@ -0,0 +1,355 @@
+package nestext
+
+import (
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testAddress struct {
+	Street string `nt:"street"`
+	City   string
+}
+
+type testPerson struct {
+	testAddress
+	Name    string            `nt:"name"`
+	Age     int               `nt:"age"`
+	Member  bool              `nt:"member"`
+	Roles   []string          `nt:"roles"`
+	Phones  map[string]string `nt:"phone"`
+	Home    *net.IP           `nt:"home"`
+	Misc    interface{}       `nt:"misc"`
+	Skipped string            `nt:"-"`
+}
+
+func TestDecoderStruct(t *testing.T) {
+	input := `
+name: Katheryn McDaniel
+age: 42
+member: true
+street: 138 Almond Street
+city: Topeka
+roles:
+  - board member
+phone:
+  cell: 1-210-555-5297
+home: 10.0.0.1
+misc:
+  [a, b]
+`
+	var person testPerson
+	dec := NewDecoder(strings.NewReader(input))
+	if err := dec.Decode(&person); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("person = %+v", person)
+	if person.Name != "Katheryn McDaniel" || person.Age != 42 || !person.Member {
+		t.Errorf("simple fields not decoded correctly")
+	}
+	if person.Street != "138 Almond Street" || person.City != "Topeka" {
+		t.Errorf("embedded fields not decoded correctly")
+	}
+	if !reflect.DeepEqual(person.Roles, []string{"board member"}) || person.Phones["cell"] == "" {
+		t.Errorf("list or dict not decoded correctly")
+	}
+	if person.Home == nil || !person.Home.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("text unmarshaler not used for IP address")
+	}
+	if !reflect.DeepEqual(person.Misc, []interface{}{"a", "b"}) {
+		t.Errorf("interface field not decoded correctly: %#v", person.Misc)
+	}
+	if err := dec.Decode(&person); err != io.EOF {
+		t.Errorf("expected second call to Decode to return EOF, have %v", err)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	var person testPerson
+	err := NewDecoder(strings.NewReader("age: old\n")).Decode(&person)
+	nterr := NestedTextError{}
+	if !errors.As(err, &nterr) || nterr.Code != ErrCodeSchema {
+		t.Fatalf("expected schema error for age, have %v", err)
+	}
+	t.Logf("got expected error: %v", err)
+	dec := NewDecoder(strings.NewReader("name: x\nnickname: y\n"))
+	dec.DisallowUnknownKeys()
+	if err = dec.Decode(&person); err == nil {
+		t.Error("expected unknown key to be rejected, wasn't")
+	}
+	if err = NewDecoder(strings.NewReader("- a\n")).Decode(person); err == nil {
+		t.Error("expected decoding into non-pointer to fail, didn't")
+	}
+}
+
+func TestDecoderInterface(t *testing.T) {
+	var tree interface{}
+	dec := NewDecoder(strings.NewReader("> Hello\n"))
+	dec.SetOptions(TopLevel("list"))
+	if err := dec.Decode(&tree); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tree, []interface{}{"Hello"}) {
+		t.Errorf("expected list with single string, have %#v", tree)
+	}
+}
+
+func TestDecoderMultiDocument(t *testing.T) {
+	input := "---\na: 1\n---  \n# second\n- x\n- y\n---\n> text\n---\n"
+	docs, err := DecodeAll(strings.NewReader(input), DocumentDelimiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{
+		map[string]interface{}{"a": "1"},
+		[]interface{}{"x", "y"},
+		"text",
+	}
+	if !reflect.DeepEqual(docs, expected) {
+		t.Errorf("expected documents %#v, have %#v", expected, docs)
+	}
+	dec := NewDecoder(strings.NewReader("a: 1\n---\nb: 2\nc\n"))
+	dec.SetDelimiter(DocumentDelimiter)
+	var v map[string]string
+	if err := dec.Decode(&v); err != nil || v["a"] != "1" {
+		t.Errorf("unexpected first document %v, %v", v, err)
+	}
+	err = dec.Decode(&v)
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Line != 4 {
+		t.Errorf("expected error in line 4, have %v", err)
+	}
+	if err = dec.Decode(&v); err != io.EOF {
+		t.Errorf("expected io.EOF after last document, have %v", err)
+	}
+	dec = NewDecoder(strings.NewReader("\ufeffa: 1\n---\nb: 2\n"))
+	dec.SetDelimiter(DocumentDelimiter)
+	if err := dec.Decode(&v); err != nil || v["a"] != "1" {
+		t.Errorf("expected byte order mark to be skipped, have %v, %v", v, err)
+	}
+}
+
+func TestStructFieldsCached(t *testing.T) {
+	type tagged struct {
+		A string `nt:"a,omitempty"`
+		B string
+	}
+	fields := structFields(reflect.TypeOf(tagged{}))
+	if len(fields) != 2 || fields[0].name != "a" || fields[1].name != "B" {
+		t.Errorf("unexpected fields %+v", fields)
+	}
+	again := structFields(reflect.TypeOf(tagged{}))
+	if &again[0] != &fields[0] {
+		t.Errorf("expected field list to be cached")
+	}
+}
+
+type testCycleA struct {
+	*testCycleB
+	A string `nt:"a"`
+}
+
+type testCycleB struct {
+	*testCycleA
+	B string `nt:"b"`
+}
+
+func TestStructFieldsSelfEmbedding(t *testing.T) {
+	type node struct {
+		*node
+		Name string `nt:"name"`
+	}
+	fields := collectStructFields(reflect.TypeOf(node{}))
+	if len(fields) != 1 || fields[0].name != "name" {
+		t.Errorf("expected self-embedding struct to be expanded once, have %+v", fields)
+	}
+	var n node
+	if err := NewDecoder(strings.NewReader("name: x\n")).Decode(&n); err != nil || n.Name != "x" {
+		t.Errorf("expected name to be decoded, have %q, %v", n.Name, err)
+	}
+	fields = collectStructFields(reflect.TypeOf(testCycleA{}))
+	if len(fields) != 2 || fields[0].name != "a" || fields[1].name != "b" {
+		t.Errorf("expected mutually embedding structs to be expanded once, have %+v", fields)
+	}
+}
+
+var benchmarkTree = map[string]interface{}{
+	"name":   "Katheryn McDaniel",
+	"age":    "42",
+	"member": "true",
+	"street": "138 Almond Street",
+	"city":   "Topeka",
+	"roles":  []interface{}{"board member", "treasurer"},
+	"phone":  map[string]interface{}{"cell": "1-210-555-5297"},
+}
+
+func BenchmarkUnmarshalStruct(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var person testPerson
+		if err := unmarshalTree(benchmarkTree, &person, valueDecoder{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStructFields(b *testing.B) {
+	t := reflect.TypeOf(testPerson{})
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			structFields(t)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			collectStructFields(t)
+		}
+	})
+}
+
+func TestDecoderReset(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("a: 1\n"))
+	dec.DisallowUnknownKeys()
+	var v struct{ A string }
+	if err := dec.Decode(&v); err != nil || v.A != "1" {
+		t.Fatalf("unexpected result %v, %v", v, err)
+	}
+	dec.Reset(strings.NewReader("a: 2\nb: 3\n"))
+	if err := dec.Decode(&v); err == nil {
+		t.Errorf("expected settings of decoder to survive Reset, unknown key accepted")
+	}
+	dec.Reset(strings.NewReader("a: 4\n"))
+	if err := dec.Decode(&v); err != nil || v.A != "4" {
+		t.Errorf("unexpected result after Reset %v, %v", v, err)
+	}
+}
+
+func TestUnmarshalAt(t *testing.T) {
+	input := "database:\n  primary:\n    host: db1\n    port: 5432\n  replica:\n    host: db2\n    port: x\nservers:\n  -\n    host: a\n    port: 80\n"
+	type dbConfig struct {
+		Host string
+		Port int
+	}
+	var db dbConfig
+	if err := UnmarshalAt(strings.NewReader(input), "database.primary", &db); err != nil {
+		t.Fatal(err)
+	}
+	if db != (dbConfig{"db1", 5432}) {
+		t.Errorf("unexpected result %+v", db)
+	}
+	if err := UnmarshalAt(strings.NewReader(input), "servers[0]", &db); err != nil || db.Host != "a" {
+		t.Errorf("unexpected result %+v, %v", db, err)
+	}
+	err := UnmarshalAt(strings.NewReader(input), "database.replica", &db)
+	if err == nil || !strings.Contains(err.Error(), "database.replica.port:") {
+		t.Errorf("expected error to report the path of the value, have %v", err)
+	}
+	for _, path := range []string{"database.backup", "database.*", "servers[1]"} {
+		if err := UnmarshalAt(strings.NewReader(input), path, &db); err == nil {
+			t.Errorf("expected decoding %q to fail", path)
+		}
+	}
+}
+
+func TestDecodeFieldNameMapper(t *testing.T) {
+	snake := func(name string) string {
+		var b strings.Builder
+		for i, r := range name {
+			if r >= 'A' && r <= 'Z' {
+				if i > 0 {
+					b.WriteByte('_')
+				}
+				r += 'a' - 'A'
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+	type config struct {
+		MaxRetries string
+		LogLevel   string `nt:"LogLevel"`
+		HostName   string
+	}
+	input := "max_retries: 3\nLogLevel: debug\n"
+	dec := NewDecoder(strings.NewReader(input), FieldNameMapper(snake))
+	var c config
+	if err := dec.Decode(&c); err != nil || c.MaxRetries != "3" || c.LogLevel != "debug" {
+		t.Errorf("unexpected result %+v, %v", c, err)
+	}
+	dec = NewDecoder(strings.NewReader("HostName: x\n"), FieldNameMapper(snake))
+	dec.DisallowUnknownKeys()
+	err := dec.Decode(&c)
+	if err == nil || !strings.Contains(err.Error(), "HostName: unknown key") {
+		t.Errorf("expected unmapped name to be unknown, have %v", err)
+	}
+	c = config{}
+	err = UnmarshalAt(strings.NewReader("db:\n  host_name: localhost\n"), "db", &c,
+		FieldNameMapper(snake))
+	if err != nil || c.HostName != "localhost" {
+		t.Errorf("expected mapped field in UnmarshalAt, have %+v, %v", c, err)
+	}
+	if names := structFields(reflect.TypeOf(config{})); names[0].name != "MaxRetries" {
+		t.Errorf("expected cached fields to be unchanged, have %+v", names)
+	}
+	if _, err = Parse(strings.NewReader("a: 1\n"), FieldNameMapper(nil)); err == nil {
+		t.Errorf("expected error for nil mapper")
+	}
+}
+
+func TestSnakeAndKebabCase(t *testing.T) {
+	inputs := map[string]string{
+		"MaxRetries":     "max_retries",
+		"ID":             "id",
+		"UserID":         "user_id",
+		"MaxHTTPRetries": "max_http_retries",
+		"Port8080Open":   "port8080_open",
+		"x":              "x",
+	}
+	for name, expected := range inputs {
+		if key := SnakeCase(name); key != expected {
+			t.Errorf("expected %s to map to %s, have %s", name, expected, key)
+		}
+	}
+	if key := KebabCase("MaxHTTPRetries"); key != "max-http-retries" {
+		t.Errorf("expected max-http-retries, have %s", key)
+	}
+}
+
+func TestFieldKeys(t *testing.T) {
+	type inner struct {
+		HostName string
+		Port     int
+	}
+	type config struct {
+		*inner
+		LogLevel string `nt:"LogLevel"`
+		Port     string
+		Skipped  string `nt:"-"`
+		hidden   string
+	}
+	keys := NewFieldKeys(SnakeCase)
+	fields := keys.structFields(reflect.TypeOf(config{}))
+	if again := keys.structFields(reflect.TypeOf(config{})); &again[0] != &fields[0] {
+		t.Errorf("expected mapped field list to be cached")
+	}
+	dict, err := keys.Dict(&config{inner: &inner{HostName: "h", Port: 1}, LogLevel: "debug", Port: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := dict.Keys(); !reflect.DeepEqual(k, []string{"host_name", "LogLevel", "port"}) {
+		t.Errorf("unexpected keys %v", k)
+	}
+	if port, _ := dict.Get("port"); port != "2" {
+		t.Errorf("expected field of outer struct to hide embedded field, have %v", port)
+	}
+	if dict, _ = keys.Dict(config{Port: "3"}); dict.Len() != 2 {
+		t.Errorf("expected fields of nil embedded pointer to be left out, have %v", dict)
+	}
+	if _, err = keys.Dict("x"); err == nil {
+		t.Errorf("expected error for non-struct")
+	}
+}
@@ -0,0 +1,131 @@
+package nestext
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// --- Auto-dedent -----------------------------------------------------------
+
+// AutoDedent requests the parser to remove indentation common to all lines of the
+// document, instead of failing with an error of code ErrCodeFormatToplevelIndent.
+// This is intended for documents copied from e-mails or code blocks of markdown
+// files, which are often indented uniformly. Comment lines and blank lines are
+// dedented as far as possible, but do not determine the common indentation.
+//
+// If indentation has been removed, a warning of code ErrCodeFormatToplevelIndent is
+// reported (see option ReportWarnings). Column numbers of errors and warnings refer to
+// the original input.
+//
+// The complete input is read before parsing starts, so this option should not be
+// used for large documents.
+//
+func AutoDedent() Option {
+	return func(p *nestedTextParser) (err error) {
+		p.autoDedent = true
+		return nil
+	}
+}
+
+// dedentInput removes indentation common to the lines of input `r`, if option
+// AutoDedent is set, and reports a warning if indentation has been removed.
+func (p *nestedTextParser) dedentInput(r io.Reader) (io.Reader, error) {
+	if !p.autoDedent || r == nil {
+		return r, nil
+	}
+	var line int
+	var err error
+	if r, p.dedent, line, p.removed, err = dedentInput(r); err != nil {
+		return nil, err
+	}
+	if p.dedent > 0 {
+		p.warning(dedentWarning(p.dedent, line))
+	}
+	return r, nil
+}
+
+// originalColumns adds the indentation removed by option AutoDedent to the column of
+// `err`, if it is a NestedTextError, to report columns of the original input.
+func (p *nestedTextParser) originalColumns(err error) error {
+	if nterr, ok := err.(NestedTextError); ok && p.dedent > 0 && nterr.Line > 0 {
+		nterr.Column += p.dedent
+		return nterr
+	}
+	return err
+}
+
+// dedentInput reads the complete input and removes indentation common to all lines
+// which are neither blank nor comments. It returns a reader on the dedented input,
+// the amount of indentation removed, the line number of the first item and the number
+// of bytes removed.
+func dedentInput(r io.Reader) (io.Reader, int, int, int64, error) {
+	src, err := ioutil.ReadAll(r)
+	if nterr, ok := err.(NestedTextError); ok { // input limit exceeded
+		return nil, 0, 0, 0, nterr
+	} else if err != nil {
+		return nil, 0, 0, 0, WrapError(ErrCodeIO, "I/O error while reading input", err)
+	}
+	lines := splitLinesKeepEOL(src)
+	common, first := -1, 0
+	for i, line := range lines {
+		body := bytes.TrimLeft(line, " ")
+		content := bytes.TrimSpace(body)
+		if len(content) == 0 || content[0] == '#' {
+			continue
+		}
+		if indent := len(line) - len(body); common < 0 || indent < common {
+			common = indent
+		}
+		if first == 0 {
+			first = i + 1
+		}
+	}
+	if common <= 0 {
+		return bytes.NewReader(src), 0, 0, 0, nil
+	}
+	var removed int64
+	out := make([]byte, 0, len(src))
+	for _, line := range lines {
+		n := 0
+		for n < common && n < len(line) && line[n] == ' ' {
+			n++
+		}
+		out = append(out, line[n:]...)
+		removed += int64(n)
+	}
+	return bytes.NewReader(out), common, first, removed, nil
+}
+
+// splitLinesKeepEOL breaks up src into lines, splitting at CR LF, CR or LF. Line
+// terminators are kept with their lines.
+func splitLinesKeepEOL(src []byte) [][]byte {
+	var lines [][]byte
+	start := 0
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '\r':
+			if i+1 < len(src) && src[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+		default:
+			continue
+		}
+		lines = append(lines, src[start:i+1])
+		start = i + 1
+	}
+	if start < len(src) {
+		lines = append(lines, src[start:])
+	}
+	return lines
+}
+
+// dedentWarning creates the warning for an applied dedent.
+func dedentWarning(indent, line int) NestedTextError {
+	w := MakeNestedTextError(ErrCodeFormatToplevelIndent,
+		fmt.Sprintf("removed common indentation of %d spaces", indent))
+	w.Line = line
+	return w
+}
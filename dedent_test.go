@@ -0,0 +1,50 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAutoDedent(t *testing.T) {
+	input := "\r\n    # contact\r\n  name: Katheryn\r\n  roles:\r\n    - treasurer\r\n"
+	if _, err := Parse(strings.NewReader(input)); err == nil {
+		t.Fatalf("expected indented document to be rejected without AutoDedent")
+	}
+	var warnings []NestedTextError
+	result, err := Parse(strings.NewReader(input), AutoDedent(),
+		ReportWarnings(func(w NestedTextError) {
+			warnings = append(warnings, w)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"name":  "Katheryn",
+		"roles": []interface{}{"treasurer"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %#v, have %#v", expected, result)
+	}
+	if len(warnings) != 1 || warnings[0].Code != ErrCodeFormatToplevelIndent || warnings[0].Line != 3 {
+		t.Errorf("expected a single dedent warning for line 3, have %v", warnings)
+	}
+	warnings = nil
+	if _, err = Parse(strings.NewReader("a: b\n"), AutoDedent(), ReportWarnings(func(w NestedTextError) {
+		warnings = append(warnings, w)
+	})); err != nil || len(warnings) != 0 {
+		t.Errorf("expected no warning for unindented document, have %v, %v", warnings, err)
+	}
+}
+
+func TestParseAutoDedentErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader("    a: b\n    {c: d\n"), AutoDedent())
+	nterr, ok := err.(NestedTextError)
+	if !ok {
+		t.Fatalf("expected partial dedent to be rejected, have %v", err)
+	}
+	t.Logf("got expected error: %v", err)
+	if nterr.Line == 0 || nterr.Column < 4 {
+		t.Errorf("expected error position to refer to original input, have %d:%d", nterr.Line, nterr.Column)
+	}
+}
@@ -0,0 +1,67 @@
+package nestext
+
+import "fmt"
+
+// --- Duplicate list items --------------------------------------------------
+
+// WarnDuplicateItems requests the parser to check lists for items which are exact
+// duplicates of preceding items of the same list, a common copy-and-paste mistake in
+// hand-maintained inventories. Strings as well as nested lists and dicts are compared,
+// disregarding the order of keys (see Equal). Every duplicate is reported as a warning
+// of code ErrCodeSchema at the line of the duplicate, with the line of its first
+// occurence in the message:
+//
+//     nestext.Parse(reader, nestext.WarnDuplicateItems(), nestext.ReportWarnings(func(w nestext.NestedTextError) {
+//         log.Printf("warning: %v", w)
+//     }))
+//
+// Items of inline lists are not checked. Without ReportWarnings, this option has no
+// effect. See also rule "duplicate-values" of package ntlint. ParseEvents does not
+// support this option.
+//
+func WarnDuplicateItems() Option {
+	return func(p *nestedTextParser) (err error) {
+		p.noDuplicates = true
+		return nil
+	}
+}
+
+// checkDuplicateItems reports every item of a list which equals a preceding item.
+// `lines` holds the line numbers of the items, `indent` their indentation. Placeholders
+// of items which failed to parse are not checked.
+func (p *nestedTextParser) checkDuplicateItems(items []interface{}, lines []int, indent int) {
+	if p.warn == nil {
+		return
+	}
+	seen := make(map[string]int, len(items)) // string value → index of first item
+	var nested []int                         // indices of lists and dicts checked so far
+	for i, item := range items {
+		if _, ok := item.(*Placeholder); ok {
+			continue
+		}
+		first := -1
+		if s, ok := item.(string); ok {
+			if j, ok := seen[s]; ok {
+				first = j
+			} else {
+				seen[s] = i
+			}
+		} else {
+			for _, j := range nested {
+				if Equal(items[j], item) {
+					first = j
+					break
+				}
+			}
+			if first < 0 {
+				nested = append(nested, i)
+			}
+		}
+		if first >= 0 {
+			w := MakeNestedTextError(ErrCodeSchema, fmt.Sprintf(
+				"duplicate list item, same as item at line %d", lines[first]))
+			w.Line, w.Column = lines[i], indent
+			p.warning(w)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package nestext
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseWarnDuplicateItems(t *testing.T) {
+	input := "hosts:\n" +
+		"  - alpha\n" +
+		"  - beta\n" +
+		"  - alpha\n" +
+		"  -\n" +
+		"    name: x\n" +
+		"    port: 1\n" +
+		"  -\n" +
+		"    port: 1\n" +
+		"    name: x\n" +
+		"  -\n" +
+		"    name: x\n" +
+		"  - [a, b]\n" +
+		"other:\n" +
+		"  - alpha\n"
+	var warnings []NestedTextError
+	_, err := Parse(strings.NewReader(input), WarnDuplicateItems(), ReportWarnings(func(w NestedTextError) {
+		warnings = append(warnings, w)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct{ line, first int }{{4, 2}, {8, 5}}
+	if len(warnings) != len(expected) {
+		t.Fatalf("expected %d warnings, have %v", len(expected), warnings)
+	}
+	for i, e := range expected {
+		w := warnings[i]
+		if w.Code != ErrCodeSchema || w.Line != e.line || w.Column != 2 ||
+			!strings.Contains(w.Error(), "item at line "+strconv.Itoa(e.first)) {
+			t.Errorf("expected warning at line %d for item at line %d, have %v", e.line, e.first, w)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package nestext
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- Event-based parsing ---------------------------------------------------
+
+// Handler is a set of callbacks for ParseEvents. Callbacks which are nil are
+// skipped. If a callback returns a non-nil error, parsing stops and the error is
+// returned to the caller of ParseEvents.
+//
+// All callbacks receive the line number of the item in the input, starting at 1.
+// Inline lists and dicts report the line of the inline item for all of their values.
+//
+type Handler struct {
+	OnDictStart func(line int) error               // start of a dict
+	OnDictEnd   func() error                       // end of the current dict
+	OnKey       func(key string, line int) error   // key of a dict entry, followed by its value
+	OnListStart func(line int) error               // start of a list
+	OnListEnd   func() error                       // end of the current list
+	OnListItem  func(index int, line int) error    // list item, followed by its value
+	OnValue     func(value string, line int) error // string value
+}
+
+// ParseEvents parses a NestedText document and reports its structure by calling
+// the callbacks of h, without building up a tree of values. This allows processing
+// documents which are too large to be held in memory. Callbacks of h may be nil,
+// h itself must not be.
+//
+// Multi-line strings are reported as a single value. Options which operate on the
+// resulting tree (TopLevel, NoMixedLists) are ignored. The following options are not
+// supported and result in an error of code ErrCodeUsage: CaptureComments,
+// CaptureLayout, ReportPositions, SelectPaths, Validate, WarnDuplicateItems,
+// RequireKeys, ContinueOnError, ResumeAtTopLevel.
+//
+// If a non-nil error is returned and has not been created by a callback, it will be of
+// type NestedTextError.
+//
+// Use as:
+//     depth := 0
+//     err := nestext.ParseEvents(reader, &nestext.Handler{
+//         OnKey: func(key string, line int) error {
+//             fmt.Printf("%s%s\n", strings.Repeat("  ", depth), key)
+//             return nil
+//         },
+//         OnDictStart: func(line int) error { depth++; return nil },
+//         OnDictEnd:   func() error { depth--; return nil },
+//     })
+//
+func ParseEvents(r io.Reader, h *Handler, opts ...Option) (err error) {
+	if h == nil {
+		return MakeNestedTextError(ErrCodeUsage, "event handler is nil")
+	}
+	p := newParser()
+	for _, opt := range opts {
+		if err = opt(p); err != nil {
+			return err
+		}
+	}
+	if err = p.checkEventOptions(); err != nil {
+		return err
+	}
+	defer func() { err = p.finishError(p.originalColumns(err)) }()
+	if r, err = p.dedentInput(p.limit(r)); err != nil {
+		return err
+	}
+	if p.sc, err = newScannerWithHooks(r, p.hooks(), p.limits.lineLength); err != nil {
+		return err
+	}
+	p.sc.Progress = p.progress
+	if p.controls == ControlReject {
+		p.sc.Check = checkControls
+	}
+	e := eventParser{p: p, h: h}
+	// initial token from scanner is a health check for the input source
+	if p.token = p.sc.NextToken(); p.token.Error != nil {
+		return p.token.Error
+	}
+	if p.token.TokenType != eof && p.token.TokenType != emptyDocument {
+		if err = e.next(); err != nil {
+			return err
+		}
+		if err = e.parseAny(0); err == nil && p.token.TokenType != eof {
+			err = p.unusedContent()
+		}
+	}
+	if err == nil {
+		if errs := p.lineBreakErrors(); len(errs) > 0 {
+			err = errs[0]
+		}
+	}
+	if err == nil && p.meta != nil {
+		p.reportMetadata()
+	}
+	return err
+}
+
+// checkEventOptions rejects options which are not supported by ParseEvents.
+// TestParseEventsOptions checks that every option of the package is either supported,
+// ignored or rejected here; new options have to be added to its table.
+func (p *nestedTextParser) checkEventOptions() error {
+	var option string
+	switch {
+	case p.layout != nil:
+		option = "CaptureLayout"
+	case p.comments != nil:
+		option = "CaptureComments"
+	case p.positions != nil:
+		option = "ReportPositions"
+	case p.selectors != nil:
+		option = "SelectPaths"
+	case p.validators != nil:
+		option = "Validate"
+	case p.noDuplicates:
+		option = "WarnDuplicateItems"
+	case p.required != nil:
+		option = "RequireKeys"
+	case p.resumeTop:
+		option = "ResumeAtTopLevel"
+	case p.collect:
+		option = "ContinueOnError"
+	default:
+		return nil
+	}
+	return MakeNestedTextError(ErrCodeUsage, "option "+option+" is not supported by ParseEvents")
+}
+
+// eventParser drives the scanner of a nestedTextParser and calls the callbacks of
+// a Handler instead of pushing values onto the parser stack.
+type eventParser struct {
+	p     *nestedTextParser
+	h     *Handler
+	depth int // count of enclosing lists and dicts
+}
+
+func (e eventParser) next() error {
+	e.p.token = e.p.sc.NextToken()
+	return e.p.token.Error
+}
+
+func (e eventParser) parseAny(indent int) error {
+	token := e.p.token
+	switch token.TokenType {
+	case stringMultiline:
+		return e.parseMultiString(indent)
+	case inlineList, inlineDict:
+		state := _S2
+		if token.TokenType == inlineDict {
+			state = _S1
+		}
+		e.p.inline.LineNo, e.p.inline.Column, e.p.inline.depth = token.LineNo, token.Indent, e.depth
+		value, err := e.p.inline.parse(state, token.Content[0])
+		if err == nil && e.p.limits.items > 0 {
+			err = e.p.countItems(inlineItemCount(value), token.LineNo)
+		}
+		if err == nil {
+			err = e.p.checkInlineKeys(value, e.p.path, token.LineNo)
+		}
+		if err != nil {
+			return err
+		}
+		if err = e.emitTree(value, token.LineNo); err != nil {
+			return err
+		}
+		return e.next()
+	case listItem, listItemMultiline:
+		return e.parseList(indent)
+	case inlineDictKeyValue, inlineDictKey, dictKeyMultiline:
+		return e.parseDict(indent)
+	}
+	return makeParsingError(token, ErrCodeFormat, "unexpected item type")
+}
+
+func (e eventParser) parseList(indent int) (err error) {
+	if err = e.enter(); err != nil {
+		return err
+	}
+	if err = e.h.listStart(e.p.token.LineNo); err != nil {
+		return err
+	}
+	for i := 0; e.p.token.Indent == indent && e.p.token.TokenType != eof; i++ {
+		token := e.p.token
+		if token.TokenType != listItem && token.TokenType != listItemMultiline {
+			break
+		}
+		if err = e.p.countItems(1, token.LineNo); err != nil {
+			return err
+		}
+		if err = e.h.listItem(i, token.LineNo); err != nil {
+			return err
+		}
+		if token.TokenType == listItem {
+			err = e.h.value(token.Content[0], token.LineNo)
+			if err == nil {
+				err = e.next()
+			}
+		} else {
+			err = e.parseNested(strconv.Itoa(i), indent, token.LineNo)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err = e.checkDedent(indent); err != nil {
+		return err
+	}
+	return e.h.listEnd()
+}
+
+func (e eventParser) parseDict(indent int) (err error) {
+	if err = e.enter(); err != nil {
+		return err
+	}
+	if err = e.h.dictStart(e.p.token.LineNo); err != nil {
+		return err
+	}
+loop:
+	for e.p.token.Indent == indent && e.p.token.TokenType != eof {
+		token := e.p.token
+		if token.TokenType == inlineDictKeyValue || token.TokenType == inlineDictKey ||
+			token.TokenType == dictKeyMultiline {
+			if err = e.p.countItems(1, token.LineNo); err != nil {
+				return err
+			}
+		}
+		switch token.TokenType {
+		case inlineDictKeyValue:
+			if err = e.key(token.Content[0], token.LineNo, indent); err == nil {
+				if err = e.h.value(token.Content[1], token.LineNo); err == nil {
+					err = e.next()
+				}
+			}
+		case inlineDictKey:
+			if err = e.key(token.Content[0], token.LineNo, indent); err == nil {
+				err = e.parseNested(token.Content[0], indent, token.LineNo)
+			}
+		case dictKeyMultiline:
+			err = e.parseMultilineKey(indent)
+		default:
+			break loop
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err = e.checkDedent(indent); err != nil {
+		return err
+	}
+	return e.h.dictEnd()
+}
+
+// enter increments the nesting depth for a list or dict, checking it against option
+// MaxDepth. As e is passed by value, the depth reverts when the list or dict is left.
+func (e *eventParser) enter() error {
+	if e.p.maxDepth > 0 && e.depth >= e.p.maxDepth {
+		return depthError(e.p.token.LineNo, e.p.token.ColNo, e.p.maxDepth)
+	}
+	e.depth++
+	return nil
+}
+
+// key checks dict key `key` at `line` and `indent` for options KeyPattern and ValidKeys,
+// and reports it.
+func (e eventParser) key(key string, line, indent int) error {
+	if err := e.p.checkKey(key, line, indent); err != nil {
+		return err
+	}
+	return e.h.key(key, line)
+}
+
+// parseNested parses the value of an item with key or index `key` without a value on
+// its own line, i.e. a nested item, or an empty string if there is no nested item.
+func (e eventParser) parseNested(key string, indent int, line int) error {
+	if err := e.next(); err != nil {
+		return err
+	}
+	if e.p.token.Indent <= indent || e.p.token.TokenType == eof {
+		return e.h.value("", line)
+	}
+	return e.parseChild(key, indent)
+}
+
+// parseChild parses the nested value of the item with key or index `key` at `indent`.
+// The path of the value is tracked for key validation.
+func (e eventParser) parseChild(key string, indent int) error {
+	if e.p.indentWidth == 0 {
+		e.p.indentWidth = e.p.token.Indent - indent
+	}
+	if e.p.validKey == nil {
+		return e.parseAny(e.p.token.Indent)
+	}
+	outer := e.p.path
+	e.p.path = AppendPath(outer, key)
+	defer func() { e.p.path = outer }()
+	return e.parseAny(e.p.token.Indent)
+}
+
+func (e eventParser) parseMultilineKey(indent int) error {
+	line := e.p.token.LineNo
+	builder := strings.Builder{}
+	builder.WriteString(allowVoid(e.p.token.Content, 0))
+	for {
+		if err := e.next(); err != nil {
+			return err
+		}
+		if e.p.token.TokenType != dictKeyMultiline || e.p.token.Indent != indent {
+			break
+		}
+		builder.WriteRune('\n')
+		builder.WriteString(allowVoid(e.p.token.Content, 0))
+	}
+	key := builder.String()
+	if err := e.key(key, line, indent); err != nil {
+		return err
+	}
+	if e.p.token.Indent <= indent || e.p.token.TokenType == eof {
+		return e.h.value("", line)
+	}
+	return e.parseChild(key, indent)
+}
+
+func (e eventParser) parseMultiString(indent int) error {
+	line := e.p.token.LineNo
+	builder := strings.Builder{}
+	builder.WriteString(allowVoid(e.p.token.Content, 0))
+	for {
+		if err := e.next(); err != nil {
+			return err
+		}
+		if e.p.token.TokenType != stringMultiline || e.p.token.Indent != indent {
+			break
+		}
+		if e.p.keepBlanks {
+			builder.WriteString(strings.Repeat("\n", e.p.token.Blanks))
+		}
+		builder.WriteRune('\n')
+		builder.WriteString(allowVoid(e.p.token.Content, 0))
+	}
+	if err := e.checkDedent(indent); err != nil {
+		return err
+	}
+	return e.h.value(builder.String(), line)
+}
+
+// checkDedent checks that an item following a list, dict or multi-line string is not
+// indented further than the preceding lines.
+func (e eventParser) checkDedent(indent int) error {
+	if e.p.token.TokenType != eof && e.p.token.Indent > indent {
+		return indentError(e.p.token,
+			"invalid indent: may only follow an item that does not already have a value")
+	}
+	return nil
+}
+
+// emitTree reports a value resulting from an inline list or dict. Dict keys are
+// reported in sorted order.
+func (e eventParser) emitTree(tree interface{}, line int) (err error) {
+	switch t := tree.(type) {
+	case string:
+		return e.h.value(t, line)
+	case []interface{}:
+		if err = e.h.listStart(line); err != nil {
+			return err
+		}
+		for i, item := range t {
+			if err = e.h.listItem(i, line); err == nil {
+				err = e.emitTree(item, line)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return e.h.listEnd()
+	case map[string]interface{}:
+		if err = e.h.dictStart(line); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err = e.h.key(k, line); err == nil {
+				err = e.emitTree(t[k], line)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return e.h.dictEnd()
+	case *OrderedMap:
+		if err = e.h.dictStart(line); err != nil {
+			return err
+		}
+		for _, k := range t.Keys() {
+			if err = e.h.key(k, line); err == nil {
+				err = e.emitTree(t.values[k], line)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return e.h.dictEnd()
+	}
+	return nil
+}
+
+// Helpers to call optional callbacks.
+
+func (h *Handler) dictStart(line int) error {
+	if h.OnDictStart == nil {
+		return nil
+	}
+	return h.OnDictStart(line)
+}
+
+func (h *Handler) dictEnd() error {
+	if h.OnDictEnd == nil {
+		return nil
+	}
+	return h.OnDictEnd()
+}
+
+func (h *Handler) key(key string, line int) error {
+	if h.OnKey == nil {
+		return nil
+	}
+	return h.OnKey(key, line)
+}
+
+func (h *Handler) listStart(line int) error {
+	if h.OnListStart == nil {
+		return nil
+	}
+	return h.OnListStart(line)
+}
+
+func (h *Handler) listEnd() error {
+	if h.OnListEnd == nil {
+		return nil
+	}
+	return h.OnListEnd()
+}
+
+func (h *Handler) listItem(index int, line int) error {
+	if h.OnListItem == nil {
+		return nil
+	}
+	return h.OnListItem(index, line)
+}
+
+func (h *Handler) value(value string, line int) error {
+	if h.OnValue == nil {
+		return nil
+	}
+	return h.OnValue(value, line)
+}
@@ -0,0 +1,457 @@
+package nestext
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseEventsMatchesParse(t *testing.T) {
+	inputs := []string{
+		"-:: x\n",
+		"> Hello\n> World\n",
+		"{a: [x, y], b: {c: d}}\n",
+		"-\n  {a: 0}\n- [x]\n",
+		":\n  >\n",
+		"- Hello\n-\n  > World\n  > !\n-\n",
+		"a:\n  > Hello World!\nb: How are you?\nc:\n",
+		": A\n: a\n  > Hello World!\nb: How are you?\n",
+		"a:\n  - x\n  -\n    b: y\n    c:\n      - z\nd: w\n",
+		"# only a comment\n",
+	}
+	for i, input := range inputs {
+		expected, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("[%d] %v", i, err)
+		}
+		b := &treeBuilder{}
+		if err := ParseEvents(strings.NewReader(input), b.handler()); err != nil {
+			t.Errorf("[%d] %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(b.result, expected) {
+			t.Errorf("[%d] expected events to build %#v, have %#v", i, expected, b.result)
+		}
+	}
+}
+
+func TestParseEventsErrors(t *testing.T) {
+	inputs := []string{
+		"> Hello\n> World!\n: key\n",
+		"{a: x, }\n",
+		"- Hello\n  - World!\n",
+		"a:\n    b: c\n  d: e\n",
+	}
+	for i, input := range inputs {
+		err := ParseEvents(strings.NewReader(input), &Handler{})
+		if err == nil {
+			t.Errorf("[%d] expected error to occur, didn't", i)
+			continue
+		}
+		t.Logf("[%d] got expected error: %v", i, err)
+	}
+	stop := errors.New("stop")
+	calls := 0
+	err := ParseEvents(strings.NewReader("- a\n- b\n- c\n"), &Handler{
+		OnValue: func(value string, line int) error {
+			calls++
+			return stop
+		},
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("expected callback error to stop parsing, have %v after %d calls", err, calls)
+	}
+}
+
+func TestParseEventsMetadata(t *testing.T) {
+	input := "a:\r\n   - x\r\nb: y"
+	var meta, emeta Metadata
+	if _, err := Parse(strings.NewReader(input), ReportMetadata(&meta)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ParseEvents(strings.NewReader(input), &Handler{}, ReportMetadata(&emeta)); err != nil {
+		t.Fatal(err)
+	}
+	if emeta != meta || emeta.Indent != 3 {
+		t.Errorf("expected metadata %+v, have %+v", meta, emeta)
+	}
+}
+
+func TestParseEventsKeyPattern(t *testing.T) {
+	inputs := []string{
+		"a: 1\nB: 2\n",
+		"a:\n  - x\n  -\n    b: 1\n    C:\n",
+		"a:\n  : multi\n  : Key\n",
+		"a:\n  -\n    {b: 1, C: 2}\n",
+		"a:\n  - x\n  -\n    b: 1\n",
+	}
+	opt := KeyPattern(regexp.MustCompile(`^[a-z]+$`))
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input), opt)
+		eerr := ParseEvents(strings.NewReader(input), &Handler{}, opt)
+		if i == len(inputs)-1 {
+			if err != nil || eerr != nil {
+				t.Errorf("%d: expected valid keys, have %v, %v", i, err, eerr)
+			}
+			continue
+		}
+		nterr, ok := err.(NestedTextError)
+		if enterr, eok := eerr.(NestedTextError); !ok || !eok || enterr != nterr || nterr.Code != ErrCodeSchema {
+			t.Errorf("%d: expected error %v, have %v", i, err, eerr)
+		}
+	}
+}
+
+func TestParseEventsKeepBlankLines(t *testing.T) {
+	var value string
+	err := ParseEvents(strings.NewReader("a:\n  > x\n\n\n  > y\n"), &Handler{
+		OnValue: func(v string, line int) error {
+			value = v
+			return nil
+		},
+	}, KeepBlankLinesInStrings())
+	if err != nil || value != "x\n\n\ny" {
+		t.Errorf("expected blank lines to be kept, have %q, %v", value, err)
+	}
+}
+
+func TestParseEventsAutoDedent(t *testing.T) {
+	input := "  a: 1\n  b:\n    - x\n"
+	expected, err := Parse(strings.NewReader(input), AutoDedent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &treeBuilder{}
+	if err := ParseEvents(strings.NewReader(input), b.handler(), AutoDedent()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b.result, expected) {
+		t.Errorf("expected events to build %#v, have %#v", expected, b.result)
+	}
+	err = ParseEvents(strings.NewReader("  a: 1\n    b: 2\n"), &Handler{}, AutoDedent())
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Line != 2 || nterr.Column != 4 {
+		t.Errorf("expected error at [2,4] of the original input, have %v", err)
+	}
+	opts := []Option{AutoDedent(), OnControlChars(ControlReject)}
+	_, err = Parse(strings.NewReader("  a: \x07\n"), opts...)
+	eerr := ParseEvents(strings.NewReader("  a: \x07\n"), &Handler{}, opts...)
+	if nterr, ok := eerr.(NestedTextError); !ok || nterr.Column != err.(NestedTextError).Column {
+		t.Errorf("expected error %v, have %v", err, eerr)
+	}
+}
+
+func TestParseEventsExpandTabs(t *testing.T) {
+	for _, opt := range []Option{ExpandTabs(4), Strict(false)} {
+		input := "a:\n\t- x\n\t-\n\t  b: y\n"
+		expected, err := Parse(strings.NewReader(input), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := &treeBuilder{}
+		if err := ParseEvents(strings.NewReader(input), b.handler(), opt); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(b.result, expected) {
+			t.Errorf("expected events to build %#v, have %#v", expected, b.result)
+		}
+	}
+}
+
+func TestParseEventsLineBreaks(t *testing.T) {
+	inputs := []struct {
+		text string
+		opts []Option
+	}{
+		{"a: 1", []Option{RequireFinalNewline()}},
+		{"# comment", []Option{RequireFinalNewline()}},
+		{"a: 1\rb: 2\r", []Option{Strict(true)}},
+		{"a: 1\nb: 2\r\n", []Option{RequireUniformNewlines()}},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text), input.opts...)
+		eerr := ParseEvents(strings.NewReader(input.text), &Handler{}, input.opts...)
+		nterr, ok := err.(NestedTextError)
+		if enterr, eok := eerr.(NestedTextError); !ok || !eok || enterr.Code != nterr.Code || enterr.Line != nterr.Line {
+			t.Errorf("%d: expected error %v, have %v", i, err, eerr)
+		}
+	}
+	var warnings []NestedTextError
+	err := ParseEvents(strings.NewReader("a: 1\rb: 2\r"), &Handler{}, Strict(false),
+		ReportWarnings(func(w NestedTextError) { warnings = append(warnings, w) }))
+	if err != nil || len(warnings) != 1 || warnings[0].Line != 1 {
+		t.Errorf("expected warning for lone CR in line 1, have %v, %v", warnings, err)
+	}
+}
+
+func TestParseEventsSkippedLines(t *testing.T) {
+	var skipped []int
+	err := ParseEvents(strings.NewReader("# c\na:\n\n  - x\n  # d\n"), &Handler{},
+		ReportSkippedLines(func(line int, text string) { skipped = append(skipped, line) }))
+	if err != nil || !reflect.DeepEqual(skipped, []int{1, 3, 5}) {
+		t.Errorf("expected lines 1, 3 and 5 to be reported, have %v, %v", skipped, err)
+	}
+}
+
+func TestParseEventsUnsupportedOptions(t *testing.T) {
+	unsupported := map[string]Option{
+		"CaptureLayout":      CaptureLayout(&Layout{}),
+		"CaptureComments":    CaptureComments(&Comments{}),
+		"ReportPositions":    ReportPositions(&Positions{}),
+		"SelectPaths":        SelectPaths("a"),
+		"Validate":           Validate("a", func(interface{}) error { return nil }),
+		"WarnDuplicateItems": WarnDuplicateItems(),
+		"RequireKeys":        RequireKeys("a"),
+		"ContinueOnError":    ContinueOnError(),
+		"ResumeAtTopLevel":   ResumeAtTopLevel(),
+	}
+	for name, opt := range unsupported {
+		err := ParseEvents(strings.NewReader("a: 1\n"), &Handler{}, opt)
+		if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeUsage {
+			t.Errorf("%s: expected usage error, have %v", name, err)
+		}
+	}
+}
+
+func TestParseEventsNilHandler(t *testing.T) {
+	err := ParseEvents(strings.NewReader("a: 1\n"), nil)
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeUsage {
+		t.Errorf("expected usage error for nil handler, have %v", err)
+	}
+}
+
+// optionRecorder collects the output of options, to compare Parse and ParseEvents.
+type optionRecorder struct {
+	log    []string
+	finish []func() // functions logging the output stored by options
+}
+
+func (r *optionRecorder) logf(format string, args ...interface{}) {
+	r.log = append(r.log, fmt.Sprintf(format, args...))
+}
+
+// eventOptions tells how ParseEvents treats every option: it supports it like Parse
+// ("same"), ignores it ("ignored", as for options which operate on the resulting
+// tree) or rejects it ("rejected"). Every option of the package has to be listed.
+var eventOptions = map[string]struct {
+	mode  string
+	input string
+	opt   func(r *optionRecorder) Option
+}{
+	"AutoDedent": {"same", "  a: 1\n  b:\n    - x\n",
+		func(r *optionRecorder) Option { return AutoDedent() }},
+	"CaptureComments": {"rejected", "a: 1\n",
+		func(r *optionRecorder) Option { return CaptureComments(&Comments{}) }},
+	"CaptureLayout": {"rejected", "a: 1\n",
+		func(r *optionRecorder) Option { return CaptureLayout(&Layout{}) }},
+	"ContinueOnError": {"rejected", "a: 1\n",
+		func(r *optionRecorder) Option { return ContinueOnError() }},
+	"ExpandTabs": {"same", "a:\n\t- x\n",
+		func(r *optionRecorder) Option { return ExpandTabs(4) }},
+	"FieldNameMapper": {"ignored", "a: 1\n",
+		func(r *optionRecorder) Option { return FieldNameMapper(SnakeCase) }},
+	"FormatMessages": {"same", "a:\n  [x, y\n",
+		func(r *optionRecorder) Option {
+			return FormatMessages(func(e NestedTextError) string { return "custom: " + e.Message })
+		}},
+	"KeepBlankLinesInStrings": {"same", "a:\n  > x\n\n  > y\n",
+		func(r *optionRecorder) Option { return KeepBlankLinesInStrings() }},
+	"KeepLegacyBidi": {"same", "a: x\u202ey\n",
+		func(r *optionRecorder) Option { return KeepLegacyBidi(true) }},
+	"KeyPattern": {"same", "a: 1\nB: 2\n",
+		func(r *optionRecorder) Option { return KeyPattern(regexp.MustCompile(`^[a-z]+$`)) }},
+	"MaxBytes": {"same", "a: 1\nb: 2\n",
+		func(r *optionRecorder) Option { return MaxBytes(5) }},
+	"MaxDepth": {"same", "a:\n  b:\n    c: 1\n",
+		func(r *optionRecorder) Option { return MaxDepth(2) }},
+	"MaxItems": {"same", "- a\n- b\n- c\n",
+		func(r *optionRecorder) Option { return MaxItems(2) }},
+	"MaxLineLength": {"same", "a: 1\nb: 12345\n",
+		func(r *optionRecorder) Option { return MaxLineLength(5) }},
+	"MaxLines": {"same", "a: 1\nb: 2\n",
+		func(r *optionRecorder) Option { return MaxLines(1) }},
+	"NoMixedLists": {"ignored", "- a\n-\n  - b\n",
+		func(r *optionRecorder) Option { return NoMixedLists() }},
+	"OnControlChars": {"same", "a: x\x01y\n",
+		func(r *optionRecorder) Option { return OnControlChars(ControlReject) }},
+	"OrderedDicts": {"same", "b: 1\na: 2\n",
+		func(r *optionRecorder) Option { return OrderedDicts() }},
+	"ReportMetadata": {"same", "a:\r\n  b: 1\r\n",
+		func(r *optionRecorder) Option {
+			m := &Metadata{}
+			r.finish = append(r.finish, func() { r.logf("metadata %+v", *m) })
+			return ReportMetadata(m)
+		}},
+	"ReportPositions": {"rejected", "a: 1\n",
+		func(r *optionRecorder) Option { return ReportPositions(&Positions{}) }},
+	"ReportProgress": {"same", "a: 1\nb: 2\n",
+		func(r *optionRecorder) Option {
+			return ReportProgress(func(bytes int64, lines int) { r.logf("progress %d %d", bytes, lines) })
+		}},
+	"ReportSkippedLines": {"same", "# c\na: 1\n\nb: 2\n",
+		func(r *optionRecorder) Option {
+			return ReportSkippedLines(func(line int, text string) { r.logf("skipped %d %q", line, text) })
+		}},
+	"ReportWarnings": {"same", "a: x\u202ey\n",
+		func(r *optionRecorder) Option {
+			return ReportWarnings(func(w NestedTextError) { r.logf("warning %v", w) })
+		}},
+	"RequireFinalNewline": {"same", "a: 1",
+		func(r *optionRecorder) Option { return RequireFinalNewline() }},
+	"RequireKeys": {"rejected", "a: 1\n",
+		func(r *optionRecorder) Option { return RequireKeys("a") }},
+	"RequireUniformNewlines": {"same", "a: 1\r\nb: 2\n",
+		func(r *optionRecorder) Option { return RequireUniformNewlines() }},
+	"ResumeAtTopLevel": {"rejected", "a: 1\n",
+		func(r *optionRecorder) Option { return ResumeAtTopLevel() }},
+	"SelectPaths": {"rejected", "a: 1\n",
+		func(r *optionRecorder) Option { return SelectPaths("a") }},
+	"Strict": {"same", "a:\n\t- x\n",
+		func(r *optionRecorder) Option { return Strict(false) }},
+	"TopLevel": {"ignored", "a: 1\n",
+		func(r *optionRecorder) Option { return TopLevel("list") }},
+	"ValidKeys": {"same", "a: 1\nb: 2\n",
+		func(r *optionRecorder) Option { return ValidKeys(func(key string) bool { return key != "b" }) }},
+	"Validate": {"rejected", "a: 1\n",
+		func(r *optionRecorder) Option { return Validate("a", func(interface{}) error { return nil }) }},
+	"WarnDuplicateItems": {"rejected", "a: 1\n",
+		func(r *optionRecorder) Option { return WarnDuplicateItems() }},
+}
+
+// packageOptions returns the names of the exported functions of the package which
+// return an Option.
+func packageOptions(t *testing.T) []string {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range pkgs["nestext"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !fn.Name.IsExported() || fn.Type.Results == nil ||
+				len(fn.Type.Results.List) != 1 {
+				continue
+			}
+			if id, ok := fn.Type.Results.List[0].Type.(*ast.Ident); ok && id.Name == "Option" {
+				names = append(names, fn.Name.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestParseEventsOptions(t *testing.T) {
+	names := packageOptions(t)
+	if len(names) == 0 {
+		t.Fatal("expected options in package")
+	}
+	for _, name := range names {
+		if _, ok := eventOptions[name]; !ok {
+			t.Errorf("option %s is neither supported nor rejected by ParseEvents", name)
+		}
+	}
+	if len(names) != len(eventOptions) {
+		t.Errorf("expected %d options, table of ParseEvents lists %d", len(names), len(eventOptions))
+	}
+	for _, name := range names {
+		entry, ok := eventOptions[name]
+		if !ok {
+			continue
+		}
+		parsed, events := &optionRecorder{}, &optionRecorder{}
+		opts := []Option{entry.opt(parsed)}
+		if entry.mode == "ignored" {
+			opts = nil
+		}
+		expected, perr := Parse(strings.NewReader(entry.input), opts...)
+		b := &treeBuilder{}
+		eerr := ParseEvents(strings.NewReader(entry.input), b.handler(), entry.opt(events))
+		if entry.mode == "rejected" {
+			if nterr, ok := eerr.(NestedTextError); !ok || nterr.Code != ErrCodeUsage {
+				t.Errorf("%s: expected usage error, have %v", name, eerr)
+			}
+			continue
+		}
+		for _, r := range []*optionRecorder{parsed, events} {
+			for _, f := range r.finish {
+				f()
+			}
+		}
+		if fmt.Sprint(perr) != fmt.Sprint(eerr) {
+			t.Errorf("%s: expected error %v, have %v", name, perr, eerr)
+		} else if perr == nil && !Equal(b.result, expected) {
+			t.Errorf("%s: expected events to build %#v, have %#v", name, expected, b.result)
+		}
+		if entry.mode == "same" && !reflect.DeepEqual(parsed.log, events.log) {
+			t.Errorf("%s: expected output %q, have %q", name, parsed.log, events.log)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+// treeBuilder re-assembles a tree of values from parser events.
+type treeBuilder struct {
+	result interface{}
+	stack  []interface{} // open lists (*[]interface{}) and dicts
+	keys   []string      // pending dict keys
+}
+
+func (b *treeBuilder) add(v interface{}) {
+	if len(b.stack) == 0 {
+		b.result = v
+		return
+	}
+	switch c := b.stack[len(b.stack)-1].(type) {
+	case *[]interface{}:
+		*c = append(*c, v)
+	case map[string]interface{}:
+		c[b.keys[len(b.keys)-1]] = v
+		b.keys = b.keys[:len(b.keys)-1]
+	}
+}
+
+func (b *treeBuilder) handler() *Handler {
+	return &Handler{
+		OnDictStart: func(line int) error {
+			b.stack = append(b.stack, map[string]interface{}{})
+			return nil
+		},
+		OnListStart: func(line int) error {
+			b.stack = append(b.stack, &[]interface{}{})
+			return nil
+		},
+		OnDictEnd: func() error { return b.close() },
+		OnListEnd: func() error { return b.close() },
+		OnKey: func(key string, line int) error {
+			b.keys = append(b.keys, key)
+			return nil
+		},
+		OnValue: func(value string, line int) error {
+			b.add(value)
+			return nil
+		},
+	}
+}
+
+func (b *treeBuilder) close() error {
+	top := b.stack[len(b.stack)-1]
+	b.stack = b.stack[:len(b.stack)-1]
+	if l, ok := top.(*[]interface{}); ok {
+		top = *l
+	}
+	b.add(top)
+	return nil
+}
@@ -1,6 +1,7 @@
 package nestext_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -32,3 +33,23 @@ additional roles:
 	// Output:
 	// Katheryn's e-mail: KateMcD@aol.com
 }
+
+func ExampleParseSnippet() {
+	result, err := nestext.ParseSnippet(`
+    server:
+      port: 8080`)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%v\n", result)
+	// Output:
+	// map[server:map[port:8080]]
+}
+
+func ExampleNestedTextError_MarshalJSON() {
+	_, err := nestext.Parse(strings.NewReader("name: Katheryn\n- board member\n"))
+	data, _ := json.Marshal(err)
+	fmt.Println(string(data))
+	// Output:
+	// {"code":204,"severity":"error","message":"unused content following valid input","line":2,"column":0,"endLine":3,"offset":15,"endOffset":30}
+}
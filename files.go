@@ -0,0 +1,60 @@
+package nestext
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// --- Resource ownership ----------------------------------------------------
+
+// Parse and ParseContext never close their input, even if it implements io.Closer:
+// the caller keeps ownership of the reader. The functions in this section take over
+// ownership, which is convenient for files and response bodies in short programs.
+
+// ParseAndClose is like Parse, but closes rc after parsing, in any case. If parsing
+// succeeds, but closing fails, an error of code ErrCodeIO is returned, together
+// with the result.
+//
+// Use as:
+//     resp, err := http.Get(url)
+//     …
+//     config, err := nestext.ParseAndClose(resp.Body)
+//
+func ParseAndClose(rc io.ReadCloser, opts ...Option) (result interface{}, err error) {
+	defer func() {
+		if e := rc.Close(); e != nil && err == nil {
+			err = WrapError(ErrCodeIO, "I/O error while closing input", e)
+		}
+	}()
+	return Parse(rc, opts...)
+}
+
+// ParseFile opens the file at `path`, parses it and closes it. If the file cannot be
+// opened, an error of code ErrCodeIO is returned.
+//
+// Use as:
+//     config, err := nestext.ParseFile("config.nt")
+//
+func ParseFile(path string, opts ...Option) (interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, WrapError(ErrCodeIO, "cannot open input: "+err.Error(), err)
+	}
+	return ParseAndClose(f, opts...)
+}
+
+// ParseFileContext is like ParseFile, but aborts as soon as ctx is canceled or its
+// deadline expires (see ParseContext). The file is closed in any case.
+func ParseFileContext(ctx context.Context, path string, opts ...Option) (result interface{}, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, WrapError(ErrCodeIO, "cannot open input: "+err.Error(), err)
+	}
+	defer func() {
+		if e := f.Close(); e != nil && err == nil {
+			err = WrapError(ErrCodeIO, "I/O error while closing input", e)
+		}
+	}()
+	return ParseContext(ctx, f, opts...)
+}
@@ -0,0 +1,62 @@
+package nestext
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+	err    error
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return c.err
+}
+
+func TestParseAndClose(t *testing.T) {
+	rc := &closeRecorder{Reader: strings.NewReader("a: b\n")}
+	if result, err := ParseAndClose(rc); err != nil || result.(map[string]interface{})["a"] != "b" {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+	if !rc.closed {
+		t.Errorf("expected input to be closed")
+	}
+	rc = &closeRecorder{Reader: strings.NewReader("a: b\n"), err: errors.New("close failed")}
+	if _, err := ParseAndClose(rc); err == nil || err.(NestedTextError).Code != ErrCodeIO {
+		t.Errorf("expected close error to be reported, have %v", err)
+	}
+	rc = &closeRecorder{Reader: strings.NewReader("- a\n  - b\n")}
+	if _, err := ParseAndClose(rc); err == nil || !rc.closed {
+		t.Errorf("expected input to be closed after parse error, have %v", err)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nestext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.nt")
+	if err = ioutil.WriteFile(path, []byte("port: 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := ParseFile(path); err != nil || result.(map[string]interface{})["port"] != "8080" {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+	if result, err := ParseFileContext(context.Background(), path); err != nil || result == nil {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+	_, err = ParseFile(filepath.Join(dir, "missing.nt"))
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeIO || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected error of code ErrCodeIO wrapping os.ErrNotExist, have %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+//go:build go1.18
+// +build go1.18
+
+package nestext
+
+import (
+	"strings"
+	"testing"
+)
+
+var fuzzSeeds = []string{
+	"a: 1\nb:\n  - x\n  - y\n",
+	"- a\n-\n  > multi\n  > line\n",
+	"key:\n  : multi-line\n  : key\n    > value\n",
+	"[a, [b, c], {d: e}]\n",
+	"{a: [1, 2], b: {}}\n",
+	"# comment\n\n> text\n",
+	"a:\n  - b: 1\n  c: 2\n",
+	"- x\nkey: value\n",
+	"a: 1\n   b: 2\n",
+	"[a, b\n",
+	"{a: b, a: c}\n",
+	"\t- tab\n",
+	"  a: 1\n  b:\n    - x\n",
+	": \n> \n- \n",
+}
+
+// fuzzOptions are the options FuzzParse combines, selected by the bits of a fuzzed
+// byte.
+var fuzzOptions = []Option{
+	ContinueOnError(),
+	ResumeAtTopLevel(),
+	NoMixedLists(),
+	WarnDuplicateItems(),
+	ReportWarnings(func(NestedTextError) {}),
+	AutoDedent(),
+	MaxDepth(5),
+	OrderedDicts(),
+}
+
+// fuzzCrashers are inputs which made the parser panic, with the options selected.
+var fuzzCrashers = []struct {
+	input   string
+	options uint8
+}{
+	{"- a\n-\n  \t> x\n", 1<<1 | 1<<2}, // ResumeAtTopLevel, NoMixedLists
+}
+
+// FuzzParse checks that the parser does not panic on malformed input, with any
+// combination of options, and that every error it returns is a NestedTextError.
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed, uint8(0))
+		f.Add(seed, uint8(0xff))
+		f.Add(seed, uint8(1<<0|1<<2|1<<3|1<<4))
+	}
+	for _, crasher := range fuzzCrashers {
+		f.Add(crasher.input, crasher.options)
+	}
+	f.Fuzz(func(t *testing.T, input string, options uint8) {
+		var opts []Option
+		for i, opt := range fuzzOptions {
+			if options&(1<<i) != 0 {
+				opts = append(opts, opt)
+			}
+		}
+		_, err := Parse(strings.NewReader(input), opts...)
+		checkFuzzError(t, err)
+		err = ParseEvents(strings.NewReader(input), &Handler{})
+		checkFuzzError(t, err)
+	})
+}
+
+func checkFuzzError(t *testing.T, err error) {
+	if err == nil {
+		return
+	}
+	switch err.(type) {
+	case NestedTextError, ErrorList:
+	default:
+		t.Errorf("expected error of type NestedTextError, have %T: %v", err, err)
+	}
+}
@@ -0,0 +1,224 @@
+package nestext
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// --- Includes --------------------------------------------------------------
+
+// IncludeKey is the dict key marking includes. Its value is a reference to a document,
+// or a list of references. The documents referenced have to be dicts; their items are
+// merged into the dict containing the include entry (see ResolveIncludes):
+//
+//     !include: common/database.nt
+//     database:
+//       name: orders
+//
+const IncludeKey = "!include"
+
+// Resolver retrieves the documents referenced by includes. The meaning of references
+// is up to the resolver: FSResolver treats them as paths in a file system, while the
+// HTTP resolver of package ntremote treats them as URLs. Clients take over ownership
+// of the readers returned and will close them.
+type Resolver interface {
+	Resolve(ref string) (io.ReadCloser, error)
+}
+
+// ResolverFunc is an adapter to use a function as a Resolver.
+type ResolverFunc func(ref string) (io.ReadCloser, error)
+
+// Resolve calls f(ref).
+func (f ResolverFunc) Resolve(ref string) (io.ReadCloser, error) {
+	return f(ref)
+}
+
+// FSResolver returns a resolver treating references as slash-separated paths in file
+// system `fsys`. Leading slashes are ignored, thus references cannot escape `fsys`.
+//
+// Use as:
+//     err := nestext.ResolveIncludes(config, nestext.FSResolver(os.DirFS("/etc/app")))
+//
+func FSResolver(fsys fs.FS) Resolver {
+	return ResolverFunc(func(ref string) (io.ReadCloser, error) {
+		return fsys.Open(strings.TrimLeft(ref, "/"))
+	})
+}
+
+// CachingResolver returns a resolver retrieving every reference from `r` only once and
+// holding the documents in memory thereafter. It is safe for concurrent use if `r` is.
+// Failed retrievals are not cached.
+func CachingResolver(r Resolver) Resolver {
+	c := &cachingResolver{resolver: r, docs: make(map[string][]byte)}
+	return ResolverFunc(c.resolve)
+}
+
+type cachingResolver struct {
+	resolver Resolver
+	mx       sync.Mutex
+	docs     map[string][]byte
+}
+
+func (c *cachingResolver) resolve(ref string) (io.ReadCloser, error) {
+	c.mx.Lock()
+	doc, ok := c.docs[ref]
+	c.mx.Unlock()
+	if ok {
+		return ioutil.NopCloser(bytes.NewReader(doc)), nil
+	}
+	rc, err := c.resolver.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	if doc, err = ioutil.ReadAll(rc); err != nil {
+		return nil, err
+	}
+	c.mx.Lock()
+	c.docs[ref] = doc
+	c.mx.Unlock()
+	return ioutil.NopCloser(bytes.NewReader(doc)), nil
+}
+
+// ResolveIncludes replaces the include entries (see IncludeKey) of the dicts of `tree`
+// by the items of the documents referenced, modifying `tree` in place. Documents are
+// retrieved from `r` and parsed with options `opts`; includes within them are resolved
+// as well. Items of the including dict take precedence over included items, and items
+// of later references over those of earlier ones. Nested dicts are merged recursively.
+//
+// Use as:
+//     config, err := nestext.ParseFile("app.nt")
+//     …
+//     err = nestext.ResolveIncludes(config, nestext.FSResolver(os.DirFS("conf")))
+//
+// If a non-nil error is returned, it will be of type NestedTextError. Errors retrieving
+// documents are reported with code ErrCodeIO, references which are not strings,
+// documents which are not dicts and cyclic includes with code ErrCodeSchema.
+func ResolveIncludes(tree interface{}, r Resolver, opts ...Option) error {
+	if r == nil {
+		return MakeNestedTextError(ErrCodeUsage, "resolver for includes is nil")
+	}
+	inc := includer{resolver: r, opts: opts}
+	return inc.resolveTree(tree)
+}
+
+// includer resolves the includes of a tree.
+type includer struct {
+	resolver Resolver
+	opts     []Option
+	active   []string // references currently being resolved, to detect cycles
+}
+
+// resolveTree resolves the includes of all dicts of `tree`.
+func (inc *includer) resolveTree(tree interface{}) error {
+	if list, ok := tree.([]interface{}); ok {
+		for _, item := range list {
+			if err := inc.resolveTree(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !isDict(tree) {
+		return nil
+	}
+	if value, ok := childAt(tree, PathSegment{Key: IncludeKey}); ok {
+		if err := inc.include(tree, value); err != nil {
+			return err
+		}
+	}
+	for _, key := range dictKeys(tree) {
+		value, _ := childAt(tree, PathSegment{Key: key})
+		if err := inc.resolveTree(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// include replaces the include entry of `dict`, with value `value`, by the items of
+// the documents referenced.
+func (inc *includer) include(dict, value interface{}) error {
+	var refs []string
+	switch v := value.(type) {
+	case string:
+		refs = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			ref, ok := item.(string)
+			if !ok {
+				return MakeNestedTextError(ErrCodeSchema, "include reference is not a string")
+			}
+			refs = append(refs, ref)
+		}
+	default:
+		return MakeNestedTextError(ErrCodeSchema, "include reference is not a string")
+	}
+	switch d := dict.(type) {
+	case map[string]interface{}:
+		delete(d, IncludeKey)
+	case *OrderedMap:
+		d.Delete(IncludeKey)
+	}
+	included := map[string]interface{}{}
+	for _, ref := range refs {
+		doc, err := inc.load(ref)
+		if err != nil {
+			return err
+		}
+		mergeDicts(included, doc)
+	}
+	mergeMissing(dict, included)
+	return nil
+}
+
+// load retrieves and parses the document referenced by `ref`, including its includes.
+func (inc *includer) load(ref string) (interface{}, error) {
+	for _, active := range inc.active {
+		if active == ref {
+			return nil, MakeNestedTextError(ErrCodeSchema, "cyclic include of "+ref)
+		}
+	}
+	rc, err := inc.resolver.Resolve(ref)
+	if err != nil {
+		return nil, WrapError(ErrCodeIO, "cannot include "+ref+": "+err.Error(), err)
+	}
+	doc, err := ParseAndClose(rc, inc.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !isDict(doc) {
+		return nil, MakeNestedTextError(ErrCodeSchema, "included document "+ref+" is not a dict")
+	}
+	inc.active = append(inc.active, ref)
+	defer func() { inc.active = inc.active[:len(inc.active)-1] }()
+	if err := inc.resolveTree(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// mergeMissing merges the items of dict `src` missing from dict `dst` into `dst`,
+// recursively.
+func mergeMissing(dst, src interface{}) {
+	for _, key := range dictKeys(src) {
+		value, _ := childAt(src, PathSegment{Key: key})
+		target, ok := childAt(dst, PathSegment{Key: key})
+		if ok {
+			if isDict(target) && isDict(value) {
+				mergeMissing(target, value)
+			}
+			continue
+		}
+		switch d := dst.(type) {
+		case map[string]interface{}:
+			d[key] = value
+		case *OrderedMap:
+			d.Set(key, value)
+		}
+	}
+}
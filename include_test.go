@@ -0,0 +1,58 @@
+package nestext
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestResolveIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"common/db.nt":  {Data: []byte("database:\n  host: db\n  port: 5432\n")},
+		"common/log.nt": {Data: []byte("!include: /common/db.nt\nlog: info\n")},
+		"cycle.nt":      {Data: []byte("!include: cycle.nt\n")},
+		"list.nt":       {Data: []byte("- a\n")},
+	}
+	config, _ := Parse(strings.NewReader(
+		"!include:\n  - common/log.nt\nlog: debug\ndatabase:\n  port: 6543\nservices:\n  -\n    !include: common/db.nt\n"))
+	if err := ResolveIncludes(config, FSResolver(fsys)); err != nil {
+		t.Fatal(err)
+	}
+	db := map[string]interface{}{"host": "db", "port": "5432"}
+	expected := map[string]interface{}{
+		"log":      "debug",
+		"database": map[string]interface{}{"host": "db", "port": "6543"},
+		"services": []interface{}{map[string]interface{}{"database": db}},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %v, have %v", expected, config)
+	}
+	for _, ref := range []string{"cycle.nt", "list.nt", "missing.nt"} {
+		tree := map[string]interface{}{IncludeKey: ref}
+		if err := ResolveIncludes(tree, FSResolver(fsys)); err == nil {
+			t.Errorf("expected include of %s to fail", ref)
+		}
+	}
+}
+
+func TestCachingResolver(t *testing.T) {
+	calls := 0
+	r := CachingResolver(ResolverFunc(func(ref string) (io.ReadCloser, error) {
+		calls++
+		return io.NopCloser(strings.NewReader("a: " + ref + "\n")), nil
+	}))
+	for i := 0; i < 2; i++ {
+		tree := map[string]interface{}{"x": map[string]interface{}{IncludeKey: "1"}}
+		if err := ResolveIncludes(tree, r); err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := Get(tree, "x.a"); v != "1" {
+			t.Errorf("expected x.a = 1, have %v", v)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected document to be retrieved once, was retrieved %d times", calls)
+	}
+}
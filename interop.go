@@ -0,0 +1,72 @@
+package nestext
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// --- Interoperability with JSON and YAML unmarshalers ----------------------
+
+// YAMLUnmarshaler is the interface implemented by types which unmarshal themselves
+// with package gopkg.in/yaml.v2. If Decoder.UseForeignUnmarshalers has been called,
+// the NestedText decoder will call UnmarshalYAML with a function decoding the
+// NestedText value of the type into an arbitrary Go value.
+//
+// Unmarshalers for gopkg.in/yaml.v3, working on yaml.Node, are not supported.
+//
+type YAMLUnmarshaler interface {
+	UnmarshalYAML(unmarshal func(interface{}) error) error
+}
+
+var yamlUnmarshalerType = reflect.TypeOf((*YAMLUnmarshaler)(nil)).Elem()
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// decodeForeign initializes v with a YAML or JSON unmarshaler, if v implements one.
+// It returns false if v implements neither of them.
+//
+// For JSON, the NestedText value is converted to JSON with strings as JSON strings.
+// If UnmarshalJSON rejects a string, but the string is a valid JSON number or literal
+// (e.g. "42" or "true"), the string is passed on as this literal.
+//
+func (d valueDecoder) decodeForeign(tree interface{}, v reflect.Value, path string) (bool, error) {
+	if !v.CanAddr() {
+		return false, nil
+	}
+	ptr := v.Addr()
+	if ptr.Type().Implements(yamlUnmarshalerType) {
+		err := ptr.Interface().(YAMLUnmarshaler).UnmarshalYAML(func(target interface{}) error {
+			return unmarshalTree(tree, target, d)
+		})
+		if err != nil {
+			return true, d.error(path, err.Error(), err)
+		}
+		return true, nil
+	}
+	if ptr.Type().Implements(jsonUnmarshalerType) {
+		u := ptr.Interface().(json.Unmarshaler)
+		data, err := json.Marshal(tree)
+		if err == nil {
+			err = u.UnmarshalJSON(data)
+		}
+		if s, ok := tree.(string); ok && err != nil && isJSONLiteral(s) {
+			err = u.UnmarshalJSON([]byte(s))
+		}
+		if err != nil {
+			return true, d.error(path, err.Error(), err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// isJSONLiteral is true for JSON numbers, booleans and null.
+func isJSONLiteral(s string) bool {
+	if s == "" || !json.Valid([]byte(s)) {
+		return false
+	}
+	switch s[0] {
+	case '"', '[', '{', ' ', '\t', '\n', '\r':
+		return false
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package nestext
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// jsonLevel implements json.Unmarshaler for JSON numbers.
+type jsonLevel int
+
+func (l *jsonLevel) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*l = jsonLevel(n)
+	return nil
+}
+
+// jsonPair implements json.Unmarshaler for a JSON list of two strings.
+type jsonPair struct {
+	First, Second string
+}
+
+func (p *jsonPair) UnmarshalJSON(data []byte) error {
+	var s []string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if len(s) != 2 {
+		return fmt.Errorf("expected 2 items, have %d", len(s))
+	}
+	p.First, p.Second = s[0], s[1]
+	return nil
+}
+
+// yamlUser implements a yaml.v2-style unmarshaler.
+type yamlUser struct {
+	Name  string
+	Admin bool
+}
+
+func (u *yamlUser) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw struct {
+		Name string `nt:"name"`
+		Role string `nt:"role"`
+	}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	u.Name, u.Admin = raw.Name, raw.Role == "admin"
+	return nil
+}
+
+func TestDecoderForeignUnmarshalers(t *testing.T) {
+	input := `
+level: 3
+pair:
+  - a
+  - b
+user:
+  name: Alice
+  role: admin
+`
+	var v struct {
+		Level jsonLevel `nt:"level"`
+		Pair  jsonPair  `nt:"pair"`
+		User  yamlUser  `nt:"user"`
+	}
+	dec := NewDecoder(strings.NewReader(input))
+	dec.UseForeignUnmarshalers()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Level != 3 || v.Pair.Second != "b" || v.User.Name != "Alice" || !v.User.Admin {
+		t.Errorf("unexpected decoding result %+v", v)
+	}
+	dec = NewDecoder(strings.NewReader("level: x\n"))
+	dec.UseForeignUnmarshalers()
+	err := dec.Decode(&v)
+	t.Logf("error = %v", err)
+	if err == nil || !strings.Contains(err.Error(), "level") {
+		t.Errorf("expected error for level, have %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package nestext
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// --- Converting to JSON streams --------------------------------------------
+
+// ToJSONStream converts a NestedText document read from `r` to JSON, writing it to `w`.
+// The document is processed with ParseEvents, without building up a tree in memory.
+// This allows piping large datasets into systems which accept JSON only.
+//
+// Keys of dicts keep the order of the document. Different from Parse, which keeps the
+// last value of a duplicate key, a duplicate key is reported as an error, in block
+// dicts as well as in inline dicts, as the value of the first occurrence has been
+// written already. For this, the keys of all open dicts are held in memory, thus
+// memory usage does not depend on the length of the document, but on its nesting depth
+// and on the count of keys of the dicts enclosing the current item. Lists of any
+// length and dicts with few keys are converted in constant memory.
+//
+// The JSON value is written in compact form, followed by a line break; an empty
+// document results in `null`. Parser options `opts` are applied as described for
+// ParseEvents. In case of an error, the JSON written so far is incomplete.
+//
+// Use as:
+//     err := nestext.ToJSONStream(bigFile, os.Stdout, nestext.MaxDepth(64))
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func ToJSONStream(r io.Reader, w io.Writer, opts ...Option) error {
+	bw := bufio.NewWriter(w)
+	js := &jsonWriter{w: bw}
+	js.enc = json.NewEncoder(&js.buf)
+	js.enc.SetEscapeHTML(false)
+	h := &Handler{
+		OnDictStart: func(int) error { return js.open('{', map[string]bool{}) },
+		OnDictEnd:   func() error { return js.close('}') },
+		OnListStart: func(int) error { return js.open('[', nil) },
+		OnListEnd:   func() error { return js.close(']') },
+		OnKey: func(key string, line int) error {
+			keys := js.keys[len(js.keys)-1]
+			if keys[key] {
+				nterr := MakeNestedTextError(ErrCodeFormat, "duplicate key: "+key)
+				nterr.Line = line
+				return nterr
+			}
+			keys[key] = true
+			if err := js.separate(); err != nil {
+				return err
+			}
+			if err := js.str(key); err != nil {
+				return err
+			}
+			return bw.WriteByte(':')
+		},
+		OnListItem: func(int, int) error { return js.separate() },
+		OnValue:    func(value string, line int) error { return js.str(value) },
+	}
+	// keep the order of inline dicts, and reject their duplicate keys
+	opts = append(opts[:len(opts):len(opts)], OrderedDicts(), uniqueInlineKeys())
+	err := ParseEvents(r, h, opts...)
+	if _, ok := err.(NestedTextError); !ok && err != nil {
+		err = WrapError(ErrCodeIO, "write error during conversion to JSON", err)
+	}
+	if err == nil && !js.written {
+		_, err = bw.WriteString("null")
+	}
+	if err == nil {
+		err = bw.WriteByte('\n')
+	}
+	if ferr := bw.Flush(); err == nil && ferr != nil {
+		err = WrapError(ErrCodeIO, "write error during conversion to JSON", ferr)
+	}
+	return err
+}
+
+// jsonWriter writes the JSON representation of parser events.
+type jsonWriter struct {
+	w       *bufio.Writer
+	buf     bytes.Buffer      // buffer for encoding strings
+	enc     *json.Encoder     // encoder for strings, writing to buf
+	counts  []int             // count of items written, for every open list and dict
+	keys    []map[string]bool // keys written, for every open dict; nil for lists
+	written bool              // has a value been written?
+}
+
+func (js *jsonWriter) open(delim byte, keys map[string]bool) error {
+	js.written = true
+	js.counts = append(js.counts, 0)
+	js.keys = append(js.keys, keys)
+	return js.w.WriteByte(delim)
+}
+
+func (js *jsonWriter) close(delim byte) error {
+	js.counts = js.counts[:len(js.counts)-1]
+	js.keys = js.keys[:len(js.keys)-1]
+	return js.w.WriteByte(delim)
+}
+
+// separate writes a comma preceding every item but the first of a list or dict.
+func (js *jsonWriter) separate() error {
+	n := len(js.counts) - 1
+	js.counts[n]++
+	if js.counts[n] > 1 {
+		return js.w.WriteByte(',')
+	}
+	return nil
+}
+
+func (js *jsonWriter) str(s string) error {
+	js.written = true
+	js.buf.Reset()
+	if err := js.enc.Encode(s); err != nil {
+		return err
+	}
+	_, err := js.w.Write(bytes.TrimSuffix(js.buf.Bytes(), []byte{'\n'}))
+	return err
+}
+
+// uniqueInlineKeys makes the parser reject duplicate keys of inline dicts.
+func uniqueInlineKeys() Option {
+	return func(p *nestedTextParser) (err error) {
+		p.inline.uniqueKeys = true
+		return nil
+	}
+}
@@ -0,0 +1,55 @@
+package nestext
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestToJSONStream(t *testing.T) {
+	inputs := []struct {
+		text, json string
+	}{
+		{"b: 1\na:\n  - x <y>\n  -\n    > multi\n    > line\n  -\n    [1, [], {z: 2, y: 3}]\n  -\n  -\n    {}\n",
+			`{"b":"1","a":["x <y>","multi\nline",["1",[],{"z":"2","y":"3"}],"",{}]}` + "\n"},
+		{"> text\n", `"text"` + "\n"},
+		{"# nothing\n", "null\n"},
+		{": multi\n: key\n", `{"multi\nkey":""}` + "\n"},
+	}
+	for i, input := range inputs {
+		var out strings.Builder
+		if err := ToJSONStream(strings.NewReader(input.text), &out); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if out.String() != input.json {
+			t.Errorf("%d: expected %s, have %s", i, input.json, out.String())
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(out.String()), &v); err != nil {
+			t.Errorf("%d: invalid JSON: %v", i, err)
+		}
+	}
+	err := ToJSONStream(strings.NewReader("a: 1\n  b: 2\n"), &strings.Builder{})
+	if _, ok := err.(NestedTextError); !ok {
+		t.Errorf("expected error of type NestedTextError, have %v", err)
+	}
+}
+
+func TestToJSONStreamDuplicateKeys(t *testing.T) {
+	var out strings.Builder
+	err := ToJSONStream(strings.NewReader("a: 1\nb:\n  a: 1\nc:\n  a: 1\n"), &out)
+	if err != nil || out.String() != `{"a":"1","b":{"a":"1"},"c":{"a":"1"}}`+"\n" {
+		t.Errorf("expected keys of nested dicts to be independent, have %v, %s", err, out.String())
+	}
+	err = ToJSONStream(strings.NewReader("a: 1\nb: 2\na: 3\n"), &strings.Builder{})
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeFormat || nterr.Line != 3 {
+		t.Errorf("expected error for duplicate key in line 3, have %v", err)
+	}
+	err = ToJSONStream(strings.NewReader("a: 1\nb:\n  {x: 1, y: [2], x: 3}\n"), &strings.Builder{})
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeFormat || nterr.Line != 3 {
+		t.Errorf("expected error for duplicate key of inline dict in line 3, have %v", err)
+	}
+	if tree, err := Parse(strings.NewReader("{x: 1, x: 3}\n")); err != nil || tree.(map[string]interface{})["x"] != "3" {
+		t.Errorf("expected Parse to keep the last value of a duplicate key, have %v, %v", tree, err)
+	}
+}
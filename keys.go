@@ -0,0 +1,149 @@
+package nestext
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// --- Key validation --------------------------------------------------------
+
+// KeyPattern requires every dict key of a document, including keys of inline dicts, to
+// match regular expression `re`. The first key violating the pattern results in an
+// error of code ErrCodeSchema, reporting the line and the path of the key (see
+// AppendPath).
+//
+// Use as:
+//     nestext.Parse(reader, nestext.KeyPattern(regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)))
+//
+// Note that the pattern is not anchored implicitly.
+//
+func KeyPattern(re *regexp.Regexp) Option {
+	return func(p *nestedTextParser) (err error) {
+		if re == nil {
+			return MakeNestedTextError(ErrCodeUsage, "option KeyPattern requires a regular expression")
+		}
+		p.validKey = re.MatchString
+		p.keyRule = "does not match pattern " + re.String()
+		return nil
+	}
+}
+
+// ValidKeys requires every dict key of a document to satisfy predicate `valid`, like
+// KeyPattern does for a regular expression.
+func ValidKeys(valid func(key string) bool) Option {
+	return func(p *nestedTextParser) (err error) {
+		if valid == nil {
+			return MakeNestedTextError(ErrCodeUsage, "option ValidKeys requires a predicate")
+		}
+		p.validKey = valid
+		p.keyRule = "is not valid"
+		return nil
+	}
+}
+
+// RequireKeys requires the document to contain items at path expressions `paths` (see
+// ParsePath), usually top-level keys. Paths may address nested items, but may not
+// contain wildcards or globs. The check is done after the document has been parsed
+// and before the TopLevel option is applied. If any of the items is missing, the
+// error is of code ErrCodeSchema, listing all the missing paths:
+//
+//     config, err := nestext.Parse(reader, nestext.RequireKeys("server", "logging.level"))
+//     // error: missing required keys: server, logging.level
+//
+// The option may be given more than once. ParseEvents does not support this option.
+//
+func RequireKeys(paths ...string) Option {
+	return func(p *nestedTextParser) (err error) {
+		for _, path := range paths {
+			segments, err := accessPath(path)
+			if err != nil {
+				return err
+			}
+			if len(segments) == 0 {
+				return MakeNestedTextError(ErrCodeUsage, "option RequireKeys requires non-empty paths")
+			}
+			p.required = append(p.required, path)
+		}
+		return nil
+	}
+}
+
+// checkRequired checks the document `tree` for the paths required by RequireKeys.
+func (p *nestedTextParser) checkRequired(tree interface{}) error {
+	var missing []string
+	for _, path := range p.required {
+		if _, err := Get(tree, path); err != nil {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	what := "key"
+	if len(missing) > 1 {
+		what = "keys"
+	}
+	return MakeNestedTextError(ErrCodeSchema,
+		fmt.Sprintf("missing required %s: %s", what, strings.Join(missing, ", ")))
+}
+
+// checkKey checks a key of the current container, found at `line` and `indent`.
+func (p *nestedTextParser) checkKey(key string, line, indent int) error {
+	if p.validKey == nil || p.validKey(key) {
+		return nil
+	}
+	return p.keyError(AppendPath(p.path, key), key, line, indent)
+}
+
+// checkInlineKeys checks the keys of an inline list or dict at `line`, which is the
+// value of the current container.
+func (p *nestedTextParser) checkInlineKeys(tree interface{}, path string, line int) error {
+	if p.validKey == nil {
+		return nil
+	}
+	switch t := tree.(type) {
+	case []interface{}:
+		for i, item := range t {
+			if err := p.checkInlineKeys(item, AppendPath(path, fmt.Sprint(i)), line); err != nil {
+				return err
+			}
+		}
+	default:
+		d, ok := asDict(tree)
+		if !ok {
+			return nil
+		}
+		keys := keysOf(tree)
+		for _, k := range keys {
+			if !p.validKey(k) {
+				return p.keyError(AppendPath(path, k), k, line, 0)
+			}
+			if err := p.checkInlineKeys(d[k], AppendPath(path, k), line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *nestedTextParser) keyError(path, key string, line, indent int) error {
+	t := parserToken{LineNo: line, ColNo: indent}
+	return makeParsingError(&t, ErrCodeSchema, fmt.Sprintf("%s: key %q %s", path, key, p.keyRule))
+}
+
+// keysOf returns the keys of a dict, in insertion order for ordered maps and sorted
+// otherwise.
+func keysOf(tree interface{}) []string {
+	if m, ok := tree.(*OrderedMap); ok {
+		return m.Keys()
+	}
+	d, _ := asDict(tree)
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,74 @@
+package nestext
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyPattern(t *testing.T) {
+	pattern := KeyPattern(regexp.MustCompile(`^[a-z_][a-z0-9_]*$`))
+	inputs := []struct {
+		text string
+		line int    // line of the error, 0 for no error
+		path string // path of the offending key
+	}{
+		{"a: 1\nb_2:\n  - c: x\n", 0, ""},
+		{"a: 1\nB: 2\n", 2, "/B"},
+		{"a:\n  - x\n  -\n    Key: y\n", 4, "/a/1/Key"},
+		{"a:\n  b:\n    : multi\n    : line\n", 3, "/a/b/multi\nline"},
+		{"a:\n  {x: 1, y: {Z: 2}}\n", 2, "/a/y/Z"},
+		{"[a, {b: 1, C: 2}]\n", 1, "/1/C"},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text), pattern)
+		if input.line == 0 {
+			if err != nil {
+				t.Errorf("%d: expected no error, have %v", i, err)
+			}
+			continue
+		}
+		nterr, ok := err.(NestedTextError)
+		if !ok || nterr.Code != ErrCodeSchema || nterr.Line != input.line ||
+			!strings.Contains(nterr.Error(), "] "+input.path+": key") {
+			t.Errorf("%d: expected error for key %s at line %d, have %v", i, input.path, input.line, err)
+		}
+	}
+}
+
+func TestParseValidKeys(t *testing.T) {
+	valid := ValidKeys(func(key string) bool { return key != "forbidden" })
+	if _, err := Parse(strings.NewReader("a:\n  forbidden: x\n"), valid); err == nil {
+		t.Error("expected key 'forbidden' to be rejected; wasn't")
+	}
+	if _, err := Parse(strings.NewReader("a:\n  allowed: x\n"), valid, OrderedDicts()); err != nil {
+		t.Errorf("expected no error, have %v", err)
+	}
+	if _, err := Parse(strings.NewReader("a: 1\n"), ValidKeys(nil)); err == nil {
+		t.Error("expected nil predicate to produce an error; didn't")
+	}
+}
+
+func TestParseRequireKeys(t *testing.T) {
+	input := "server:\n  host: localhost\nlogging: off\n"
+	if _, err := Parse(strings.NewReader(input), RequireKeys("server", "logging", "server.host")); err != nil {
+		t.Errorf("expected no error, have %v", err)
+	}
+	_, err := Parse(strings.NewReader(input), RequireKeys("server", "database", "server.port"), RequireKeys("cache"))
+	nterr, ok := err.(NestedTextError)
+	if !ok || nterr.Code != ErrCodeSchema ||
+		!strings.HasSuffix(nterr.Error(), "missing required keys: database, server.port, cache") {
+		t.Errorf("expected error listing missing keys, have %v", err)
+	}
+	if _, err := Parse(strings.NewReader(""), RequireKeys("server")); err == nil {
+		t.Error("expected empty document to miss key 'server'; didn't")
+	}
+	if _, err := Parse(strings.NewReader("a: 1\n"), RequireKeys("a"), TopLevel("dict.config")); err != nil {
+		t.Errorf("expected paths to be relative to the document, have %v", err)
+	}
+	for _, bad := range []string{"", "servers.*"} {
+		if _, err := Parse(strings.NewReader(input), RequireKeys(bad)); err == nil {
+			t.Errorf("expected path %q to be rejected; wasn't", bad)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package nestext
+
+import (
+	"fmt"
+	"io"
+)
+
+// --- Input limits ----------------------------------------------------------
+
+// Applications accepting NestedText from untrusted sources, e.g. uploads, should limit
+// the resources a document may claim. Documents exceeding a limit result in an error of
+// code ErrCodeTooLarge. See also option MaxDepth.
+
+// MaxBytes limits the size of the input to `n` bytes. Reading stops as soon as the
+// limit is exceeded, thus larger inputs are never read completely. A limit of 0 (the
+// default) means no limit.
+//
+// Use as:
+//     nestext.Parse(upload, nestext.MaxBytes(1 << 20), nestext.MaxLines(10000))
+//
+func MaxBytes(n int64) Option {
+	return func(p *nestedTextParser) (err error) {
+		if n < 0 {
+			return MakeNestedTextError(ErrCodeUsage, "option MaxBytes requires a size >= 0")
+		}
+		p.limits.bytes = n
+		return nil
+	}
+}
+
+// MaxLines limits the input to `n` lines, including blank lines and comment lines.
+// A limit of 0 (the default) means no limit.
+func MaxLines(n int) Option {
+	return func(p *nestedTextParser) (err error) {
+		if n < 0 {
+			return MakeNestedTextError(ErrCodeUsage, "option MaxLines requires a count >= 0")
+		}
+		p.limits.lines = n
+		return nil
+	}
+}
+
+// MaxItems limits the total count of list items and dict entries of a document to `n`,
+// including the items of nested and of inline lists and dicts. A limit of 0 (the
+// default) means no limit.
+func MaxItems(n int) Option {
+	return func(p *nestedTextParser) (err error) {
+		if n < 0 {
+			return MakeNestedTextError(ErrCodeUsage, "option MaxItems requires a count >= 0")
+		}
+		p.limits.items = n
+		return nil
+	}
+}
+
+// MaxLineLength sets the maximum length of a line of input to `n` bytes, excluding
+// the line terminator. Lines exceeding the limit result in an error of code
+// ErrCodeLineTooLong. The default limit of 64 KiB may be too small for documents
+// containing large inline lists or encoded binary data on a single line, and may be
+// too large for inputs from untrusted sources, as every line is buffered completely.
+//
+// Use as:
+//     nestext.Parse(reader, nestext.MaxLineLength(1 << 20))
+//
+// A limit less than 1 results in an error returned by Parse(…).
+//
+func MaxLineLength(n int) Option {
+	return func(p *nestedTextParser) (err error) {
+		if n < 1 {
+			return MakeNestedTextError(ErrCodeUsage, "option MaxLineLength requires a length >= 1")
+		}
+		p.limits.lineLength = n
+		return nil
+	}
+}
+
+// inputLimits holds the limits set by options MaxBytes, MaxLines, MaxItems and
+// MaxLineLength.
+type inputLimits struct {
+	bytes      int64
+	lines      int
+	items      int
+	lineLength int // 0 for the default of bufio.Scanner
+}
+
+// maxLineLength returns the line length set by option MaxLineLength among `opts`, or
+// 0 if it is not set. It is used for splitting input into lines before parsing it.
+// Errors of options are ignored, as they will be reported by Parse.
+func maxLineLength(opts []Option) int {
+	p := newParser()
+	for _, opt := range opts {
+		if opt(p) != nil {
+			return 0
+		}
+	}
+	return p.limits.lineLength
+}
+
+// limit wraps the input reader of the parser, if limits for bytes or lines are set.
+func (p *nestedTextParser) limit(r io.Reader) io.Reader {
+	if r == nil || p.limits.bytes == 0 && p.limits.lines == 0 {
+		return r
+	}
+	return &limitedReader{r: r, limits: p.limits}
+}
+
+// countItems adds `n` items to the count of items parsed so far and checks it against
+// option MaxItems. `line` is the line of the item, or of the inline item containing it.
+func (p *nestedTextParser) countItems(n int, line int) error {
+	p.items += n
+	if p.limits.items > 0 && p.items > p.limits.items {
+		err := MakeNestedTextError(ErrCodeTooLarge,
+			fmt.Sprintf("document exceeds the maximum of %d items", p.limits.items))
+		err.Line = line
+		return err
+	}
+	return nil
+}
+
+// inlineItemCount returns the count of items of an inline list or dict, including the
+// items of nested ones.
+func inlineItemCount(tree interface{}) int {
+	n := 0
+	switch t := tree.(type) {
+	case []interface{}:
+		for _, item := range t {
+			n += 1 + inlineItemCount(item)
+		}
+	default:
+		if d, ok := asDict(tree); ok {
+			for _, v := range d {
+				n += 1 + inlineItemCount(v)
+			}
+		}
+	}
+	return n
+}
+
+// limitedReader is a reader which fails with an error of code ErrCodeTooLarge as soon as
+// its input exceeds a limit for bytes or for lines. Lines are separated by CR LF, CR or
+// LF, as with splitLines.
+type limitedReader struct {
+	r      io.Reader
+	limits inputLimits
+	bytes  int64 // count of bytes read
+	lines  int   // count of line breaks read
+	cr     bool  // last byte read was a CR
+}
+
+func (lr *limitedReader) Read(b []byte) (int, error) {
+	n, err := lr.r.Read(b)
+	for i := 0; i < n; i++ {
+		crlf := lr.cr && b[i] == '\n'
+		if lr.limits.lines > 0 && lr.lines >= lr.limits.lines && !crlf {
+			return i, lr.error(fmt.Sprintf("document exceeds the maximum of %d lines", lr.limits.lines))
+		}
+		if lr.bytes++; lr.limits.bytes > 0 && lr.bytes > lr.limits.bytes {
+			return i, lr.error(fmt.Sprintf("document exceeds the maximum size of %d bytes", lr.limits.bytes))
+		}
+		if b[i] == '\r' || b[i] == '\n' && !crlf {
+			lr.lines++
+		}
+		lr.cr = b[i] == '\r'
+	}
+	return n, err
+}
+
+func (lr *limitedReader) error(msg string) NestedTextError {
+	err := MakeNestedTextError(ErrCodeTooLarge, msg)
+	err.Line = lr.lines + 1
+	return err
+}
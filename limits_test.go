@@ -0,0 +1,114 @@
+package nestext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLimits(t *testing.T) {
+	doc := "# comment\na: 1\nb:\n  - x\n  -\n    [y, {z: 2}]\n"
+	inputs := []struct {
+		opt  Option
+		line int // line of the error, 0 for no error
+	}{
+		{MaxBytes(int64(len(doc))), 0},
+		{MaxBytes(20), 4},
+		{MaxLines(6), 0},
+		{MaxLines(5), 6},
+		{MaxLines(1), 2},
+		{MaxItems(7), 0},
+		{MaxItems(6), 6},
+		{MaxItems(3), 5},
+	}
+	for i, c := range inputs {
+		_, err := Parse(strings.NewReader(doc), c.opt)
+		eerr := ParseEvents(strings.NewReader(doc), &Handler{}, c.opt)
+		_, derr := Parse(strings.NewReader(doc), c.opt, AutoDedent())
+		for _, err := range []error{err, eerr, derr} {
+			if c.line == 0 {
+				if err != nil {
+					t.Errorf("%d: expected no error, have %v", i, err)
+				}
+				continue
+			}
+			nterr, ok := err.(NestedTextError)
+			if !ok || nterr.Code != ErrCodeTooLarge || nterr.Line != c.line {
+				t.Errorf("%d: expected error of code ErrCodeTooLarge at line %d, have %v", i, c.line, err)
+			}
+		}
+	}
+}
+
+func TestParseLimitsLineBreaks(t *testing.T) {
+	for _, doc := range []string{"a: 1\r\nb: 2\r\n", "a: 1\rb: 2\r", "a: 1\nb: 2\n"} {
+		if _, err := Parse(strings.NewReader(doc), MaxLines(2)); err != nil {
+			t.Errorf("%q: expected no error, have %v", doc, err)
+		}
+		if _, err := Parse(strings.NewReader(strings.TrimRight(doc, "\r\n")), MaxLines(2)); err != nil {
+			t.Errorf("%q: expected no error, have %v", doc, err)
+		}
+		if _, err := Parse(strings.NewReader(doc+"c: 3"), MaxLines(2)); err == nil {
+			t.Errorf("%q: expected 3 lines to exceed the limit", doc+"c: 3")
+		}
+	}
+}
+
+func TestParseMaxLineLength(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	inputs := []struct {
+		text    string
+		maxLine int
+		line    int // line of the error, 0 for no error
+	}{
+		{"a: " + long + "\n", 200 * 1024, 0},
+		{"- 1\n- " + long, 200 * 1024, 0},
+		{"a: 12\r\nb: 2\n", 5, 0},
+		{"a: 123\r\nb: 2\n", 5, 1},
+		{"a: 1\rb: 234", 5, 2},
+		{"a: 1\n# comment\n", 5, 2},
+		{"\ufeffa: 12\n", 5, 0},
+	}
+	for i, input := range inputs {
+		for _, events := range []bool{false, true} {
+			var err error
+			if events {
+				err = ParseEvents(strings.NewReader(input.text), &Handler{}, MaxLineLength(input.maxLine))
+			} else {
+				_, err = Parse(strings.NewReader(input.text), MaxLineLength(input.maxLine))
+			}
+			if input.line == 0 {
+				if err != nil {
+					t.Errorf("%d: expected no error, have %v", i, err)
+				}
+				continue
+			}
+			nterr, ok := err.(NestedTextError)
+			if !ok || nterr.Code != ErrCodeLineTooLong || nterr.Line != input.line ||
+				!strings.Contains(nterr.Message, "length of 5 bytes") {
+				t.Errorf("%d: expected error for line too long at line %d, have %v", i, input.line, err)
+			}
+		}
+	}
+	if _, err := Parse(strings.NewReader("a: 1\n"), MaxLineLength(0)); err == nil {
+		t.Error("expected length 0 to produce an error; didn't")
+	}
+}
+
+func TestDecodeMaxLineLength(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	docs, err := DecodeAll(strings.NewReader("a: "+long+"\n---\nb: 1\n"), DocumentDelimiter,
+		MaxLineLength(200*1024))
+	if err != nil || len(docs) != 2 {
+		t.Errorf("expected 2 documents, have %d and %v", len(docs), err)
+	}
+	_, err = DecodeAll(strings.NewReader("a: 1\n---\nb: 123\n"), DocumentDelimiter, MaxLineLength(5))
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeLineTooLong || nterr.Line != 3 {
+		t.Errorf("expected error for line too long at line 3, have %v", err)
+	}
+	var items []interface{}
+	_, err = ParseListItems(strings.NewReader("- "+long+"\n- y\n"), Checkpoint{},
+		func(item interface{}) error { items = append(items, item); return nil }, MaxLineLength(200*1024))
+	if err != nil || len(items) != 2 {
+		t.Errorf("expected 2 items, have %d and %v", len(items), err)
+	}
+}
@@ -2,7 +2,9 @@ package nestext
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"regexp"
 	"strings"
@@ -20,36 +22,72 @@ type lineBuffer struct {
 	Line        *strings.Reader // reader on Text
 	isEof       int             // is this buffer done reading? May be 0, 1 or 2.
 	LastError   error           // last error, if any (except EOF errors)
+	Consumed    int64           // count of bytes consumed from the input so far
+	Meta        Metadata        // properties of the input, collected while reading
+	Hooks       lineHooks       // optional callbacks for lines read
+	Blanks      int             // count of blank lines skipped directly before the current line
+	LoneCR      int             // first line terminated by a lone CR, 0 if none
+	LoneCRStart int64           // byte offset of line LoneCR
+	MaxLine     int             // maximum length of a line in bytes, 0 for the default
+	LineStart   int64           // byte offset of the current line
+	LineEnd     int64           // byte offset of the end of the current line, excluding the line terminator
+	recent      lineWindow      // the most recent lines read, for locating errors
+	items       int             // count of item lines read
+	Mixed       int             // first line terminated differently from the first line, 0 if none
+	MixedEOL    string          // line terminator of line Mixed
+	MixedStart  int64           // byte offset of line Mixed
+}
+
+// lineHooks are optional callbacks for lines read by a line buffer.
+type lineHooks struct {
+	Skipped func(line int, text string)        // called for skipped blank lines and comment lines
+	Read    func(start int64, length int)      // called for every line read, in order
+	Rewrite func(line int, text string) string // may replace the text of every line read
 }
 
 const eolMarker = '\n'
 
 var errAtEof error = errors.New("EOF")
 
-func newLineBuffer(inputDoc io.Reader) *lineBuffer {
+func newLineBuffer(inputDoc io.Reader, hooks lineHooks, maxLine int) *lineBuffer {
+	buf := &lineBuffer{Hooks: hooks, MaxLine: maxLine}
 	input := bufio.NewScanner(inputDoc)
-	// From the spec:
-	// Line breaks: A NestedText document is partitioned into lines where the lines are split by
-	// CR LF, CR, or LF where CR and LF are the ASCII carriage return and line feed characters.
-	// A single document may employ any or all of these ways of splitting lines.
-	split := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		advance, token, err = bufio.ScanLines(data, atEOF)
-		for i, ch := range data {
-			if ch == '\r' {
-				if i < len(data)-1 && i < len(token) {
-					if data[i+1] != '\n' {
-						advance = i + 1
-						token = data[:i]
-						err = nil
-						return
-					}
-				}
+	limitLineLength(input, maxLine)
+	input.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if buf.Consumed == 0 {
+			n, err := byteOrderMark(data, atEOF)
+			if n > 0 {
+				buf.Meta.BOM = true
+				buf.Consumed += int64(n)
+				return n, nil, nil
+			} else if n < 0 || err != nil {
+				return 0, nil, err
 			}
 		}
-		return
-	}
-	input.Split(split)
-	buf := &lineBuffer{Input: input}
+		advance, token, err := splitLines(data, atEOF)
+		if maxLine > 0 && len(token) > maxLine {
+			return 0, nil, bufio.ErrTooLong
+		}
+		if token != nil {
+			buf.LineStart, buf.LineEnd = buf.Consumed, buf.Consumed+int64(len(token))
+		}
+		if token != nil && buf.Hooks.Read != nil {
+			buf.Hooks.Read(buf.Consumed, len(token))
+		}
+		buf.Consumed += int64(advance)
+		if token != nil {
+			eol := string(data[len(token):advance])
+			buf.Meta.addLine(eol)
+			if eol == "\r" && buf.LoneCR == 0 {
+				buf.LoneCR, buf.LoneCRStart = buf.Meta.Lines, buf.LineStart
+			}
+			if buf.Meta.MixedNewlines && buf.Mixed == 0 {
+				buf.Mixed, buf.MixedEOL, buf.MixedStart = buf.Meta.Lines, eol, buf.LineStart
+			}
+		}
+		return advance, token, err
+	})
+	buf.Input = input
 	err := buf.AdvanceLine()
 	if err != errAtEof {
 		buf.LastError = err
@@ -57,6 +95,110 @@ func newLineBuffer(inputDoc io.Reader) *lineBuffer {
 	return buf
 }
 
+// splitLines is a split function for bufio.Scanner. From the spec:
+// Line breaks: A NestedText document is partitioned into lines where the lines are split by
+// CR LF, CR, or LF where CR and LF are the ASCII carriage return and line feed characters.
+// A single document may employ any or all of these ways of splitting lines.
+//
+// The line terminator is not part of the token, but is included in `advance`. A CR at the
+// end of `data` may be the first half of a CR LF, thus more data is requested, unless
+// at EOF.
+func splitLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	i := bytes.IndexAny(data, "\r\n")
+	switch {
+	case i < 0:
+		if atEOF && len(data) > 0 { // last line without line terminator
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	case data[i] == '\n':
+		return i + 1, data[:i], nil
+	case i+1 < len(data):
+		if data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	case atEOF:
+		return i + 1, data[:i], nil
+	}
+	return 0, nil, nil
+}
+
+// newlineNames are the names of line terminators, for messages.
+var newlineNames = map[string]string{"\n": "LF", "\r\n": "CR LF", "\r": "CR"}
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16BEBOM = []byte{0xfe, 0xff}
+	utf16LEBOM = []byte{0xff, 0xfe}
+)
+
+// byteOrderMark checks the start of the input for a byte order mark. It returns the
+// length of a UTF-8 BOM, which is to be skipped, or -1 if more input is needed to decide.
+// Editors on Windows often start documents with a UTF-8 BOM, which would otherwise
+// become part of the first item. NestedText documents are encoded in UTF-8, thus
+// a UTF-16 BOM results in an error of code ErrCodeEncoding.
+func byteOrderMark(data []byte, atEOF bool) (int, error) {
+	if len(data) < len(utf8BOM) && !atEOF {
+		for _, bom := range [][]byte{utf8BOM, utf16BEBOM, utf16LEBOM} {
+			if bytes.HasPrefix(bom, data) {
+				return -1, nil
+			}
+		}
+	}
+	if bytes.HasPrefix(data, utf8BOM) {
+		return len(utf8BOM), nil
+	}
+	var order string
+	if bytes.HasPrefix(data, utf16BEBOM) {
+		order = "big endian"
+	} else if bytes.HasPrefix(data, utf16LEBOM) {
+		order = "little endian"
+	} else {
+		return 0, nil
+	}
+	err := MakeNestedTextError(ErrCodeEncoding, fmt.Sprintf(
+		"unsupported encoding UTF-16 (%s); NestedText documents have to be encoded in UTF-8", order))
+	err.Line = 1
+	return 0, err
+}
+
+// limitLineLength sets the buffer of line scanner `input` for lines of up to `maxLine`
+// bytes, plus a line terminator. For `maxLine` = 0, the buffer is left at its default
+// maximum of bufio.MaxScanTokenSize. Split functions have to check the length of lines
+// terminated by EOF.
+func limitLineLength(input *bufio.Scanner, maxLine int) {
+	if maxLine == 0 {
+		return
+	}
+	size := maxLine + 2 // CR LF, or CR and the first character of the next line
+	initial := size
+	if initial > 4096 {
+		initial = 4096
+	}
+	input.Buffer(make([]byte, 0, initial), size)
+}
+
+// readError creates an error for a failure of the line scanner, which occured when
+// reading line number `line`. `maxLine` is the maximum length of lines, or 0 for the
+// default.
+func readError(err error, line int, maxLine int) error {
+	if nterr, ok := err.(NestedTextError); ok { // e.g. from limitedReader
+		return nterr
+	}
+	if errors.Is(err, bufio.ErrTooLong) {
+		if maxLine == 0 {
+			maxLine = bufio.MaxScanTokenSize
+		}
+		e := WrapError(ErrCodeLineTooLong, fmt.Sprintf(
+			"line exceeds maximum line length of %d bytes; consider splitting its content into a multi-line item",
+			maxLine), err)
+		e.Line = line
+		return e
+	}
+	return WrapError(ErrCodeIO, "I/O error while reading input", err)
+}
+
 func (buf *lineBuffer) IsEof() bool {
 	return buf.isEof >= 2 || buf.Line.Size() == 0
 }
@@ -101,34 +243,50 @@ func (buf *lineBuffer) readRune() (rune, error) {
 // Line-count and cursor are updated.
 //
 func (buf *lineBuffer) AdvanceLine() error {
-	//fmt.Printf("===> advance line..")
 	buf.Cursor = 0
 	buf.ByteCursor = 0
 	// iterate over the lines of the input document until valid line found or EOF
 	if buf.isEof == 1 {
 		buf.isEof = 2
-		//fmt.Printf("..1->2")
 		return errAtEof
 	}
-	//fmt.Printf("..ok\n")
+	buf.Blanks = 0
 	for buf.isEof == 0 {
 		buf.CurrentLine++
-		//fmt.Printf("===> reading line #%d\n", buf.CurrentLine)
 		if !buf.Input.Scan() { // could not read a new line: either I/O-error or EOF
 			if err := buf.Input.Err(); err != nil {
-				return WrapError(ErrCodeIO, "I/O error while reading input", err)
+				buf.isEof = 2
+				buf.Line = strings.NewReader("")
+				return readError(err, buf.CurrentLine, buf.MaxLine)
+			}
+			if tracing {
+				tracef("EOF after line %d", buf.CurrentLine-1)
 			}
-			//fmt.Println("===> EOF !")
 			buf.isEof = 1
 			buf.Line = strings.NewReader("")
 			return errAtEof
 		}
 		buf.Text = buf.Input.Text()
-		//fmt.Printf("===> %q\n", buf.Text)
+		l := &buf.recent[buf.items%len(buf.recent)] // overwritten by the next line if ignored
+		*l = lineText{line: buf.CurrentLine, start: buf.LineStart, next: buf.Consumed, text: buf.Text}
+		if buf.Hooks.Rewrite != nil { // findings of Rewrite are located in the original text
+			buf.Text = buf.Hooks.Rewrite(buf.CurrentLine, buf.Text)
+			l.text = buf.Text
+		}
+		if tracing {
+			tracef("line %d: %q", buf.CurrentLine, buf.Text)
+		}
 		if !buf.IsIgnoredLine() {
+			buf.items++
 			buf.Line = strings.NewReader(buf.Text)
 			break
 		}
+		if blankPattern.MatchString(buf.Text) {
+			buf.Blanks++
+		}
+		if buf.Hooks.Skipped != nil {
+			buf.Hooks.Skipped(buf.CurrentLine, buf.Text)
+		}
 	}
 	buf.Line = strings.NewReader(buf.Text)
 	return buf.AdvanceCursor()
@@ -158,7 +316,9 @@ func (buf *lineBuffer) ReadLineRemainder() string {
 	if buf.IsEof() {
 		s = ""
 	} else if buf.ByteCursor == buf.Line.Size() {
-		s = string(buf.Lookahead)
+		if buf.Lookahead != eolMarker { // lookahead is the last character of the line
+			s = string(buf.Lookahead)
+		}
 	} else if buf.ByteCursor > buf.Line.Size() {
 		s = ""
 	} else {
@@ -0,0 +1,18 @@
+package nestext
+
+// Marshaler is the interface implemented by types that can convert themselves
+// into a tree of NestedText values, i.e., into a string, []interface{} or
+// map[string]interface{}, suitable for encoding.
+//
+// Implementations are usually generated by `cmd/ntgen`.
+type Marshaler interface {
+	MarshalNestedText() (interface{}, error)
+}
+
+// Unmarshaler is the interface implemented by types that can initialize themselves
+// from a tree of NestedText values, as produced by `Parse`.
+//
+// Implementations are usually generated by `cmd/ntgen`.
+type Unmarshaler interface {
+	UnmarshalNestedText(tree interface{}) error
+}
@@ -0,0 +1,120 @@
+package nestext
+
+import "sort"
+
+// --- Merging documents -----------------------------------------------------
+
+// Merge merges tree `src` into tree `dst` at path expression `path` (see ParsePath)
+// and returns the result, leaving both inputs unmodified. This allows to graft an
+// override file into a base configuration:
+//
+//     config, err := nestext.Merge(base, override, "overrides.staging")
+//
+// Dicts are merged recursively, with items of `src` taking precedence; all other
+// values of `src` replace the value at their path in `dst`. Missing dicts on the way
+// to `path` are created. If `path` is empty and either tree is not a dict, the result
+// is a copy of `src`.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func Merge(dst, src interface{}, path string) (interface{}, error) {
+	if path == "" && !(isDict(dst) && isDict(src)) {
+		return deepCopy(src), nil
+	}
+	result := deepCopy(dst)
+	if result == nil {
+		result = map[string]interface{}{}
+	}
+	if err := MergeInto(result, src, path); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MergeInto merges tree `src` into tree `dst` at path expression `path`, like Merge,
+// but modifies `dst` in place. Values of `src` are copied, thus `src` may be
+// modified later on without affecting `dst`. An empty path requires both trees to be
+// dicts.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func MergeInto(dst, src interface{}, path string) error {
+	if path == "" {
+		if !isDict(dst) || !isDict(src) {
+			return MakeNestedTextError(ErrCodeUsage, "cannot merge non-dicts at top level in place")
+		}
+		mergeDicts(dst, src)
+		return nil
+	}
+	if _, err := accessPath(path); err != nil {
+		return err
+	}
+	if target, err := Get(dst, path); err == nil && isDict(target) && isDict(src) {
+		mergeDicts(target, src)
+		return nil
+	}
+	return Set(dst, path, deepCopy(src))
+}
+
+// mergeDicts merges dict `src` into dict `dst`.
+func mergeDicts(dst, src interface{}) {
+	for _, key := range dictKeys(src) {
+		value, _ := childAt(src, PathSegment{Key: key})
+		if target, ok := childAt(dst, PathSegment{Key: key}); ok && isDict(target) && isDict(value) {
+			mergeDicts(target, value)
+			continue
+		}
+		switch d := dst.(type) {
+		case map[string]interface{}:
+			d[key] = deepCopy(value)
+		case *OrderedMap:
+			d.Set(key, deepCopy(value))
+		}
+	}
+}
+
+// dictKeys returns the keys of a dict, sorted for maps and in insertion order for
+// ordered maps.
+func dictKeys(tree interface{}) []string {
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	case *OrderedMap:
+		return t.Keys()
+	}
+	return nil
+}
+
+func isDict(tree interface{}) bool {
+	_, ok := asDict(tree)
+	return ok
+}
+
+// deepCopy copies the dicts and lists of a tree.
+func deepCopy(tree interface{}) interface{} {
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = deepCopy(v)
+		}
+		return m
+	case *OrderedMap:
+		m := NewOrderedMap()
+		for _, k := range t.Keys() {
+			v, _ := t.Get(k)
+			m.Set(k, deepCopy(v))
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, v := range t {
+			l[i] = deepCopy(v)
+		}
+		return l
+	}
+	return tree
+}
@@ -0,0 +1,61 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base, _ := Parse(strings.NewReader("server:\n  host: a\n  port: 80\n  aliases:\n    - x\n"))
+	override, _ := Parse(strings.NewReader("port: 8080\naliases:\n  - y\ntls:\n  cert: c.pem\n"))
+	merged, err := Merge(base, override, "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"server": map[string]interface{}{
+			"host":    "a",
+			"port":    "8080",
+			"aliases": []interface{}{"y"},
+			"tls":     map[string]interface{}{"cert": "c.pem"},
+		},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %v, have %v", expected, merged)
+	}
+	if v, _ := Get(base, "server.port"); v != "80" {
+		t.Error("expected base to be unmodified")
+	}
+	merged, err = Merge(base, override, "overrides.staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Get(merged, "overrides.staging.tls.cert"); err != nil || v != "c.pem" {
+		t.Errorf("expected override to be grafted, have %v, %v", v, err)
+	}
+	override.(map[string]interface{})["tls"].(map[string]interface{})["cert"] = "d.pem"
+	if v, _ := Get(merged, "overrides.staging.tls.cert"); v != "c.pem" {
+		t.Error("expected merged tree not to share dicts with the override")
+	}
+	if merged, _ = Merge(base, []interface{}{"a"}, ""); !reflect.DeepEqual(merged, []interface{}{"a"}) {
+		t.Errorf("expected non-dict at top-level to replace base, have %v", merged)
+	}
+}
+
+func TestMergeInto(t *testing.T) {
+	dst, _ := Parse(strings.NewReader("b: 1\na:\n  x: 1\n"), OrderedDicts())
+	src, _ := Parse(strings.NewReader("c: 3\na:\n  y: 2\n"), OrderedDicts())
+	if err := MergeInto(dst, src, ""); err != nil {
+		t.Fatal(err)
+	}
+	if s := dst.(*OrderedMap).String(); s != "map[b:1 a:map[x:1 y:2] c:3]" {
+		t.Errorf("unexpected result %s", s)
+	}
+	if err := MergeInto(dst, "x", ""); err == nil {
+		t.Error("expected merging a string at top level to fail")
+	}
+	if err := MergeInto(dst, src, "b.c"); err == nil {
+		t.Error("expected merging below a string to fail")
+	}
+}
@@ -0,0 +1,43 @@
+package nestext
+
+// --- Message formatting ----------------------------------------------------
+
+// MessageFormatter is a callback type for formatting the messages of errors and
+// warnings. It receives an error with the default (English) message and returns the
+// message to report instead. Code, severity and position of the error provide the context
+// for selecting a localized message; the default message may be returned for errors
+// without a translation.
+type MessageFormatter func(e NestedTextError) string
+
+// FormatMessages installs a formatter for the messages of all errors and warnings
+// reported by the parser, including the errors of an ErrorList (see ContinueOnError),
+// the errors of placeholders (see ResumeAtTopLevel) and warnings passed to the callback
+// of ReportWarnings. Errors wrapped by NestedTextErrors, e.g. I/O errors, are left
+// unchanged.
+//
+// Use as:
+//     german := map[int]string{
+//         nestext.ErrCodeFormatNoInput: "keine Eingabe",
+//     }
+//     result, err := nestext.Parse(reader, nestext.FormatMessages(func(e nestext.NestedTextError) string {
+//         if msg, ok := german[e.Code]; ok {
+//             return msg
+//         }
+//         return e.Message
+//     }))
+//
+func FormatMessages(f MessageFormatter) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.formatter = f
+		return nil
+	}
+}
+
+// formatMessage replaces the message of `e` by the message created by the formatter,
+// if present.
+func (p *nestedTextParser) formatMessage(e NestedTextError) NestedTextError {
+	if p.formatter != nil {
+		e.Message = p.formatter(e)
+	}
+	return e
+}
@@ -0,0 +1,54 @@
+package nestext
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFormatMessages(t *testing.T) {
+	upper := FormatMessages(func(e NestedTextError) string {
+		if e.Code == ErrCodeFormat {
+			return strings.ToUpper(e.Message)
+		}
+		return e.Message
+	})
+	_, err := Parse(strings.NewReader("a: 1\n  b: 2\n"), upper)
+	nterr, ok := err.(NestedTextError)
+	if !ok || nterr.Message != strings.ToUpper(nterr.Message) || nterr.Line != 2 {
+		t.Errorf("expected formatted message at line 2, have %v", err)
+	}
+	err = ParseEvents(strings.NewReader("a: 1\n  b: 2\n"), &Handler{}, upper)
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Message != strings.ToUpper(nterr.Message) {
+		t.Errorf("expected formatted message from ParseEvents, have %v", err)
+	}
+	_, err = Parse(strings.NewReader("- a\n  - b\n- c\n> d\n"), upper, ContinueOnError())
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 2 {
+		t.Fatalf("expected list of 2 errors, have %v", err)
+	}
+	for _, e := range list {
+		if e.Message != strings.ToUpper(e.Message) {
+			t.Errorf("expected formatted message, have %q", e.Message)
+		}
+	}
+}
+
+func TestFormatMessagesWarningsAndPlaceholders(t *testing.T) {
+	var warnings []NestedTextError
+	_, err := Parse(strings.NewReader("a:\n  [x]]\n"), Strict(false),
+		FormatMessages(func(e NestedTextError) string { return "W" + e.Severity.String() }),
+		ReportWarnings(func(w NestedTextError) { warnings = append(warnings, w) }))
+	if err != nil || len(warnings) != 1 || warnings[0].Message != "Wwarning" {
+		t.Errorf("expected formatted warning, have %v and %v", err, warnings)
+	}
+	result, _ := Parse(strings.NewReader("- a\n-\n  [b\n- c\n"), ResumeAtTopLevel(),
+		FormatMessages(func(e NestedTextError) string { return "broken" }))
+	list, ok := result.([]interface{})
+	if !ok || len(list) != 3 {
+		t.Fatalf("expected list of 3 items, have %v", result)
+	}
+	if ph, ok := list[1].(*Placeholder); !ok || ph.Err.Message != "broken" {
+		t.Errorf("expected placeholder with formatted error, have %v", list[1])
+	}
+}
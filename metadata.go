@@ -0,0 +1,55 @@
+package nestext
+
+// --- Document metadata -----------------------------------------------------
+
+// Metadata describes properties of a NestedText document's source which are not
+// part of its content, but may be worth preserving, e.g. by formatters.
+type Metadata struct {
+	Bytes         int64  // size of the document in bytes
+	Lines         int    // count of lines, including blank lines and comments
+	Newline       string // line terminator: "\n", "\r\n" or "\r"; empty if there are no line breaks
+	MixedNewlines bool   // does the document use more than one kind of line terminator?
+	FinalNewline  bool   // is the last line terminated by a line break?
+	Indent        int    // indentation width of the first nested value; 0 if there is none
+	BOM           bool   // does the document start with a UTF-8 byte order mark?
+}
+
+// addLine records a line of input with line terminator `eol`.
+func (m *Metadata) addLine(eol string) {
+	m.Lines++
+	m.FinalNewline = eol != ""
+	if eol == "" {
+		return
+	}
+	if m.Newline == "" {
+		m.Newline = eol
+	} else if m.Newline != eol {
+		m.MixedNewlines = true
+	}
+}
+
+// ReportMetadata requests the parser to store metadata of the document into `m`,
+// after the document has been parsed successfully.
+//
+// Use as:
+//     var meta nestext.Metadata
+//     result, err := nestext.Parse(reader, nestext.ReportMetadata(&meta))
+//     …
+//     if !meta.FinalNewline {
+//         …
+//     }
+//
+func ReportMetadata(m *Metadata) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.meta = m
+		return nil
+	}
+}
+
+// reportMetadata stores the metadata of the document parsed into the Metadata of
+// option ReportMetadata.
+func (p *nestedTextParser) reportMetadata() {
+	*p.meta = p.sc.Buf.Meta
+	p.meta.Bytes = p.sc.Buf.Consumed + p.removed
+	p.meta.Indent = p.indentWidth
+}
@@ -0,0 +1,30 @@
+package nestext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReportMetadata(t *testing.T) {
+	inputs := []struct {
+		text string
+		meta Metadata
+	}{
+		{"a: 1\r\n# comment\r\n\r\nb: 2\r\n", Metadata{Bytes: 25, Lines: 4, Newline: "\r\n", FinalNewline: true}},
+		{"- x\n- y", Metadata{Bytes: 7, Lines: 2, Newline: "\n"}},
+		{"- x\r- y\n", Metadata{Bytes: 8, Lines: 2, Newline: "\r", MixedNewlines: true, FinalNewline: true}},
+		{"> text", Metadata{Bytes: 6, Lines: 1}},
+		{"", Metadata{}},
+		{"a:\n    - x\n    -\n      > y\n", Metadata{Bytes: 27, Lines: 4, Newline: "\n", FinalNewline: true, Indent: 4}},
+		{": key\n   > value\n", Metadata{Bytes: 17, Lines: 2, Newline: "\n", FinalNewline: true, Indent: 3}},
+	}
+	for i, input := range inputs {
+		var meta Metadata
+		if _, err := Parse(strings.NewReader(input.text), ReportMetadata(&meta)); err != nil {
+			t.Fatalf("[%d] %v", i, err)
+		}
+		if meta != input.meta {
+			t.Errorf("[%d] expected metadata %+v, have %+v", i, input.meta, meta)
+		}
+	}
+}
@@ -58,6 +58,8 @@
 package nestext
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"unicode"
 )
@@ -65,11 +67,32 @@ import (
 // --- Error type ------------------------------------------------------------
 
 // NestedTextError is a custom error type for working with NestedText instances.
+// Warnings, as reported by option ReportWarnings, are of this type as well.
+//
+// Errors may be passed between processes as JSON, e.g. from build tools to editors
+// (see the example of MarshalJSON):
+//
+//     {"code":204,"severity":"error","message":"unused content following valid input","line":2,"column":0,"endLine":3,"offset":15,"endOffset":30}
+//
+// End positions, byte offsets and the message of a wrapped error ("cause") are present
+// only if set. Wrapped errors are restored from JSON as plain errors carrying the message
+// only.
+//
+// Byte offsets allow editors to map errors to ranges of their buffers directly. They
+// are set for errors and warnings reported by the parser and by the tokenizer, and refer
+// to the input as read, e.g. after dedenting with option AutoDedent. For lines changed by
+// the parser, e.g. by expanding tabs, offsets within the line are approximate. The parser
+// holds only the most recent lines in memory; errors which refer to lines read long
+// before they are detected, e.g. for option RequireKeys, may lack offsets.
+//
 type NestedTextError struct {
-	Code         int // error code
-	Line, Column int // error position
-	msg          string
-	wrappedError error
+	Code               int      // error code
+	Severity           Severity // error or warning
+	Line, Column       int      // error position
+	EndLine, EndColumn int      // end of the span of the error, exclusive; 0 if unknown
+	Offset, EndOffset  int64    // byte offsets of the span of the error, EndOffset exclusive; 0 if unknown
+	Message            string   // error message, without position
+	wrappedError       error
 }
 
 // We use a custom error type which contains a numeric error code.
@@ -86,9 +109,28 @@ const (
 	ErrCodeFormatIllegalTag                  // NestedText format error: tag not recognized
 )
 
+// ErrCodeLineTooLong flags a line of input exceeding the maximum line length.
+const ErrCodeLineTooLong = 11
+
+// ErrCodeTooDeep flags lists and dicts nesting deeper than allowed by option MaxDepth.
+const ErrCodeTooDeep = 12
+
+// ErrCodeTooLarge flags input exceeding a limit set by MaxBytes, MaxLines or MaxItems.
+const ErrCodeTooLarge = 13
+
+// ErrCodeEncoding flags input in an unsupported character encoding, i.e. not UTF-8.
+const ErrCodeEncoding = 14
+
+// Further format error codes, continuing the ones above.
+const (
+	ErrCodeFormatNoFinalNewline = ErrCodeFormatIllegalTag + 1 + iota // NestedText format error: last line not terminated
+	ErrCodeFormatTabIndent                                           // NestedText format error: tab character in indentation
+	ErrCodeFormatMixedNewlines                                       // NestedText format error: lines terminated differently
+)
+
 // Error produces an error message from a NestedText error.
 func (e NestedTextError) Error() string {
-	return fmt.Sprintf("[%d,%d] %s", e.Line, e.Column, e.msg)
+	return fmt.Sprintf("[%d,%d] %s", e.Line, e.Column, e.Message)
 }
 
 // Unwrap returns an optionally present underlying error condition, e.g., an I/O-Error.
@@ -99,8 +141,8 @@ func (e NestedTextError) Unwrap() error {
 // MakeNestedTextError creates a NestedTextError with a given error code and message.
 func MakeNestedTextError(code int, errMsg string) NestedTextError {
 	err := NestedTextError{
-		Code: code,
-		msg:  errMsg,
+		Code:    code,
+		Message: errMsg,
 	}
 	return err
 }
@@ -112,6 +154,99 @@ func WrapError(code int, errMsg string, err error) NestedTextError {
 	return e
 }
 
+// Severity is the severity of a NestedTextError. The zero value denotes an error.
+type Severity int
+
+// Severities of errors
+const (
+	SeverityError   Severity = iota // parsing fails, or has failed for an item
+	SeverityWarning                 // finding reported by option ReportWarnings
+)
+
+var severityNames = []string{"error", "warning"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText encodes a severity as its name, i.e. "error" or "warning".
+func (s Severity) MarshalText() ([]byte, error) {
+	if s < 0 || int(s) >= len(severityNames) {
+		return nil, MakeNestedTextError(ErrCodeUsage, fmt.Sprintf("invalid severity %d", int(s)))
+	}
+	return []byte(severityNames[s]), nil
+}
+
+// UnmarshalText decodes the name of a severity.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severityNames {
+		if string(text) == name {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return MakeNestedTextError(ErrCodeUsage, fmt.Sprintf("invalid severity %q", text))
+}
+
+// jsonError is the JSON representation of a NestedTextError.
+type jsonError struct {
+	Code      int      `json:"code"`
+	Severity  Severity `json:"severity"`
+	Message   string   `json:"message"`
+	Line      int      `json:"line"`
+	Column    int      `json:"column"`
+	EndLine   int      `json:"endLine,omitempty"`
+	EndColumn int      `json:"endColumn,omitempty"`
+	Offset    int64    `json:"offset,omitempty"`
+	EndOffset int64    `json:"endOffset,omitempty"`
+	Cause     string   `json:"cause,omitempty"`
+}
+
+// MarshalJSON encodes e as a JSON object, see type NestedTextError.
+func (e NestedTextError) MarshalJSON() ([]byte, error) {
+	je := jsonError{
+		Code:      e.Code,
+		Severity:  e.Severity,
+		Message:   e.Message,
+		Line:      e.Line,
+		Column:    e.Column,
+		EndLine:   e.EndLine,
+		EndColumn: e.EndColumn,
+		Offset:    e.Offset,
+		EndOffset: e.EndOffset,
+	}
+	if e.wrappedError != nil {
+		je.Cause = e.wrappedError.Error()
+	}
+	return json.Marshal(je)
+}
+
+// UnmarshalJSON decodes a JSON object created by MarshalJSON.
+func (e *NestedTextError) UnmarshalJSON(data []byte) error {
+	var je jsonError
+	if err := json.Unmarshal(data, &je); err != nil {
+		return err
+	}
+	*e = NestedTextError{
+		Code:      je.Code,
+		Severity:  je.Severity,
+		Line:      je.Line,
+		Column:    je.Column,
+		EndLine:   je.EndLine,
+		EndColumn: je.EndColumn,
+		Offset:    je.Offset,
+		EndOffset: je.EndOffset,
+		Message:   je.Message,
+	}
+	if je.Cause != "" {
+		e.wrappedError = errors.New(je.Cause)
+	}
+	return nil
+}
+
 // --- Parser token type -----------------------------------------------------
 
 // parserToken is a type for communicating between the line-level scanner and the parser.
@@ -119,9 +254,13 @@ func WrapError(code int, errMsg string, err error) NestedTextError {
 // parser to perform its operations on.
 type parserToken struct {
 	LineNo, ColNo int             // start of the tag within the input source
+	Offset        int64           // byte offset of the line
+	EndOffset     int64           // byte offset of the end of the line, excluding the line terminator
 	TokenType     parserTokenType // type of token
 	Indent        int             // amount of indent of this line
 	Content       []string        // UTF-8 content of the line (without indent and item tag)
+	Padding       string          // white space between an inline key and its tag
+	Blanks        int             // count of blank lines directly preceding the line
 	Error         error           // error condition, if any
 }
 
@@ -199,8 +338,8 @@ func inlineTokenFor(r rune) inlineTokenType {
 
 func makeParsingError(token *parserToken, code int, errMsg string) NestedTextError {
 	err := NestedTextError{
-		Code: code,
-		msg:  errMsg,
+		Code:    code,
+		Message: errMsg,
 	}
 	if token != nil {
 		err.Line = token.LineNo
@@ -0,0 +1,76 @@
+package nestext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestErrorJSON(t *testing.T) {
+	_, err := Parse(strings.NewReader("a:\n  {a: b, c}\n"))
+	nterr, ok := err.(NestedTextError)
+	if !ok {
+		t.Fatalf("expected NestedTextError, have %v", err)
+	}
+	data, err := json.Marshal(nterr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf(`{"code":%d,"severity":"error","message":"format error","line":2,"column":10,"offset":13}`,
+		ErrCodeFormat)
+	if string(data) != expected {
+		t.Errorf("expected JSON %s, have %s", expected, data)
+	}
+	var decoded NestedTextError
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != nterr {
+		t.Errorf("expected %#v, have %#v", nterr, decoded)
+	}
+}
+
+func TestErrorJSONSpanAndCause(t *testing.T) {
+	e := WrapError(ErrCodeIO, "read failed", io.ErrUnexpectedEOF)
+	e.Severity, e.Line, e.Column, e.EndLine, e.EndColumn = SeverityWarning, 3, 2, 3, 7
+	e.Offset, e.EndOffset = 20, 25
+	data, err := json.Marshal(ErrorList{e})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"code":10,"severity":"warning","message":"read failed","line":3,"column":2,` +
+		`"endLine":3,"endColumn":7,"offset":20,"endOffset":25,"cause":"unexpected EOF"}]`
+	if string(data) != expected {
+		t.Errorf("expected JSON %s, have %s", expected, data)
+	}
+	var decoded ErrorList
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || decoded[0].Error() != e.Error() || decoded[0].EndColumn != 7 || decoded[0].EndOffset != 25 ||
+		decoded[0].Unwrap() == nil || decoded[0].Unwrap().Error() != "unexpected EOF" {
+		t.Errorf("expected %v, have %#v", e, decoded)
+	}
+	if err = json.Unmarshal([]byte(`{"severity":"fatal"}`), &decoded[0]); err == nil {
+		t.Error("expected invalid severity to produce an error; didn't")
+	}
+	var nterr NestedTextError
+	if !errors.As(err, &nterr) || nterr.Code != ErrCodeUsage {
+		t.Errorf("expected usage error, have %v", err)
+	}
+}
+
+func TestWarningSeverity(t *testing.T) {
+	var warnings []NestedTextError
+	_, err := Parse(strings.NewReader("- a\n- a\n"), WarnDuplicateItems(),
+		ReportWarnings(func(w NestedTextError) { warnings = append(warnings, w) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Severity != SeverityWarning {
+		t.Errorf("expected a single warning, have %v", warnings)
+	}
+}
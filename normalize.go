@@ -0,0 +1,191 @@
+package nestext
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// --- Normalization ---------------------------------------------------------
+
+// Normalize converts a tree into a canonical form, suitable for comparing trees
+// regardless of how they have been produced: dicts, including *OrderedMap and maps with
+// string keys of any value type, become map[string]interface{}; lists, including
+// slices and arrays of any element type, become []interface{}. Nil dicts and lists,
+// including nil *OrderedMaps, become empty ones. Strings and all other values are kept
+// as they are. The tree passed in is not modified.
+//
+// Maps carry no order of keys, thus normalized trees print with sorted keys, e.g.
+// with fmt's %#v, and compare equal with reflect.DeepEqual if they hold the same
+// entries.
+//
+// Values implementing Marshaler are normalized from the tree returned by
+// MarshalNestedText; if marshaling fails, the value is kept as it is.
+//
+func Normalize(tree interface{}) interface{} {
+	switch t := tree.(type) {
+	case nil, string:
+		return tree
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = Normalize(v)
+		}
+		return m
+	case *OrderedMap:
+		if t == nil {
+			return map[string]interface{}{}
+		}
+		return Normalize(t.values)
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, v := range t {
+			l[i] = Normalize(v)
+		}
+		return l
+	case Marshaler:
+		if m, err := t.MarshalNestedText(); err == nil {
+			return Normalize(m)
+		}
+		return tree
+	}
+	v := reflect.ValueOf(tree)
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return tree
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = Normalize(iter.Value().Interface())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		l := make([]interface{}, v.Len())
+		for i := range l {
+			l[i] = Normalize(v.Index(i).Interface())
+		}
+		return l
+	}
+	return tree
+}
+
+// Equal reports whether two trees are equal after normalization (see Normalize), i.e.
+// disregarding the order of keys and the concrete types of dicts and lists.
+func Equal(a, b interface{}) bool {
+	return reflect.DeepEqual(Normalize(a), Normalize(b))
+}
+
+// --- Differences -----------------------------------------------------------
+
+// Difference is an item which differs between two trees, as reported by Diff.
+type Difference struct {
+	Path string      // path expression of the item (see ParsePath), "" for the top-level value
+	A    interface{} // normalized item of the first tree, nil if it is missing
+	B    interface{} // normalized item of the second tree, nil if it is missing
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %#v != %#v", d.Path, d.A, d.B)
+}
+
+// Diff compares two trees after normalization (see Normalize) and returns the items
+// which differ, ordered by path. Dicts are compared key by key and lists item by item,
+// thus a difference is reported at the deepest item possible; for items which are
+// missing from either tree, as well as for items of different types, the item is
+// reported as a whole. Diff returns nil for equal trees.
+func Diff(a, b interface{}) []Difference {
+	var diffs []Difference
+	diff(Normalize(a), Normalize(b), nil, &diffs)
+	return diffs
+}
+
+func diff(a, b interface{}, path []PathSegment, diffs *[]Difference) {
+	da, aIsDict := a.(map[string]interface{})
+	db, bIsDict := b.(map[string]interface{})
+	if aIsDict && bIsDict {
+		keys := make([]string, 0, len(da)+len(db))
+		for k := range da {
+			keys = append(keys, k)
+		}
+		for k := range db {
+			if _, ok := da[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diff(da[k], db[k], append(path[:len(path):len(path)], PathSegment{Key: k}), diffs)
+		}
+		return
+	}
+	la, aIsList := a.([]interface{})
+	lb, bIsList := b.([]interface{})
+	if aIsList && bIsList {
+		for i := 0; i < len(la) || i < len(lb); i++ {
+			var ia, ib interface{}
+			if i < len(la) {
+				ia = la[i]
+			}
+			if i < len(lb) {
+				ib = lb[i]
+			}
+			diff(ia, ib, append(path[:len(path):len(path)], PathSegment{Index: i, IsIndex: true}), diffs)
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*diffs = append(*diffs, Difference{Path: JoinPath(path), A: a, B: b})
+	}
+}
+
+// --- Hashing ---------------------------------------------------------------
+
+// CanonicalHash returns a SHA-256 hash of the normalized form of a tree (see
+// Normalize). Trees which are Equal have the same hash, thus hashes may be used to
+// detect changes of configurations or as keys of caches. Values other than strings,
+// dicts and lists are hashed by their type and their representation with fmt's %v.
+func CanonicalHash(tree interface{}) [sha256.Size]byte {
+	h := sha256.New()
+	writeCanonical(h, Normalize(tree))
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// writeCanonical writes an unambiguous serialization of a normalized tree: every value
+// is tagged by its kind, and strings and collections are prefixed by their length.
+func writeCanonical(w io.Writer, tree interface{}) {
+	writeString := func(tag byte, s string) {
+		w.Write([]byte{tag})
+		w.Write([]byte(strconv.Itoa(len(s)) + ":" + s))
+	}
+	switch t := tree.(type) {
+	case nil:
+		w.Write([]byte{'n'})
+	case string:
+		writeString('s', t)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		w.Write([]byte("d" + strconv.Itoa(len(keys)) + ":"))
+		for _, k := range keys {
+			writeString('s', k)
+			writeCanonical(w, t[k])
+		}
+	case []interface{}:
+		w.Write([]byte("l" + strconv.Itoa(len(t)) + ":"))
+		for _, item := range t {
+			writeCanonical(w, item)
+		}
+	default:
+		writeString('v', fmt.Sprintf("%T:%v", t, t))
+	}
+}
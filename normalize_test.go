@@ -0,0 +1,110 @@
+package nestext
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("b", []string{"x", "y"})
+	m.Set("a", map[string]string{"k": "v"})
+	tree := []interface{}{m, [2]string{"1", "2"}, "s"}
+	expected := []interface{}{
+		map[string]interface{}{
+			"a": map[string]interface{}{"k": "v"},
+			"b": []interface{}{"x", "y"},
+		},
+		[]interface{}{"1", "2"},
+		"s",
+	}
+	n := Normalize(tree)
+	if !reflect.DeepEqual(n, expected) {
+		t.Errorf("expected %#v, have %#v", expected, n)
+	}
+	if s := fmt.Sprintf("%v", Normalize(m)); s != "map[a:map[k:v] b:[x y]]" {
+		t.Errorf("expected keys to be sorted, have %s", s)
+	}
+	if _, ok := m.Get("b"); !ok || m.Keys()[0] != "b" {
+		t.Error("expected ordered map to be left unchanged")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	input := "b: 1\na:\n  - x\n"
+	plain, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ordered, err := Parse(strings.NewReader(input), OrderedDicts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(plain, ordered) {
+		t.Errorf("expected %v to equal %v", plain, ordered)
+	}
+	if Equal(plain, map[string]interface{}{"a": []string{"x"}, "b": "2"}) {
+		t.Error("expected trees with different values not to be equal")
+	}
+}
+
+func TestNormalizeNil(t *testing.T) {
+	nils := []interface{}{map[string]interface{}(nil), (*OrderedMap)(nil), map[string]interface{}{}, NewOrderedMap()}
+	for i, a := range nils {
+		for j, b := range nils {
+			if !Equal(a, b) {
+				t.Errorf("expected %#v (%d) to equal %#v (%d)", a, i, b, j)
+			}
+		}
+	}
+	if !Equal([]interface{}(nil), []string{}) {
+		t.Error("expected nil list to equal empty list")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a, _ := Parse(strings.NewReader("b: 1\na:\n  - x\n  - y\nc.d: 3\n"), OrderedDicts())
+	b := map[string]interface{}{"a": []string{"x"}, "b": "2", "c.d": "3", "e": "4"}
+	expected := []Difference{
+		{Path: "a[1]", A: "y"},
+		{Path: "b", A: "1", B: "2"},
+		{Path: "e", B: "4"},
+	}
+	if diffs := Diff(a, b); !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected %v, have %v", expected, diffs)
+	}
+	if diffs := Diff(a, a); diffs != nil {
+		t.Errorf("expected no differences, have %v", diffs)
+	}
+	if diffs := Diff("x", []interface{}{"x"}); len(diffs) != 1 || diffs[0].Path != "" {
+		t.Errorf("expected top-level value to differ, have %v", diffs)
+	}
+	m := map[string]interface{}{"a.b": map[string]interface{}{"c": "1"}}
+	if diffs := Diff(m, map[string]interface{}{}); len(diffs) != 1 || diffs[0].Path != `a\.b` {
+		t.Errorf("expected escaped path of missing dict, have %v", diffs)
+	}
+}
+
+func TestCanonicalHash(t *testing.T) {
+	input := "b: 1\na:\n  - x\n"
+	plain, _ := Parse(strings.NewReader(input))
+	ordered, _ := Parse(strings.NewReader(input), OrderedDicts())
+	if CanonicalHash(plain) != CanonicalHash(ordered) {
+		t.Error("expected equal trees to have the same hash")
+	}
+	distinct := []interface{}{
+		nil, "", []interface{}{}, map[string]interface{}{}, "1", 1,
+		[]interface{}{"ab"}, []interface{}{"a", "b"},
+		map[string]interface{}{"a": "b"}, map[string]interface{}{"ab": ""},
+	}
+	seen := map[[32]byte]int{}
+	for i, tree := range distinct {
+		h := CanonicalHash(tree)
+		if j, ok := seen[h]; ok {
+			t.Errorf("expected trees %#v and %#v to have different hashes", distinct[j], tree)
+		}
+		seen[h] = i
+	}
+}
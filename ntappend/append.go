@@ -0,0 +1,321 @@
+// Package ntappend appends items to NestedText files without parsing the
+// complete document.
+//
+// This is intended for high-frequency, low-risk additions to documents which
+// grow over time, like audit records collected in a top-level list. Only the first
+// item line of a document is inspected to check the type of the top-level item;
+// the remainder of the document is neither read nor validated. Appending to a nested
+// dict (see AppendEntryAt) requires the lines of the document to be scanned for the
+// position of the dict, but the document is not parsed either.
+//
+// Appended values are encoded by package `ntenc`, which determines the layout of
+// multi-line values. Every item is written by a single call to Write on a file
+// opened in append mode, thus concurrent appends of moderately sized items will
+// not interleave on most operating systems.
+//
+package ntappend
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntenc"
+)
+
+// AppendItem appends a list item to a NestedText file with a top-level list.
+// If the file does not exist, it is created.
+//
+// Encoder options are used for encoding `value`.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+//
+func AppendItem(path string, value interface{}, opts ...ntenc.EncoderOption) error {
+	return appendTo(path, "list", []interface{}{value}, opts)
+}
+
+// AppendEntry appends a key-value pair to a NestedText file with a top-level dict.
+// If the file does not exist, it is created.
+// AppendEntry does not check if `key` is already present in the document; in this
+// case, the resulting document will be invalid.
+//
+// Encoder options are used for encoding `value`.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+//
+func AppendEntry(path string, key string, value interface{}, opts ...ntenc.EncoderOption) error {
+	return appendTo(path, "dict", map[string]interface{}{key: value}, opts)
+}
+
+// AppendEntryAt appends a key-value pair to the dict at path expression `at` (see
+// nestext.ParsePath) of a NestedText file, like AppendEntry does for the top-level
+// dict. The path has to consist of keys only, and the dict has to be the last item of
+// the document on every level, as entries are appended to the end of the file:
+//
+//     # audit.nt
+//     service: orders
+//     events:
+//       2024-05-01T12:00:00Z: started
+//
+//     err := ntappend.AppendEntryAt("audit.nt", "events", time.Now().Format(time.RFC3339), "stopped")
+//
+// A key without value, e.g. "events:", becomes a dict with the entry appended. An empty
+// path denotes the top-level dict; in this case the file is created if it does not
+// exist.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+//
+func AppendEntryAt(path string, at string, key string, value interface{}, opts ...ntenc.EncoderOption) error {
+	if at == "" {
+		return AppendEntry(path, key, value, opts...)
+	}
+	keys, err := dictPath(at)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nestext.WrapError(nestext.ErrCodeIO, "cannot open document for appending", err)
+	}
+	defer f.Close()
+	indent, terminated, err := locateFile(path, keys)
+	if err != nil {
+		return err
+	}
+	var entry, buf bytes.Buffer
+	if _, err = ntenc.Encode(map[string]interface{}{key: value}, &entry, opts...); err != nil {
+		return err
+	}
+	if !terminated {
+		buf.WriteByte('\n')
+	}
+	prefix := strings.Repeat(" ", indent)
+	for _, line := range strings.SplitAfter(entry.String(), "\n") {
+		if line != "" && line != "\n" {
+			buf.WriteString(prefix)
+		}
+		buf.WriteString(line)
+	}
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		return nestext.WrapError(nestext.ErrCodeIO, "cannot append to document", err)
+	}
+	return nil
+}
+
+// dictPath converts path expression `at` to a sequence of keys.
+func dictPath(at string) ([]string, error) {
+	segments, err := nestext.ParsePath(at)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(segments))
+	for i, seg := range segments {
+		if seg.IsIndex || seg.Wildcard || seg.Recursive || seg.Glob {
+			return nil, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+				fmt.Sprintf("path %q has to consist of keys only", at))
+		}
+		keys[i] = seg.Key
+	}
+	return keys, nil
+}
+
+func appendTo(path string, toplevel string, item interface{}, opts []ntenc.EncoderOption) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nestext.WrapError(nestext.ErrCodeIO, "cannot open document for appending", err)
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	if doctype, terminated, err := inspectFile(path); err != nil {
+		return err
+	} else if doctype != "" && doctype != toplevel {
+		return nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+			fmt.Sprintf("cannot append to %s: top-level item is a %s", path, doctype))
+	} else if !terminated {
+		buf.WriteByte('\n')
+	}
+	if _, err = ntenc.Encode(item, &buf, opts...); err != nil {
+		return err
+	}
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		return nestext.WrapError(nestext.ErrCodeIO, "cannot append to document", err)
+	}
+	return nil
+}
+
+// inspectFile inspects the document at `path` (see inspect), using a read-only handle,
+// as the handle for appending cannot be read from.
+func inspectFile(path string) (doctype string, terminated bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, nestext.WrapError(nestext.ErrCodeIO, "cannot inspect document", err)
+	}
+	defer f.Close()
+	return inspect(f)
+}
+
+// inspect determines the type of the top-level item of a document ("list", "dict",
+// "string", "inline list" or "inline dict"), with "" denoting an empty document,
+// and checks if the document ends with a line break.
+func inspect(f *os.File) (doctype string, terminated bool, err error) {
+	empty, terminated, err := terminatedFile(f)
+	if err != nil || empty {
+		return "", terminated, err
+	}
+	input := bufio.NewScanner(f)
+	for first := true; input.Scan(); first = false {
+		line := input.Text()
+		if first { // the parser skips a byte order mark as well
+			line = strings.TrimPrefix(line, byteOrderMark)
+		}
+		if trimmed := strings.TrimSpace(line); trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		switch {
+		case line == "-" || strings.HasPrefix(line, "- "):
+			return "list", terminated, nil
+		case line[0] == '[':
+			return "inline list", terminated, nil
+		case line[0] == '{':
+			return "inline dict", terminated, nil
+		case line == ">" || strings.HasPrefix(line, "> "):
+			return "string", terminated, nil
+		}
+		return "dict", terminated, nil
+	}
+	if err = input.Err(); err != nil {
+		return "", false, nestext.WrapError(nestext.ErrCodeIO, "cannot inspect document", err)
+	}
+	return "", terminated, nil
+}
+
+// byteOrderMark is the UTF-8 encoded byte order mark, which may start a document.
+const byteOrderMark = "\ufeff"
+
+// terminatedFile checks if a file is empty, and if it ends with a line break. Empty
+// files count as terminated.
+func terminatedFile(f *os.File) (empty bool, terminated bool, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return false, false, nestext.WrapError(nestext.ErrCodeIO, "cannot inspect document", err)
+	}
+	if info.Size() == 0 {
+		return true, true, nil
+	}
+	last := make([]byte, 1)
+	if _, err = f.ReadAt(last, info.Size()-1); err != nil {
+		return false, false, nestext.WrapError(nestext.ErrCodeIO, "cannot inspect document", err)
+	}
+	return false, last[0] == '\n' || last[0] == '\r', nil
+}
+
+// --- Locating nested dicts -------------------------------------------------
+
+// trailItem is an item on the way to the last line of a document.
+type trailItem struct {
+	indent      int    // indentation of the item
+	key         string // key of a dict item
+	isKey       bool   // is the item a dict item with a single-line key?
+	inline      bool   // has the item a value on the same line?
+	child       string // kind of the first line nested into the item, if any
+	childIndent int    // indentation of the first line nested into the item
+}
+
+// locateFile locates the dict at `keys` in the document at `path` (see locate), using
+// a read-only handle.
+func locateFile(path string, keys []string) (indent int, terminated bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false, nestext.WrapError(nestext.ErrCodeIO, "cannot inspect document", err)
+	}
+	defer f.Close()
+	if _, terminated, err = terminatedFile(f); err != nil {
+		return 0, false, err
+	}
+	indent, err = locate(f, path, keys)
+	return indent, terminated, err
+}
+
+// locate scans the lines of a document for the trail of items leading to its last
+// line, and returns the indentation of entries of the dict at `keys`. The dict has to
+// be on the trail. Lines are classified by their tags only; the document is not
+// validated.
+func locate(f *os.File, path string, keys []string) (int, error) {
+	var trail []trailItem
+	input := bufio.NewScanner(f)
+	for first := true; input.Scan(); first = false {
+		line := input.Text()
+		if first {
+			line = strings.TrimPrefix(line, byteOrderMark)
+		}
+		trimmed := strings.TrimLeft(line, " ")
+		if t := strings.TrimSpace(trimmed); t == "" || t[0] == '#' {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		for len(trail) > 0 && trail[len(trail)-1].indent >= indent {
+			trail = trail[:len(trail)-1]
+		}
+		item, kind := classifyLine(trimmed)
+		if len(trail) > 0 && trail[len(trail)-1].child == "" {
+			trail[len(trail)-1].child, trail[len(trail)-1].childIndent = kind, indent
+		}
+		if kind == "list" || kind == "dict" {
+			item.indent = indent
+			trail = append(trail, item)
+		}
+	}
+	if err := input.Err(); err != nil {
+		return 0, nestext.WrapError(nestext.ErrCodeIO, "cannot inspect document", err)
+	}
+	at := strings.Join(keys, ".")
+	if len(trail) < len(keys) {
+		return 0, locateError(path, at, "is not the last item of the document")
+	}
+	for i, key := range keys {
+		if !trail[i].isKey || trail[i].key != key {
+			return 0, locateError(path, at, "is not the last item of the document")
+		}
+	}
+	target := trail[len(keys)-1]
+	switch {
+	case target.inline:
+		return 0, locateError(path, at, "is not a dict")
+	case target.child == "":
+		return target.indent + 2, nil
+	case target.child != "dict":
+		return 0, locateError(path, at, "is not a dict")
+	}
+	return target.childIndent, nil
+}
+
+func locateError(path, at, msg string) error {
+	return nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+		fmt.Sprintf("cannot append to %s: item %q %s", path, at, msg))
+}
+
+// classifyLine determines the kind of a line ("list", "dict", "string" or "inline")
+// from its tag, with indentation removed. For list and dict items, it returns the item.
+func classifyLine(line string) (trailItem, string) {
+	switch {
+	case line == "-" || strings.HasPrefix(line, "- "):
+		return trailItem{inline: line != "-"}, "list"
+	case line == ">" || strings.HasPrefix(line, "> "):
+		return trailItem{}, "string"
+	case line == ":" || strings.HasPrefix(line, ": "):
+		return trailItem{}, "dict" // line of a multi-line key
+	case line[0] == '[' || line[0] == '{':
+		return trailItem{}, "inline"
+	}
+	if i := strings.Index(line, ": "); i >= 0 {
+		return trailItem{key: strings.TrimRight(line[:i], " \t"), isKey: true,
+			inline: strings.TrimSpace(line[i+2:]) != ""}, "dict"
+	}
+	if strings.HasSuffix(line, ":") {
+		return trailItem{key: strings.TrimRight(line[:len(line)-1], " \t"), isKey: true}, "dict"
+	}
+	return trailItem{}, "string" // invalid line, treated as part of a value
+}
@@ -0,0 +1,137 @@
+package ntappend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+func TestAppendItem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.nt")
+	if err := ioutil.WriteFile(path, []byte("# audit log\n- first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendItem(path, "second"); err != nil {
+		t.Fatal(err)
+	}
+	record := map[string]interface{}{"who": "me", "what": []string{"a", "b"}}
+	if err := AppendItem(path, record); err != nil {
+		t.Fatal(err)
+	}
+	tree := parseFile(t, path)
+	expected := []interface{}{"first", "second", map[string]interface{}{
+		"who": "me", "what": []interface{}{"a", "b"},
+	}}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("expected %#v, have %#v", expected, tree)
+	}
+}
+
+func TestAppendEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.nt")
+	if err := AppendEntry(path, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendEntry(path, "b", "multi\nline"); err != nil {
+		t.Fatal(err)
+	}
+	tree := parseFile(t, path)
+	expected := map[string]interface{}{"a": "1", "b": "multi\nline"}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("expected %#v, have %#v", expected, tree)
+	}
+	if err := AppendItem(path, "x"); err == nil {
+		t.Error("expected appending a list item to a dict to fail, didn't")
+	} else {
+		t.Logf("got expected error: %v", err)
+	}
+}
+
+func TestAppendItemAfterByteOrderMark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.nt")
+	if err := ioutil.WriteFile(path, []byte("\xef\xbb\xbf- a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendItem(path, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if tree := parseFile(t, path); !reflect.DeepEqual(tree, []interface{}{"a", "b"}) {
+		t.Errorf("expected [a b], have %#v", tree)
+	}
+}
+
+func TestAppendEntryAt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.nt")
+	doc := "\xef\xbb\xbfservice: orders\nlog:\n    # events by time\n    events:\n        t1: started\n        t2:\n            > multi\n            > line\n"
+	if err := ioutil.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendEntryAt(path, "log.events", "t3", "stopped"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendEntryAt(path, "log", "empty", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendEntryAt(path, "log.empty", "t4", map[string]interface{}{"a": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	tree := parseFile(t, path)
+	expected := map[string]interface{}{
+		"service": "orders",
+		"log": map[string]interface{}{
+			"events": map[string]interface{}{"t1": "started", "t2": "multi\nline", "t3": "stopped"},
+			"empty":  map[string]interface{}{"t4": map[string]interface{}{"a": "1"}},
+		},
+	}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("expected %#v, have %#v", expected, tree)
+	}
+	for _, at := range []string{"service", "log.events", "missing", "log[0]", "log.*"} {
+		if err := AppendEntryAt(path, at, "x", "1"); err == nil {
+			t.Errorf("expected appending to %q to fail, didn't", at)
+		}
+	}
+}
+
+func parseFile(t *testing.T, path string) interface{} {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tree, err := nestext.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestAppendConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.nt")
+	const n = 100
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- AppendItem(path, map[string]interface{}{"record": fmt.Sprint(i)})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tree, ok := parseFile(t, path).([]interface{}); !ok || len(tree) != n {
+		t.Errorf("expected %d items, have %#v", n, tree)
+	}
+}
@@ -0,0 +1,233 @@
+// Package ntast represents NestedText documents as trees of nodes, preserving
+// everything in the source: comments, blank lines, indentation, the layout of
+// multi-line items and line terminators. Writing a document tree reproduces its
+// source byte by byte.
+//
+// This is the foundation for tools which edit documents and have to keep the
+// changes minimal, like formatters or refactoring tools. Nodes may be edited with
+// methods SetValue, SetKey, InsertKey, RemoveKey and AppendListItem, which re-create
+// the source lines of the edited items only; package ntrefactor builds refactorings
+// on top of them. Clients interested in the values of a document only should use
+// nestext.Parse instead.
+//
+// Every node owns the source lines it has been created from. Container nodes
+// (lists and dicts) do not own lines themselves, but hold their items as children.
+// Items with a nested value hold the value as a child node, possibly preceded by
+// comments and blank lines. Comments and blank lines between items are children
+// of the innermost list or dict which continues after them.
+//
+// Use as:
+//     doc, err := ntast.Parse(reader)
+//     …
+//     for _, item := range doc.Root().Items() {
+//         fmt.Printf("line %d: %s\n", item.Line, item.Key)
+//     }
+//     doc.WriteTo(w)  // reproduces the input
+//
+package ntast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Nodes -----------------------------------------------------------------
+
+// NodeType is the type of a node of a document tree.
+type NodeType int8
+
+// Types of nodes
+const (
+	BlankNode      NodeType = iota // blank line
+	CommentNode                    // comment line
+	ListNode                       // list; children are list items, comments and blank lines
+	DictNode                       // dict; children are dict items, comments and blank lines
+	ListItemNode                   // "- value" or "-" with a nested value
+	DictItemNode                   // "key: value", "key:" or ": key" lines, optionally with a nested value
+	StringNode                     // multi-line string of "> text" lines
+	InlineListNode                 // "[ … ]"
+	InlineDictNode                 // "{ … }"
+)
+
+var nodeTypeNames = []string{"Blank", "Comment", "List", "Dict", "ListItem", "DictItem", "String",
+	"InlineList", "InlineDict"}
+
+func (t NodeType) String() string {
+	if t < 0 || int(t) >= len(nodeTypeNames) {
+		return fmt.Sprintf("NodeType(%d)", int(t))
+	}
+	return nodeTypeNames[t]
+}
+
+// SourceLine is a line of a document's source.
+type SourceLine struct {
+	Text string // text of the line, including indentation
+	EOL  string // line terminator: "\n", "\r\n", "\r" or empty for an unterminated last line
+}
+
+// Node is a node of a document tree.
+type Node struct {
+	Type     NodeType
+	Line     int          // number of the node's first line, starting at 1; 0 for lists, dicts and edited nodes
+	Indent   int          // indentation of the node's lines
+	Key      string       // key of a dict item
+	Value    string       // value of a string, list item or dict item; source of inline items
+	Children []*Node      // items of lists and dicts, nested values of items
+	Lines    []SourceLine // source lines the node has been created from, excluding children
+	step     int          // indentation step of the document, used for editing
+}
+
+// IsTrivia is true for comments and blank lines.
+func (n *Node) IsTrivia() bool {
+	return n.Type == BlankNode || n.Type == CommentNode
+}
+
+// Items returns the children of a list or dict which are not trivia, i.e. its list
+// items or dict items.
+func (n *Node) Items() []*Node {
+	var items []*Node
+	for _, c := range n.Children {
+		if !c.IsTrivia() {
+			items = append(items, c)
+		}
+	}
+	return items
+}
+
+// Nested returns the nested value of a list item or dict item, or nil if the value
+// of the item is given on the item's line (or is empty).
+func (n *Node) Nested() *Node {
+	if n.Type != ListItemNode && n.Type != DictItemNode {
+		return nil
+	}
+	for _, c := range n.Children {
+		if !c.IsTrivia() && !c.isEmpty() {
+			return c
+		}
+	}
+	return nil
+}
+
+// isEmpty is true for lists and dicts without items, which may result from editing.
+func (n *Node) isEmpty() bool {
+	return (n.Type == ListNode || n.Type == DictNode) && len(n.Items()) == 0
+}
+
+// Tree returns the value represented by a node as a tree of strings,
+// []interface{} and map[string]interface{}, just like nestext.Parse would.
+// Comments and blank lines have a value of nil.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (n *Node) Tree() (interface{}, error) {
+	switch n.Type {
+	case ListNode:
+		list := []interface{}{}
+		for _, item := range n.Items() {
+			v, err := item.Tree()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case DictNode:
+		dict := map[string]interface{}{}
+		for _, item := range n.Items() {
+			v, err := item.Tree()
+			if err != nil {
+				return nil, err
+			}
+			dict[item.Key] = v
+		}
+		return dict, nil
+	case ListItemNode, DictItemNode:
+		if nested := n.Nested(); nested != nil {
+			return nested.Tree()
+		}
+		return n.Value, nil
+	case StringNode:
+		return n.Value, nil
+	case InlineListNode, InlineDictNode:
+		return nestext.Parse(strings.NewReader(n.Value))
+	}
+	return nil, nil
+}
+
+// writeTo writes the source lines of a node and its children.
+func (n *Node) writeTo(w io.Writer, cnt int64) (int64, error) {
+	for _, l := range n.Lines {
+		c, err := io.WriteString(w, l.Text+l.EOL)
+		cnt += int64(c)
+		if err != nil {
+			return cnt, err
+		}
+	}
+	for _, child := range n.Children {
+		var err error
+		if cnt, err = child.writeTo(w, cnt); err != nil {
+			return cnt, err
+		}
+	}
+	return cnt, nil
+}
+
+// --- Documents -------------------------------------------------------------
+
+// Document is the tree representation of a NestedText document.
+type Document struct {
+	Nodes []*Node // top-level nodes: comments, blank lines and at most one value
+}
+
+// Root returns the top-level value of a document, or nil for an empty document.
+func (doc *Document) Root() *Node {
+	for _, n := range doc.Nodes {
+		if !n.IsTrivia() && !n.isEmpty() {
+			return n
+		}
+	}
+	return nil
+}
+
+// Indent returns the indentation width of a document, i.e. the indentation of the
+// first nested value relative to its item, or 0 if there are no nested values.
+// Items added by editing are indented by this width.
+func (doc *Document) Indent() int {
+	if root := doc.Root(); root != nil {
+		return root.step
+	}
+	return 0
+}
+
+// Tree returns the value of a document, just like nestext.Parse would.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (doc *Document) Tree() (interface{}, error) {
+	if root := doc.Root(); root != nil {
+		return root.Tree()
+	}
+	return nil, nil
+}
+
+// WriteTo writes the source of a document to w. For an unmodified document tree,
+// the output is identical to the input it has been parsed from.
+func (doc *Document) WriteTo(w io.Writer) (int64, error) {
+	var cnt int64
+	for _, n := range doc.Nodes {
+		var err error
+		if cnt, err = n.writeTo(w, cnt); err != nil {
+			return cnt, nestext.WrapError(nestext.ErrCodeIO, "write error", err)
+		}
+	}
+	return cnt, nil
+}
+
+// Bytes returns the source of a document.
+func (doc *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	doc.WriteTo(&buf)
+	return buf.Bytes()
+}
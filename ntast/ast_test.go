@@ -0,0 +1,77 @@
+package ntast
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+var roundTripInputs = []string{
+	"",
+	"# only a comment\n\n",
+	"> Hello\n# interspersed\n>\n> World",
+	"-:: x\n",
+	"# header\r\n\r\nname: Alice  \r\ntags:\r\n  # first\r\n  - a\r\n\r\n  -\r\n    > line\r\n  - [x, y]\r\n# trailer\r\n",
+	"a:\n  - x\n  # after x\nb: y\n  # trailing\n\n",
+	": multi\n:   line key\n  > value\n: empty\nc:\n",
+	"-\n  {a: 0}\n- z\n-\n",
+	"president:\n   name: Katheryn McDaniel\n   address:\n      > 138 Almond Street\n      > Topeka, Kansas 20697\n" +
+		"   phone:\n      cell: 1-210-555-5297\n      home: 1-210-555-8470\n         # call her on her cell phone.\n" +
+		"   additional roles:\n      - board member\n",
+	"a: 1\rb: 2\r\nc: 3\n",
+}
+
+func TestRoundTrip(t *testing.T) {
+	for i, input := range roundTripInputs {
+		doc, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("[%d] %v", i, err)
+		}
+		if out := doc.Bytes(); !bytes.Equal(out, []byte(input)) {
+			t.Errorf("[%d] expected output to equal input\n%q\nhave\n%q", i, input, out)
+		}
+		expected, _ := nestext.Parse(strings.NewReader(input))
+		tree, err := doc.Tree()
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+		}
+		if !reflect.DeepEqual(tree, expected) {
+			t.Errorf("[%d] expected tree %#v, have %#v", i, expected, tree)
+		}
+	}
+}
+
+func TestNodes(t *testing.T) {
+	input := "# header\na:\n  # about x\n  - x\n  -\n    > s\nb: y\n"
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.Root()
+	if root.Type != DictNode || len(root.Items()) != 2 {
+		t.Fatalf("expected root to be a dict with 2 items, is %s", root.Type)
+	}
+	a := root.Items()[0]
+	if a.Key != "a" || a.Line != 2 || a.Nested() == nil || a.Nested().Type != ListNode {
+		t.Errorf("unexpected dict item %+v", a)
+	}
+	if c := a.Children[0]; c.Type != CommentNode || c.Value != " about x" || c.Line != 3 {
+		t.Errorf("expected comment preceding nested list, have %+v", c)
+	}
+	items := a.Nested().Items()
+	if len(items) != 2 || items[0].Value != "x" || items[1].Nested().Type != StringNode {
+		t.Errorf("unexpected list items %v", items)
+	}
+	if b := root.Items()[1]; b.Value != "y" || b.Nested() != nil {
+		t.Errorf("unexpected dict item %+v", b)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse(strings.NewReader("- a\n  - b\n")); err == nil {
+		t.Errorf("expected invalid document to be rejected")
+	}
+}
@@ -0,0 +1,305 @@
+package ntast
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntenc"
+)
+
+// --- Editing ---------------------------------------------------------------
+
+// SetValue replaces the value of a list item or dict item with `value`, which may be
+// any tree ntenc.Encode is able to encode. The lines of the item are re-created,
+// including its nested value, while all other nodes keep their source lines. Comments
+// and blank lines within the item's old value are dropped.
+//
+// Use as:
+//     doc, err := ntast.Parse(reader)
+//     …
+//     port := doc.Root().Lookup("port")
+//     err = port.SetValue("8080")
+//     doc.WriteTo(w)  // changes the line of item "port" only
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (n *Node) SetValue(value interface{}) error {
+	var tree interface{}
+	switch n.Type {
+	case ListItemNode:
+		tree = []interface{}{value}
+	case DictItemNode:
+		tree = map[string]interface{}{n.Key: value}
+	default:
+		return nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("cannot set value of %s node", n.Type))
+	}
+	item, err := render(tree, n, n.Indent)
+	if err != nil {
+		return err
+	}
+	if last := lastLine(n); last != nil && last.EOL == "" {
+		lastLine(item).EOL = ""
+	}
+	*n = *item
+	return nil
+}
+
+// SetKey replaces the key of a dict item with `key`. The lines holding the key are
+// re-created, while a nested value keeps its source lines, including comments and
+// blank lines. If the new key has to be written as a multi-line key, a value given on
+// the item's line becomes a nested string.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (n *Node) SetKey(key string) error {
+	if n.Type != DictItemNode {
+		return nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("cannot set key of %s node", n.Type))
+	}
+	nested := n.Nested()
+	var value interface{} = n.Value
+	if nested != nil {
+		value = ""
+	}
+	item, err := render(map[string]interface{}{key: value}, n, n.Indent)
+	if err != nil {
+		return err
+	}
+	if nested != nil {
+		item.Children = n.Children
+	} else if last := lastLine(n); last != nil && last.EOL == "" {
+		lastLine(item).EOL = ""
+	}
+	*n = *item
+	return nil
+}
+
+// Reindent changes the indentation of n to `indent`, shifting all the lines of its
+// subtree by the same amount. Lines indented by less than the amount to remove, e.g.
+// comments, lose their indentation. Blank lines are kept as they are.
+func (n *Node) Reindent(indent int) {
+	if delta := indent - n.Indent; delta != 0 {
+		shift(n, delta)
+	}
+}
+
+func shift(n *Node, delta int) {
+	n.Indent += delta
+	if n.Indent < 0 {
+		n.Indent = 0
+	}
+	for i, l := range n.Lines {
+		if strings.TrimSpace(l.Text) == "" {
+			continue
+		}
+		if delta > 0 {
+			n.Lines[i].Text = strings.Repeat(" ", delta) + l.Text
+		} else {
+			body := strings.TrimLeft(l.Text, " ")
+			if remove := len(l.Text) - len(body); remove > -delta {
+				n.Lines[i].Text = l.Text[-delta:]
+			} else {
+				n.Lines[i].Text = body
+			}
+		}
+	}
+	for _, c := range n.Children {
+		shift(c, delta)
+	}
+}
+
+// Lookup returns the item of a dict with key `key`, or nil if there is no such item.
+func (n *Node) Lookup(key string) *Node {
+	if n.Type != DictNode {
+		return nil
+	}
+	for _, item := range n.Items() {
+		if item.Key == key {
+			return item
+		}
+	}
+	return nil
+}
+
+// InsertKey inserts a new item with key `key` and value `value` into a dict, in front
+// of the item at position `index` (counting items only). Comments and blank lines
+// preceding that item stay with it. An index equal to the number of items appends
+// the new item. Inserting a key which is already present is an error.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (n *Node) InsertKey(index int, key string, value interface{}) (*Node, error) {
+	if n.Type != DictNode {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("cannot insert key into %s node", n.Type))
+	}
+	if n.Lookup(key) != nil {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("duplicate key %q", key))
+	}
+	items := n.Items()
+	if index < 0 || index > len(items) {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("index %d out of range for dict of %d items", index, len(items)))
+	}
+	item, err := render(map[string]interface{}{key: value}, n, n.Indent)
+	if err != nil {
+		return nil, err
+	}
+	if index == len(items) {
+		n.appendItem(item)
+		return item, nil
+	}
+	at := 0
+	for n.Children[at] != items[index] {
+		at++
+	}
+	for at > 0 && n.Children[at-1].IsTrivia() {
+		at--
+	}
+	n.Children = append(n.Children, nil)
+	copy(n.Children[at+1:], n.Children[at:])
+	n.Children[at] = item
+	return item, nil
+}
+
+// RemoveKey removes the item with key `key` from a dict, together with its nested
+// value. Comments and blank lines preceding the item are kept. It returns false if
+// there is no such item.
+//
+// Removing the only item of a dict leaves the enclosing item with an empty value.
+func (n *Node) RemoveKey(key string) bool {
+	item := n.Lookup(key)
+	if item == nil {
+		return false
+	}
+	for i, c := range n.Children {
+		if c != item {
+			continue
+		}
+		n.Children = append(n.Children[:i], n.Children[i+1:]...)
+		if last := lastLine(item); last.EOL == "" && i > 0 && i == len(n.Children) {
+			lastLine(n.Children[i-1]).EOL = ""
+		}
+		break
+	}
+	return true
+}
+
+// AppendListItem appends a new item with value `value` to a list.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (n *Node) AppendListItem(value interface{}) (*Node, error) {
+	if n.Type != ListNode {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("cannot append list item to %s node", n.Type))
+	}
+	item, err := render([]interface{}{value}, n, n.Indent)
+	if err != nil {
+		return nil, err
+	}
+	n.appendItem(item)
+	return item, nil
+}
+
+// appendItem appends an item to a list or dict. If the container ends the document
+// without a final line terminator, the new item takes over this property.
+func (n *Node) appendItem(item *Node) {
+	if len(n.Children) > 0 {
+		if last := lastLine(n.Children[len(n.Children)-1]); last != nil && last.EOL == "" {
+			last.EOL = lastLine(item).EOL
+			lastLine(item).EOL = ""
+		}
+	}
+	n.Children = append(n.Children, item)
+}
+
+// render encodes a list or dict with a single item and returns the node of this item,
+// indented by `indent`. Indentation steps and line terminators are taken over from
+// the nodes in `context`.
+func render(tree interface{}, context *Node, indent int) (*Node, error) {
+	step, eol := context.step, lineTerminator(context)
+	if step == 0 {
+		step = 2
+	}
+	if eol == "" {
+		eol = "\n"
+	}
+	var buf bytes.Buffer
+	if _, err := ntenc.Encode(tree, &buf, ntenc.IndentBy(step)); err != nil {
+		return nil, err
+	}
+	prefix := strings.Repeat(" ", indent)
+	lines := splitLines(buf.Bytes())
+	for i, l := range lines {
+		lines[i] = classify(SourceLine{Text: prefix + l.src.Text, EOL: eol})
+	}
+	t := ListNode
+	if _, ok := tree.(map[string]interface{}); ok {
+		t = DictNode
+	}
+	p := &parser{lines: lines}
+	item := p.parseContainer(t, indent).Children[0]
+	setStep(item, step)
+	clearLineNumbers(item)
+	return item, nil
+}
+
+// indentStep returns the indentation of the first nested value in the subtree of n,
+// relative to its item, or 0 if there is none.
+func indentStep(n *Node) int {
+	if nested := n.Nested(); nested != nil && nested.Indent > n.Indent {
+		return nested.Indent - n.Indent
+	}
+	for _, c := range n.Children {
+		if step := indentStep(c); step > 0 {
+			return step
+		}
+	}
+	return 0
+}
+
+// lineTerminator returns the first non-empty line terminator in the subtree of n,
+// or "" if there is none.
+func lineTerminator(n *Node) string {
+	for _, l := range n.Lines {
+		if l.EOL != "" {
+			return l.EOL
+		}
+	}
+	for _, c := range n.Children {
+		if eol := lineTerminator(c); eol != "" {
+			return eol
+		}
+	}
+	return ""
+}
+
+// lastLine returns the last source line of the subtree of n.
+func lastLine(n *Node) *SourceLine {
+	for i := len(n.Children) - 1; i >= 0; i-- {
+		if l := lastLine(n.Children[i]); l != nil {
+			return l
+		}
+	}
+	if len(n.Lines) == 0 {
+		return nil
+	}
+	return &n.Lines[len(n.Lines)-1]
+}
+
+// setStep sets the indentation step for the subtree of n.
+func setStep(n *Node, step int) {
+	n.step = step
+	for _, c := range n.Children {
+		setStep(c, step)
+	}
+}
+
+// clearLineNumbers marks the subtree of n as created by editing.
+func clearLineNumbers(n *Node) {
+	n.Line = 0
+	for _, c := range n.Children {
+		clearLineNumbers(c)
+	}
+}
@@ -0,0 +1,134 @@
+package ntast
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetValue(t *testing.T) {
+	input := "# server config\nhost: localhost\nport: 80  \n\n# users\nusers:\n    - alice\n"
+	doc := mustParse(t, input)
+	if err := doc.Root().Lookup("port").SetValue("8080"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "# server config\nhost: localhost\nport: 8080\n\n# users\nusers:\n    - alice\n"
+	expectSource(t, doc, expected)
+	if err := doc.Root().Lookup("host").SetValue([]interface{}{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = "# server config\nhost:\n    - a\n    - b\nport: 8080\n\n# users\nusers:\n    - alice\n"
+	expectSource(t, doc, expected)
+	users := doc.Root().Lookup("users").Nested().Items()
+	if err := users[0].SetValue("line 1\nline 2"); err != nil {
+		t.Fatal(err)
+	}
+	expected = "# server config\nhost:\n    - a\n    - b\nport: 8080\n\n# users\nusers:\n    -\n        > line 1\n        > line 2\n"
+	expectSource(t, doc, expected)
+	if err := doc.Root().SetValue("x"); err == nil {
+		t.Errorf("expected setting the value of a dict to fail")
+	}
+}
+
+func TestInsertKey(t *testing.T) {
+	doc := mustParse(t, "a: 1\r\n\r\n# about c\r\nc: 3")
+	root := doc.Root()
+	if _, err := root.InsertKey(1, "b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.InsertKey(3, "d: e", map[string]interface{}{"x": "y"}); err != nil {
+		t.Fatal(err)
+	}
+	expectSource(t, doc, "a: 1\r\nb: 2\r\n\r\n# about c\r\nc: 3\r\n: d: e\r\n  x: y")
+	if _, err := root.InsertKey(0, "a", "again"); err == nil {
+		t.Errorf("expected duplicate key to be rejected")
+	}
+	if _, err := root.InsertKey(9, "z", "z"); err == nil {
+		t.Errorf("expected index out of range to be rejected")
+	}
+}
+
+func TestRemoveKey(t *testing.T) {
+	doc := mustParse(t, "a:\n  x: 1\n  # about y\n  y: 2\nb: 3")
+	a := doc.Root().Lookup("a").Nested()
+	if !a.RemoveKey("y") || a.RemoveKey("y") {
+		t.Errorf("expected key y to be removed exactly once")
+	}
+	if !doc.Root().RemoveKey("b") {
+		t.Errorf("expected key b to be removed")
+	}
+	expectSource(t, doc, "a:\n  x: 1\n  # about y")
+	a.RemoveKey("x")
+	expectSource(t, doc, "a:\n  # about y")
+	tree, err := doc.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tree, map[string]interface{}{"a": ""}) {
+		t.Errorf("expected key a to have an empty value, have %#v", tree)
+	}
+}
+
+func TestAppendListItem(t *testing.T) {
+	doc := mustParse(t, "list:\n   - a\n   -\n      > b\nnext: x\n")
+	list := doc.Root().Lookup("list").Nested()
+	item, err := list.AppendListItem(map[string]interface{}{"k": "v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Type != ListItemNode || item.Line != 0 {
+		t.Errorf("unexpected list item %+v", item)
+	}
+	if doc.Indent() != 3 {
+		t.Errorf("expected indentation width of 3, have %d", doc.Indent())
+	}
+	expectSource(t, doc, "list:\n   - a\n   -\n      > b\n   -\n      k: v\nnext: x\n")
+	if _, err := doc.Root().AppendListItem("x"); err == nil {
+		t.Errorf("expected appending to a dict to fail")
+	}
+}
+
+func TestSetKey(t *testing.T) {
+	doc := mustParse(t, "a: 1\nb:\n  # about x\n  x: 2\nc: 3")
+	root := doc.Root()
+	if err := root.Lookup("a").SetKey("alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Lookup("b").SetKey("- beta"); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Lookup("c").SetKey("gamma: 3"); err != nil {
+		t.Fatal(err)
+	}
+	expectSource(t, doc, "alpha: 1\n: - beta\n  # about x\n  x: 2\n: gamma: 3\n  > 3")
+	if err := root.SetKey("x"); err == nil {
+		t.Errorf("expected setting the key of a dict to fail")
+	}
+}
+
+func TestReindent(t *testing.T) {
+	doc := mustParse(t, "a:\n    - x\n\n    # y\n    -\n        > y\n")
+	doc.Root().Lookup("a").Nested().Reindent(2)
+	expectSource(t, doc, "a:\n  - x\n\n  # y\n  -\n      > y\n")
+}
+
+// ----------------------------------------------------------------------
+
+func mustParse(t *testing.T, input string) *Document {
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// expectSource checks the source of doc and its validity.
+func expectSource(t *testing.T, doc *Document, expected string) {
+	t.Helper()
+	if out := string(doc.Bytes()); out != expected {
+		t.Errorf("expected document\n%q\nhave\n%q", expected, out)
+	}
+	if _, err := Parse(strings.NewReader(expected)); err != nil {
+		t.Errorf("expected valid document, have %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package ntast
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Parsing ---------------------------------------------------------------
+
+// Parse reads a NestedText document and creates its document tree.
+// The document is validated by nestext.Parse first, applying options `opts`.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func Parse(r io.Reader, opts ...nestext.Option) (*Document, error) {
+	if r == nil {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeFormatNoInput, "no input present")
+	}
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nestext.WrapError(nestext.ErrCodeIO, "I/O error while reading input", err)
+	}
+	if _, err = nestext.Parse(bytes.NewReader(src), opts...); err != nil {
+		return nil, err
+	}
+	p := &parser{lines: splitLines(src)}
+	doc := &Document{}
+	p.trivia(&doc.Nodes)
+	if p.pos < len(p.lines) {
+		root := p.parseValue()
+		setStep(root, indentStep(root))
+		doc.Nodes = append(doc.Nodes, root)
+		p.trivia(&doc.Nodes)
+	}
+	if p.pos < len(p.lines) { // should not happen for valid documents
+		err := nestext.MakeNestedTextError(nestext.ErrCodeFormat, "unused content following valid input")
+		err.Line = p.pos + 1
+		return nil, err
+	}
+	return doc, nil
+}
+
+type lineKind int8
+
+const (
+	blankLine lineKind = iota
+	commentLine
+	listItem   // "- …"
+	stringItem // "> …"
+	keyItem    // ": …"
+	dictItem   // "key: …"
+	inlineList // "[ … ]"
+	inlineDict // "{ … }"
+)
+
+// line is a classified source line.
+type line struct {
+	src      SourceLine
+	kind     lineKind
+	indent   int
+	key      string // key of dict items
+	content  string // text following the item tag
+	hasValue bool   // is the item tag followed by a value?
+}
+
+// splitLines breaks up a document into classified lines, splitting at CR LF, CR or LF.
+func splitLines(src []byte) []line {
+	var lines []line
+	start := 0
+	for start < len(src) {
+		end := start
+		for end < len(src) && src[end] != '\n' && src[end] != '\r' {
+			end++
+		}
+		next := end
+		switch {
+		case end == len(src):
+		case src[end] == '\r' && end+1 < len(src) && src[end+1] == '\n':
+			next = end + 2
+		default:
+			next = end + 1
+		}
+		lines = append(lines, classify(SourceLine{Text: string(src[start:end]), EOL: string(src[end:next])}))
+		start = next
+	}
+	return lines
+}
+
+// classify determines the kind of a line, following the rules of the spec.
+func classify(src SourceLine) line {
+	l := line{src: src}
+	body := strings.TrimLeft(src.Text, " ")
+	l.indent = len(src.Text) - len(body)
+	trimmed := strings.TrimLeft(body, " \t")
+	if trimmed == "" {
+		return l
+	}
+	if trimmed[0] == '#' {
+		l.kind = commentLine
+		return l
+	}
+	switch {
+	case isTagged(body, '-'):
+		l.kind, l.content, l.hasValue = listItem, tagContent(body), len(body) > 1
+	case isTagged(body, '>'):
+		l.kind, l.content, l.hasValue = stringItem, tagContent(body), len(body) > 1
+	case isTagged(body, ':'):
+		l.kind, l.content, l.hasValue = keyItem, tagContent(body), len(body) > 1
+	case body[0] == '[':
+		l.kind, l.content, l.hasValue = inlineList, body, true
+	case body[0] == '{':
+		l.kind, l.content, l.hasValue = inlineDict, body, true
+	default:
+		l.kind = dictItem
+		if i := strings.Index(body, ": "); i >= 0 {
+			l.key, l.content, l.hasValue = strings.TrimSpace(body[:i]), body[i+2:], true
+		} else {
+			l.key = strings.TrimSpace(strings.TrimSuffix(body, ":"))
+		}
+	}
+	return l
+}
+
+func isTagged(body string, tag byte) bool {
+	return body[0] == tag && (len(body) == 1 || body[1] == ' ')
+}
+
+func tagContent(body string) string {
+	if len(body) <= 2 {
+		return ""
+	}
+	return body[2:]
+}
+
+func (l line) isTrivia() bool {
+	return l.kind == blankLine || l.kind == commentLine
+}
+
+// parser builds a document tree from the lines of a valid document.
+type parser struct {
+	lines []line
+	pos   int // index of the next line to process
+}
+
+// next returns the index of the next item line, skipping trivia, or -1 at the end
+// of input.
+func (p *parser) next() int {
+	for i := p.pos; i < len(p.lines); i++ {
+		if !p.lines[i].isTrivia() {
+			return i
+		}
+	}
+	return -1
+}
+
+// continues checks if the next item line has kind `kind` and indentation `indent`.
+func (p *parser) continues(kind lineKind, indent int) bool {
+	i := p.next()
+	return i >= 0 && p.lines[i].kind == kind && p.lines[i].indent == indent
+}
+
+// nestedFollows checks if the next item line is indented deeper than `indent`.
+func (p *parser) nestedFollows(indent int) bool {
+	i := p.next()
+	return i >= 0 && p.lines[i].indent > indent
+}
+
+// trivia appends nodes for the comments and blank lines up to the next item line.
+func (p *parser) trivia(nodes *[]*Node) {
+	for ; p.pos < len(p.lines) && p.lines[p.pos].isTrivia(); p.pos++ {
+		l := p.lines[p.pos]
+		n := &Node{Type: BlankNode, Line: p.pos + 1, Indent: l.indent, Lines: []SourceLine{l.src}}
+		if l.kind == commentLine {
+			n.Type = CommentNode
+			n.Value = strings.TrimSpace(l.src.Text)[1:]
+		}
+		*nodes = append(*nodes, n)
+	}
+}
+
+// parseValue creates a node for the value starting at the current line, which has
+// to be an item line.
+func (p *parser) parseValue() *Node {
+	l := p.lines[p.pos]
+	switch l.kind {
+	case listItem:
+		return p.parseContainer(ListNode, l.indent)
+	case keyItem, dictItem:
+		return p.parseContainer(DictNode, l.indent)
+	case stringItem:
+		n := &Node{Type: StringNode, Line: p.pos + 1, Indent: l.indent}
+		n.Value = p.collectLines(n, stringItem)
+		return n
+	}
+	n := &Node{Type: InlineListNode, Line: p.pos + 1, Indent: l.indent, Value: l.content}
+	if l.kind == inlineDict {
+		n.Type = InlineDictNode
+	}
+	n.Lines = []SourceLine{l.src}
+	p.pos++
+	return n
+}
+
+// parseContainer creates a list or dict node and its items.
+func (p *parser) parseContainer(t NodeType, indent int) *Node {
+	container := &Node{Type: t, Indent: indent}
+	for {
+		l := p.lines[p.pos]
+		item := &Node{Type: ListItemNode, Line: p.pos + 1, Indent: indent}
+		switch l.kind {
+		case listItem:
+			item.Value, item.Lines = l.content, []SourceLine{l.src}
+			p.pos++
+		case dictItem:
+			item.Type = DictItemNode
+			item.Key, item.Value, item.Lines = l.key, l.content, []SourceLine{l.src}
+			p.pos++
+		case keyItem:
+			item.Type = DictItemNode
+			item.Key = p.collectLines(item, keyItem)
+		}
+		if (l.kind == keyItem || !l.hasValue) && p.nestedFollows(indent) {
+			p.trivia(&item.Children)
+			item.Children = append(item.Children, p.parseValue())
+		}
+		container.Children = append(container.Children, item)
+		if t == ListNode && !p.continues(listItem, indent) ||
+			t == DictNode && !p.continues(dictItem, indent) && !p.continues(keyItem, indent) {
+			return container
+		}
+		p.trivia(&container.Children)
+	}
+}
+
+// collectLines adds consecutive lines of kind `kind` to the lines of node n, including
+// interspersed comments and blank lines. It returns the joined content of the lines.
+func (p *parser) collectLines(n *Node, kind lineKind) string {
+	var content []string
+	for {
+		l := p.lines[p.pos]
+		n.Lines = append(n.Lines, l.src)
+		content = append(content, l.content)
+		p.pos++
+		if !p.continues(kind, n.Indent) {
+			return strings.Join(content, "\n")
+		}
+		for ; p.lines[p.pos].isTrivia(); p.pos++ {
+			n.Lines = append(n.Lines, p.lines[p.pos].src)
+		}
+	}
+}
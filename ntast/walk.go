@@ -0,0 +1,62 @@
+package ntast
+
+import (
+	"strconv"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Traversal -------------------------------------------------------------
+
+// WalkFunc is called by Walk for every node, once on entering the node (`enter` is
+// true) and once on leaving it, after its children have been walked. Returning false
+// on entering a node skips its children and the call on leaving it. The return value
+// on leaving a node is ignored.
+//
+// Paths of nodes are formed as described for type nestext.Comments. List items and
+// dict items extend the path of their container by their index or key, while all
+// other nodes share the path of the item they belong to. Comments and blank lines
+// between items have the path of the enclosing list or dict.
+type WalkFunc func(n *Node, path string, enter bool) bool
+
+// Walk traverses the subtree of n in depth-first order, calling fn for every node.
+// Paths are relative to n, i.e. n has the empty path "".
+//
+// Use as:
+//     ntast.Walk(doc.Root(), func(n *ntast.Node, path string, enter bool) bool {
+//         if enter && n.Type == ntast.CommentNode {
+//             fmt.Printf("%s: %s\n", path, n.Value)
+//         }
+//         return true
+//     })
+//
+func Walk(n *Node, fn WalkFunc) {
+	walk(n, "", fn)
+}
+
+// Walk traverses all nodes of a document, including top-level comments and blank
+// lines. See function Walk.
+func (doc *Document) Walk(fn WalkFunc) {
+	for _, n := range doc.Nodes {
+		walk(n, "", fn)
+	}
+}
+
+func walk(n *Node, path string, fn WalkFunc) {
+	if !fn(n, path, true) {
+		return
+	}
+	index := 0
+	for _, c := range n.Children {
+		switch c.Type {
+		case ListItemNode:
+			walk(c, nestext.AppendPath(path, strconv.Itoa(index)), fn)
+			index++
+		case DictItemNode:
+			walk(c, nestext.AppendPath(path, c.Key), fn)
+		default:
+			walk(c, path, fn)
+		}
+	}
+	fn(n, path, false)
+}
@@ -0,0 +1,27 @@
+package ntast
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	doc := mustParse(t, "# header\na:\n  # about x\n  - x\n  -\n    k/1: v\nb: y\n")
+	var trace []string
+	doc.Walk(func(n *Node, path string, enter bool) bool {
+		if enter {
+			trace = append(trace, fmt.Sprintf("%s %q", n.Type, path))
+		} else if n.Type == DictNode {
+			trace = append(trace, "leave "+path)
+		}
+		return n.Type != ListItemNode || n.Value != "x"
+	})
+	expected := []string{
+		`Comment ""`, `Dict ""`, `DictItem "/a"`, `Comment "/a"`, `List "/a"`, `ListItem "/a/0"`,
+		`ListItem "/a/1"`, `Dict "/a/1"`, `DictItem "/a/1/k~11"`, "leave /a/1", `DictItem "/b"`, "leave ",
+	}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("expected\n%v\nhave\n%v", expected, trace)
+	}
+}
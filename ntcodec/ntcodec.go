@@ -0,0 +1,163 @@
+// Package ntcodec is the runtime support package for code generated by `ntgen`.
+//
+// NestedText stores all scalar values as strings. Generated `UnmarshalNestedText`
+// methods call the conversion helpers of this package to check the shape of a
+// parse result and to convert strings into Go types, without resorting to reflection.
+// Errors returned are of type nestext.NestedTextError with code ErrCodeSchema.
+//
+// Clients usually do not call functions of this package directly.
+//
+package ntcodec
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+// Dict asserts that a tree node is a NestedText dict. Dicts parsed with option
+// nestext.OrderedDicts are accepted as well; the map returned must not be modified.
+func Dict(tree interface{}) (map[string]interface{}, error) {
+	switch d := tree.(type) {
+	case map[string]interface{}:
+		return d, nil
+	case *nestext.OrderedMap:
+		return d.Map(), nil
+	}
+	return nil, mismatch("dict", tree)
+}
+
+// List asserts that a tree node is a NestedText list.
+func List(tree interface{}) ([]interface{}, error) {
+	if l, ok := tree.([]interface{}); ok {
+		return l, nil
+	}
+	return nil, mismatch("list", tree)
+}
+
+// String asserts that a tree node is a NestedText string.
+func String(tree interface{}) (string, error) {
+	if s, ok := tree.(string); ok {
+		return s, nil
+	}
+	return "", mismatch("string", tree)
+}
+
+// Int converts a string node to a signed integer of the given bit size
+// (0 denotes the size of int).
+func Int(tree interface{}, bitSize int) (int64, error) {
+	s, err := String(tree)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(s, 0, bitSize)
+	if err != nil {
+		return 0, conversion("integer", s, err)
+	}
+	return n, nil
+}
+
+// Uint converts a string node to an unsigned integer of the given bit size
+// (0 denotes the size of uint).
+func Uint(tree interface{}, bitSize int) (uint64, error) {
+	s, err := String(tree)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(s, 0, bitSize)
+	if err != nil {
+		return 0, conversion("unsigned integer", s, err)
+	}
+	return n, nil
+}
+
+// Float converts a string node to a floating point number of the given bit size.
+func Float(tree interface{}, bitSize int) (float64, error) {
+	s, err := String(tree)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err != nil {
+		return 0, conversion("float", s, err)
+	}
+	return f, nil
+}
+
+// Bool converts a string node to a boolean value. Accepted values are the ones
+// understood by strconv.ParseBool.
+func Bool(tree interface{}) (bool, error) {
+	s, err := String(tree)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, conversion("boolean", s, err)
+	}
+	return b, nil
+}
+
+// Field wraps an error which occured while decoding the value for key `key`.
+// Generated code calls Field on every level of nesting, thus the resulting error
+// message will contain the dotted path to the offending value.
+func Field(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var se *schemaError
+	if errors.As(err, &se) {
+		se = &schemaError{path: append([]string{key}, se.path...), msg: se.msg, cause: se.cause}
+	} else {
+		se = &schemaError{path: []string{key}, msg: err.Error(), cause: err}
+	}
+	return nestext.WrapError(nestext.ErrCodeSchema, se.Error(), se)
+}
+
+// --- Errors ----------------------------------------------------------------
+
+// schemaError is wrapped into a NestedTextError, remembering the path to a value
+// which did not meet the expectations of generated code.
+type schemaError struct {
+	path  []string
+	msg   string
+	cause error
+}
+
+func (se *schemaError) Error() string {
+	if len(se.path) == 0 {
+		return se.msg
+	}
+	return strings.Join(se.path, ".") + ": " + se.msg
+}
+
+func (se *schemaError) Unwrap() error {
+	return se.cause
+}
+
+func mismatch(expected string, tree interface{}) error {
+	se := &schemaError{msg: fmt.Sprintf("expected %s, have %s", expected, kindOf(tree))}
+	return nestext.WrapError(nestext.ErrCodeSchema, se.Error(), se)
+}
+
+func conversion(expected string, s string, err error) error {
+	se := &schemaError{msg: fmt.Sprintf("cannot convert %q to %s", s, expected), cause: err}
+	return nestext.WrapError(nestext.ErrCodeSchema, se.Error(), se)
+}
+
+func kindOf(tree interface{}) string {
+	switch tree.(type) {
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "dict"
+	case nil:
+		return "nothing"
+	}
+	return fmt.Sprintf("%T", tree)
+}
@@ -0,0 +1,91 @@
+package ntcodec_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntcodec"
+	"github.com/npillmayer/nestext/ntenc"
+)
+
+func TestConversions(t *testing.T) {
+	if n, err := ntcodec.Int("0x10", 8); err != nil || n != 16 {
+		t.Errorf("expected 16, have %d (%v)", n, err)
+	}
+	if _, err := ntcodec.Int("300", 8); err == nil {
+		t.Error("expected 300 to overflow int8, didn't")
+	}
+	if b, err := ntcodec.Bool("true"); err != nil || !b {
+		t.Errorf("expected true, have %v (%v)", b, err)
+	}
+	if _, err := ntcodec.String([]interface{}{}); err == nil {
+		t.Error("expected list to be rejected as a string, wasn't")
+	}
+}
+
+func TestGeneratedRoundTrip(t *testing.T) {
+	config := Config{
+		Name:     "test",
+		Timeouts: []int{10, 20},
+		Primary:  Server{Host: "localhost", Port: 8080, Debug: true, Labels: map[string]string{"a": "b"}},
+		Replicas: []Server{{Host: "replica", Port: 8081, Aliases: []string{"r1"}}},
+		Zones:    map[string][]int32{"eu": {1, 2}},
+		Ignored:  "not encoded",
+	}
+	var buf bytes.Buffer
+	if _, err := ntenc.Encode(config, &buf); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("encoded:\n%s", buf.String())
+	tree, err := nestext.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Config
+	if err = decoded.UnmarshalNestedText(tree); err != nil {
+		t.Fatal(err)
+	}
+	config.Ignored = ""
+	if !reflect.DeepEqual(config, decoded) {
+		t.Errorf("round trip failed:\n have %#v\n want %#v", decoded, config)
+	}
+}
+
+func TestGeneratedOrderedDicts(t *testing.T) {
+	input := "Name: test\ntimeouts:\n  - 10\nprimary:\n  host: localhost\n  port: 80\n  labels:\n    a: b\n"
+	tree, err := nestext.Parse(strings.NewReader(input), nestext.OrderedDicts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Config
+	if err = decoded.UnmarshalNestedText(tree); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Primary.Port != 80 || decoded.Primary.Labels["a"] != "b" {
+		t.Errorf("unexpected result %#v", decoded)
+	}
+}
+
+func TestGeneratedErrorPath(t *testing.T) {
+	tree := map[string]interface{}{
+		"Name":    "test",
+		"primary": map[string]interface{}{"port": "99999"},
+	}
+	var config Config
+	err := config.UnmarshalNestedText(tree)
+	if err == nil {
+		t.Fatal("expected port 99999 to overflow uint16, didn't")
+	}
+	t.Logf("error = %v", err)
+	nterr := nestext.NestedTextError{}
+	if !errors.As(err, &nterr) || nterr.Code != nestext.ErrCodeSchema {
+		t.Errorf("expected error to be a schema error")
+	}
+	if !strings.Contains(err.Error(), "primary.port:") {
+		t.Errorf("expected error message to contain path 'primary.port'")
+	}
+}
@@ -0,0 +1,303 @@
+// Code generated by ntgen. DO NOT EDIT.
+
+package ntcodec_test
+
+import (
+	"strconv"
+
+	"github.com/npillmayer/nestext/ntcodec"
+)
+
+// MarshalNestedText implements interface nestext.Marshaler.
+func (x Server) MarshalNestedText() (interface{}, error) {
+	m := make(map[string]interface{}, 6)
+	{
+		var v1 interface{}
+		v1 = string(x.Host)
+		m["host"] = v1
+	}
+	{
+		var v2 interface{}
+		v2 = strconv.FormatUint(uint64(x.Port), 10)
+		m["port"] = v2
+	}
+	if x.Debug {
+		var v3 interface{}
+		v3 = strconv.FormatBool(bool(x.Debug))
+		m["debug"] = v3
+	}
+	if x.Load != 0 {
+		var v4 interface{}
+		v4 = strconv.FormatFloat(float64(x.Load), 'g', -1, 64)
+		m["load"] = v4
+	}
+	if len(x.Aliases) > 0 {
+		var v5 interface{}
+		var l6 []interface{}
+		if x.Aliases != nil {
+			l6 = make([]interface{}, len(x.Aliases))
+		}
+		for i7, e8 := range x.Aliases {
+			var v9 interface{}
+			v9 = string(e8)
+			l6[i7] = v9
+		}
+		v5 = l6
+		m["aliases"] = v5
+	}
+	if len(x.Labels) > 0 {
+		var v10 interface{}
+		var m11 map[string]interface{}
+		if x.Labels != nil {
+			m11 = make(map[string]interface{}, len(x.Labels))
+		}
+		for k12, e13 := range x.Labels {
+			var v14 interface{}
+			v14 = string(e13)
+			m11[k12] = v14
+		}
+		v10 = m11
+		m["labels"] = v10
+	}
+	return m, nil
+}
+
+// UnmarshalNestedText implements interface nestext.Unmarshaler.
+func (x *Server) UnmarshalNestedText(tree interface{}) error {
+	m, err := ntcodec.Dict(tree)
+	if err != nil {
+		return err
+	}
+	if v15, ok := m["host"]; ok {
+		{
+			s, err := ntcodec.String(v15)
+			if err != nil {
+				return ntcodec.Field("host", err)
+			}
+			x.Host = string(s)
+		}
+	}
+	if v16, ok := m["port"]; ok {
+		{
+			n, err := ntcodec.Uint(v16, 16)
+			if err != nil {
+				return ntcodec.Field("port", err)
+			}
+			x.Port = uint16(n)
+		}
+	}
+	if v17, ok := m["debug"]; ok {
+		{
+			b, err := ntcodec.Bool(v17)
+			if err != nil {
+				return ntcodec.Field("debug", err)
+			}
+			x.Debug = bool(b)
+		}
+	}
+	if v18, ok := m["load"]; ok {
+		{
+			f, err := ntcodec.Float(v18, 64)
+			if err != nil {
+				return ntcodec.Field("load", err)
+			}
+			x.Load = float64(f)
+		}
+	}
+	if v19, ok := m["aliases"]; ok {
+		l20, err := ntcodec.List(v19)
+		if err != nil {
+			return ntcodec.Field("aliases", err)
+		}
+		s21 := make([]string, len(l20))
+		for i22, e23 := range l20 {
+			{
+				s, err := ntcodec.String(e23)
+				if err != nil {
+					return ntcodec.Field("aliases", err)
+				}
+				s21[i22] = string(s)
+			}
+		}
+		x.Aliases = s21
+	}
+	if v24, ok := m["labels"]; ok {
+		d25, err := ntcodec.Dict(v24)
+		if err != nil {
+			return ntcodec.Field("labels", err)
+		}
+		m26 := make(map[string]string, len(d25))
+		for k27, e28 := range d25 {
+			var v29 string
+			{
+				s, err := ntcodec.String(e28)
+				if err != nil {
+					return ntcodec.Field("labels", err)
+				}
+				v29 = string(s)
+			}
+			m26[k27] = v29
+		}
+		x.Labels = m26
+	}
+	return nil
+}
+
+// MarshalNestedText implements interface nestext.Marshaler.
+func (x Config) MarshalNestedText() (interface{}, error) {
+	m := make(map[string]interface{}, 5)
+	{
+		var v30 interface{}
+		v30 = string(x.Name)
+		m["Name"] = v30
+	}
+	{
+		var v31 interface{}
+		var l32 []interface{}
+		if x.Timeouts != nil {
+			l32 = make([]interface{}, len(x.Timeouts))
+		}
+		for i33, e34 := range x.Timeouts {
+			var v35 interface{}
+			v35 = strconv.FormatInt(int64(e34), 10)
+			l32[i33] = v35
+		}
+		v31 = l32
+		m["timeouts"] = v31
+	}
+	{
+		var v36 interface{}
+		if t, err := x.Primary.MarshalNestedText(); err != nil {
+			return nil, ntcodec.Field("primary", err)
+		} else {
+			v36 = t
+		}
+		m["primary"] = v36
+	}
+	if len(x.Replicas) > 0 {
+		var v37 interface{}
+		var l38 []interface{}
+		if x.Replicas != nil {
+			l38 = make([]interface{}, len(x.Replicas))
+		}
+		for i39, e40 := range x.Replicas {
+			var v41 interface{}
+			if t, err := e40.MarshalNestedText(); err != nil {
+				return nil, ntcodec.Field("replicas", err)
+			} else {
+				v41 = t
+			}
+			l38[i39] = v41
+		}
+		v37 = l38
+		m["replicas"] = v37
+	}
+	if len(x.Zones) > 0 {
+		var v42 interface{}
+		var m43 map[string]interface{}
+		if x.Zones != nil {
+			m43 = make(map[string]interface{}, len(x.Zones))
+		}
+		for k44, e45 := range x.Zones {
+			var v46 interface{}
+			var l47 []interface{}
+			if e45 != nil {
+				l47 = make([]interface{}, len(e45))
+			}
+			for i48, e49 := range e45 {
+				var v50 interface{}
+				v50 = strconv.FormatInt(int64(e49), 10)
+				l47[i48] = v50
+			}
+			v46 = l47
+			m43[k44] = v46
+		}
+		v42 = m43
+		m["zones"] = v42
+	}
+	return m, nil
+}
+
+// UnmarshalNestedText implements interface nestext.Unmarshaler.
+func (x *Config) UnmarshalNestedText(tree interface{}) error {
+	m, err := ntcodec.Dict(tree)
+	if err != nil {
+		return err
+	}
+	if v51, ok := m["Name"]; ok {
+		{
+			s, err := ntcodec.String(v51)
+			if err != nil {
+				return ntcodec.Field("Name", err)
+			}
+			x.Name = string(s)
+		}
+	}
+	if v52, ok := m["timeouts"]; ok {
+		l53, err := ntcodec.List(v52)
+		if err != nil {
+			return ntcodec.Field("timeouts", err)
+		}
+		s54 := make([]int, len(l53))
+		for i55, e56 := range l53 {
+			{
+				n, err := ntcodec.Int(e56, 0)
+				if err != nil {
+					return ntcodec.Field("timeouts", err)
+				}
+				s54[i55] = int(n)
+			}
+		}
+		x.Timeouts = s54
+	}
+	if v57, ok := m["primary"]; ok {
+		var v58 Server
+		if err := v58.UnmarshalNestedText(v57); err != nil {
+			return ntcodec.Field("primary", err)
+		}
+		x.Primary = v58
+	}
+	if v59, ok := m["replicas"]; ok {
+		l60, err := ntcodec.List(v59)
+		if err != nil {
+			return ntcodec.Field("replicas", err)
+		}
+		s61 := make([]Server, len(l60))
+		for i62, e63 := range l60 {
+			var v64 Server
+			if err := v64.UnmarshalNestedText(e63); err != nil {
+				return ntcodec.Field("replicas", err)
+			}
+			s61[i62] = v64
+		}
+		x.Replicas = s61
+	}
+	if v65, ok := m["zones"]; ok {
+		d66, err := ntcodec.Dict(v65)
+		if err != nil {
+			return ntcodec.Field("zones", err)
+		}
+		m67 := make(map[string][]int32, len(d66))
+		for k68, e69 := range d66 {
+			var v70 []int32
+			l71, err := ntcodec.List(e69)
+			if err != nil {
+				return ntcodec.Field("zones", err)
+			}
+			s72 := make([]int32, len(l71))
+			for i73, e74 := range l71 {
+				{
+					n, err := ntcodec.Int(e74, 32)
+					if err != nil {
+						return ntcodec.Field("zones", err)
+					}
+					s72[i73] = int32(n)
+				}
+			}
+			v70 = s72
+			m67[k68] = v70
+		}
+		x.Zones = m67
+	}
+	return nil
+}
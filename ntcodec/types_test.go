@@ -0,0 +1,28 @@
+package ntcodec_test
+
+//go:generate go run ../cmd/ntgen -o types_ntgen_test.go types_test.go
+
+// Server is a test type for generated code.
+//
+//ntgen:codec
+type Server struct {
+	Host    string            `nt:"host"`
+	Port    uint16            `nt:"port"`
+	Debug   bool              `nt:"debug,omitempty"`
+	Load    float64           `nt:"load,omitempty"`
+	Aliases []string          `nt:"aliases,omitempty"`
+	Labels  map[string]string `nt:"labels,omitempty"`
+	secret  string
+}
+
+// Config is a test type for generated code.
+//
+//ntgen:codec
+type Config struct {
+	Name     string
+	Timeouts []int              `nt:"timeouts"`
+	Primary  Server             `nt:"primary"`
+	Replicas []Server           `nt:"replicas,omitempty"`
+	Zones    map[string][]int32 `nt:"zones,omitempty"`
+	Ignored  string             `nt:"-"`
+}
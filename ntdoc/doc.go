@@ -0,0 +1,263 @@
+// Package ntdoc generates documentation of configuration options from an annotated
+// example configuration file, making the file itself the single source of
+// documentation. Every dict item of the example becomes an option, described by the
+// comment lines preceding it:
+//
+//     # Host name of the primary database server.
+//     database:
+//         host: db.example.com
+//         # Port to connect to.
+//         port: 5432
+//
+// If a Go value is given, options are paired with the struct fields they decode
+// into, following the rules of nestext.Decoder, to document their types and to detect
+// options missing from the example.
+//
+// Use as:
+//     entries, err := ntdoc.Collect(example, &Config{})
+//     …
+//     err = ntdoc.Markdown(w, entries)
+//
+package ntdoc
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Entries ---------------------------------------------------------------
+
+// Entry documents a single configuration option.
+type Entry struct {
+	Path        string // path expression of the option; "*" stands for any list item
+	Description string // comment lines preceding the option in the example
+	Example     string // example value, if the option is a string
+	Field       string // Go struct field, e.g. "Database.Host", if paired with a struct
+	Type        string // Go type of the field
+	Missing     bool   // is the option a struct field missing from the example?
+}
+
+// Collect parses example configuration `example` and returns an entry for every dict
+// item, in the order of the example. The items of lists are documented by the first
+// one. If `v` is non-nil, it has to be a struct or a pointer to a struct, into which
+// configurations will be decoded. Entries are then paired with fields of `v`, and
+// fields missing from the example are appended to the result.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func Collect(example io.Reader, v interface{}) ([]Entry, error) {
+	var comments nestext.Comments
+	tree, err := nestext.Parse(example, nestext.OrderedDicts(), nestext.CaptureComments(&comments))
+	if err != nil {
+		return nil, err
+	}
+	c := &collector{comments: comments.Items}
+	var t reflect.Type
+	if v != nil {
+		t = reflect.TypeOf(v)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+				fmt.Sprintf("cannot pair options with fields of non-struct type %s", t))
+		}
+	}
+	c.collect(tree, nil, "", "", t)
+	return c.entries, nil
+}
+
+type collector struct {
+	comments map[string][]string
+	entries  []Entry
+}
+
+// collect appends entries for the items of `tree`, which has been reached by
+// `segments` and comment path `cpath`. `field` is the Go field `tree` is paired
+// with and `t` its type, if any.
+func (c *collector) collect(tree interface{}, segments []nestext.PathSegment, cpath string,
+	field string, t reflect.Type) {
+	//
+	t = deref(t)
+	switch n := tree.(type) {
+	case *nestext.OrderedMap:
+		var fields []fieldInfo
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = structFields(t, field)
+		}
+		seen := make(map[string]bool)
+		for _, key := range n.Keys() {
+			value, _ := n.Get(key)
+			seg := append(segments[:len(segments):len(segments)], nestext.PathSegment{Key: key})
+			e := Entry{
+				Path:        nestext.JoinPath(seg),
+				Description: description(c.comments[nestext.AppendPath(cpath, key)]),
+			}
+			if s, ok := value.(string); ok {
+				e.Example = s
+			}
+			var ft reflect.Type
+			if t != nil && t.Kind() == reflect.Map {
+				ft = t.Elem()
+				e.Type = ft.String()
+			} else if f := lookup(fields, key); f != nil {
+				seen[f.name] = true
+				ft = f.typ
+				e.Field, e.Type = f.path, f.typ.String()
+			}
+			c.entries = append(c.entries, e)
+			c.collect(value, seg, nestext.AppendPath(cpath, key), e.Field, ft)
+		}
+		for _, f := range fields {
+			if !seen[f.name] {
+				seg := append(segments[:len(segments):len(segments)], nestext.PathSegment{Key: f.name})
+				c.entries = append(c.entries, Entry{
+					Path:    nestext.JoinPath(seg),
+					Field:   f.path,
+					Type:    f.typ.String(),
+					Missing: true,
+				})
+			}
+		}
+	case []interface{}:
+		if len(n) == 0 {
+			return
+		}
+		var et reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		}
+		seg := append(segments[:len(segments):len(segments)], nestext.PathSegment{Key: "*", Wildcard: true})
+		c.collect(n[0], seg, nestext.AppendPath(cpath, "0"), field+"[]", et)
+	}
+}
+
+// description joins comment lines into a single paragraph.
+func description(lines []string) string {
+	words := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			words = append(words, l)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// --- Struct fields ---------------------------------------------------------
+
+// fieldInfo describes a struct field options may decode into.
+type fieldInfo struct {
+	name string       // key in NestedText dict
+	path string       // Go path of the field, starting at the top-level struct
+	typ  reflect.Type // type of the field
+}
+
+// structFields collects the fields of a struct type like nestext.Decoder does,
+// including fields of embedded structs.
+func structFields(t reflect.Type, prefix string) []fieldInfo {
+	var fields, embedded []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("nt")
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			tag = tag[:j]
+		}
+		if tag == "-" {
+			continue
+		}
+		if ft := deref(sf.Type); sf.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, structFields(ft, prefix)...)
+			continue
+		}
+		if sf.PkgPath != "" { // unexported
+			continue
+		}
+		if tag == "" {
+			tag = sf.Name
+		}
+		path := sf.Name
+		if prefix != "" {
+			path = prefix + "." + sf.Name
+		}
+		fields = append(fields, fieldInfo{name: tag, path: path, typ: sf.Type})
+	}
+	// fields of the outer struct take precedence over fields of embedded structs
+	return append(fields, embedded...)
+}
+
+// lookup finds the field for a dict key, preferring an exact match over a
+// case-insensitive one.
+func lookup(fields []fieldInfo, key string) *fieldInfo {
+	var fold *fieldInfo
+	for i := range fields {
+		if fields[i].name == key {
+			return &fields[i]
+		}
+		if fold == nil && strings.EqualFold(fields[i].name, key) {
+			fold = &fields[i]
+		}
+	}
+	return fold
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// --- Markdown --------------------------------------------------------------
+
+// Markdown writes `entries` as a Markdown table to w. Columns for Go fields and types
+// are present only if entries have been paired with a struct. Options without a
+// description are marked as undocumented, as are options missing from the example.
+func Markdown(w io.Writer, entries []Entry) error {
+	typed := false
+	for _, e := range entries {
+		typed = typed || e.Field != ""
+	}
+	var b strings.Builder
+	if typed {
+		b.WriteString("| Option | Field | Type | Example | Description |\n")
+		b.WriteString("|---|---|---|---|---|\n")
+	} else {
+		b.WriteString("| Option | Example | Description |\n")
+		b.WriteString("|---|---|---|\n")
+	}
+	for _, e := range entries {
+		b.WriteString("| " + code(e.Path) + " | ")
+		if typed {
+			b.WriteString(code(e.Field) + " | " + code(e.Type) + " | ")
+		}
+		desc := cell(e.Description)
+		if e.Missing {
+			desc = "*missing from example*"
+		} else if desc == "" {
+			desc = "*undocumented*"
+		}
+		b.WriteString(code(e.Example) + " | " + desc + " |\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// code formats s as inline code within a table cell. Multi-line values are shortened
+// to their first line.
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " …"
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+// cell escapes s for use within a table cell.
+func cell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,74 @@
+package ntdoc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const example = `# Host name of the primary database server.
+database:
+  host: db.example.com
+  # Port to connect to.
+  port: 5432
+servers:
+  -
+    # Address to listen on;
+    # may be "any".
+    listen: any
+`
+
+type server struct {
+	Listen string
+}
+
+type config struct {
+	Database struct {
+		Host string
+		Port int `nt:"port"`
+	}
+	Servers []*server `nt:"servers"`
+	Timeout string
+}
+
+func TestCollect(t *testing.T) {
+	entries, err := Collect(strings.NewReader(example), &config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Entry{
+		{Path: "database", Description: "Host name of the primary database server.", Field: "Database", Type: "struct { Host string; Port int \"nt:\\\"port\\\"\" }"},
+		{Path: "database.host", Example: "db.example.com", Field: "Database.Host", Type: "string"},
+		{Path: "database.port", Description: "Port to connect to.", Example: "5432", Field: "Database.Port", Type: "int"},
+		{Path: "servers", Field: "Servers", Type: "[]*ntdoc.server"},
+		{Path: "servers.*.listen", Description: `Address to listen on; may be "any".`, Example: "any", Field: "Servers[].Listen", Type: "string"},
+		{Path: "Timeout", Field: "Timeout", Type: "string", Missing: true},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected\n%v\nhave\n%v", expected, entries)
+	}
+	if _, err = Collect(strings.NewReader(example), 42); err == nil {
+		t.Error("expected non-struct value to be rejected")
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	entries, err := Collect(strings.NewReader(example), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err = Markdown(&b, entries); err != nil {
+		t.Fatal(err)
+	}
+	expected := "| Option | Example | Description |\n" +
+		"|---|---|---|\n" +
+		"| `database` |  | Host name of the primary database server. |\n" +
+		"| `database.host` | `db.example.com` | *undocumented* |\n" +
+		"| `database.port` | `5432` | Port to connect to. |\n" +
+		"| `servers` |  | *undocumented* |\n" +
+		"| `servers.*.listen` | `any` | Address to listen on; may be \"any\". |\n"
+	if b.String() != expected {
+		t.Errorf("expected\n%s\nhave\n%s", expected, b.String())
+	}
+}
@@ -0,0 +1,184 @@
+package ntenc
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Round-trip audit ------------------------------------------------------
+
+// Alteration describes a key or value which does not survive encoding and parsing
+// unchanged.
+type Alteration struct {
+	Path   string      // path expression of the item (see nestext.ParsePath)
+	Key    bool        // is the key of the item altered, rather than its value?
+	Before interface{} // the original key or value
+	After  interface{} // the key or value after parsing, nil if the encoding is invalid
+	Reason string      // human readable description of the alteration
+}
+
+// Audit lists the keys and values of `tree` which would be altered by encoding it
+// with options `opts` and parsing the result, e.g. because line breaks are normalized
+// or values which are not strings are converted to strings. This allows to assess if
+// NestedText is a faithful storage format for some data.
+//
+// Every key and value is checked in isolation, so the result does not depend on
+// the layout of its neighbours, and a problem with one item does not hide others.
+// Alterations are listed in the order Encode writes the items.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError and stem
+// from encoding.
+func Audit(tree interface{}, opts ...EncoderOption) ([]Alteration, error) {
+	a := &auditor{opts: opts}
+	if err := a.audit(tree, nil, false); err != nil {
+		return nil, err
+	}
+	return a.alterations, nil
+}
+
+type auditor struct {
+	opts        []EncoderOption
+	alterations []Alteration
+}
+
+// audit checks `tree`, found at path `segments`. `inContainer` tells if tree is a value
+// of a dict or list, as opposed to the top-level value.
+func (a *auditor) audit(tree interface{}, segments []nestext.PathSegment, inContainer bool) error {
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		if len(t) == 0 && inContainer { // empty dicts and lists may become strings
+			break
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return a.auditDict(keys, t, segments)
+	case *nestext.OrderedMap:
+		if t.Len() == 0 && inContainer {
+			break
+		}
+		return a.auditDict(t.Keys(), t.Map(), segments)
+	case []interface{}:
+		if len(t) == 0 && inContainer {
+			break
+		}
+		for i, item := range t {
+			seg := append(segments[:len(segments):len(segments)], nestext.PathSegment{Index: i, IsIndex: true})
+			if err := a.audit(item, seg, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return a.auditLeaf(tree, segments, inContainer)
+}
+
+// auditLeaf checks a value which is not a dict or list.
+func (a *auditor) auditLeaf(tree interface{}, segments []nestext.PathSegment, inContainer bool) error {
+	// wrap the value in a container of its own kind, as the encoding of values
+	// differs between dicts and lists
+	var doc interface{} = tree
+	if inContainer {
+		if last := segments[len(segments)-1]; last.IsIndex {
+			doc = []interface{}{tree}
+		} else {
+			doc = map[string]interface{}{last.Key: tree}
+		}
+	}
+	after, err := a.roundTrip(doc)
+	if err != nil {
+		return err
+	}
+	if inContainer {
+		after = soleItem(after)
+	}
+	a.check(segments, false, tree, after)
+	return nil
+}
+
+func (a *auditor) auditDict(keys []string, dict map[string]interface{}, segments []nestext.PathSegment) error {
+	for _, k := range keys {
+		seg := append(segments[:len(segments):len(segments)], nestext.PathSegment{Key: k})
+		after, err := a.roundTrip(map[string]interface{}{k: ""})
+		if err != nil {
+			return err
+		}
+		var key interface{}
+		if m, ok := after.(map[string]interface{}); ok && len(m) == 1 {
+			for key = range m {
+			}
+		}
+		a.check(seg, true, k, key)
+		if err = a.audit(dict[k], seg, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// soleItem returns the single item of a dict or list, or nil.
+func soleItem(tree interface{}) interface{} {
+	switch t := tree.(type) {
+	case []interface{}:
+		if len(t) == 1 {
+			return t[0]
+		}
+	case map[string]interface{}:
+		if len(t) == 1 {
+			for _, v := range t {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// roundTrip encodes and parses `tree`. Parse errors result in a nil tree.
+func (a *auditor) roundTrip(tree interface{}) (interface{}, error) {
+	var buf bytes.Buffer
+	if _, err := Encode(tree, &buf, a.opts...); err != nil {
+		return nil, err
+	}
+	after, err := nestext.Parse(&buf)
+	if err != nil {
+		return nil, nil
+	}
+	return after, nil
+}
+
+// check records an alteration if `before` and `after` differ.
+func (a *auditor) check(segments []nestext.PathSegment, key bool, before, after interface{}) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	s, isString := before.(string)
+	var reason string
+	switch {
+	case after == nil:
+		reason = "encoding is not valid NestedText"
+	case !isString:
+		reason = "value is not a string"
+	case strings.ContainsRune(s, '\r'):
+		reason = "line breaks are normalized"
+	case strings.TrimSpace(s) != s:
+		reason = "leading or trailing white space is changed"
+	default:
+		reason = "value is changed"
+	}
+	if key && after != nil {
+		reason = strings.Replace(reason, "value", "key", 1)
+	}
+	a.alterations = append(a.alterations, Alteration{
+		Path:   nestext.JoinPath(segments),
+		Key:    key,
+		Before: before,
+		After:  after,
+		Reason: reason,
+	})
+}
@@ -0,0 +1,52 @@
+package ntenc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+func TestAudit(t *testing.T) {
+	tree := map[string]interface{}{
+		"fine":  "value",
+		"cr":    "a\rb",
+		"crlf":  "a\r\n",
+		"items": []interface{}{"ok", " leading", "line\n", ""},
+		"num":   42,
+		"empty": []interface{}{},
+	}
+	alterations, err := Audit(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		path   string
+		key    bool
+		reason string
+	}
+	var results []result
+	for _, a := range alterations {
+		results = append(results, result{a.Path, a.Key, a.Reason})
+	}
+	expected := []result{
+		{"cr", false, "encoding is not valid NestedText"},
+		{"crlf", false, "line breaks are normalized"},
+		{"num", false, "value is not a string"},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, have %v", expected, results)
+	}
+	if alterations[0].After != nil || alterations[2].After != "42" {
+		t.Errorf("unexpected values after round trip: %v", alterations)
+	}
+	if alterations, err = Audit("top-level\nstring"); err != nil || len(alterations) != 0 {
+		t.Errorf("expected top-level string to survive, have %v, %v", alterations, err)
+	}
+	om := nestext.NewOrderedMap()
+	om.Set("b", "1")
+	om.Set("a", "2\r")
+	if alterations, _ = Audit(om); len(alterations) != 1 || alterations[0].Path != "a" {
+		t.Errorf("expected ordered map to be audited, have %v", alterations)
+	}
+}
@@ -1,6 +1,7 @@
 // Package ntenc implements encoding of configuration data into NestedText format.
 // Configuration data is a tree of map[string]interface{}, []interface{} and strings.
-// It may not contain structs, channels nor unsafe types.
+// It may not contain channels nor unsafe types, and contains structs only if option
+// FieldNameMapper is given.
 //
 // This package is the counterpart to the NestedText parser (located in the base package
 // of module `nestext`).
@@ -8,6 +9,7 @@
 package ntenc
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"reflect"
@@ -31,31 +33,253 @@ const MaxIndent = 16
 // `map[string]interface{}` and `[]interface{}`, as a byte stream in NestedText format.
 // It returns the number of bytes written and possibly an error (of type nestext.NestedTextError).
 //
-// Map entries are sorted alphabetically by key.
+// Map entries are sorted alphabetically by key, while entries of a *nestext.OrderedMap
+// are written in the order of its keys.
 //
-// Encode won't handle structs, channels nor unsafe types.
+// Encode won't handle channels nor unsafe types, and handles structs only with option
+// FieldNameMapper. Types implementing nestext.Marshaler will be encoded from the tree
+// returned by `MarshalNestedText`.
 //
 func Encode(tree interface{}, w io.Writer, opts ...EncoderOption) (int, error) {
-	enc := &encoder{indentSize: 2, inlineLimit: DefaultInlineLimit}
-	for _, opt := range opts {
-		opt(enc)
+	enc := newEncoder(opts)
+	return enc.encodeDocument(tree, w, 0, nil)
+}
+
+// EncodeContext is like Encode, but aborts as soon as ctx is canceled or its deadline
+// expires. Cancelation is checked before each write to w; in case of cancelation, an
+// error of code ErrCodeIO is returned, wrapping ctx.Err().
+func EncodeContext(ctx context.Context, tree interface{}, w io.Writer, opts ...EncoderOption) (int, error) {
+	enc := newEncoder(opts)
+	return enc.encodeDocument(tree, ctxWriter{ctx: ctx, w: w}, 0, nil)
+}
+
+// ctxWriter fails writing if its context is done.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw ctxWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
 	}
-	return enc.encode(0, tree, w, 0, nil)
+	return cw.w.Write(p)
 }
 
 type encoder struct {
 	indentSize  int
 	inlineLimit int
+	unsupported Unsupported        // policy for values of unsupported types
+	nils        NilPolicy          // policy for nil maps and slices
+	comments    *nestext.Comments  // comments to write, may be nil
+	commentFn   CommentFunc        // callback for comments to write, may be nil
+	omitNewline bool               // leave out the line break of the last line
+	held        bool               // has the line break of the last document been left out?
+	layout      *nestext.Layout    // layout to apply, may be nil
+	path        string             // path of the current item, if comments are written
+	maxDepth    int                // maximum nesting depth of lists and dicts, 0 = unlimited
+	open        map[container]bool // lists and dicts currently being encoded
+	reference   bool               // lay out items like the reference implementation
+	keys        *nestext.FieldKeys // keys of struct fields, if structs are encoded
+}
+
+// container identifies a list or dict by the address of its data.
+type container struct {
+	ptr uintptr
+	len int // length of lists, -1 for dicts
+}
+
+func newEncoder(opts []EncoderOption) *encoder {
+	enc := &encoder{indentSize: 2, inlineLimit: DefaultInlineLimit}
+	for _, opt := range opts {
+		opt(enc)
+	}
+	return enc
+}
+
+// --- Encoder type -----------------------------------------------------
+
+// An Encoder writes NestedText documents to an output stream. It is intended for
+// long-lived writers (log files, sockets) which are encoded to repeatedly, without
+// having to pass options on every call. It mirrors the API of `encoding/json`'s Encoder.
+//
+// Use as:
+//     enc := ntenc.NewEncoder(w)
+//     enc.SetIndent(4)
+//     for _, record := range records {
+//         if err := enc.Encode(record); err != nil {
+//             …
+//         }
+//     }
+//
+type Encoder struct {
+	w         io.Writer
+	enc       *encoder
+	delimiter string // delimiter line between documents
+	count     int    // count of documents written
+}
+
+// NewEncoder returns a new encoder that writes to w, applying encoder options `opts`
+// for every call to Encode.
+func NewEncoder(w io.Writer, opts ...EncoderOption) *Encoder {
+	return &Encoder{w: w, enc: newEncoder(opts)}
+}
+
+// Encode writes the NestedText encoding of `tree` to the output stream. See function
+// `Encode` for details.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (e *Encoder) Encode(tree interface{}) error {
+	var err error
+	if e.delimiter != "" && e.count > 0 {
+		_, err = wr(e.w, 0, nil, e.enc.delimiter(e.delimiter))
+	}
+	_, err = e.enc.encodeDocument(tree, e.w, 0, err)
+	e.count++
+	return err
+}
+
+// SetDelimiter sets a delimiter line (usually nestext.DocumentDelimiter) to be written
+// between documents, creating a multi-document stream. It may be read with a
+// nestext.Decoder using the same delimiter.
+func (e *Encoder) SetDelimiter(delim string) {
+	e.delimiter = delim
+}
+
+// EncodeAll writes a multi-document stream, encoding each of `trees` as a document.
+// Documents are separated by delimiter lines consisting of `delim`
+// (usually nestext.DocumentDelimiter).
+// It returns the number of bytes written and possibly an error (of type
+// nestext.NestedTextError).
+func EncodeAll(trees []interface{}, w io.Writer, delim string, opts ...EncoderOption) (int, error) {
+	enc := newEncoder(opts)
+	var bcnt int
+	var err error
+	for i, tree := range trees {
+		if i > 0 {
+			bcnt, err = wr(w, bcnt, err, enc.delimiter(delim))
+		}
+		bcnt, err = enc.encodeDocument(tree, w, bcnt, err)
+	}
+	return bcnt, err
+}
+
+// Reset makes the encoder write to w, keeping options and settings. A delimiter line
+// will not be written before the next document. This allows re-using encoders, e.g.
+// with a sync.Pool.
+func (e *Encoder) Reset(w io.Writer) {
+	e.w, e.count = w, 0
+}
+
+// SetIndent sets the number of spaces per indentation level for subsequent calls
+// to Encode. See option `IndentBy`.
+func (e *Encoder) SetIndent(indentSize int) {
+	IndentBy(indentSize)(e.enc)
+}
+
+// SetInlineLimit sets the threshold above which lists and dicts are never inlined,
+// for subsequent calls to Encode. See option `InlineLimited`.
+func (e *Encoder) SetInlineLimit(limit int) {
+	InlineLimited(limit)(e.enc)
+}
+
+// SetOptions applies encoder options for subsequent calls to Encode.
+func (e *Encoder) SetOptions(opts ...EncoderOption) {
+	for _, opt := range opts {
+		opt(e.enc)
+	}
+}
+
+// encodeDocument encodes `tree` as a document. With option OmitFinalNewline, the
+// line break of the last line is held back.
+func (enc *encoder) encodeDocument(tree interface{}, w io.Writer, bcnt int, err error) (int, error) {
+	enc.held = false
+	if !enc.omitNewline {
+		return enc.encodeLines(tree, w, bcnt, err)
+	}
+	hw := &holdingWriter{w: w}
+	bcnt, err = enc.encodeLines(tree, hw, bcnt, err)
+	if enc.held = hw.holding; enc.held {
+		bcnt--
+	}
+	return bcnt, err
+}
+
+// delimiter returns the delimiter line to write between documents of a stream,
+// terminating the last line of the preceding document if necessary.
+func (enc *encoder) delimiter(delim string) []byte {
+	if enc.held {
+		return []byte("\n" + delim + "\n")
+	}
+	return []byte(delim + "\n")
+}
+
+// holdingWriter holds back a line break at the end of each write, until the next
+// write follows.
+type holdingWriter struct {
+	w       io.Writer
+	holding bool // has a line break been held back?
+}
+
+func (hw *holdingWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if hw.holding {
+		if _, err := hw.w.Write([]byte{'\n'}); err != nil {
+			return 0, err
+		}
+		hw.holding = false
+	}
+	if p[len(p)-1] != '\n' {
+		return hw.w.Write(p)
+	}
+	n, err := hw.w.Write(p[:len(p)-1])
+	if err == nil {
+		hw.holding, n = true, n+1
+	}
+	return n, err
+}
+
+// encodeLines encodes `tree` as a document, including comments, if present.
+func (enc *encoder) encodeLines(tree interface{}, w io.Writer, bcnt int, err error) (int, error) {
+	if !enc.withComments() {
+		return enc.encode(0, tree, w, bcnt, err)
+	}
+	enc.path = ""
+	bcnt, err = enc.writeItemComments(w, bcnt, err, 0)
+	bcnt, err = enc.encode(0, tree, w, bcnt, err)
+	if enc.comments == nil {
+		return bcnt, err
+	}
+	var blank []int
+	if enc.layout != nil {
+		blank = enc.layout.TrailingBlankLines
+	}
+	return enc.writeComments(w, bcnt, err, 0, enc.comments.Trailing, blank)
 }
 
 // encode is the top level function to encode data into NestedText format.
 // It will be called recursively and therefore carries the current indentation depth
 // as a parameter.
 func (enc *encoder) encode(indent int, tree interface{}, w io.Writer, bcnt int, err error) (int, error) {
+	tree, skip, e := enc.resolve(tree)
+	if err == nil {
+		err = e
+	}
+	if skip || err != nil {
+		return bcnt, err
+	}
 	if !isEncodable(tree) {
 		return 0, nestext.MakeNestedTextError(nestext.ErrCodeSchema,
 			fmt.Sprintf("unable to encode type %T", tree))
 	}
+	if c, ok := containerOf(tree); ok {
+		if err = enc.enterContainer(c, indent); err != nil {
+			return bcnt, err
+		}
+		defer delete(enc.open, c)
+	}
 	switch t := tree.(type) {
 	// We first try a couple of standard-cases without relying on reflection
 	case string:
@@ -68,13 +292,17 @@ func (enc *encoder) encode(indent int, tree interface{}, w io.Writer, bcnt int,
 			S := strings.Split(t, "\n")
 			for _, s := range S {
 				bcnt, err = enc.indent(w, bcnt, err, indent)
+				if s == "" && enc.reference { // no trailing white space
+					bcnt, err = wr(w, bcnt, err, []byte{'>', '\n'})
+					continue
+				}
 				bcnt, err = wr(w, bcnt, err, []byte{'>', ' '})
 				bcnt, err = wr(w, bcnt, err, []byte(s))
 				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
 			}
 		}
 	case []string:
-		if len(t) <= 5 { // max of 5 is completely arbitrary
+		if len(t) <= 5 && !enc.reference { // max of 5 is completely arbitrary
 			l := 0
 			inlineable := true
 			S := make([][]byte, len(t))
@@ -89,6 +317,7 @@ func (enc *encoder) encode(indent int, tree interface{}, w io.Writer, bcnt int,
 			}
 			// if the complete array fits into one line, output "[ a, b, … ]"
 			if inlineable && l <= enc.inlineLimit {
+				bcnt, err = enc.indent(w, bcnt, err, indent)
 				bcnt, err = wr(w, bcnt, err, []byte{'['})
 				for i, item := range t {
 					if i > 0 {
@@ -101,10 +330,14 @@ func (enc *encoder) encode(indent int, tree interface{}, w io.Writer, bcnt int,
 			}
 		}
 		// general case: list item with '-' as tag
-		for _, s := range t {
+		for i, s := range t {
+			outer := enc.enter(strconv.Itoa(i))
+			bcnt, err = enc.writeItemComments(w, bcnt, err, indent)
 			bcnt, err = enc.indent(w, bcnt, err, indent)
 			bcnt, err = wr(w, bcnt, err, []byte{'-'})
-			if strings.IndexByte(s, '\n') == -1 { // no newlines in string
+			if s == "" && enc.reference {
+				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
+			} else if !nestext.NeedsMultiline(s) { // no newlines in string
 				bcnt, err = wr(w, bcnt, err, []byte{' '})
 				bcnt, err = wr(w, bcnt, err, []byte(s))
 				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
@@ -112,9 +345,11 @@ func (enc *encoder) encode(indent int, tree interface{}, w io.Writer, bcnt int,
 				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
 				bcnt, err = enc.encode(indent+1, s, w, bcnt, err)
 			}
+			enc.path = outer
 		}
 	case []int:
-		if len(t) <= 10 { // max of 10 is completely arbitrary
+		if len(t) <= 10 && !enc.reference { // max of 10 is completely arbitrary
+			bcnt, err = enc.indent(w, bcnt, err, indent)
 			bcnt, err = wr(w, bcnt, err, []byte{'['})
 			for i, n := range t {
 				if i > 0 {
@@ -132,17 +367,41 @@ func (enc *encoder) encode(indent int, tree interface{}, w io.Writer, bcnt int,
 			bcnt, err = wr(w, bcnt, err, []byte{'\n'})
 		}
 	case []interface{}:
-		for _, item := range t {
+		if len(t) == 0 {
+			bcnt, err = enc.indent(w, bcnt, err, indent)
+			return wr(w, bcnt, err, []byte("[]\n"))
+		}
+		for i, item := range t {
+			item, skip, e := enc.resolve(item)
+			if skip {
+				continue
+			} else if err == nil {
+				err = e
+			}
+			outer := enc.enter(strconv.Itoa(i))
+			bcnt, err = enc.writeItemComments(w, bcnt, err, indent)
 			bcnt, err = enc.indent(w, bcnt, err, indent)
 			bcnt, err = wr(w, bcnt, err, []byte("-"))
-			if ok, itemAsBytes := isInlineable(asList, item); ok {
+			if ok, itemAsBytes := isInlineable(asString, item); ok {
 				bcnt, err = wr(w, bcnt, err, []byte{' '})
 				bcnt, err = wr(w, bcnt, err, itemAsBytes)
 				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
+			} else if enc.reference && item == "" {
+				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
 			} else {
 				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
 				bcnt, err = enc.encode(indent+1, item, w, bcnt, err)
 			}
+			enc.path = outer
+		}
+	case *nestext.OrderedMap:
+		if t == nil || t.Len() == 0 {
+			bcnt, err = enc.indent(w, bcnt, err, indent)
+			return wr(w, bcnt, err, []byte("{}\n"))
+		}
+		for _, key := range t.Keys() {
+			item, _ := t.Get(key)
+			bcnt, err = enc.encodeDictItem(indent, key, item, w, bcnt, err)
 		}
 	default:
 		bcnt, err = enc.encodeReflected(indent, tree, w, bcnt, err)
@@ -150,6 +409,41 @@ func (enc *encoder) encode(indent int, tree interface{}, w io.Writer, bcnt int,
 	return bcnt, err
 }
 
+// containerOf returns the identity of lists and dicts. Empty lists, which cannot be part
+// of a cycle, are not considered.
+func containerOf(tree interface{}) (container, bool) {
+	v := reflect.ValueOf(tree)
+	if _, ok := tree.(*nestext.OrderedMap); ok {
+		return container{ptr: v.Pointer(), len: -1}, true
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		return container{ptr: v.Pointer(), len: -1}, true
+	case reflect.Slice:
+		if v.Len() > 0 {
+			return container{ptr: v.Pointer(), len: v.Len()}, true
+		}
+	}
+	return container{}, false
+}
+
+// enterContainer checks the nesting depth limit and cycles before a list or dict at
+// indentation level `indent` is encoded.
+func (enc *encoder) enterContainer(c container, indent int) error {
+	if enc.maxDepth > 0 && indent >= enc.maxDepth {
+		return nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+			fmt.Sprintf("tree nests deeper than maximum depth of %d", enc.maxDepth))
+	}
+	if enc.open == nil {
+		enc.open = make(map[container]bool)
+	} else if enc.open[c] {
+		return nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+			"tree contains a cycle: list or dict contains itself")
+	}
+	enc.open[c] = true
+	return nil
+}
+
 // encodeReflected encodes container types slice and map. As the name suggests,
 // we use reflection to get detailled type information.
 // The code here is not difficult in structure, but rather simply tedious for all the
@@ -159,23 +453,38 @@ func (enc *encoder) encodeReflected(indent int, tree interface{}, w io.Writer, b
 	switch v.Kind() {
 	case reflect.Slice:
 		l := v.Len()
+		if l == 0 {
+			bcnt, err = enc.indent(w, bcnt, err, indent)
+			return wr(w, bcnt, err, []byte("[]\n"))
+		}
 		for i := 0; i < l; i++ {
-			item := v.Index(i).Interface()
+			item, skip, e := enc.resolve(v.Index(i).Interface())
+			if skip {
+				continue
+			} else if err == nil {
+				err = e
+			}
+			outer := enc.enter(strconv.Itoa(i))
+			bcnt, err = enc.writeItemComments(w, bcnt, err, indent)
 			bcnt, err = enc.indent(w, bcnt, err, indent)
 			bcnt, err = wr(w, bcnt, err, []byte{'-'})
-			if ok, itemAsBytes := isInlineable(asList, item); ok {
+			if ok, itemAsBytes := isInlineable(asString, item); ok {
 				bcnt, err = wr(w, bcnt, err, []byte{' '})
 				bcnt, err = wr(w, bcnt, err, itemAsBytes)
 				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
+			} else if enc.reference && item == "" {
+				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
 			} else {
 				bcnt, err = wr(w, bcnt, err, []byte{'\n'})
 				bcnt, err = enc.encode(indent+1, item, w, bcnt, err)
 			}
+			enc.path = outer
 		}
 	case reflect.Map:
 		keys := v.MapKeys()
 		// special case: empty map
 		if len(keys) == 0 {
+			bcnt, err = enc.indent(w, bcnt, err, indent)
 			return wr(w, bcnt, err, []byte("{}\n"))
 		}
 		// first sort items alphabetically by key
@@ -190,36 +499,8 @@ func (enc *encoder) encodeReflected(indent int, tree interface{}, w io.Writer, b
 				return 0, nestext.MakeNestedTextError(nestext.ErrCodeSchema,
 					"map key is not a string; can only keys of type string")
 			}
-			key := k.Interface().(string)
-			item := v.MapIndex(k).Interface()
-			if ok, keyAsBytes := isInlineable(asKey, key); ok {
-				bcnt, err = enc.indent(w, bcnt, err, indent)
-				bcnt, err = wr(w, bcnt, err, keyAsBytes)
-				bcnt, err = wr(w, bcnt, err, []byte{':'})
-				if ok, itemAsBytes := isInlineable(asString, item); ok {
-					bcnt, err = wr(w, bcnt, err, []byte{' '})
-					bcnt, err = wr(w, bcnt, err, itemAsBytes)
-					bcnt, err = wr(w, bcnt, err, []byte{'\n'})
-				} else {
-					bcnt, err = wr(w, bcnt, err, []byte{'\n'})
-					bcnt, err = encodeIfNotEmpty(enc, item, w, indent, bcnt, err)
-					//bcnt, err = enc.encode(indent+1, item, w, bcnt, err)
-				}
-			} else { // output key as a multi-line key
-				S := strings.Split(key, "\n")
-				for _, s := range S {
-					bcnt, err = enc.indent(w, bcnt, err, indent)
-					if s == "" {
-						bcnt, err = wr(w, bcnt, err, []byte(":"))
-					} else {
-						bcnt, err = wr(w, bcnt, err, []byte(": "))
-						bcnt, err = wr(w, bcnt, err, []byte(s))
-					}
-					bcnt, err = wr(w, bcnt, err, []byte{'\n'})
-				}
-				bcnt, err = encodeIfNotEmpty(enc, item, w, indent, bcnt, err)
-				//bcnt, err = enc.encode(indent+1, item, w, bcnt, err)
-			}
+			key := k.String()
+			bcnt, err = enc.encodeDictItem(indent, key, v.MapIndex(k).Interface(), w, bcnt, err)
 		}
 	default:
 		err = nestext.MakeNestedTextError(nestext.ErrCodeSchema,
@@ -228,6 +509,56 @@ func (enc *encoder) encodeReflected(indent int, tree interface{}, w io.Writer, b
 	return bcnt, err
 }
 
+// encodeDictItem encodes an entry of a dict.
+func (enc *encoder) encodeDictItem(indent int, key string, item interface{}, w io.Writer, bcnt int,
+	err error) (int, error) {
+	//
+	item, skip, e := enc.resolve(item)
+	if skip {
+		return bcnt, err
+	} else if err == nil {
+		err = e
+	}
+	outer := enc.enter(key)
+	bcnt, err = enc.writeItemComments(w, bcnt, err, indent)
+	if ok, keyAsBytes := isInlineable(asKey, key); ok {
+		bcnt, err = enc.indent(w, bcnt, err, indent)
+		bcnt, err = wr(w, bcnt, err, keyAsBytes)
+		bcnt, err = wr(w, bcnt, err, enc.keyPadding())
+		bcnt, err = wr(w, bcnt, err, []byte{':'})
+		if ok, itemAsBytes := isInlineable(asString, item); ok {
+			bcnt, err = wr(w, bcnt, err, []byte{' '})
+			bcnt, err = wr(w, bcnt, err, itemAsBytes)
+			bcnt, err = wr(w, bcnt, err, []byte{'\n'})
+		} else {
+			bcnt, err = wr(w, bcnt, err, []byte{'\n'})
+			bcnt, err = encodeIfNotEmpty(enc, item, w, indent, bcnt, err)
+			//bcnt, err = enc.encode(indent+1, item, w, bcnt, err)
+		}
+	} else { // output key as a multi-line key
+		S := strings.Split(key, "\n")
+		for _, s := range S {
+			bcnt, err = enc.indent(w, bcnt, err, indent)
+			if s == "" {
+				bcnt, err = wr(w, bcnt, err, []byte(":"))
+			} else {
+				bcnt, err = wr(w, bcnt, err, []byte(": "))
+				bcnt, err = wr(w, bcnt, err, []byte(s))
+			}
+			bcnt, err = wr(w, bcnt, err, []byte{'\n'})
+		}
+		if item == "" && enc.reference { // values of multi-line keys are never omitted
+			bcnt, err = enc.indent(w, bcnt, err, indent+1)
+			bcnt, err = wr(w, bcnt, err, []byte{'>', '\n'})
+		} else {
+			bcnt, err = encodeIfNotEmpty(enc, item, w, indent, bcnt, err)
+		}
+		//bcnt, err = enc.encode(indent+1, item, w, bcnt, err)
+	}
+	enc.path = outer
+	return bcnt, err
+}
+
 func encodeIfNotEmpty(enc *encoder, item interface{}, w io.Writer, indent, bcnt int, err error) (int, error) {
 	if err != nil {
 		return bcnt, err
@@ -240,11 +571,147 @@ func encodeIfNotEmpty(enc *encoder, item interface{}, w io.Writer, indent, bcnt
 	return enc.encode(indent+1, item, w, bcnt, err)
 }
 
+// resolve replaces items implementing nestext.Marshaler by the tree they marshal to,
+// and applies the policy for unsupported types to an item. It returns the item to
+// encode, or skip=true if the item should be left out.
+func (enc *encoder) resolve(item interface{}) (interface{}, bool, error) {
+	if m, ok := item.(nestext.Marshaler); ok && !isNil(item) {
+		marshaled, err := m.MarshalNestedText()
+		if err != nil {
+			return nil, false, err
+		}
+		item = marshaled
+	}
+	if enc.keys != nil && isStruct(item) {
+		dict, err := enc.keys.Dict(item)
+		if err != nil {
+			return nil, false, err
+		}
+		item = dict
+	}
+	if enc.nils == NilOmitted && isNil(item) {
+		return nil, true, nil
+	}
+	if item == nil || isEncodable(item) {
+		return item, false, nil
+	}
+	switch enc.unsupported {
+	case Skip:
+		return nil, true, nil
+	case Stringify:
+		return stringify(item), false, nil
+	}
+	return item, false, nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+		fmt.Sprintf("unable to encode type %T", item))
+}
+
+// enter makes the item with key or index `key` of the current container the current
+// item, if comments are written. It returns the path of the container.
+func (enc *encoder) enter(key string) string {
+	outer := enc.path
+	if enc.withComments() {
+		enc.path = nestext.AppendPath(outer, key)
+	}
+	return outer
+}
+
+// writeItemComments writes the comments and blank lines preceding the current item.
+func (enc *encoder) writeItemComments(w io.Writer, bcnt int, err error, indent int) (int, error) {
+	if enc.comments != nil {
+		var blank []int
+		if enc.layout != nil {
+			blank = enc.layout.BlankLines[enc.path]
+		}
+		bcnt, err = enc.writeComments(w, bcnt, err, indent, enc.comments.Items[enc.path], blank)
+	}
+	if enc.commentFn == nil {
+		return bcnt, err
+	}
+	var lines []string
+	for _, text := range enc.commentFn(enc.path) {
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimRight(" "+line, " \t\r"))
+		}
+	}
+	return enc.writeComments(w, bcnt, err, indent, lines, nil)
+}
+
+// withComments is true if comments have to be written.
+func (enc *encoder) withComments() bool {
+	return enc.comments != nil || enc.commentFn != nil
+}
+
+// writeComments writes comment lines at indentation level `indent`, interspersed with
+// blank lines at positions `blank` (see nestext.Layout).
+func (enc *encoder) writeComments(w io.Writer, bcnt int, err error, indent int, lines []string,
+	blank []int) (int, error) {
+	//
+	b := 0
+	for i := 0; i <= len(lines); i++ {
+		for ; b < len(blank) && blank[b] <= i; b++ {
+			bcnt, err = wr(w, bcnt, err, []byte{'\n'})
+		}
+		if i < len(lines) {
+			bcnt, err = enc.indent(w, bcnt, err, indent)
+			bcnt, err = wr(w, bcnt, err, []byte("#"+lines[i]+"\n"))
+		}
+	}
+	return bcnt, err
+}
+
+// keyPadding returns the white space to write between the key of the current item and ':'.
+func (enc *encoder) keyPadding() []byte {
+	if enc.layout == nil {
+		return nil
+	}
+	return []byte(enc.layout.KeyPadding[enc.path])
+}
+
+// isNil is true for nil values, including nil maps and nil slices.
+func isNil(item interface{}) bool {
+	if item == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(item); v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.IsNil()
+	case reflect.Ptr:
+		if _, ok := item.(*nestext.OrderedMap); ok {
+			return v.IsNil()
+		}
+	}
+	return false
+}
+
+// stringify creates a string representation for values of unsupported types.
+func stringify(item interface{}) string {
+	if s, ok := item.(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch reflect.ValueOf(item).Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return fmt.Sprintf("<%T>", item)
+	}
+	return fmt.Sprintf("%+v", item)
+}
+
+// isStruct is true for structs and non-nil pointers to structs, except for ordered maps.
+func isStruct(item interface{}) bool {
+	if _, ok := item.(*nestext.OrderedMap); ok {
+		return false
+	}
+	v := reflect.ValueOf(item)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.Kind() == reflect.Struct
+}
+
 func isEncodable(item interface{}) bool {
 	switch reflect.ValueOf(item).Kind() {
 	case reflect.Chan, reflect.Func, reflect.Invalid, reflect.Uintptr, reflect.UnsafePointer:
 		return false
-	case reflect.Struct: // maybe we'll support this one day
+	case reflect.Struct: // unless converted to a dict, see option FieldNameMapper
 		return false
 	}
 	return true
@@ -252,36 +719,40 @@ func isEncodable(item interface{}) bool {
 
 // item categories
 const (
-	asKey int = iota
-	asString
-	asList
-	asDict
+	asKey    int = iota // key of a "key: value" line
+	asString            // value following a tag or key on the same line
+	asList              // item of an inline list
+	asDict              // value of an inline dict
 )
 
-// itemPattern holds a string (list of characters) per item category which are
-// forbidden for this item.
-var itemPattern = []string{
-	":\n",    // Key
-	"\n",     // String
-	"[],\n",  // List
-	"{},:\n", // Dict
+// inlineableAs checks if a string may be written in-line for an item category, with
+// the rules of the spec.
+func inlineableAs(what int, s string) bool {
+	switch what {
+	case asKey:
+		return !nestext.NeedsMultilineKey(s)
+	case asString:
+		return !nestext.NeedsMultiline(s)
+	}
+	return nestext.IsValidInlineValue(s)
 }
 
 func isInlineable(what int, item interface{}) (bool, []byte) {
+	if _, ok := item.(*nestext.OrderedMap); ok {
+		return false, nil
+	}
 	switch reflect.ValueOf(item).Kind() {
 	case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice, reflect.Struct:
 		return false, nil
 	case reflect.String:
-		if item.(string) == "" {
-			return false, nil
-		}
-		if strings.ContainsAny(item.(string), itemPattern[what]) {
+		s := reflect.ValueOf(item).String() // item may be of a named string type
+		if s == "" || !inlineableAs(what, s) {
 			return false, nil
 		}
-		return true, []byte(item.(string))
+		return true, []byte(s)
 	default:
 		v := fmt.Sprintf("%v", item)
-		if strings.ContainsAny(v, itemPattern[what]) {
+		if !inlineableAs(what, v) {
 			return false, nil
 		}
 		return true, []byte(v)
@@ -341,6 +812,197 @@ func IndentBy(indentSize int) EncoderOption {
 	}
 }
 
+// ReferenceLayout requests the encoder to make the layout decisions of the reference
+// implementation of NestedText (Python package nestedtext, function dumps with default
+// arguments), so that the output may be compared byte-for-byte to the encoding outputs
+// of the official test suite:
+//
+//   - indentation is 4 spaces, unless option IndentBy follows this option,
+//   - lists are never inlined, except for empty ones ("[]"),
+//   - empty strings are written without trailing white space, i.e. as "-" for list
+//     items and as ">" for lines of multi-line strings,
+//   - values of multi-line keys are always written on lines of their own, including
+//     empty strings.
+//
+// Keys of maps are still sorted, while *nestext.OrderedMap keeps the order of keys as
+// dicts of Python do. These rules have not been verified against the official test
+// suite yet, thus outputs may still differ from those of the reference implementation.
+//
+// Use as:
+//     ntenc.Encode(mydata, w, ntenc.ReferenceLayout())
+//
+func ReferenceLayout() EncoderOption {
+	return func(enc *encoder) {
+		enc.reference = true
+		enc.indentSize = 4
+	}
+}
+
+// MaxDepth limits the nesting of lists and dicts to `depth` levels, with a top-level
+// list or dict being on level 1. Trees nesting deeper result in an error of code
+// ErrCodeSchema. A depth of 0 (the default) means no limit.
+//
+// Independently of this option, trees containing cycles, e.g. maps which contain
+// themselves, are always rejected.
+//
+// Use as:
+//     ntenc.Encode(mydata, w, ntenc.MaxDepth(32))
+//
+func MaxDepth(depth int) EncoderOption {
+	return func(enc *encoder) {
+		if depth < 0 {
+			depth = 0
+		}
+		enc.maxDepth = depth
+	}
+}
+
+// Unsupported is a policy for values of types which cannot be encoded, such as
+// functions, channels or structs.
+type Unsupported int
+
+// Policies for option OnUnsupported
+const (
+	Error     Unsupported = iota // fail with an error of code ErrCodeSchema (default)
+	Skip                         // leave out the value, including its key or list item
+	Stringify                    // encode a string representation of the value
+)
+
+// OnUnsupported sets the policy for values of types which cannot be encoded.
+// The default is to fail with an error. For debugging dumps of arbitrary runtime state,
+// values may be skipped or encoded as strings instead. Stringify uses method
+// String() if present; functions, channels and unsafe pointers are represented by
+// their type.
+//
+// Use as:
+//     ntenc.Encode(state, w, ntenc.OnUnsupported(ntenc.Stringify))
+//
+func OnUnsupported(policy Unsupported) EncoderOption {
+	return func(enc *encoder) {
+		enc.unsupported = policy
+	}
+}
+
+// FieldNameMapper makes the encoder encode structs as dicts, with the keys
+// nestext.Decoder matches to their fields: keys given by a tag `nt:"key"`, or the
+// names of fields mapped by `mapper`, e.g. to snake_case. A nil mapper keeps names
+// unchanged. Fields with tag `nt:"-"` and unexported fields are left out. This is the
+// counterpart of option nestext.FieldNameMapper, thus structs survive a round trip
+// with the same mapper. Without this option, structs are unsupported (see
+// OnUnsupported).
+//
+// Use as:
+//     ntenc.Encode(config, w, ntenc.FieldNameMapper(nestext.SnakeCase))
+//
+func FieldNameMapper(mapper func(goName string) string) EncoderOption {
+	keys := nestext.NewFieldKeys(mapper)
+	return func(enc *encoder) {
+		enc.keys = keys
+	}
+}
+
+// WithComments requests the encoder to write comments, usually captured by parsing
+// a document with option nestext.CaptureComments. Comments are written in front of
+// the items they are attached to, at the indentation of the item. Comments of items
+// which are not encoded, including items of inline lists, are dropped.
+//
+// Use as:
+//     var comments nestext.Comments
+//     config, err := nestext.Parse(reader, nestext.CaptureComments(&comments))
+//     …  // modify config
+//     ntenc.Encode(config, w, ntenc.WithComments(&comments))
+//
+func WithComments(c *nestext.Comments) EncoderOption {
+	return func(enc *encoder) {
+		enc.comments = c
+	}
+}
+
+// CommentFunc is a callback which returns comment texts to write in front of the item
+// at `path`. Paths are formed as described for type nestext.Comments, with "" denoting
+// the top-level value. Each text is written as a comment line "# text"; texts
+// containing newlines result in multiple comment lines.
+type CommentFunc func(path string) []string
+
+// WithCommentFunc requests the encoder to call `fn` for every item and to write the
+// comments returned, e.g. to explain entries of generated configuration files.
+// Comments are written in front of the items, at the indentation of the item, but
+// after comments of option WithComments. As with option WithComments, comments of
+// items which are not encoded, including items of inline lists, are dropped.
+//
+// Use as:
+//     ntenc.Encode(config, w, ntenc.WithCommentFunc(func(path string) []string {
+//         if path == "/server/port" {
+//             return []string{"port to listen on, default 8080"}
+//         }
+//         return nil
+//     }))
+//
+func WithCommentFunc(fn CommentFunc) EncoderOption {
+	return func(enc *encoder) {
+		enc.commentFn = fn
+	}
+}
+
+// OmitFinalNewline requests the encoder to leave out the line break terminating the
+// last line of a document, e.g. for embedding the output into other text. By
+// default, every line of the output is terminated by a single line break; blank
+// lines at the end of the output are written only if requested by option WithLayout.
+// Within multi-document streams, the line break is written together with the
+// delimiter line following a document.
+//
+func OmitFinalNewline() EncoderOption {
+	return func(enc *encoder) {
+		enc.omitNewline = true
+	}
+}
+
+// WithLayout requests the encoder to re-create the layout of a document, i.e. comments,
+// blank lines and padding of keys, usually captured by parsing the document with option
+// nestext.CaptureLayout. See WithComments for details on placement of comments.
+// The indentation width of the layout is used as if given by option IndentBy, which
+// may follow to override it. This re-creates documents which are sorted by key nearly
+// unchanged, keeping diffs of automated edits to a minimum.
+//
+// Use as:
+//     var layout nestext.Layout
+//     config, err := nestext.Parse(reader, nestext.CaptureLayout(&layout))
+//     …  // modify config
+//     ntenc.Encode(config, w, ntenc.WithLayout(&layout))
+//
+func WithLayout(l *nestext.Layout) EncoderOption {
+	return func(enc *encoder) {
+		enc.comments, enc.layout = &l.Comments, l
+		if l.Indent > 0 {
+			IndentBy(l.Indent)(enc)
+		}
+	}
+}
+
+// NilPolicy is a policy for nil maps and nil slices.
+type NilPolicy int
+
+// Policies for option OnNil
+const (
+	NilAsEmpty NilPolicy = iota // encode nil maps as "{}" and nil slices as "[]" (default)
+	NilOmitted                  // leave out nil maps, nil slices and nil values, including their keys or list items
+)
+
+// OnNil sets the policy for nil maps and nil slices, as often found in partially
+// initialized data structures. By default they are encoded like empty maps and
+// slices, i.e. as "{}" and "[]". With policy NilOmitted, they are left out, together
+// with untyped nil values (which are an error otherwise). Code generated by ntgen
+// keeps nil maps and slices of struct fields, so this policy applies to them as well.
+//
+// Use as:
+//     ntenc.Encode(mydata, w, ntenc.OnNil(ntenc.NilOmitted))
+//
+func OnNil(policy NilPolicy) EncoderOption {
+	return func(enc *encoder) {
+		enc.nils = policy
+	}
+}
+
 // InlineLimited sets the threshold above which lists and dicts are never inlined.
 // If set to a small number, inlining is suppressed.
 //
@@ -1,9 +1,14 @@
 package ntenc
 
 import (
+	"context"
+	"errors"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/npillmayer/nestext"
 )
 
 func TestEncodeOptions(t *testing.T) {
@@ -91,6 +96,17 @@ Key2:
 `)
 }
 
+func TestEncodeNestedInlineList(t *testing.T) {
+	expect(t, map[string]interface{}{
+		"a": []string{"x", "y"},
+		"b": []int{1},
+	}, `a:
+  [x, y]
+b:
+  [1]
+`)
+}
+
 func TestEncodeStruct(t *testing.T) {
 	_, err := Encode(struct{ a int }{a: 1}, io.Discard)
 	t.Logf("error for struct = %v", err)
@@ -99,6 +115,395 @@ func TestEncodeStruct(t *testing.T) {
 	}
 }
 
+func TestEncodeFieldNameMapper(t *testing.T) {
+	type server struct {
+		HostName string
+		MaxConns int `nt:"connections"`
+	}
+	type config struct {
+		LogLevel string
+		Servers  []server
+		Primary  *server
+	}
+	c := config{LogLevel: "debug", Servers: []server{{"a", 1}}, Primary: &server{"b", 2}}
+	buf := &strings.Builder{}
+	if _, err := Encode(c, buf, FieldNameMapper(nestext.SnakeCase)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "log_level: debug\nservers:\n  -\n    host_name: a\n    connections: 1\nprimary:\n  host_name: b\n  connections: 2\n"
+	if buf.String() != expected {
+		t.Errorf("expected output\n%s\nhave\n%s", expected, buf.String())
+	}
+	var decoded config
+	dec := nestext.NewDecoder(strings.NewReader(buf.String()), nestext.FieldNameMapper(nestext.SnakeCase))
+	if err := dec.Decode(&decoded); err != nil || !reflect.DeepEqual(decoded, c) {
+		t.Errorf("expected round trip to restore %+v, have %+v, %v", c, decoded, err)
+	}
+}
+
+type testName string
+
+func TestEncodeNamedStrings(t *testing.T) {
+	buf := &strings.Builder{}
+	v := struct{ FirstName testName }{"x"}
+	if _, err := Encode(v, buf, FieldNameMapper(nestext.SnakeCase)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "first_name: x\n" {
+		t.Errorf("expected named string field to be encoded, have %q", buf.String())
+	}
+	buf.Reset()
+	tree := map[testName]interface{}{"a": testName("1"), "b": []testName{"2"}}
+	if _, err := Encode(tree, buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "a: 1\nb:\n  - 2\n" {
+		t.Errorf("expected named string keys to be encoded, have %q", buf.String())
+	}
+}
+
+func TestEncoderType(t *testing.T) {
+	out := &strings.Builder{}
+	enc := NewEncoder(out)
+	enc.SetIndent(4)
+	if err := enc.Encode(map[string]interface{}{"a": map[string]interface{}{"b": "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(map[string]interface{}{"c": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a:\n    b: 1\nc: 2\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	tree := map[string]interface{}{
+		"a": "1",
+		"f": func() {},
+		"l": []interface{}{"x", make(chan int), struct{ N int }{N: 7}},
+	}
+	if _, err := Encode(tree, io.Discard); err == nil {
+		t.Errorf("expected encoding of func to fail, didn't")
+	}
+	out := &strings.Builder{}
+	if _, err := Encode(tree, out, OnUnsupported(Skip)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a: 1\nl:\n  - x\n" {
+		t.Errorf("unexpected output for Skip: %q", out.String())
+	}
+	out.Reset()
+	if _, err := Encode(tree, out, OnUnsupported(Stringify)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a: 1\nf: <func()>\nl:\n  - x\n  - <chan int>\n  - {N:7}\n" {
+		t.Errorf("unexpected output for Stringify: %q", out.String())
+	}
+}
+
+func TestEncodeAll(t *testing.T) {
+	out := &strings.Builder{}
+	trees := []interface{}{map[string]interface{}{"a": "1"}, "text"}
+	if _, err := EncodeAll(trees, out, nestext.DocumentDelimiter); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a: 1\n---\n> text\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	out.Reset()
+	enc := NewEncoder(out)
+	enc.SetDelimiter(nestext.DocumentDelimiter)
+	for _, tree := range trees {
+		enc.Encode(tree)
+	}
+	docs, err := nestext.DecodeAll(strings.NewReader(out.String()), nestext.DocumentDelimiter)
+	if err != nil || len(docs) != 2 {
+		t.Errorf("expected encoded stream to decode into 2 documents, have %v, %v", docs, err)
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	out := &strings.Builder{}
+	enc := NewEncoder(out)
+	enc.SetDelimiter(nestext.DocumentDelimiter)
+	enc.Encode("a")
+	enc.Encode("b")
+	out2 := &strings.Builder{}
+	enc.Reset(out2)
+	enc.Encode("c")
+	if out.String() != "> a\n---\n> b\n" || out2.String() != "> c\n" {
+		t.Errorf("unexpected output %q and %q", out.String(), out2.String())
+	}
+}
+
+func TestEncodeContext(t *testing.T) {
+	tree := map[string]interface{}{"a": []interface{}{"1", "2"}, "b": "3"}
+	out := &strings.Builder{}
+	if _, err := EncodeContext(context.Background(), tree, out); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err := EncodeContext(ctx, tree, io.Discard)
+	if !errors.Is(err, context.Canceled) || n != 0 {
+		t.Errorf("expected encoding to be canceled, have %d bytes written, error %v", n, err)
+	}
+}
+
+func TestEncodeComments(t *testing.T) {
+	input := `# Contact information
+president:
+  # multi-line key
+  : a:b
+  : c
+    > x
+  # current roles
+  roles:
+    - board member
+    # since 2021
+    -
+      > treasurer
+      > (acting)
+# end of document
+`
+	var comments nestext.Comments
+	tree, err := nestext.Parse(strings.NewReader(input), nestext.CaptureComments(&comments))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &strings.Builder{}
+	if _, err = Encode(tree, out, WithComments(&comments)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != input {
+		t.Errorf("expected comments to survive round trip, have\n%s", out.String())
+	}
+}
+
+func TestEncodeLayout(t *testing.T) {
+	input := `# Contact information
+
+name   : Katheryn McDaniel
+
+# current roles
+roles:
+  - board member
+
+  # since 2021
+  - treasurer
+version: 1
+
+# end of document
+
+`
+	var layout nestext.Layout
+	tree, err := nestext.Parse(strings.NewReader(input), nestext.CaptureLayout(&layout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.(map[string]interface{})["version"] = "2"
+	out := &strings.Builder{}
+	if _, err = Encode(tree, out, WithLayout(&layout)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.Replace(input, "version: 1", "version: 2", 1); out.String() != expected {
+		t.Errorf("expected layout to survive round trip, have\n%s", out.String())
+	}
+}
+
+func TestEncodeLayoutIndent(t *testing.T) {
+	input := "a:\n    b:\n        - x\n"
+	var layout nestext.Layout
+	tree, err := nestext.Parse(strings.NewReader(input), nestext.CaptureLayout(&layout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &strings.Builder{}
+	if _, err = Encode(tree, out, WithLayout(&layout)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != input {
+		t.Errorf("expected indentation width to be kept, have\n%s", out.String())
+	}
+	out.Reset()
+	if _, err = Encode(tree, out, WithLayout(&layout), IndentBy(2)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a:\n  b:\n    - x\n" {
+		t.Errorf("expected option IndentBy to override layout, have\n%s", out.String())
+	}
+}
+
+func TestEncodeMaxDepth(t *testing.T) {
+	tree := map[string]interface{}{
+		"a": []interface{}{"x", map[string]interface{}{"b": "y"}},
+	}
+	if _, err := Encode(tree, io.Discard, MaxDepth(3)); err != nil {
+		t.Errorf("expected tree of depth 3 to be accepted, have %v", err)
+	}
+	_, err := Encode(tree, io.Discard, MaxDepth(2))
+	if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != nestext.ErrCodeSchema {
+		t.Errorf("expected tree of depth 3 to be rejected, have %v", err)
+	}
+}
+
+func TestEncodeCycles(t *testing.T) {
+	shared := []interface{}{"x"}
+	tree := map[string]interface{}{"a": shared, "b": shared}
+	if _, err := Encode(tree, io.Discard); err != nil {
+		t.Errorf("expected shared list to be accepted, have %v", err)
+	}
+	cyclic := map[string]interface{}{"a": "x"}
+	cyclic["self"] = cyclic
+	if _, err := Encode(cyclic, io.Discard); err == nil {
+		t.Errorf("expected map containing itself to be rejected")
+	}
+	list := []interface{}{"x", nil}
+	list[1] = list
+	_, err := Encode(list, io.Discard)
+	if err == nil {
+		t.Fatalf("expected list containing itself to be rejected")
+	}
+	t.Logf("got expected error: %v", err)
+}
+
+func TestEncodeOrderedMap(t *testing.T) {
+	input := "zeta: 1\nalpha:\n  - x\n  -\n    {b: 2, a: 3}\nmid:\n  > text\n"
+	tree, err := nestext.Parse(strings.NewReader(input), nestext.OrderedDicts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &strings.Builder{}
+	if _, err = Encode(tree, out); err != nil {
+		t.Fatal(err)
+	}
+	expected := "zeta: 1\nalpha:\n  - x\n  -\n    b: 2\n    a: 3\nmid: text\n"
+	if out.String() != expected {
+		t.Errorf("expected order of keys to be kept, have\n%s", out.String())
+	}
+}
+
+func TestEncodeNil(t *testing.T) {
+	tree := map[string]interface{}{
+		"a": []interface{}(nil),
+		"b": map[string]interface{}(nil),
+		"c": []string{},
+		"d": []interface{}{"x", nil, []int(nil)},
+	}
+	out := &strings.Builder{}
+	if _, err := Encode(tree, out, OnNil(NilOmitted)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "c:\n  []\nd:\n  - x\n"
+	if out.String() != expected {
+		t.Errorf("expected nil values to be omitted, have\n%s", out.String())
+	}
+	delete(tree, "d")
+	out.Reset()
+	if _, err := Encode(tree, out); err != nil {
+		t.Fatal(err)
+	}
+	expected = "a:\n  []\nb:\n  {}\nc:\n  []\n"
+	if out.String() != expected {
+		t.Errorf("expected nil values to be encoded as empty, have\n%s", out.String())
+	}
+	result, err := nestext.Parse(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := result.(map[string]interface{})["a"].([]interface{}); !ok || len(l) != 0 {
+		t.Errorf("expected empty list to parse as empty list, have %#v", result)
+	}
+}
+
+func TestEncodeTrailingWhitespace(t *testing.T) {
+	inputs := []struct {
+		tree     interface{}
+		expected string
+	}{
+		{"a  \nb\t\n \t", "> a  \n> b\t\n>  \t\n"},
+		{[]interface{}{"x \t", "\t\ny "}, "- x \t\n-\n  > \t\n  > y \n"},
+		{map[string]interface{}{"k": "v  "}, "k: v  \n"},
+	}
+	for _, input := range inputs {
+		out := &strings.Builder{}
+		if _, err := Encode(input.tree, out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != input.expected {
+			t.Errorf("expected %q, have %q", input.expected, out.String())
+		}
+		result, err := nestext.Parse(strings.NewReader(out.String()))
+		if err != nil || !reflect.DeepEqual(result, input.tree) {
+			t.Errorf("expected %q to parse to %#v, have %#v, %v", out.String(), input.tree, result, err)
+		}
+	}
+}
+
+func TestEncodeSpecialKeys(t *testing.T) {
+	tree := map[string]interface{}{"- a": "x", "a:b": "y", "[k]": []interface{}{"v, w"}}
+	out := &strings.Builder{}
+	if _, err := Encode(tree, out); err != nil {
+		t.Fatal(err)
+	}
+	expected := ": - a\n  > x\n: [k]\n  - v, w\na:b: y\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\nhave\n%s", expected, out.String())
+	}
+	result, err := nestext.Parse(strings.NewReader(out.String()))
+	if err != nil || !reflect.DeepEqual(result, tree) {
+		t.Errorf("expected output to parse to input tree, have %#v, %v", result, err)
+	}
+}
+
+func TestEncodeCommentFunc(t *testing.T) {
+	tree := map[string]interface{}{
+		"server": map[string]interface{}{"host": "localhost", "port": "8080"},
+		"users":  []interface{}{"alice"},
+	}
+	explain := func(path string) []string {
+		switch path {
+		case "":
+			return []string{"generated file, do not edit"}
+		case "/server/port":
+			return []string{"port to listen on", "", "default: 8080"}
+		case "/users/0":
+			return []string{"first user\nhas admin rights"}
+		}
+		return nil
+	}
+	out := &strings.Builder{}
+	if _, err := Encode(tree, out, WithCommentFunc(explain)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "# generated file, do not edit\nserver:\n  host: localhost\n  # port to listen on\n  #\n" +
+		"  # default: 8080\n  port: 8080\nusers:\n  # first user\n  # has admin rights\n  - alice\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\nhave\n%s", expected, out.String())
+	}
+}
+
+func TestEncodeFinalNewline(t *testing.T) {
+	tree := map[string]interface{}{"a": "1", "b": []interface{}{"x\ny"}}
+	out := &strings.Builder{}
+	n, err := Encode(tree, out, OmitFinalNewline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a: 1\nb:\n  -\n    > x\n    > y"; out.String() != expected || n != len(expected) {
+		t.Errorf("expected final line break to be left out, have %q (%d bytes)", out.String(), n)
+	}
+	out.Reset()
+	n, err = EncodeAll([]interface{}{"one", "two"}, out, nestext.DocumentDelimiter, OmitFinalNewline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "> one\n" + nestext.DocumentDelimiter + "\n> two"; out.String() != expected || n != len(expected) {
+		t.Errorf("expected delimiter to terminate documents, have %q (%d bytes)", out.String(), n)
+	}
+}
+
 // ----------------------------------------------------------------------
 
 func expect(t *testing.T, tree interface{}, target string) {
@@ -122,3 +527,96 @@ func expect(t *testing.T, tree interface{}, target string) {
 		}
 	}
 }
+
+func TestEncodeReferenceLayout(t *testing.T) {
+	dict := nestext.NewOrderedMap()
+	dict.Set("z", []string{"a", ""})
+	dict.Set("a", []interface{}{"", "x\n\ny", []interface{}{}})
+	dict.Set("key\nlines", "")
+	dict.Set("empty", "")
+	buf := &strings.Builder{}
+	if _, err := Encode(dict, buf, ReferenceLayout()); err != nil {
+		t.Fatal(err)
+	}
+	expected := `z:
+    - a
+    -
+a:
+    -
+    -
+        > x
+        >
+        > y
+    -
+        []
+: key
+: lines
+    >
+empty:
+`
+	if buf.String() != expected {
+		t.Errorf("expected output\n%s\nhave\n%s", expected, buf.String())
+	}
+	tree, err := nestext.Parse(strings.NewReader(buf.String()), nestext.OrderedDicts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nestext.Equal(tree, dict) {
+		t.Errorf("expected output to decode to input, have %v", tree)
+	}
+}
+
+type marshaledItem struct{ s string }
+
+func (m marshaledItem) MarshalNestedText() (interface{}, error) {
+	return m.s, nil
+}
+
+// failingWriter fails on the first call to Write.
+type failingWriter struct{ calls int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.calls++
+	if w.calls == 1 {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+func TestEncodeMarshalerKeepsError(t *testing.T) {
+	tree := []interface{}{"x", marshaledItem{"a"}, marshaledItem{"b"}}
+	if _, err := Encode(tree, &failingWriter{}); err == nil {
+		t.Errorf("expected write error to be reported, wasn't")
+	}
+}
+
+type color int
+
+func (c color) MarshalNestedText() (interface{}, error) {
+	return []string{"red", "green", "blue", "cyan"}[c], nil
+}
+
+func TestEncodeNestedMarshalers(t *testing.T) {
+	dict := nestext.NewOrderedMap()
+	dict.Set("c", color(3))
+	dict.Set("l", []interface{}{color(1), marshaledItem{"a\nb"}})
+	inputs := []struct {
+		tree     interface{}
+		expected string
+	}{
+		{map[string]interface{}{"c": color(3)}, "c: cyan\n"},
+		{map[string]color{"c": color(0)}, "c: red\n"},
+		{[]interface{}{color(2), "x"}, "- blue\n- x\n"},
+		{[]color{color(1)}, "- green\n"},
+		{dict, "c: cyan\nl:\n  - green\n  -\n    > a\n    > b\n"},
+	}
+	for _, input := range inputs {
+		buf := &strings.Builder{}
+		if _, err := Encode(input.tree, buf, IndentBy(2)); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != input.expected {
+			t.Errorf("expected output\n%s\nhave\n%s", input.expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package ntenc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Converting JSON streams -----------------------------------------------
+
+// FromJSONStream converts the next JSON value read from `dec` to NestedText, writing it
+// to `w`. The JSON value is processed token by token, without building up a tree in
+// memory, thus large JSON exports may be converted for human review. It returns the
+// number of bytes written and possibly an error (of type nestext.NestedTextError).
+//
+// The order of keys is kept. Numbers are written as they appear in the JSON source (for
+// this, FromJSONStream calls dec.UseNumber), booleans as "true" or "false", and null as
+// an empty string. As the encoder cannot look ahead, lists and dicts are never written
+// as inline lists or dicts, except for empty ones. Of the encoder options, only IndentBy
+// and MaxDepth apply.
+//
+// Use as:
+//     dec := json.NewDecoder(jsonFile)
+//     _, err := ntenc.FromJSONStream(dec, os.Stdout, ntenc.IndentBy(4))
+//
+func FromJSONStream(dec *json.Decoder, w io.Writer, opts ...EncoderOption) (int, error) {
+	if dec == nil {
+		return 0, nestext.MakeNestedTextError(nestext.ErrCodeUsage, "no JSON decoder present")
+	}
+	dec.UseNumber()
+	s := &jsonStream{enc: newEncoder(opts), dec: dec, w: w}
+	tok, err := s.token()
+	if err != nil {
+		return 0, err
+	}
+	if delim, ok := tok.(json.Delim); ok {
+		if !dec.More() {
+			s.empty(delim, 0)
+		} else {
+			s.container(delim, 0, 1)
+		}
+	} else {
+		s.multiline(scalar(tok), 0)
+	}
+	return s.bcnt, s.err
+}
+
+// jsonStream holds the state of a conversion of a JSON stream.
+type jsonStream struct {
+	enc  *encoder
+	dec  *json.Decoder
+	w    io.Writer
+	bcnt int   // count of bytes written
+	err  error // first error, stops further output
+}
+
+func (s *jsonStream) write(data string) {
+	s.bcnt, s.err = wr(s.w, s.bcnt, s.err, []byte(data))
+}
+
+func (s *jsonStream) indent(indent int) {
+	s.bcnt, s.err = s.enc.indent(s.w, s.bcnt, s.err, indent)
+}
+
+// token reads the next JSON token, recording errors.
+func (s *jsonStream) token() (json.Token, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	tok, err := s.dec.Token()
+	if err != nil {
+		s.err = nestext.WrapError(nestext.ErrCodeFormat, "invalid JSON input", err)
+		return nil, s.err
+	}
+	return tok, nil
+}
+
+// container writes the items of a non-empty JSON array or object, the opening delimiter
+// of which has been read, with indentation `indent` and nesting depth `depth`.
+func (s *jsonStream) container(delim json.Delim, indent, depth int) {
+	if s.enc.maxDepth > 0 && depth > s.enc.maxDepth {
+		s.err = nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+			fmt.Sprintf("nesting depth exceeds maximum of %d", s.enc.maxDepth))
+		return
+	}
+	for s.err == nil && s.dec.More() {
+		if delim == '[' {
+			tok, err := s.token()
+			if err != nil {
+				return
+			}
+			s.indent(indent)
+			s.write("-")
+			s.item(tok, indent, depth, true)
+			continue
+		}
+		tok, err := s.token()
+		if err != nil {
+			return
+		}
+		key := tok.(string) // object keys are always strings
+		if tok, err = s.token(); err != nil {
+			return
+		}
+		if ok, k := isInlineable(asKey, key); ok {
+			s.indent(indent)
+			s.write(string(k) + ":")
+			s.item(tok, indent, depth, true)
+			continue
+		}
+		for _, line := range strings.Split(key, "\n") { // multi-line key
+			s.indent(indent)
+			if line == "" {
+				s.write(":\n")
+			} else {
+				s.write(": " + line + "\n")
+			}
+		}
+		s.item(tok, indent, depth, false)
+	}
+	s.token() // closing delimiter
+}
+
+// item writes the value `tok` of a list item or dict item at `indent`, the tag or key
+// of which has been written. If `open` is set, the line of the tag or key has not been
+// terminated yet.
+func (s *jsonStream) item(tok json.Token, indent, depth int, open bool) {
+	if delim, ok := tok.(json.Delim); ok {
+		if open {
+			s.write("\n")
+		}
+		if !s.dec.More() {
+			s.empty(delim, indent+1)
+		} else {
+			s.container(delim, indent+1, depth+1)
+		}
+		return
+	}
+	str := scalar(tok)
+	if open {
+		if ok, b := isInlineable(asString, str); ok {
+			s.write(" " + string(b) + "\n")
+			return
+		}
+		s.write("\n")
+	}
+	if str != "" {
+		s.multiline(str, indent+1)
+	}
+}
+
+// empty writes an empty list or dict, the opening delimiter of which has been read.
+func (s *jsonStream) empty(delim json.Delim, indent int) {
+	s.token() // closing delimiter
+	s.indent(indent)
+	if delim == '[' {
+		s.write("[]\n")
+	} else {
+		s.write("{}\n")
+	}
+}
+
+// multiline writes a multi-line string.
+func (s *jsonStream) multiline(str string, indent int) {
+	for _, line := range strings.Split(str, "\n") {
+		s.indent(indent)
+		if line == "" {
+			s.write(">\n")
+		} else {
+			s.write("> " + line + "\n")
+		}
+	}
+}
+
+// scalar converts a JSON scalar token to a string.
+func scalar(tok json.Token) string {
+	switch t := tok.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(tok)
+}
@@ -0,0 +1,82 @@
+package ntenc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+func TestFromJSONStream(t *testing.T) {
+	input := `{"name": "app", "port": 8080, "debug": false, "tags": [], "opts": {},
+		"hosts": ["a", {"b": null, "c": [1.50, "x\ny"]}], "line\nkey": "v", "": ""}`
+	expected := `name: app
+port: 8080
+debug: false
+tags:
+  []
+opts:
+  {}
+hosts:
+  - a
+  -
+    b:
+    c:
+      - 1.50
+      -
+        > x
+        > y
+: line
+: key
+  > v
+:
+`
+	var out strings.Builder
+	n, err := FromJSONStream(json.NewDecoder(strings.NewReader(input)), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected || n != len(expected) {
+		t.Errorf("expected\n%s\nhave (%d bytes)\n%s", expected, n, out.String())
+	}
+	tree, err := nestext.Parse(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	var want interface{}
+	if err = dec.Decode(&want); err != nil {
+		t.Fatal(err)
+	}
+	want.(map[string]interface{})["port"] = "8080"
+	want.(map[string]interface{})["debug"] = "false"
+	want.(map[string]interface{})["hosts"] = []interface{}{"a", map[string]interface{}{
+		"b": "", "c": []interface{}{"1.50", "x\ny"},
+	}}
+	if !nestext.Equal(tree, want) {
+		t.Errorf("expected %v, have %v", want, tree)
+	}
+}
+
+func TestFromJSONStreamScalarsAndErrors(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`"one" ["two"] {"a": `))
+	var out strings.Builder
+	for i := 0; i < 2; i++ {
+		if _, err := FromJSONStream(dec, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.String() != "> one\n- two\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	_, err := FromJSONStream(dec, &out)
+	if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != nestext.ErrCodeFormat {
+		t.Errorf("expected error for truncated JSON, have %v", err)
+	}
+	_, err = FromJSONStream(json.NewDecoder(strings.NewReader(`[[["x"]]]`)), &out, MaxDepth(2))
+	if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != nestext.ErrCodeSchema {
+		t.Errorf("expected error for nesting depth, have %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+// Package ntfmt formats NestedText documents in a canonical layout, like gofmt does
+// for Go source. Formatting never changes the value of a document and never
+// re-orders its items or comments. It
+//
+//   - re-indents all items by a fixed number of spaces per nesting level, by default
+//     the indentation width of the document's first nested value,
+//   - indents comments like the items they precede,
+//   - removes padding between dict keys and their colon (`key   : value`),
+//   - removes trailing whitespace from comments and blank lines,
+//   - collapses runs of blank lines and removes blank lines at the start and end,
+//   - terminates all lines, including the last one, by "\n".
+//
+// Use as:
+//     formatted, err := ntfmt.Format(src)
+//
+package ntfmt
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntast"
+)
+
+// --- Options ---------------------------------------------------------------
+
+// Option configures the formatter.
+type Option _Option
+
+type _Option func(*formatter) // internal synonym to hide unterlying type of options.
+
+// DefaultIndent is the number of spaces per indentation level for documents without
+// nested values.
+const DefaultIndent = 2
+
+// IndentBy sets the number of spaces per indentation level. By default, the
+// indentation width of the document is kept (see ntast.Document.Indent). Values below
+// 1 are ignored.
+//
+// Use as:
+//     ntfmt.Format(src, ntfmt.IndentBy(4))
+//
+func IndentBy(indentSize int) Option {
+	return func(f *formatter) {
+		if indentSize > 0 {
+			f.step = indentSize
+		}
+	}
+}
+
+// --- Formatting ------------------------------------------------------------
+
+// Format formats the source of a NestedText document. Invalid documents are
+// rejected with the error reported by nestext.Parse.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func Format(src []byte, opts ...Option) ([]byte, error) {
+	f := &formatter{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	doc, err := ntast.Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	if f.step == 0 {
+		if f.step = doc.Indent(); f.step == 0 {
+			f.step = DefaultIndent
+		}
+	}
+	for _, n := range doc.Nodes {
+		f.node(n, 0)
+	}
+	var out bytes.Buffer
+	blanks := 0
+	for _, l := range f.lines {
+		if l == "" {
+			blanks++
+			continue
+		}
+		if blanks > 0 && out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		blanks = 0
+		out.WriteString(l)
+		out.WriteByte('\n')
+	}
+	// safety net: formatting must not change the value of the document
+	before, _ := nestext.Parse(bytes.NewReader(src))
+	after, err := nestext.Parse(bytes.NewReader(out.Bytes()))
+	if err != nil || !reflect.DeepEqual(before, after) {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeFormat,
+			"formatting would change the value of the document")
+	}
+	return out.Bytes(), nil
+}
+
+// formatter collects the formatted lines of a document.
+type formatter struct {
+	step  int      // spaces per indentation level
+	lines []string // formatted lines, without line terminators
+}
+
+// node formats a node and its children, indenting items by `indent` spaces.
+func (f *formatter) node(n *ntast.Node, indent int) {
+	for _, l := range n.Lines {
+		f.line(n, l.Text, indent)
+	}
+	nested := indent
+	if n.Type == ntast.ListItemNode || n.Type == ntast.DictItemNode {
+		nested += f.step
+	}
+	for _, c := range n.Children {
+		f.node(c, nested)
+	}
+}
+
+// line formats a source line of node n.
+func (f *formatter) line(n *ntast.Node, text string, indent int) {
+	body := strings.TrimLeft(text, " ")
+	trimmed := strings.TrimSpace(body)
+	prefix := strings.Repeat(" ", indent)
+	switch {
+	case trimmed == "":
+		f.lines = append(f.lines, "")
+	case trimmed[0] == '#':
+		f.lines = append(f.lines, prefix+trimmed)
+	case n.Type == ntast.DictItemNode && strings.HasPrefix(body, n.Key) && !isKeyLine(body):
+		rest := strings.TrimLeft(body[len(n.Key):], " \t")
+		f.lines = append(f.lines, prefix+n.Key+rest)
+	default:
+		f.lines = append(f.lines, prefix+body)
+	}
+}
+
+// isKeyLine checks if a line is part of a multi-line key (": key").
+func isKeyLine(body string) bool {
+	return body[0] == ':' && (len(body) == 1 || body[1] == ' ')
+}
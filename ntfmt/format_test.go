@@ -0,0 +1,57 @@
+package ntfmt
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	inputs := []struct {
+		src, expected string
+	}{
+		{"a: 1", "a: 1\n"},
+		{"\n\n# header  \r\n\r\nname   : Alice\r\ntags:\r\n    # first\r\n    - a\r\n\r\n\r\n    -\r\n       > line  \r\n",
+			"# header\n\nname: Alice\ntags:\n  # first\n  - a\n\n  -\n    > line  \n"},
+		{"a:\n     b:\n            c:   x\n     d: [x,  y]\n", "a:\n  b:\n    c:   x\n  d: [x,  y]\n"},
+		{": multi\n    # inside key\n:   line key\n        > value\n", ": multi\n# inside key\n:   line key\n  > value\n"},
+		{"-:: x\nkey: \n", "-:: x\nkey: \n"},
+	}
+	for i, input := range inputs {
+		out, err := Format([]byte(input.src), IndentBy(2))
+		if err != nil {
+			t.Fatalf("[%d] %v", i, err)
+		}
+		if string(out) != input.expected {
+			t.Errorf("[%d] expected\n%q\nhave\n%q", i, input.expected, out)
+		}
+		again, _ := Format(out)
+		if string(again) != string(out) {
+			t.Errorf("[%d] expected formatting to be idempotent, have\n%q", i, again)
+		}
+	}
+}
+
+func TestFormatIndent(t *testing.T) {
+	out, err := Format([]byte("a:\n  -\n   b: c\n"), IndentBy(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "a:\n    -\n        b: c\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestFormatKeepsIndent(t *testing.T) {
+	out, err := Format([]byte("a:\n    b:\n      c: x\nd:\n  - y\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "a:\n    b:\n        c: x\nd:\n    - y\n" {
+		t.Errorf("expected indentation of first nested value to be kept, have %q", out)
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	if _, err := Format([]byte("a: 1\n  b: 2\n")); err == nil {
+		t.Errorf("expected invalid document to be rejected")
+	}
+}
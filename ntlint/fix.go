@@ -0,0 +1,80 @@
+package ntlint
+
+import (
+	"sort"
+)
+
+// --- Fixes -----------------------------------------------------------------
+
+// Edit is a replacement of a range of bytes of a document's source.
+type Edit struct {
+	Offset int    // byte offset of the range to replace
+	Length int    // length of the range in bytes; 0 for insertions
+	Text   string // replacement text
+}
+
+// Fix is a set of edits which fixes a finding. Edits of a fix do not overlap and
+// have to be applied together.
+type Fix struct {
+	Edits []Edit
+}
+
+// ApplyFixes applies the fixes carried by findings to the source of a document.
+// It returns the fixed source and the number of fixes applied.
+//
+// Fixes which overlap with fixes applied before are skipped. They may become
+// applicable after linting the fixed source again; clients will usually repeat
+// linting and fixing until no more fixes are applied.
+//
+func ApplyFixes(src []byte, findings []Finding) ([]byte, int) {
+	var edits []Edit
+	applied := 0
+	for _, f := range findings {
+		if f.Fix == nil || overlaps(edits, f.Fix.Edits) {
+			continue
+		}
+		edits = append(edits, f.Fix.Edits...)
+		applied++
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Offset < edits[j].Offset
+	})
+	out := make([]byte, 0, len(src))
+	pos := 0
+	for _, e := range edits {
+		out = append(out, src[pos:e.Offset]...)
+		out = append(out, e.Text...)
+		pos = e.Offset + e.Length
+	}
+	return append(out, src[pos:]...), applied
+}
+
+// overlaps checks if any edit of a fix touches a range already covered by edits.
+// Insertions at the same position are considered overlapping, too.
+func overlaps(edits []Edit, fix []Edit) bool {
+	for _, e := range edits {
+		for _, f := range fix {
+			if f.Offset < e.Offset+e.Length && e.Offset < f.Offset+f.Length || f.Offset == e.Offset {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maxFixPasses limits the number of lint-and-fix passes of FixAll.
+const maxFixPasses = 10
+
+// FixAll repeatedly lints a document and applies fixes, until no more fixes are
+// applicable. It returns the fixed source and the findings which remain.
+func FixAll(src []byte, cfg *Config) ([]byte, []Finding) {
+	findings := Lint(src, cfg)
+	for i := 0; i < maxFixPasses; i++ {
+		var n int
+		if src, n = ApplyFixes(src, findings); n == 0 {
+			break
+		}
+		findings = Lint(src, cfg)
+	}
+	return src, findings
+}
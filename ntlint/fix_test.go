@@ -0,0 +1,42 @@
+package ntlint
+
+import (
+	"testing"
+)
+
+func TestApplyFixes(t *testing.T) {
+	src := "# header  \n" +
+		"a:\n" +
+		"  - x\n" +
+		"b:\n" +
+		"     c: d   \n" +
+		"     e:\n" +
+		"          > text\n" +
+		"  \n" +
+		"f: g"
+	fixed, findings := FixAll([]byte(src), nil)
+	expected := "# header\n" +
+		"a:\n" +
+		"  - x\n" +
+		"b:\n" +
+		"  c: d   \n" +
+		"  e:\n" +
+		"    > text\n" +
+		"\n" +
+		"f: g\n"
+	t.Logf("fixed:\n%s", fixed)
+	if string(fixed) != expected {
+		t.Errorf("expected fixed source to be %q, is %q", expected, fixed)
+	}
+	if len(findings) != 1 || findings[0].Rule != RuleTrailingWhitespace || findings[0].Fix != nil {
+		t.Errorf("expected trailing whitespace of a value to remain unfixed, have %v", findings)
+	}
+}
+
+func TestApplyFixesCRLF(t *testing.T) {
+	src := []byte("a:\r\n    b: c\r\n    d: e")
+	fixed, _ := FixAll(src, nil)
+	if string(fixed) != "a:\r\n    b: c\r\n    d: e\r\n" {
+		t.Errorf("unexpected fix result %q", fixed)
+	}
+}
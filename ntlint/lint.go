@@ -0,0 +1,239 @@
+// Package ntlint checks NestedText documents for constructs which are valid, but
+// questionable, like trailing whitespace or inconsistent indentation.
+//
+// Linting is a line-oriented process and does not require a document to be valid
+// NestedText. Clients will usually check for validity first by parsing a document
+// with nestext.Parse.
+//
+// Rules may be switched off individually and their severities may be configured,
+// usually from a configuration file `.ntlint.nt` like this:
+//
+//     rules:
+//         trailing-whitespace: error
+//         duplicate-values: off
+//     max-depth: 6
+//
+package ntlint
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Severities ------------------------------------------------------------
+
+// Severity is the severity level of a finding. Severity `Off` disables a rule.
+type Severity int
+
+// Severity levels for rules
+const (
+	Off Severity = iota
+	Info
+	Warning
+	Error
+)
+
+var severityNames = []string{"off", "info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < Off || s > Error {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity returns the severity for one of "off", "info", "warning" or "error".
+func ParseSeverity(s string) (Severity, error) {
+	for i, name := range severityNames {
+		if strings.EqualFold(s, name) {
+			return Severity(i), nil
+		}
+	}
+	return Off, fmt.Errorf("unknown severity %q", s)
+}
+
+// --- Rules and findings ----------------------------------------------------
+
+// Finding is a single problem found in a document.
+type Finding struct {
+	Rule     string   // name of the rule which produced the finding
+	Severity Severity // severity, as configured for the rule
+	Line     int      // line number, starting at 1
+	Column   int      // column, starting at 0
+	Message  string   // human readable description
+	Fix      *Fix     // machine-applicable fix, if the finding may be fixed safely
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("[%d,%d] %s: %s (%s)", f.Line, f.Column, f.Severity, f.Message, f.Rule)
+}
+
+// Rule describes a lint rule.
+type Rule struct {
+	Name        string   // name to identify the rule in a configuration
+	Description string   // short description
+	Severity    Severity // default severity
+	check       func(doc *document, cfg *Config, report reporter)
+}
+
+// reporter is called by rules to report a finding. Rules may provide text edits to
+// fix the problem.
+type reporter func(line, col int, msg string, edits ...Edit)
+
+// Rule names
+const (
+	RuleIndentWidth        = "indent-width"
+	RuleTrailingWhitespace = "trailing-whitespace"
+	RuleEmptyKey           = "empty-key"
+	RuleDeepNesting        = "deep-nesting"
+	RuleDuplicateValues    = "duplicate-values"
+	RuleSuspiciousUnicode  = "suspicious-unicode"
+	RuleFinalNewline       = "final-newline"
+)
+
+var rules = []Rule{
+	{RuleIndentWidth, "indentation width differs from the rest of the document", Warning, checkIndentWidth},
+	{RuleTrailingWhitespace, "line ends with whitespace", Warning, checkTrailingWhitespace},
+	{RuleEmptyKey, "dict key is empty", Warning, checkEmptyKeys},
+	{RuleDeepNesting, "items nested deeper than configured maximum", Info, checkDeepNesting},
+	{RuleDuplicateValues, "list contains identical sibling values", Warning, checkDuplicateValues},
+	{RuleSuspiciousUnicode, "invisible or bidi control characters", Error, checkSuspiciousUnicode},
+	{RuleFinalNewline, "last line is not terminated by a newline", Info, checkFinalNewline},
+}
+
+// Rules returns the list of all available rules, with their default severities.
+func Rules() []Rule {
+	r := make([]Rule, len(rules))
+	copy(r, rules)
+	return r
+}
+
+// --- Configuration ---------------------------------------------------------
+
+// DefaultMaxDepth is the default nesting depth above which rule "deep-nesting" reports
+// findings.
+const DefaultMaxDepth = 8
+
+// Config selects rules and configures their severities.
+type Config struct {
+	Severities map[string]Severity // severities by rule name, overriding defaults
+	MaxDepth   int                 // maximum nesting depth for rule "deep-nesting"
+}
+
+// DefaultConfig returns a configuration with all rules at their default severities.
+func DefaultConfig() *Config {
+	return &Config{Severities: map[string]Severity{}, MaxDepth: DefaultMaxDepth}
+}
+
+// LoadConfig reads a configuration in NestedText format, usually from a file
+// `.ntlint.nt`. It has to be a dict with optional keys "rules" (a dict of rule names
+// and severities) and "max-depth".
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func LoadConfig(r io.Reader) (*Config, error) {
+	cfg := DefaultConfig()
+	tree, err := nestext.Parse(r, nestext.TopLevel("dict"))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range tree.(map[string]interface{}) {
+		switch key {
+		case "rules":
+			rs, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, configError("rules must be a dict of rule names and severities")
+			}
+			for name, sev := range rs {
+				if findRule(name) == nil {
+					return nil, configError(fmt.Sprintf("unknown rule %q", name))
+				}
+				s, _ := sev.(string)
+				severity, err := ParseSeverity(s)
+				if err != nil {
+					return nil, configError(fmt.Sprintf("rule %q: %s", name, err.Error()))
+				}
+				cfg.Severities[name] = severity
+			}
+		case "max-depth":
+			s, _ := value.(string)
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return nil, configError("max-depth must be a positive number")
+			}
+			cfg.MaxDepth = n
+		case "nestedtext": // wrapped by TopLevel option
+			if value != nil {
+				return nil, configError("configuration must be a dict")
+			}
+		default:
+			return nil, configError(fmt.Sprintf("unknown configuration key %q", key))
+		}
+	}
+	return cfg, nil
+}
+
+func configError(msg string) error {
+	return nestext.MakeNestedTextError(nestext.ErrCodeUsage, "lint configuration: "+msg)
+}
+
+func findRule(name string) *Rule {
+	for i := range rules {
+		if rules[i].Name == name {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// severity returns the configured severity for a rule.
+func (cfg *Config) severity(rule *Rule) Severity {
+	if s, ok := cfg.Severities[rule.Name]; ok {
+		return s
+	}
+	return rule.Severity
+}
+
+// --- Linting ---------------------------------------------------------------
+
+// Lint checks a NestedText document with all rules enabled in `cfg`.
+// If cfg is nil, the default configuration is used.
+// Findings are sorted by position.
+func Lint(src []byte, cfg *Config) []Finding {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	doc := splitDocument(src)
+	var findings []Finding
+	for i := range rules {
+		rule := &rules[i]
+		severity := cfg.severity(rule)
+		if severity == Off {
+			continue
+		}
+		rule.check(doc, cfg, func(line, col int, msg string, edits ...Edit) {
+			f := Finding{
+				Rule:     rule.Name,
+				Severity: severity,
+				Line:     line,
+				Column:   col,
+				Message:  msg,
+			}
+			if len(edits) > 0 {
+				f.Fix = &Fix{Edits: edits}
+			}
+			findings = append(findings, f)
+		})
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line == findings[j].Line {
+			return findings[i].Column < findings[j].Column
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
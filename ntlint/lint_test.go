@@ -0,0 +1,109 @@
+package ntlint
+
+import (
+	"strings"
+	"testing"
+)
+
+const lintInput = "# inventory \n" +
+	"hosts:\n" +
+	"  - alpha\n" +
+	"  - beta\n" +
+	"  - alpha\n" +
+	"settings:\n" +
+	"    mode: fast  \n" +
+	"    \u202eevil: x\n" +
+	":\n" +
+	"  > empty key\n" +
+	"deep:\n" +
+	"  a:\n" +
+	"    b:\n" +
+	"      c: d\n"
+
+func TestLintDefaults(t *testing.T) {
+	findings := Lint([]byte(lintInput), nil)
+	for _, f := range findings {
+		t.Logf("%s", f)
+	}
+	expected := []struct {
+		rule string
+		line int
+	}{
+		{RuleTrailingWhitespace, 1},
+		{RuleDuplicateValues, 5},
+		{RuleIndentWidth, 7},
+		{RuleTrailingWhitespace, 7},
+		{RuleIndentWidth, 8},
+		{RuleSuspiciousUnicode, 8},
+		{RuleEmptyKey, 9},
+	}
+	if len(findings) != len(expected) {
+		t.Fatalf("expected %d findings, have %d", len(expected), len(findings))
+	}
+	for i, e := range expected {
+		if findings[i].Rule != e.rule || findings[i].Line != e.line {
+			t.Errorf("expected finding %d to be %s at line %d, is %s", i, e.rule, e.line, findings[i])
+		}
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	config := `
+rules:
+    trailing-whitespace: off
+    indent-width: error
+max-depth: 2
+`
+	cfg, err := LoadConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := Lint([]byte(lintInput), cfg)
+	deep := 0
+	for _, f := range findings {
+		t.Logf("%s", f)
+		switch f.Rule {
+		case RuleTrailingWhitespace:
+			t.Errorf("rule %s should have been switched off", f.Rule)
+		case RuleIndentWidth:
+			if f.Severity != Error {
+				t.Errorf("expected rule %s to have severity error", f.Rule)
+			}
+		case RuleDeepNesting:
+			deep++
+		}
+	}
+	if deep != 1 {
+		t.Errorf("expected 1 finding for deep nesting, have %d", deep)
+	}
+	for _, bad := range []string{"rules:\n  no-such-rule: off\n", "max-depth: x\n", "- a\n"} {
+		if _, err := LoadConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected configuration %q to be rejected", bad)
+		}
+	}
+}
+
+func TestLintDuplicateItems(t *testing.T) {
+	input := "hosts:\n" +
+		"  -\n" +
+		"    name: alpha\n" +
+		"    port: 80\n" +
+		"  -\n" +
+		"    name: beta\n" +
+		"  -\n" +
+		"    # copied\n" +
+		"    name: alpha\n" +
+		"    port: 80\n" +
+		"  -\n" +
+		"  -\n"
+	cfg := &Config{Severities: map[string]Severity{}}
+	for _, r := range Rules() {
+		if r.Name != RuleDuplicateValues {
+			cfg.Severities[r.Name] = Off
+		}
+	}
+	findings := Lint([]byte(input), cfg)
+	if len(findings) != 1 || findings[0].Line != 7 || !strings.Contains(findings[0].Message, "line 2") {
+		t.Errorf("expected duplicate of line 2 at line 7, have %v", findings)
+	}
+}
@@ -0,0 +1,356 @@
+package ntlint
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Document lines --------------------------------------------------------
+
+type lineKind int8
+
+const (
+	blankLine   lineKind = iota
+	commentLine          // # …
+	listItem             // - …
+	stringItem           // > …
+	keyItem              // : … (multi-line key)
+	dictItem             // key: …
+	inlineList           // [ … ]
+	inlineDict           // { … }
+	otherLine            // not recognized, e.g. a dict key without ':'
+)
+
+// line is a classified line of a document.
+type line struct {
+	no       int      // line number, starting at 1
+	offset   int      // byte offset of the start of the line
+	text     string   // complete text of the line, without line terminator
+	eol      string   // line terminator, empty for the last line if not terminated
+	indent   int      // count of leading spaces
+	kind     lineKind // kind of item this line represents
+	content  string   // text after the item tag, or key for dict items
+	hasValue bool     // is the line's item tag followed by a value?
+}
+
+type document struct {
+	lines []line
+	size  int // length of the source in bytes
+}
+
+// splitDocument breaks up a document into lines, splitting at CR LF, CR or LF.
+func splitDocument(src []byte) *document {
+	doc := &document{size: len(src)}
+	start := 0
+	for start < len(src) {
+		end, next := start, start
+		for end < len(src) && src[end] != '\n' && src[end] != '\r' {
+			end++
+		}
+		switch {
+		case end == len(src):
+			next = end
+		case src[end] == '\r' && end+1 < len(src) && src[end+1] == '\n':
+			next = end + 2
+		default:
+			next = end + 1
+		}
+		l := classify(len(doc.lines)+1, string(src[start:end]))
+		l.offset, l.eol = start, string(src[end:next])
+		doc.lines = append(doc.lines, l)
+		start = next
+	}
+	return doc
+}
+
+func classify(no int, text string) line {
+	l := line{no: no, text: text}
+	body := strings.TrimLeft(text, " ")
+	l.indent = len(text) - len(body)
+	if strings.TrimSpace(body) == "" {
+		return l // blank line
+	}
+	switch {
+	case strings.HasPrefix(strings.TrimLeft(body, " \t"), "#"):
+		l.kind = commentLine
+	case isTagged(body, '-'):
+		l.kind, l.content, l.hasValue = listItem, tagContent(body), len(body) > 1
+	case isTagged(body, '>'):
+		l.kind, l.content, l.hasValue = stringItem, tagContent(body), len(body) > 1
+	case isTagged(body, ':'):
+		l.kind, l.content, l.hasValue = keyItem, tagContent(body), len(body) > 1
+	case body[0] == '[':
+		l.kind = inlineList
+	case body[0] == '{':
+		l.kind = inlineDict
+	default:
+		if i := strings.Index(body, ": "); i >= 0 {
+			l.kind, l.content, l.hasValue = dictItem, strings.TrimSpace(body[:i]), true
+		} else if strings.HasSuffix(body, ":") {
+			l.kind, l.content = dictItem, strings.TrimSpace(body[:len(body)-1])
+		} else {
+			l.kind = otherLine
+		}
+	}
+	return l
+}
+
+func isTagged(body string, tag byte) bool {
+	return body[0] == tag && (len(body) == 1 || body[1] == ' ')
+}
+
+func tagContent(body string) string {
+	if len(body) <= 2 {
+		return ""
+	}
+	return body[2:]
+}
+
+// isItem is true for lines which are neither blank nor comments.
+func (l line) isItem() bool {
+	return l.kind != blankLine && l.kind != commentLine
+}
+
+// nesting calls f for each item line with the nesting depth of the line (starting
+// at 1) and the indentation of its parent item (-1 for top-level items).
+func (doc *document) nesting(f func(l line, depth int, parentIndent int)) {
+	var stack []int // indentation of open items
+	for _, l := range doc.lines {
+		if !l.isItem() {
+			continue
+		}
+		for len(stack) > 0 && stack[len(stack)-1] >= l.indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := -1
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+		stack = append(stack, l.indent)
+		f(l, len(stack), parent)
+	}
+}
+
+// --- Rules -----------------------------------------------------------------
+
+func checkIndentWidth(doc *document, cfg *Config, report reporter) {
+	width, first := 0, 0
+	doc.nesting(func(l line, depth int, parent int) {
+		if i := strings.IndexByte(leadingSpace(l.text), '\t'); i >= 0 {
+			report(l.no, i, "tab character in indentation")
+			return
+		}
+		if parent < 0 {
+			return
+		}
+		if width == 0 {
+			width, first = l.indent-parent, l.no
+		} else if l.indent-parent != width {
+			report(l.no, l.indent, fmt.Sprintf("indented by %d spaces, line %d is indented by %d",
+				l.indent-parent, first, width), doc.shiftSubtree(l, parent+width-l.indent)...)
+		}
+	})
+}
+
+// shiftSubtree returns edits to move an item line, together with all the lines nested
+// under it, by delta columns. If any of these lines is indented by tabs, no edits are
+// returned.
+func (doc *document) shiftSubtree(l line, delta int) []Edit {
+	var edits []Edit
+	for i := l.no - 1; i < len(doc.lines); i++ {
+		sub := doc.lines[i]
+		if i >= l.no && sub.isItem() && sub.indent <= l.indent {
+			break // end of subtree
+		}
+		if sub.kind == blankLine || sub.indent < l.indent {
+			continue
+		}
+		if strings.IndexByte(leadingSpace(sub.text), '\t') >= 0 {
+			return nil
+		}
+		if delta > 0 {
+			edits = append(edits, Edit{Offset: sub.offset, Text: strings.Repeat(" ", delta)})
+		} else {
+			edits = append(edits, Edit{Offset: sub.offset, Length: -delta})
+		}
+	}
+	return edits
+}
+
+func leadingSpace(text string) string {
+	return text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+}
+
+func checkTrailingWhitespace(doc *document, cfg *Config, report reporter) {
+	for _, l := range doc.lines {
+		trimmed := strings.TrimRight(l.text, " \t")
+		if trimmed == l.text {
+			continue
+		}
+		col := utf8.RuneCountInString(trimmed)
+		remove := Edit{Offset: l.offset + len(trimmed), Length: len(l.text) - len(trimmed)}
+		switch {
+		case l.kind == blankLine:
+			report(l.no, col, "blank line contains whitespace", remove)
+		case l.hasValue: // removing whitespace would change the value
+			report(l.no, col, "trailing whitespace (is part of the value)")
+		default:
+			report(l.no, col, "trailing whitespace", remove)
+		}
+	}
+}
+
+func checkEmptyKeys(doc *document, cfg *Config, report reporter) {
+	for i := 0; i < len(doc.lines); i++ {
+		l := doc.lines[i]
+		switch l.kind {
+		case keyItem: // multi-line key: check if all lines are empty
+			empty, j := true, i
+			for ; j < len(doc.lines) && doc.lines[j].kind == keyItem && doc.lines[j].indent == l.indent; j++ {
+				empty = empty && doc.lines[j].content == ""
+			}
+			if empty {
+				report(l.no, l.indent, "empty multi-line key")
+			}
+			i = j - 1
+		case inlineDict:
+			tree, err := nestext.Parse(strings.NewReader(strings.TrimSpace(l.text)))
+			if err == nil && hasEmptyKey(tree) {
+				report(l.no, l.indent, "inline dict contains an empty key")
+			}
+		}
+	}
+}
+
+func hasEmptyKey(tree interface{}) bool {
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		for k, v := range t {
+			if k == "" || hasEmptyKey(v) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, v := range t {
+			if hasEmptyKey(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkDeepNesting(doc *document, cfg *Config, report reporter) {
+	max := cfg.MaxDepth
+	if max < 1 {
+		max = DefaultMaxDepth
+	}
+	doc.nesting(func(l line, depth int, parent int) {
+		if depth == max+1 { // report only the first line exceeding the limit
+			report(l.no, l.indent, fmt.Sprintf("item is nested more than %d levels deep", max))
+		}
+	})
+}
+
+func checkDuplicateValues(doc *document, cfg *Config, report reporter) {
+	type list struct {
+		indent int
+		seen   map[string]int // value → line number
+	}
+	var lists []list
+	for i, l := range doc.lines {
+		if !l.isItem() {
+			continue
+		}
+		for len(lists) > 0 && (lists[len(lists)-1].indent > l.indent ||
+			lists[len(lists)-1].indent == l.indent && l.kind != listItem) {
+			lists = lists[:len(lists)-1]
+		}
+		if l.kind != listItem {
+			continue
+		}
+		if len(lists) == 0 || lists[len(lists)-1].indent != l.indent {
+			lists = append(lists, list{indent: l.indent, seen: map[string]int{}})
+		}
+		value, msg := l.content, fmt.Sprintf("duplicate list value %q", l.content)
+		if !l.hasValue {
+			if value = doc.subtree(i); value == "" {
+				continue
+			}
+			value, msg = "\x00"+value, "duplicate list item"
+		}
+		seen := lists[len(lists)-1].seen
+		if first, ok := seen[value]; ok {
+			report(l.no, l.indent, fmt.Sprintf("%s, first occurence in line %d", msg, first))
+		} else {
+			seen[value] = l.no
+		}
+	}
+}
+
+// subtree returns the item lines nested under the item at index i, relative to the
+// indentation of the item and separated by newlines. Blank lines and comments are
+// omitted.
+func (doc *document) subtree(i int) string {
+	var b strings.Builder
+	indent := doc.lines[i].indent
+	for _, sub := range doc.lines[i+1:] {
+		if !sub.isItem() {
+			continue
+		}
+		if sub.indent <= indent {
+			break
+		}
+		b.WriteString(sub.text[indent:])
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func checkSuspiciousUnicode(doc *document, cfg *Config, report reporter) {
+	for _, l := range doc.lines {
+		col := 0
+		for _, r := range l.text {
+			if what := suspicious(r); what != "" {
+				report(l.no, col, fmt.Sprintf("%s %U", what, r))
+			}
+			col++
+		}
+	}
+}
+
+// suspicious classifies invisible characters which may be used to disguise the
+// content of a document.
+func suspicious(r rune) string {
+	switch {
+	case r == '\t':
+		return ""
+	case r < 0x20 || r == 0x7f || r >= 0x80 && r <= 0x9f:
+		return "control character"
+	case r == 0x061c || r == 0x200e || r == 0x200f || r >= 0x202a && r <= 0x202e ||
+		r >= 0x2066 && r <= 0x2069:
+		return "bidi control character"
+	case r >= 0x200b && r <= 0x200d || r == 0x2060 || r == 0xfeff:
+		return "zero-width character"
+	}
+	return ""
+}
+
+func checkFinalNewline(doc *document, cfg *Config, report reporter) {
+	if len(doc.lines) == 0 {
+		return
+	}
+	last := doc.lines[len(doc.lines)-1]
+	if last.eol != "" {
+		return
+	}
+	eol := doc.lines[0].eol
+	if eol == "" {
+		eol = "\n"
+	}
+	report(last.no, utf8.RuneCountInString(last.text), "missing newline at end of document",
+		Edit{Offset: doc.size, Text: eol})
+}
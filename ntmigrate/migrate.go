@@ -0,0 +1,245 @@
+// Package ntmigrate upgrades user-edited NestedText documents, usually configuration
+// files, between versions of the application reading them. Applications register
+// an ordered list of migrations, each upgrading documents to a new version by a
+// sequence of steps like renaming keys, moving items and converting values. The
+// version of a document is given by a top-level item, e.g. "version: 3".
+//
+// Migrations operate on lossless document trees of package ntast, thus comments,
+// blank lines and the layout of unchanged items are preserved:
+//
+//	m := ntmigrate.New("version")
+//	m.Register("2", ntmigrate.Rename("servers.*.addr", "address"))
+//	m.Register("3", ntmigrate.Move("db_host", "database.host"),
+//	    ntmigrate.Convert("timeout", addSeconds))
+//
+//	doc, report, err := m.Load(reader)
+//	…
+//	if report.Changed() {
+//	    log.Printf("upgraded configuration from version %s to %s", report.From, report.To)
+//	    doc.WriteTo(w)
+//	}
+package ntmigrate
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntast"
+	"github.com/npillmayer/nestext/ntrefactor"
+)
+
+// --- Steps -----------------------------------------------------------------
+
+// Step is a single transformation of a document, as part of a migration.
+type Step struct {
+	Description string                          // description for reports, e.g. "rename a to b"
+	Apply       func(doc *ntast.Document) error // transformation, modifying doc in place
+}
+
+// Rename creates a step renaming the keys of the dict items at path expression `path`
+// to `key` (see ntrefactor.RenameKey).
+func Rename(path, key string) Step {
+	return Step{
+		Description: fmt.Sprintf("rename %s to %s", path, key),
+		Apply: func(doc *ntast.Document) error {
+			return ntrefactor.RenameKey(doc, path, key)
+		},
+	}
+}
+
+// Move creates a step moving the dict item at path expression `from` to path `to`
+// (see ntrefactor.MoveSubtree).
+func Move(from, to string) Step {
+	return Step{
+		Description: fmt.Sprintf("move %s to %s", from, to),
+		Apply: func(doc *ntast.Document) error {
+			return ntrefactor.MoveSubtree(doc, from, to)
+		},
+	}
+}
+
+// Convert creates a step converting the values of the items at path expression `path`
+// (see ntrefactor.ConvertValue).
+func Convert(path string, convert func(value interface{}) (interface{}, error)) Step {
+	return Step{
+		Description: fmt.Sprintf("convert %s", path),
+		Apply: func(doc *ntast.Document) error {
+			return ntrefactor.ConvertValue(doc, path, convert)
+		},
+	}
+}
+
+// Optional wraps a step, ignoring errors for items which are not present in a
+// document. Users may have removed optional items from their configuration.
+func Optional(step Step) Step {
+	return Step{
+		Description: step.Description,
+		Apply: func(doc *ntast.Document) error {
+			err := step.Apply(doc)
+			if nterr, ok := err.(nestext.NestedTextError); ok && nterr.Code == nestext.ErrCodeSchema &&
+				nterr.Unwrap() == nil {
+				return nil
+			}
+			return err
+		},
+	}
+}
+
+// --- Migrations ------------------------------------------------------------
+
+// Migration upgrades documents to version Version, from the version of the
+// migration registered before it.
+type Migration struct {
+	Version string
+	Steps   []Step
+}
+
+// Migrator holds an ordered list of migrations.
+type Migrator struct {
+	key        string      // top-level key of the version item
+	migrations []Migration // in order of versions
+}
+
+// New creates a migrator for documents carrying their version in the top-level item
+// with key `versionKey`.
+func New(versionKey string) *Migrator {
+	return &Migrator{key: versionKey}
+}
+
+// Register appends a migration upgrading documents to version `version`, by applying
+// `steps` in order. Versions are ordered by registration, they are not compared
+// otherwise. Registering a version twice is an error.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (m *Migrator) Register(version string, steps ...Step) error {
+	if version == "" || m.index(version) >= 0 {
+		return nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("invalid or duplicate migration version %q", version))
+	}
+	m.migrations = append(m.migrations, Migration{Version: version, Steps: steps})
+	return nil
+}
+
+// Latest returns the version of the last migration, or "" if none has been registered.
+func (m *Migrator) Latest() string {
+	if len(m.migrations) == 0 {
+		return ""
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) index(version string) int {
+	for i, mig := range m.migrations {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// Change is a step applied to a document.
+type Change struct {
+	Version     string // version the step's migration upgrades to
+	Description string // description of the step
+}
+
+// Report describes the migration of a document.
+type Report struct {
+	From    string   // version of the document before migrating; "" if it had no version
+	To      string   // version of the document after migrating
+	Changes []Change // steps applied, in order
+}
+
+// Changed tells if the document has been modified.
+func (r *Report) Changed() bool {
+	return r.From != r.To
+}
+
+// Migrate upgrades document doc to the latest version, by applying all migrations
+// following the version of the document, and finally updating its version item.
+// Documents without a version item are of the version preceding all migrations; the
+// version item is inserted as the first item of the document. Documents of the latest
+// version are left unchanged.
+//
+// If a step fails, the error names the version and the step; the document is left
+// partially migrated and should be discarded. A document of a version unknown to the
+// migrator is an error of code ErrCodeSchema.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (m *Migrator) Migrate(doc *ntast.Document) (*Report, error) {
+	root := doc.Root()
+	if root != nil && root.Type != ntast.DictNode {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+			"document to migrate has to be a dict")
+	}
+	var item *ntast.Node
+	if root != nil {
+		item = root.Lookup(m.key)
+	}
+	report := &Report{}
+	start := 0
+	if item != nil {
+		if item.Nested() != nil {
+			return nil, versionError(item, fmt.Sprintf("%s has to be a single-line string", m.key))
+		}
+		report.From = item.Value
+		if start = m.index(item.Value) + 1; start == 0 {
+			return nil, versionError(item, fmt.Sprintf("unknown %s %q", m.key, item.Value))
+		}
+	}
+	report.To = report.From
+	for _, mig := range m.migrations[start:] {
+		for _, step := range mig.Steps {
+			if err := step.Apply(doc); err != nil {
+				return nil, nestext.WrapError(nestext.ErrCodeSchema, fmt.Sprintf(
+					"migration to %s %s: %s: %s", m.key, mig.Version, step.Description, err.Error()), err)
+			}
+			report.Changes = append(report.Changes, Change{Version: mig.Version, Description: step.Description})
+		}
+		report.To = mig.Version
+	}
+	if !report.Changed() {
+		return report, nil
+	}
+	if err := m.setVersion(doc, report.To); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// setVersion sets the version item of doc, creating it if necessary.
+func (m *Migrator) setVersion(doc *ntast.Document, version string) error {
+	root := doc.Root()
+	if root == nil {
+		return nestext.MakeNestedTextError(nestext.ErrCodeSchema, "migrations left an empty document")
+	}
+	if item := root.Lookup(m.key); item != nil {
+		return item.SetValue(version)
+	}
+	_, err := root.InsertKey(0, m.key, version)
+	return err
+}
+
+// Load reads a document with ntast.Parse, applying parser options `opts`, and migrates
+// it to the latest version. Clients should write the document back if the report
+// tells it has been changed.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (m *Migrator) Load(r io.Reader, opts ...nestext.Option) (*ntast.Document, *Report, error) {
+	doc, err := ntast.Parse(r, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	report, err := m.Migrate(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, report, nil
+}
+
+func versionError(item *ntast.Node, msg string) error {
+	err := nestext.MakeNestedTextError(nestext.ErrCodeSchema, msg)
+	err.Line = item.Line
+	return err
+}
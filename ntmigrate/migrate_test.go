@@ -0,0 +1,88 @@
+package ntmigrate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntast"
+)
+
+func newMigrator(t *testing.T) *Migrator {
+	m := New("version")
+	addSeconds := func(v interface{}) (interface{}, error) {
+		return v.(string) + "s", nil
+	}
+	if err := m.Register("2", Rename("servers.*.addr", "address")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register("3", Move("db_host", "database.host"), Optional(Convert("timeout", addSeconds))); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register("2"); err == nil {
+		t.Error("expected duplicate version to be rejected; wasn't")
+	}
+	return m
+}
+
+func TestMigrate(t *testing.T) {
+	m := newMigrator(t)
+	input := "# my config\nservers:\n  -\n    addr: example.com\ndb_host: localhost\n\ndatabase:\n  name: app\ntimeout: 10\n"
+	doc, report, err := m.Load(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# my config\nversion: 3\nservers:\n  -\n    address: example.com\n\ndatabase:\n  name: app\n  host: localhost\ntimeout: 10s\n"
+	if out := string(doc.Bytes()); out != expected {
+		t.Errorf("expected document\n%q\nhave\n%q", expected, out)
+	}
+	if !report.Changed() || report.From != "" || report.To != "3" || len(report.Changes) != 3 ||
+		report.Changes[1] != (Change{Version: "3", Description: "move db_host to database.host"}) {
+		t.Errorf("unexpected report %+v", report)
+	}
+	// migrating again does not change anything
+	report, err = m.Migrate(doc)
+	if err != nil || report.Changed() || len(report.Changes) != 0 {
+		t.Errorf("expected no changes, have %+v, %v", report, err)
+	}
+}
+
+func TestMigrateFromVersion(t *testing.T) {
+	m := newMigrator(t)
+	doc, report, err := m.Load(strings.NewReader("version: 2\ndb_host: x\ndatabase:\n  name: y\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.From != "2" || len(report.Changes) != 2 {
+		t.Errorf("expected migration from version 2 in 2 steps, have %+v", report)
+	}
+	expected := "version: 3\ndatabase:\n  name: y\n  host: x\n"
+	if out := string(doc.Bytes()); out != expected {
+		t.Errorf("expected document\n%q\nhave\n%q", expected, out)
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	m := newMigrator(t)
+	for _, input := range []string{
+		"version: 7\n",
+		"- a\n",
+		"version:\n  - 1\n",
+		"version: 2\ndatabase:\n  name: y\n", // db_host is missing
+	} {
+		_, _, err := m.Load(strings.NewReader(input))
+		if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != nestext.ErrCodeSchema {
+			t.Errorf("%q: expected error of code ErrCodeSchema, have %v", input, err)
+		}
+	}
+	doc, err := ntast.Parse(strings.NewReader("version: 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report, err := m.Migrate(doc); err != nil || report.Changed() {
+		t.Errorf("expected latest version to be left unchanged, have %+v, %v", report, err)
+	}
+	if m.Latest() != "3" {
+		t.Errorf("expected latest version 3, have %q", m.Latest())
+	}
+}
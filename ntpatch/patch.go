@@ -0,0 +1,126 @@
+// Package ntpatch rewrites single values of NestedText documents in place, leaving
+// the remainder of the source untouched. This keeps comments and layout of
+// hand-edited files, and avoids re-writing large documents if only a scalar changes.
+//
+// Use as:
+//     f, err := os.OpenFile("config.nt", os.O_RDWR, 0)
+//     …
+//     inPlace, err := ntpatch.PatchValue(f, "server.port", "8081")
+//
+package ntpatch
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/npillmayer/nestext"
+)
+
+// Truncater is implemented by documents which may shrink, like *os.File.
+type Truncater interface {
+	Truncate(size int64) error
+}
+
+// PatchValue replaces the string value at path expression `path` of the document in
+// `rws` by `value`. The value has to be a string on the line of its key or list tag,
+// and `value` must not contain line breaks. Values whose source differs from the
+// parsed value, e.g. because bidi control characters have been stripped, are not
+// patched.
+//
+// If the new value has the length of the old one, its bytes are overwritten and
+// PatchValue returns true. Otherwise the document is re-written from the value to
+// its end, and false is returned. Documents which shrink have to implement Truncater.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func PatchValue(rws io.ReadWriteSeeker, path string, value string) (bool, error) {
+	if nestext.NeedsMultiline(value) {
+		return false, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("cannot patch %q with a multi-line value", path))
+	}
+	if _, err := rws.Seek(0, io.SeekStart); err != nil {
+		return false, ioError(err)
+	}
+	src, err := ioutil.ReadAll(rws)
+	if err != nil {
+		return false, ioError(err)
+	}
+	pos, err := locate(src, path)
+	if err != nil {
+		return false, err
+	}
+	replacement := []byte(value)
+	if pos.ValueOffset == pos.ValueEndOffset && src[pos.ValueOffset-1] != ' ' {
+		replacement = append([]byte{' '}, replacement...) // "key:" → "key: value"
+	}
+	inPlace := len(replacement) == int(pos.ValueEndOffset-pos.ValueOffset)
+	if !inPlace {
+		replacement = append(replacement, src[pos.ValueEndOffset:]...)
+		if int64(len(replacement)) < int64(len(src))-pos.ValueOffset {
+			if _, ok := rws.(Truncater); !ok {
+				return false, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+					fmt.Sprintf("cannot patch %q with a shorter value: document cannot be truncated", path))
+			}
+		}
+	}
+	if _, err = rws.Seek(pos.ValueOffset, io.SeekStart); err != nil {
+		return false, ioError(err)
+	}
+	if _, err = rws.Write(replacement); err != nil {
+		return false, ioError(err)
+	}
+	if t, ok := rws.(Truncater); ok && !inPlace {
+		if err = t.Truncate(pos.ValueOffset + int64(len(replacement))); err != nil {
+			return false, ioError(err)
+		}
+	}
+	return inPlace, nil
+}
+
+// PatchFile replaces the string value at path expression `path` of the document in
+// file `filename` by `value`, like PatchValue does.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func PatchFile(filename string, path string, value string) (bool, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return false, nestext.WrapError(nestext.ErrCodeIO, "cannot open document for patching", err)
+	}
+	inPlace, err := PatchValue(f, path, value)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = ioError(cerr)
+	}
+	return inPlace, err
+}
+
+// locate finds the position of the value at path expression `path` within `src`,
+// which has to be a string on the line of its item.
+func locate(src []byte, path string) (nestext.Position, error) {
+	var positions nestext.Positions
+	tree, err := nestext.Parse(bytes.NewReader(src), nestext.ReportPositions(&positions))
+	if err != nil {
+		return nestext.Position{}, err
+	}
+	s, err := nestext.GetString(tree, path)
+	if err != nil {
+		return nestext.Position{}, err
+	}
+	pointer, _ := nestext.PathToPointer(path) // path is valid, as GetString succeeded
+	pos, ok := positions[pointer]
+	if !ok || pointer == "" || pos.EndLine != pos.Line {
+		return nestext.Position{}, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("cannot patch %q: not a single-line value of an item", path))
+	}
+	if string(src[pos.ValueOffset:pos.ValueEndOffset]) != s {
+		// the parser rewrote the line, e.g. by stripping bidi control characters
+		return nestext.Position{}, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			fmt.Sprintf("cannot patch %q: source of the value differs from the value", path))
+	}
+	return pos, nil
+}
+
+func ioError(err error) error {
+	return nestext.WrapError(nestext.ErrCodeIO, "I/O error while patching document", err)
+}
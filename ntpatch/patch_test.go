@@ -0,0 +1,86 @@
+package ntpatch
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+const document = "# server settings\nserver:\n  host: example.com\n  port: 8080\n  aliases:\n    -\n    - b\n# end\n"
+
+func patch(t *testing.T, path, value string, inPlace bool, expected string) {
+	filename := filepath.Join(t.TempDir(), "config.nt")
+	if err := ioutil.WriteFile(filename, []byte(document), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := PatchFile(filename, path, value)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	if ok != inPlace {
+		t.Errorf("%s: expected in-place flag %v, have %v", path, inPlace, ok)
+	}
+	src, _ := ioutil.ReadFile(filename)
+	if string(src) != expected {
+		t.Errorf("%s: expected\n%q\nhave\n%q", path, expected, src)
+	}
+}
+
+func TestPatchValue(t *testing.T) {
+	patch(t, "server.port", "8081", true,
+		"# server settings\nserver:\n  host: example.com\n  port: 8081\n  aliases:\n    -\n    - b\n# end\n")
+	patch(t, "server.host", "a.example.com", false,
+		"# server settings\nserver:\n  host: a.example.com\n  port: 8080\n  aliases:\n    -\n    - b\n# end\n")
+	patch(t, "server.host", "x", false,
+		"# server settings\nserver:\n  host: x\n  port: 8080\n  aliases:\n    -\n    - b\n# end\n")
+	patch(t, "server.aliases[0]", "a", false,
+		"# server settings\nserver:\n  host: example.com\n  port: 8080\n  aliases:\n    - a\n    - b\n# end\n")
+}
+
+func TestPatchValueErrors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.nt")
+	if err := ioutil.WriteFile(filename, []byte(document), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inputs := []struct {
+		path, value string
+		code        int
+	}{
+		{"server.port", "80\n81", nestext.ErrCodeUsage},
+		{"server.aliases", "x", nestext.ErrCodeSchema},
+		{"server.user", "x", nestext.ErrCodeSchema},
+		{"server.*", "x", nestext.ErrCodeUsage},
+	}
+	for _, input := range inputs {
+		_, err := PatchFile(filename, input.path, input.value)
+		if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != input.code {
+			t.Errorf("%s: expected error code %d, have %v", input.path, input.code, err)
+		}
+	}
+	if src, _ := ioutil.ReadFile(filename); string(src) != document {
+		t.Errorf("expected failed patches to leave document untouched")
+	}
+}
+
+func TestPatchValueRewrittenLine(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.nt")
+	doc := "a: x\u200ey\nb\u200e: z\n"
+	if err := ioutil.WriteFile(filename, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := PatchFile(filename, "a", "QQ")
+	if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != nestext.ErrCodeUsage {
+		t.Errorf("expected usage error for value containing a bidi control, have %v", err)
+	}
+	if src, _ := ioutil.ReadFile(filename); string(src) != doc {
+		t.Errorf("expected refused patch to leave document untouched, have %q", src)
+	}
+	if _, err = PatchFile(filename, "b", "QQ"); err != nil {
+		t.Fatal(err)
+	}
+	if src, _ := ioutil.ReadFile(filename); string(src) != "a: x\u200ey\nb\u200e: QQ\n" {
+		t.Errorf("expected value following a stripped key character to be patched, have %q", src)
+	}
+}
@@ -0,0 +1,194 @@
+// Package ntpath evaluates path expressions against the trees returned by
+// nestext.Parse, sparing applications chains of type assertions:
+//
+//     tree, err := nestext.Parse(reader)
+//     …
+//     street, err := ntpath.Get(tree, "president.address")
+//     ports, err := ntpath.Query(tree, "servers.*.port")
+//     ports, err := ntpath.Query(tree, "**.port")
+//
+// The syntax of path expressions is described for nestext.ParsePath. Dicts may be
+// of type map[string]interface{} or *nestext.OrderedMap, lists have to be of type
+// []interface{}.
+//
+package ntpath
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Paths -----------------------------------------------------------------
+
+// Path is a compiled path expression.
+type Path struct {
+	expr     string
+	segments []nestext.PathSegment
+}
+
+// Compile parses a path expression.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func Compile(expr string) (*Path, error) {
+	segments, err := nestext.ParsePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Path{expr: expr, segments: segments}, nil
+}
+
+// MustCompile is like Compile, but panics if the expression cannot be parsed.
+func MustCompile(expr string) *Path {
+	p, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *Path) String() string {
+	return p.expr
+}
+
+// --- Queries ---------------------------------------------------------------
+
+// Match is a value found by a query.
+type Match struct {
+	Path     string            // path expression of the value, without wildcards or globs
+	Value    interface{}       // the value
+	Position *nestext.Position // source position of the value, if known (see Locate)
+}
+
+// Find returns all values of `tree` matched by p. Items of lists are visited in
+// order, items of dicts ordered by key, except for ordered maps. Segments "**" match
+// a value before the items within it.
+func (p *Path) Find(tree interface{}) []Match {
+	var matches []Match
+	find(tree, p.segments, nil, &matches)
+	return matches
+}
+
+// Locate is like Find, but sets the source positions of the matches from `pos`, as
+// reported by a parse with option nestext.ReportPositions. This allows tools to
+// annotate the source or jump to the matched items:
+//
+//     var pos nestext.Positions
+//     tree, err := nestext.Parse(reader, nestext.ReportPositions(&pos))
+//     …
+//     for _, m := range ntpath.MustCompile("**.port").Locate(tree, pos) {
+//         fmt.Printf("config.nt:%d:%d: %v\n", m.Position.Line, m.Position.Column, m.Value)
+//     }
+//
+// Matches without an entry in `pos` have a nil position.
+func (p *Path) Locate(tree interface{}, pos nestext.Positions) []Match {
+	matches := p.Find(tree)
+	for i, m := range matches {
+		ptr := ""
+		if m.Path != "" {
+			ptr, _ = nestext.PathToPointer(m.Path) // paths of matches are always valid
+		}
+		if position, ok := pos[ptr]; ok {
+			matches[i].Position = &position
+		}
+	}
+	return matches
+}
+
+// Get returns the first value of `tree` matched by p. If there is none, an error of
+// code ErrCodeSchema is returned.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (p *Path) Get(tree interface{}) (interface{}, error) {
+	if matches := p.Find(tree); len(matches) > 0 {
+		return matches[0].Value, nil
+	}
+	return nil, nestext.MakeNestedTextError(nestext.ErrCodeSchema, "no value at path "+strconv.Quote(p.expr))
+}
+
+// Query returns all values of `tree` matched by path expression `expr`.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func Query(tree interface{}, expr string) ([]Match, error) {
+	p, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(tree), nil
+}
+
+// QueryPositions returns all values of `tree` matched by path expression `expr`,
+// together with their source positions from `pos` (see Path.Locate).
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func QueryPositions(tree interface{}, pos nestext.Positions, expr string) ([]Match, error) {
+	p, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Locate(tree, pos), nil
+}
+
+// Get returns the first value of `tree` matched by path expression `expr`. If there
+// is none, an error of code ErrCodeSchema is returned.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func Get(tree interface{}, expr string) (interface{}, error) {
+	p, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Get(tree)
+}
+
+// find collects the matches of `segments` in `tree`, which has been reached by the
+// segments in `trail`.
+func find(tree interface{}, segments []nestext.PathSegment, trail []nestext.PathSegment, matches *[]Match) {
+	if len(segments) == 0 {
+		*matches = append(*matches, Match{Path: nestext.JoinPath(trail), Value: tree})
+		return
+	}
+	seg, rest := segments[0], segments[1:]
+	if seg.Recursive {
+		for len(rest) > 0 && rest[0].Recursive { // "**.**" is the same as "**"
+			rest = rest[1:]
+		}
+		find(tree, rest, trail, matches)
+		rest = segments[len(segments)-len(rest)-1:] // items within continue to match "**"
+	}
+	switch t := tree.(type) {
+	case []interface{}:
+		for i, item := range t {
+			if seg.Wildcard || seg.Recursive || seg.IsIndex && seg.Index == i {
+				find(item, rest, appendSegment(trail, nestext.PathSegment{Index: i, IsIndex: true}), matches)
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		findInDict(keys, t, seg, rest, trail, matches)
+	case *nestext.OrderedMap:
+		findInDict(t.Keys(), t.Map(), seg, rest, trail, matches)
+	}
+}
+
+func findInDict(keys []string, dict map[string]interface{}, seg nestext.PathSegment,
+	rest []nestext.PathSegment, trail []nestext.PathSegment, matches *[]Match) {
+	//
+	for _, k := range keys {
+		if seg.Recursive || seg.MatchKey(k) {
+			find(dict[k], rest, appendSegment(trail, nestext.PathSegment{Key: k}), matches)
+		}
+	}
+}
+
+// appendSegment appends to a copy of `trail`, as trails are shared between branches.
+func appendSegment(trail []nestext.PathSegment, seg nestext.PathSegment) []nestext.PathSegment {
+	t := make([]nestext.PathSegment, len(trail), len(trail)+1)
+	copy(t, trail)
+	return append(t, seg)
+}
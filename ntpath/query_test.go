@@ -0,0 +1,102 @@
+package ntpath
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+const queryInput = `president:
+  name: Katheryn McDaniel
+  address:
+    > 138 Almond Street
+    > Topeka, Kansas 20697
+  roles:
+    - board member
+    - treasurer
+servers:
+  beta:
+    port: 8081
+  alpha:
+    port: 8080
+    host: a.example.com
+`
+
+func parse(t *testing.T, opts ...nestext.Option) interface{} {
+	tree, err := nestext.Parse(strings.NewReader(queryInput), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestGet(t *testing.T) {
+	tree := parse(t)
+	inputs := map[string]interface{}{
+		"president.address":  "138 Almond Street\nTopeka, Kansas 20697",
+		"president.roles[1]": "treasurer",
+		"servers.*.port":     "8080",
+	}
+	for expr, expected := range inputs {
+		v, err := Get(tree, expr)
+		if err != nil || v != expected {
+			t.Errorf("%s: expected %q, have %q, %v", expr, expected, v, err)
+		}
+	}
+	if v, err := Get(tree, ""); err != nil || !reflect.DeepEqual(v, tree) {
+		t.Errorf("expected empty path to address the document")
+	}
+	for _, expr := range []string{"president.phone", "president.roles[2]", "president.name.x", "president[0]"} {
+		if _, err := Get(tree, expr); err == nil || err.(nestext.NestedTextError).Code != nestext.ErrCodeSchema {
+			t.Errorf("%s: expected no value, have %v", expr, err)
+		}
+	}
+	if _, err := Get(tree, "a..b"); err == nil || err.(nestext.NestedTextError).Code != nestext.ErrCodeUsage {
+		t.Errorf("expected invalid path to be rejected, have %v", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	expected := []Match{{Path: "servers.beta.port", Value: "8081"}, {Path: "servers.alpha.port", Value: "8080"}}
+	matches, err := Query(parse(t, nestext.OrderedDicts()), "servers.*.port")
+	if err != nil || !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %v, have %v, %v", expected, matches, err)
+	}
+	matches, err = Query(parse(t, nestext.OrderedDicts()), "**.port")
+	if err != nil || !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %v, have %v, %v", expected, matches, err)
+	}
+	matches = MustCompile("s*.al*").Find(parse(t))
+	if len(matches) != 1 || matches[0].Path != "servers.alpha" {
+		t.Errorf("expected glob to match servers.alpha, have %v", matches)
+	}
+	matches = MustCompile("president.**").Find(parse(t))
+	if len(matches) != 6 || matches[0].Path != "president" || matches[4].Path != "president.roles[0]" {
+		t.Errorf("expected recursive match of president and its 5 descendants, have %v", matches)
+	}
+	matches = MustCompile("president.roles[*]").Find(parse(t))
+	expected = []Match{{Path: "president.roles[0]", Value: "board member"}, {Path: "president.roles[1]", Value: "treasurer"}}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %v, have %v", expected, matches)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	var pos nestext.Positions
+	tree := parse(t, nestext.ReportPositions(&pos))
+	matches, err := QueryPositions(tree, pos, "**.port")
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("expected two matches, have %v, %v", matches, err)
+	}
+	if p := matches[0].Position; matches[0].Path != "servers.alpha.port" || p == nil || p.Line != 13 || p.Column != 4 {
+		t.Errorf("expected servers.alpha.port at 13:4, have %v at %v", matches[0].Path, p)
+	}
+	if m := MustCompile("").Locate(tree, pos); len(m) != 1 || m[0].Position == nil || m[0].Position.Line != 1 {
+		t.Errorf("expected top-level value to be located, have %v", m)
+	}
+	if m := MustCompile("president.name").Locate(tree, nil); len(m) != 1 || m[0].Position != nil {
+		t.Errorf("expected missing position to be nil, have %v", m)
+	}
+}
@@ -0,0 +1,341 @@
+// Package ntrefactor provides refactorings of NestedText documents, like renaming
+// keys and moving items. They operate on lossless document trees of package ntast,
+// thus comments, blank lines and the layout of all other items are preserved.
+//
+// Applications may script migrations of their configuration files between releases:
+//
+//     doc, err := ntast.Parse(reader)
+//     …
+//     err = ntrefactor.RenameKey(doc, "servers.*.addr", "address")
+//     err = ntrefactor.MoveSubtree(doc, "db_host", "database.host")
+//     err = ntrefactor.ConvertValue(doc, "timeout", addUnit)
+//     doc.WriteTo(w)
+//
+// Paths are path expressions as described for nestext.ParsePath.
+//
+package ntrefactor
+
+import (
+	"fmt"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntast"
+)
+
+// --- Refactorings ----------------------------------------------------------
+
+// RenameKey changes the key of the dict item at path expression `path` to `key`.
+// Paths may contain wildcards and globs, to rename the keys of all matching items.
+// The lines of the items' keys are re-created, while their values keep their source
+// lines, including comments.
+//
+// It is an error if no item matches `path`, or if a dict already contains an item
+// with key `key`; the document is not modified in this case.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func RenameKey(doc *ntast.Document, path string, key string) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	matches := find(doc, segments)
+	if len(matches) == 0 {
+		return notFound(path)
+	}
+	for _, m := range matches {
+		if m.item.Type != ntast.DictItemNode {
+			return usageError(fmt.Sprintf("path %q: cannot rename list item", path))
+		}
+		if other := m.parent.Lookup(key); other != nil && other != m.item {
+			return usageError(fmt.Sprintf("path %q: duplicate key %q", path, key))
+		}
+	}
+	for _, m := range matches {
+		if m.item.Key == key {
+			continue
+		}
+		if err = m.item.SetKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MoveSubtree moves the dict item at path expression `from` to path `to`, i.e. it
+// becomes an item of the dict containing `to`, with the last segment of `to` as its
+// key. The item is appended to this dict, together with the comments directly
+// preceding it, and its lines are re-indented to the indentation of the dict.
+//
+//     err := ntrefactor.MoveSubtree(doc, "logging.file", "output.log-file")
+//
+// The dict containing `to` has to exist, and must not contain an item with the key
+// already. Paths may not contain wildcards or globs.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func MoveSubtree(doc *ntast.Document, from, to string) error {
+	src, err := parsePath(from)
+	if err != nil {
+		return err
+	}
+	dst, err := parsePath(to)
+	if err != nil {
+		return err
+	}
+	if isPattern(src) || isPattern(dst) {
+		return usageError("cannot move items for paths with wildcards")
+	}
+	last := dst[len(dst)-1]
+	if last.IsIndex {
+		return usageError(fmt.Sprintf("path %q: target has to be a dict item", to))
+	}
+	matches := find(doc, src)
+	if len(matches) == 0 {
+		return notFound(from)
+	}
+	m := matches[0]
+	if m.item.Type != ntast.DictItemNode {
+		return usageError(fmt.Sprintf("path %q: cannot move list item", from))
+	}
+	target, err := dictAt(doc, dst[:len(dst)-1], to)
+	if err != nil {
+		return err
+	}
+	if contains(m.item, target) {
+		return usageError(fmt.Sprintf("cannot move %q into itself", from))
+	}
+	if other := target.Lookup(last.Key); other != nil && other != m.item {
+		return usageError(fmt.Sprintf("path %q: duplicate key %q", to, last.Key))
+	}
+	nodes := detach(doc, m.parent, m.item)
+	if m.item.Key != last.Key {
+		if err = m.item.SetKey(last.Key); err != nil {
+			return err
+		}
+	}
+	for _, n := range nodes {
+		n.Reindent(target.Indent)
+	}
+	appendNodes(target, nodes)
+	return nil
+}
+
+// ConvertValue replaces the values of the items at path expression `path` with the
+// result of calling `convert` for their current values, e.g. to convert units or to
+// split a string into a list. Values are passed as nestext.Parse would return them,
+// results may be any tree ntenc.Encode is able to encode. Paths may contain wildcards
+// and globs. Items for which `convert` returns an equal value (see nestext.Equal)
+// keep their source lines.
+//
+//     err := ntrefactor.ConvertValue(doc, "timeout", func(v interface{}) (interface{}, error) {
+//         return v.(string) + "s", nil
+//     })
+//
+// It is an error if no item matches `path`. An error returned by `convert` stops the
+// conversion and is wrapped into an error of code ErrCodeSchema.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func ConvertValue(doc *ntast.Document, path string, convert func(value interface{}) (interface{}, error)) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	matches := find(doc, segments)
+	if len(matches) == 0 {
+		return notFound(path)
+	}
+	for _, m := range matches {
+		value, err := m.item.Tree()
+		if err != nil {
+			return err
+		}
+		converted, err := convert(value)
+		if err != nil {
+			return nestext.WrapError(nestext.ErrCodeSchema,
+				fmt.Sprintf("path %q: cannot convert value: %s", path, err.Error()), err)
+		}
+		if nestext.Equal(value, converted) {
+			continue
+		}
+		if err = m.item.SetValue(converted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Helpers ---------------------------------------------------------------
+
+// match is an item of a document matching a path, together with its list or dict.
+type match struct {
+	parent *ntast.Node
+	item   *ntast.Node
+}
+
+// find returns the items of doc matching the path `segments`.
+func find(doc *ntast.Document, segments []nestext.PathSegment) []match {
+	containers := []*ntast.Node{doc.Root()}
+	var matches []match
+	for i, seg := range segments {
+		matches = matches[:0]
+		for _, c := range containers {
+			if c == nil {
+				continue
+			}
+			for index, item := range c.Items() {
+				if selects(seg, item, index) {
+					matches = append(matches, match{parent: c, item: item})
+				}
+			}
+		}
+		if i < len(segments)-1 {
+			containers = containers[:0]
+			for _, m := range matches {
+				containers = append(containers, m.item.Nested())
+			}
+		}
+	}
+	return matches
+}
+
+// selects checks if path segment `seg` selects `item`, the item at position `index`
+// of its list or dict.
+func selects(seg nestext.PathSegment, item *ntast.Node, index int) bool {
+	if item.Type == ntast.ListItemNode {
+		return seg.Wildcard || seg.IsIndex && seg.Index == index
+	}
+	return seg.MatchKey(item.Key)
+}
+
+// dictAt returns the dict at the path `segments`, part of path expression `path`.
+func dictAt(doc *ntast.Document, segments []nestext.PathSegment, path string) (*ntast.Node, error) {
+	dict := doc.Root()
+	if len(segments) > 0 {
+		matches := find(doc, segments)
+		if len(matches) == 0 {
+			return nil, notFound(nestext.JoinPath(segments))
+		}
+		dict = matches[0].item.Nested()
+	}
+	if dict == nil || dict.Type != ntast.DictNode {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+			fmt.Sprintf("path %q: item is not contained in a dict", path))
+	}
+	return dict, nil
+}
+
+// contains checks if node n is part of the subtree of `root`.
+func contains(root, n *ntast.Node) bool {
+	if root == n {
+		return true
+	}
+	for _, c := range root.Children {
+		if contains(c, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// detach removes `item` from list or dict `parent`, together with the comments
+// directly preceding it, and returns the removed nodes. Comments preceding the first
+// item of a nested list or dict belong to the item holding it.
+func detach(doc *ntast.Document, parent, item *ntast.Node) []*ntast.Node {
+	end := 0
+	for parent.Children[end] != item {
+		end++
+	}
+	start := end
+	for start > 0 && parent.Children[start-1].Type == ntast.CommentNode {
+		start--
+	}
+	var nodes []*ntast.Node
+	if owner := ownerOf(doc.Nodes, parent); start == 0 && owner != nil {
+		at := len(owner.Children) - 1 // nested value is the last child
+		first := at
+		for first > 0 && owner.Children[first-1].Type == ntast.CommentNode {
+			first--
+		}
+		nodes = append(nodes, owner.Children[first:at]...)
+		owner.Children = append(owner.Children[:first], owner.Children[at:]...)
+	}
+	nodes = append(nodes, parent.Children[start:end+1]...)
+	parent.Children = append(parent.Children[:start:start], parent.Children[end+1:]...)
+	if l := lastLine(item); l != nil && l.EOL == "" && start > 0 && start == len(parent.Children) {
+		prev := lastLine(parent.Children[start-1])
+		l.EOL, prev.EOL = prev.EOL, ""
+	}
+	return nodes
+}
+
+// ownerOf returns the list item or dict item holding `container` as its nested value,
+// searching `nodes` and their subtrees.
+func ownerOf(nodes []*ntast.Node, container *ntast.Node) *ntast.Node {
+	for _, n := range nodes {
+		if len(n.Children) > 0 && n.Children[len(n.Children)-1] == container &&
+			(n.Type == ntast.ListItemNode || n.Type == ntast.DictItemNode) {
+			return n
+		}
+		if owner := ownerOf(n.Children, container); owner != nil {
+			return owner
+		}
+	}
+	return nil
+}
+
+// appendNodes appends nodes to list or dict `parent`. If the parent ends the document
+// without a final line terminator, the last node takes over this property.
+func appendNodes(parent *ntast.Node, nodes []*ntast.Node) {
+	if len(parent.Children) > 0 {
+		prev := lastLine(parent.Children[len(parent.Children)-1])
+		if l := lastLine(nodes[len(nodes)-1]); prev != nil && l != nil && prev.EOL == "" {
+			prev.EOL, l.EOL = l.EOL, ""
+		}
+	}
+	parent.Children = append(parent.Children, nodes...)
+}
+
+// lastLine returns the last source line of the subtree of n.
+func lastLine(n *ntast.Node) *ntast.SourceLine {
+	for i := len(n.Children) - 1; i >= 0; i-- {
+		if l := lastLine(n.Children[i]); l != nil {
+			return l
+		}
+	}
+	if len(n.Lines) == 0 {
+		return nil
+	}
+	return &n.Lines[len(n.Lines)-1]
+}
+
+func parsePath(path string) ([]nestext.PathSegment, error) {
+	segments, err := nestext.ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, usageError("path must address an item")
+	}
+	for _, seg := range segments {
+		if seg.Recursive {
+			return nil, usageError(fmt.Sprintf("path %q: recursive segments not allowed", path))
+		}
+	}
+	return segments, nil
+}
+
+func isPattern(segments []nestext.PathSegment) bool {
+	for _, seg := range segments {
+		if seg.IsPattern() {
+			return true
+		}
+	}
+	return false
+}
+
+func notFound(path string) error {
+	return nestext.MakeNestedTextError(nestext.ErrCodeSchema, fmt.Sprintf("path %q: no such item", path))
+}
+
+func usageError(msg string) error {
+	return nestext.MakeNestedTextError(nestext.ErrCodeUsage, msg)
+}
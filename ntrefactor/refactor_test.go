@@ -0,0 +1,145 @@
+package ntrefactor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntast"
+)
+
+func TestRenameKey(t *testing.T) {
+	doc := mustParse(t, "# servers\nservers:\n  -\n    addr: a.example.com\n    port: 80\n  -\n    # backup\n    addr:\n      > b.example.com\n")
+	if err := RenameKey(doc, "servers.*.addr", "address"); err != nil {
+		t.Fatal(err)
+	}
+	expectSource(t, doc, "# servers\nservers:\n  -\n    address: a.example.com\n    port: 80\n  -\n    # backup\n    address:\n      > b.example.com\n")
+	if err := RenameKey(doc, "servers", "hosts: all"); err != nil {
+		t.Fatal(err)
+	}
+	expectSource(t, doc, "# servers\n: hosts: all\n  -\n    address: a.example.com\n    port: 80\n  -\n    # backup\n    address:\n      > b.example.com\n")
+}
+
+func TestRenameKeyErrors(t *testing.T) {
+	input := "a: 1\nb: 2\nl:\n  - x\n"
+	doc := mustParse(t, input)
+	for _, c := range []struct {
+		path, key string
+		code      int
+	}{
+		{"c", "d", nestext.ErrCodeSchema},
+		{"a", "b", nestext.ErrCodeUsage},
+		{"l[0]", "y", nestext.ErrCodeUsage},
+		{"", "y", nestext.ErrCodeUsage},
+		{"**.a", "y", nestext.ErrCodeUsage},
+	} {
+		err := RenameKey(doc, c.path, c.key)
+		if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != c.code {
+			t.Errorf("rename %q: expected error of code %d, have %v", c.path, c.code, err)
+		}
+	}
+	expectSource(t, doc, input)
+}
+
+func TestMoveSubtree(t *testing.T) {
+	doc := mustParse(t, "db_host: localhost\n# the port\ndb_port: 5432\n\ndatabase:\n    name: app\nlogging:\n  # verbose\n  level:\n    - debug\n")
+	if err := MoveSubtree(doc, "db_host", "database.host"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MoveSubtree(doc, "db_port", "database.port"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MoveSubtree(doc, "logging.level", "log-level"); err != nil {
+		t.Fatal(err)
+	}
+	expectSource(t, doc, "\ndatabase:\n    name: app\n    host: localhost\n    # the port\n    port: 5432\nlogging:\n# verbose\nlog-level:\n  - debug\n")
+}
+
+func TestMoveSubtreeFinalLine(t *testing.T) {
+	doc := mustParse(t, "a:\n  x: 1\nb: 2")
+	if err := MoveSubtree(doc, "b", "a.b"); err != nil {
+		t.Fatal(err)
+	}
+	expectSource(t, doc, "a:\n  x: 1\n  b: 2")
+}
+
+func TestMoveSubtreeErrors(t *testing.T) {
+	input := "a:\n  x: 1\nb: 2\nl:\n  - y\n"
+	doc := mustParse(t, input)
+	for _, c := range []struct {
+		from, to string
+		code     int
+	}{
+		{"c", "a.c", nestext.ErrCodeSchema},
+		{"b", "c.b", nestext.ErrCodeSchema},
+		{"b", "l.b", nestext.ErrCodeSchema},
+		{"b", "a.x", nestext.ErrCodeUsage},
+		{"a", "a.x.a", nestext.ErrCodeSchema},
+		{"a", "a.z", nestext.ErrCodeUsage},
+		{"l[0]", "y", nestext.ErrCodeUsage},
+		{"*", "a.y", nestext.ErrCodeUsage},
+		{"b", "l[1]", nestext.ErrCodeUsage},
+	} {
+		err := MoveSubtree(doc, c.from, c.to)
+		if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != c.code {
+			t.Errorf("move %q to %q: expected error of code %d, have %v", c.from, c.to, c.code, err)
+		}
+	}
+	expectSource(t, doc, input)
+}
+
+func TestConvertValue(t *testing.T) {
+	doc := mustParse(t, "# timeouts\nconnect: 10\nread: 10s\nhosts: a,b\n")
+	seconds := func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("not a string")
+		}
+		return strings.TrimSuffix(s, "s") + "s", nil
+	}
+	if err := ConvertValue(doc, "missing", seconds); err == nil {
+		t.Error("expected missing item to be reported; wasn't")
+	}
+	for _, path := range []string{"connect", "read"} {
+		if err := ConvertValue(doc, path, seconds); err != nil {
+			t.Fatal(err)
+		}
+	}
+	split := func(v interface{}) (interface{}, error) {
+		var list []interface{}
+		for _, s := range strings.Split(v.(string), ",") {
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	if err := ConvertValue(doc, "hosts", split); err != nil {
+		t.Fatal(err)
+	}
+	expectSource(t, doc, "# timeouts\nconnect: 10s\nread: 10s\nhosts:\n  - a\n  - b\n")
+	err := ConvertValue(doc, "hosts", seconds)
+	if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != nestext.ErrCodeSchema {
+		t.Errorf("expected conversion error, have %v", err)
+	}
+}
+
+// ----------------------------------------------------------------------
+
+func mustParse(t *testing.T, input string) *ntast.Document {
+	doc, err := ntast.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// expectSource checks the source of doc and its validity.
+func expectSource(t *testing.T, doc *ntast.Document, expected string) {
+	t.Helper()
+	if out := string(doc.Bytes()); out != expected {
+		t.Errorf("expected document\n%q\nhave\n%q", expected, out)
+	}
+	if _, err := ntast.Parse(strings.NewReader(expected)); err != nil {
+		t.Errorf("expected valid document, have %v", err)
+	}
+}
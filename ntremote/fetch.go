@@ -0,0 +1,154 @@
+// Package ntremote fetches NestedText documents over HTTP, for services pulling shared
+// configuration from a configuration server.
+//
+// Documents are validated by parsing them before they are handed out or cached.
+// With a cache, repeated fetches are conditional requests (If-None-Match,
+// If-Modified-Since); if the server reports the document as unchanged, the cached
+// copy is used. Subscribe builds on this to deliver new versions of a document as
+// they appear, by polling or long polling; SubscribeEvents receives them as
+// server-sent events. Resolver retrieves documents included by configurations (see
+// nestext.ResolveIncludes).
+//
+// Use as:
+//     cache := ntremote.NewMemoryCache()
+//     …
+//     config, err := ntremote.Fetch(ctx, "https://config.example.com/app.nt", cache)
+//
+package ntremote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Caching ---------------------------------------------------------------
+
+// Entry is a validated document, as stored in a cache.
+type Entry struct {
+	ETag         string // value of the ETag header of the response, if any
+	LastModified string // value of the Last-Modified header of the response, if any
+	Body         []byte // source of the document
+}
+
+// Cache stores documents by URL. Implementations have to be safe for concurrent use.
+type Cache interface {
+	Get(url string) (Entry, bool)
+	Put(url string, e Entry)
+}
+
+// MemoryCache is a Cache holding documents in memory.
+type MemoryCache struct {
+	mx      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryCache creates an empty in-memory cache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]Entry)}
+}
+
+// Get returns the entry for `url` and a flag signalling if it is present.
+func (c *MemoryCache) Get(url string) (Entry, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	e, ok := c.entries[url]
+	return e, ok
+}
+
+// Put stores the entry for `url`.
+func (c *MemoryCache) Put(url string, e Entry) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.entries[url] = e
+}
+
+// --- Fetching --------------------------------------------------------------
+
+// Client is the HTTP client used by Fetch. It may be replaced, e.g. to configure
+// timeouts or transports.
+var Client = http.DefaultClient
+
+// Fetch retrieves the document at `url` and parses it with options `opts`. If `cache`
+// is non-nil, the request is conditional on the cached copy of the document, which is
+// parsed if the server responds with status 304 (not modified). Documents are cached
+// only if they are valid NestedText. Every call returns a tree of its own, thus
+// clients are free to modify it.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError. Failed
+// requests and HTTP status codes other than 200 and 304 are reported with code
+// ErrCodeIO.
+func Fetch(ctx context.Context, url string, cache Cache, opts ...nestext.Option) (interface{}, error) {
+	tree, _, err := fetch(ctx, url, cache, nil, opts)
+	return tree, err
+}
+
+// fetch implements Fetch, sending additional request headers `header`. It
+// additionally reports if the document has been transferred, as opposed to taken
+// from the cache.
+func fetch(ctx context.Context, url string, cache Cache, header http.Header, opts []nestext.Option) (interface{}, bool, error) {
+	e, transferred, err := download(ctx, url, cache, header)
+	if err != nil {
+		return nil, false, err
+	}
+	tree, err := nestext.Parse(bytes.NewReader(e.Body), opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	if transferred && cache != nil {
+		cache.Put(url, e)
+	}
+	return tree, transferred, nil
+}
+
+// download retrieves the document at `url`, conditional on the copy in `cache`, if
+// any, sending additional request headers `header`. It reports if the document has been transferred, as opposed to taken from the
+// cache. Transferred documents are not yet stored in the cache, as they have not been
+// validated.
+func download(ctx context.Context, url string, cache Cache, header http.Header) (Entry, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Entry{}, false, nestext.WrapError(nestext.ErrCodeUsage, "invalid request: "+err.Error(), err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	var cached Entry
+	var hasCached bool
+	if cache != nil {
+		if cached, hasCached = cache.Get(url); hasCached {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return Entry{}, false, nestext.WrapError(nestext.ErrCodeIO, "cannot fetch "+url, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCached:
+		return cached, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return Entry{}, false, nestext.MakeNestedTextError(nestext.ErrCodeIO,
+			fmt.Sprintf("cannot fetch %s: %s", url, resp.Status))
+	}
+	body, err := ioutil.ReadAll(nestext.ContextReader(ctx, resp.Body))
+	if err != nil {
+		return Entry{}, false, nestext.WrapError(nestext.ErrCodeIO, "cannot fetch "+url, err)
+	}
+	return Entry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         body,
+	}, true, nil
+}
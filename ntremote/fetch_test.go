@@ -0,0 +1,56 @@
+package ntremote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	requests, fetched := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/invalid.nt" {
+			w.Write([]byte("  a: 1\n"))
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetched++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("server:\n  port: 8080\n"))
+	}))
+	defer srv.Close()
+	cache := NewMemoryCache()
+	expected := map[string]interface{}{"server": map[string]interface{}{"port": "8080"}}
+	for i := 0; i < 2; i++ {
+		tree, err := Fetch(context.Background(), srv.URL+"/app.nt", cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tree, expected) {
+			t.Errorf("expected %v, have %v", expected, tree)
+		}
+	}
+	if requests != 2 || fetched != 1 {
+		t.Errorf("expected 2 requests and 1 transfer, have %d, %d", requests, fetched)
+	}
+	if _, err := Fetch(context.Background(), srv.URL+"/invalid.nt", cache); err == nil {
+		t.Error("expected invalid document to be rejected")
+	}
+	if _, ok := cache.Get(srv.URL + "/invalid.nt"); ok {
+		t.Error("expected invalid document not to be cached")
+	}
+}
+
+func TestFetchStatus(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	if _, err := Fetch(context.Background(), srv.URL, nil); err == nil {
+		t.Error("expected status 404 to be reported")
+	}
+}
@@ -0,0 +1,71 @@
+package ntremote
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"time"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Include resolver ------------------------------------------------------
+
+// Resolver is a nestext.Resolver retrieving included documents over HTTP, allowing
+// configurations to reference fragments held by a configuration server:
+//
+//     r := ntremote.Resolver{Base: "https://config.example.com/", Cache: cache, Timeout: 5 * time.Second}
+//     err := nestext.ResolveIncludes(config, r)
+//
+// References are URLs, which may be relative to Base.
+type Resolver struct {
+	Base    string        // URL relative references are resolved against, may be empty
+	Cache   Cache         // if non-nil, requests are conditional on cached copies
+	Timeout time.Duration // limit for each request, 0 for no limit
+}
+
+// Resolve retrieves the document referenced by `ref`. As with Fetch, documents are
+// cached only if they are valid NestedText.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (r Resolver) Resolve(ref string) (io.ReadCloser, error) {
+	u, err := r.resolveURL(ref)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	e, transferred, err := download(ctx, u, r.Cache, nil)
+	if err != nil {
+		return nil, err
+	}
+	if transferred && r.Cache != nil {
+		if _, err := nestext.Parse(bytes.NewReader(e.Body)); err != nil {
+			return nil, err
+		}
+		r.Cache.Put(u, e)
+	}
+	return ioutil.NopCloser(bytes.NewReader(e.Body)), nil
+}
+
+// resolveURL resolves reference `ref` against the base URL.
+func (r Resolver) resolveURL(ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", nestext.WrapError(nestext.ErrCodeUsage, "invalid reference: "+err.Error(), err)
+	}
+	if r.Base == "" {
+		return u.String(), nil
+	}
+	base, err := url.Parse(r.Base)
+	if err != nil {
+		return "", nestext.WrapError(nestext.ErrCodeUsage, "invalid base URL: "+err.Error(), err)
+	}
+	return base.ResolveReference(u).String(), nil
+}
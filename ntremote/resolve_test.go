@@ -0,0 +1,46 @@
+package ntremote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/npillmayer/nestext"
+)
+
+func TestResolver(t *testing.T) {
+	fetched := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow.nt":
+			time.Sleep(50 * time.Millisecond)
+		case "/conf/db.nt":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			fetched++
+			w.Header().Set("ETag", `"v1"`)
+		}
+		w.Write([]byte("host: db\n"))
+	}))
+	defer srv.Close()
+	r := Resolver{Base: srv.URL + "/conf/app.nt", Cache: NewMemoryCache(), Timeout: 10 * time.Millisecond}
+	for i := 0; i < 2; i++ {
+		tree := map[string]interface{}{nestext.IncludeKey: "db.nt"}
+		if err := nestext.ResolveIncludes(tree, r); err != nil {
+			t.Fatal(err)
+		}
+		if tree["host"] != "db" {
+			t.Errorf("expected host = db, have %v", tree["host"])
+		}
+	}
+	if fetched != 1 {
+		t.Errorf("expected 1 transfer, have %d", fetched)
+	}
+	tree := map[string]interface{}{nestext.IncludeKey: "/slow.nt"}
+	if err := nestext.ResolveIncludes(tree, r); err == nil {
+		t.Error("expected request exceeding the timeout to fail")
+	}
+}
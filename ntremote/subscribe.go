@@ -0,0 +1,231 @@
+package ntremote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Subscriptions ---------------------------------------------------------
+
+// Update is a change of a subscribed document, or an error fetching it. Changes are
+// computed with nestext.Diff, thus their items are normalized; for the first version
+// received, Changes holds a single difference for the top-level value. Versions which
+// differ from their predecessor in layout only are not reported.
+type Update struct {
+	Tree    interface{}          // the new version of the document, if Err is nil
+	Changes []nestext.Difference // items changed with respect to the previous version
+	Err     error                // error of type nestext.NestedTextError, if fetching failed
+}
+
+// Subscribe fetches the document at `url` repeatedly and sends an Update whenever its
+// content changes, starting with the current version. Requests are conditional on the
+// last version received and are started every `interval`. Requests carry a header
+// "Prefer: wait=<seconds>" (RFC 7240), asking servers supporting long polling to hold
+// them until the document changes or `interval` has passed; the next request is
+// started as soon as a held request returns. Failed requests are reported as updates
+// as well, and are retried after `interval`.
+//
+// The channel is closed after ctx has been canceled. Clients have to receive updates
+// promptly, as no further requests are made while an update is pending.
+//
+// Use as:
+//     for u := range ntremote.Subscribe(ctx, "https://config.example.com/app.nt", time.Minute) {
+//         if u.Err == nil {
+//             apply(u.Tree, u.Changes)
+//         }
+//     }
+//
+func Subscribe(ctx context.Context, url string, interval time.Duration, opts ...nestext.Option) <-chan Update {
+	updates := make(chan Update)
+	go func() {
+		defer close(updates)
+		s := subscriber{ctx: ctx, updates: updates}
+		cache := NewMemoryCache()
+		var header http.Header
+		if wait := int(interval / time.Second); wait > 0 {
+			header = http.Header{"Prefer": {"wait=" + strconv.Itoa(wait)}}
+		}
+		for {
+			start := time.Now()
+			tree, transferred, err := fetch(ctx, url, cache, header, opts)
+			if ctx.Err() != nil {
+				return
+			}
+			delay := interval - time.Since(start)
+			ok := true
+			if err != nil {
+				delay = interval
+				ok = s.send(Update{Err: err})
+			} else if transferred {
+				ok = s.change(tree)
+			}
+			if !ok || !s.sleep(delay) {
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+// SubscribeEvents subscribes to the document at `url` as a stream of server-sent
+// events, and sends an Update whenever its content changes. The data of every event
+// is a complete version of the document; the server is expected to send the current
+// version first. Events which are not valid NestedText are reported as updates with
+// an error, as are failed connections.
+//
+// If the connection fails or is closed by the server, SubscribeEvents reconnects after
+// `retry`, or after the reconnection time set by the server, sending the ID of the
+// last event received in header Last-Event-ID.
+//
+// The channel is closed after ctx has been canceled. Clients have to receive updates
+// promptly, as no further events are read while an update is pending.
+//
+// Use as:
+//     for u := range ntremote.SubscribeEvents(ctx, "https://config.example.com/app.nt/events", 5*time.Second) {
+//         if u.Err == nil {
+//             apply(u.Tree, u.Changes)
+//         }
+//     }
+//
+func SubscribeEvents(ctx context.Context, url string, retry time.Duration, opts ...nestext.Option) <-chan Update {
+	updates := make(chan Update)
+	go func() {
+		defer close(updates)
+		s := subscriber{ctx: ctx, updates: updates, retry: retry}
+		for {
+			err := s.stream(url, opts)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && !s.send(Update{Err: err}) {
+				return
+			}
+			if !s.sleep(s.retry) {
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+// subscriber tracks the versions of a subscribed document.
+type subscriber struct {
+	ctx      context.Context
+	updates  chan<- Update
+	last     interface{}   // normalized last version received
+	received bool          // has a version been received?
+	lastID   string        // ID of the last server-sent event
+	retry    time.Duration // reconnection time for event streams
+}
+
+// change sends an update for version `tree`, if it differs from the last version. It
+// returns false if ctx has been canceled.
+func (s *subscriber) change(tree interface{}) bool {
+	changes := nestext.Diff(s.last, tree)
+	if s.received && len(changes) == 0 {
+		return true
+	}
+	s.last, s.received = nestext.Normalize(tree), true
+	return s.send(Update{Tree: tree, Changes: changes})
+}
+
+// send sends update `u`. It returns false if ctx has been canceled.
+func (s *subscriber) send(u Update) bool {
+	select {
+	case s.updates <- u:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// sleep waits for duration `d`. It returns false if ctx has been canceled.
+func (s *subscriber) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return s.ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// stream connects to the event stream at `url` and processes its events until the
+// connection is closed. Closing by the server is not an error.
+func (s *subscriber) stream(url string, opts []nestext.Option) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nestext.WrapError(nestext.ErrCodeUsage, "invalid request: "+err.Error(), err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastID != "" {
+		req.Header.Set("Last-Event-ID", s.lastID)
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return nestext.WrapError(nestext.ErrCodeIO, "cannot subscribe to "+url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nestext.MakeNestedTextError(nestext.ErrCodeIO,
+			fmt.Sprintf("cannot subscribe to %s: %s", url, resp.Status))
+	}
+	r := bufio.NewReader(resp.Body)
+	var data bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF || s.ctx.Err() != nil {
+				return nil
+			}
+			return nestext.WrapError(nestext.ErrCodeIO, "cannot read events from "+url, err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" { // dispatch the event
+			if data.Len() > 0 && !s.event(data.Bytes(), opts) {
+				return nil
+			}
+			data.Reset()
+			continue
+		}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			s.lastID = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// event processes the data of a server-sent event. It returns false if ctx has been
+// canceled.
+func (s *subscriber) event(data []byte, opts []nestext.Option) bool {
+	tree, err := nestext.Parse(bytes.NewReader(data), opts...)
+	if err != nil {
+		return s.send(Update{Err: err})
+	}
+	return s.change(tree)
+}
@@ -0,0 +1,132 @@
+package ntremote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	var mx sync.Mutex
+	versions := []string{"v: 1\n", "v: 1\n", "- invalid\nv: 2\n", "v: 2\n"}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		defer mx.Unlock()
+		if requests >= len(versions) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(versions[requests]))
+		requests++
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := Subscribe(ctx, srv.URL, time.Millisecond)
+	expected := []interface{}{"1", nil, "2"}
+	for i, v := range expected {
+		u := <-updates
+		if v == nil {
+			if u.Err == nil {
+				t.Errorf("[%d] expected error for invalid document", i)
+			}
+			continue
+		}
+		if u.Err != nil || !reflect.DeepEqual(u.Tree, map[string]interface{}{"v": v}) {
+			t.Errorf("[%d] expected v = %v, have %v, %v", i, v, u.Tree, u.Err)
+		}
+	}
+	cancel()
+	for range updates {
+		t.Error("expected no further updates")
+	}
+}
+
+func TestSubscribeChanges(t *testing.T) {
+	var mx sync.Mutex
+	versions := []string{"a: 1\nb: 2\n", "b: 2\na: 1\n", "a: 1\nb: 3\n"}
+	requests := 0
+	var prefer string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		defer mx.Unlock()
+		prefer = r.Header.Get("Prefer")
+		if requests >= len(versions) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(versions[requests]))
+		requests++
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := Subscribe(ctx, srv.URL, time.Second)
+	u := <-updates
+	if len(u.Changes) != 1 || u.Changes[0].Path != "" || u.Changes[0].A != nil {
+		t.Errorf("expected the first version as a single change, have %v", u.Changes)
+	}
+	// the reordered version is not reported, as its content does not change
+	u = <-updates
+	expected := []string{"b: \"2\" != \"3\""}
+	if len(u.Changes) != 1 || u.Changes[0].String() != expected[0] {
+		t.Errorf("expected changes %v, have %v", expected, u.Changes)
+	}
+	cancel()
+	for range updates {
+	}
+	mx.Lock()
+	defer mx.Unlock()
+	if prefer != "wait=1" {
+		t.Errorf("expected requests to ask for long polling, have Prefer: %q", prefer)
+	}
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	var mx sync.Mutex
+	var lastIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		connection := len(lastIDs)
+		mx.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		if connection == 1 {
+			w.Write([]byte("retry: 1\n: a comment\nid: 1\ndata: a: 1\ndata: b: 2\n\n" +
+				"id: 2\ndata: - invalid\ndata: a: 2\n\n"))
+			return // closed by the server, the client reconnects
+		}
+		w.Write([]byte("id: 3\r\ndata: a: 1\r\ndata: b: 3\r\n\r\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := SubscribeEvents(ctx, srv.URL, time.Hour)
+	u := <-updates
+	if u.Err != nil || !reflect.DeepEqual(u.Tree, map[string]interface{}{"a": "1", "b": "2"}) {
+		t.Errorf("expected first version, have %v, %v", u.Tree, u.Err)
+	}
+	if u = <-updates; u.Err == nil {
+		t.Errorf("expected error for invalid document, have %v", u.Tree)
+	}
+	u = <-updates
+	if u.Err != nil || len(u.Changes) != 1 || u.Changes[0].Path != "b" {
+		t.Errorf("expected change of b, have %v, %v", u.Changes, u.Err)
+	}
+	cancel()
+	for range updates {
+	}
+	mx.Lock()
+	defer mx.Unlock()
+	if strings.Join(lastIDs, ",") != ",2" {
+		t.Errorf("expected reconnection with last event ID 2, have %q", lastIDs)
+	}
+}
@@ -0,0 +1,100 @@
+package ntschema
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Coercion --------------------------------------------------------------
+
+// Coerce returns a copy of `tree` with strings converted to the Go types declared by
+// schema s: int, float64, bool and time.Time for types int, float, bool and date,
+// respectively. Downstream code thus receives native values instead of strings:
+//
+//     config := schema.Coerce(tree)
+//     port, _ := nestext.Get(config, "server.port") // port is of type int
+//
+// Strings which cannot be converted are left unchanged; use Validate to detect them,
+// or use Decode, which does both. Dicts and lists are copied, with ordered maps
+// staying ordered maps; `tree` is not modified.
+func (s *Schema) Coerce(tree interface{}) interface{} {
+	switch t := tree.(type) {
+	case string:
+		if s == nil {
+			return t
+		}
+		if v, err := convert(t, s.Type); err == nil {
+			return v
+		}
+		return t
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, item := range t {
+			list[i] = s.items().Coerce(item)
+		}
+		return list
+	case map[string]interface{}:
+		dict := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			dict[k] = s.key(k).Coerce(v)
+		}
+		return dict
+	case *nestext.OrderedMap:
+		dict := nestext.NewOrderedMap()
+		for _, k := range t.Keys() {
+			v, _ := t.Get(k)
+			dict.Set(k, s.key(k).Coerce(v))
+		}
+		return dict
+	}
+	return tree
+}
+
+// items returns the schema for the items of a list, which may be nil.
+func (s *Schema) items() *Schema {
+	if s == nil {
+		return nil
+	}
+	return s.Items
+}
+
+// key returns the schema for key `k` of a dict, which may be nil.
+func (s *Schema) key(k string) *Schema {
+	if s == nil {
+		return nil
+	}
+	return s.Keys[k]
+}
+
+// Decode parses a NestedText document from `r` with parser options `opts`, validates
+// it against schema s and returns it with values coerced to native Go types (see
+// Coerce). If the document does not conform to the schema, the error will be of code
+// ErrCodeSchema and report the first violation, with its line. The error wraps a
+// *Report holding all violations:
+//
+//     config, err := schema.Decode(reader)
+//     var report *ntschema.Report
+//     if errors.As(err, &report) {
+//         fmt.Println(report) // one violation per line
+//     }
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (s *Schema) Decode(r io.Reader, opts ...nestext.Option) (interface{}, error) {
+	tree, report, err := s.validateDocument(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if report != nil {
+		first := report.Violations[0]
+		msg := first.String()
+		if n := len(report.Violations); n > 1 {
+			msg = fmt.Sprintf("%s (and %d more violations)", msg, n-1)
+		}
+		nterr := nestext.WrapError(nestext.ErrCodeSchema, msg, report)
+		nterr.Line = first.Line
+		return nil, nterr
+	}
+	return s.Coerce(tree), nil
+}
@@ -0,0 +1,90 @@
+package ntschema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/npillmayer/nestext"
+)
+
+const coerceSchema = `type: dict
+keys:
+    port:
+        type: int
+    ratio:
+        type: float
+    debug:
+        type: bool
+    since:
+        type: date
+    hosts:
+        type: list
+        items:
+            type: string
+    limits:
+        items:
+            type: int
+`
+
+func TestCoerce(t *testing.T) {
+	schema, err := Parse(strings.NewReader(coerceSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "port: 0x1F90\nratio: 0.5\ndebug: yes\nsince: 2021-06-01\nhosts:\n  - 1\nlimits:\n  - 2\n  - 3\nother: 4\n"
+	tree := parse(t, input)
+	coerced := schema.Coerce(tree)
+	expected := map[string]interface{}{
+		"port":   8080,
+		"ratio":  0.5,
+		"debug":  "yes", // not convertible, left unchanged
+		"since":  time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+		"hosts":  []interface{}{"1"},
+		"limits": []interface{}{2, 3},
+		"other":  "4",
+	}
+	if !reflect.DeepEqual(coerced, expected) {
+		t.Errorf("expected %#v, have %#v", expected, coerced)
+	}
+	if tree.(map[string]interface{})["port"] != "0x1F90" {
+		t.Error("expected input tree to be unmodified")
+	}
+	ordered, err := nestext.Parse(strings.NewReader("debug: true\nport: 1\n"), nestext.OrderedDicts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := schema.Coerce(ordered).(*nestext.OrderedMap)
+	if v, _ := m.Get("port"); v != 1 || !reflect.DeepEqual(m.Keys(), []string{"debug", "port"}) {
+		t.Errorf("expected ordered map to be coerced, have %v", m)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	schema, err := Parse(strings.NewReader(coerceSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := schema.Decode(strings.NewReader("port: 80\ndebug: false\n"))
+	if err != nil || !reflect.DeepEqual(tree, map[string]interface{}{"port": 80, "debug": false}) {
+		t.Errorf("unexpected result %#v, %v", tree, err)
+	}
+	_, err = schema.Decode(strings.NewReader("port: x\ndebug: maybe\n"))
+	nterr, ok := err.(nestext.NestedTextError)
+	if !ok || nterr.Code != nestext.ErrCodeSchema ||
+		!strings.HasSuffix(err.Error(), `debug: expected bool, have "maybe" (and 1 more violations)`) {
+		t.Errorf("expected schema error, have %v", err)
+	}
+}
+
+func TestCoerceJSONSchemaFormat(t *testing.T) {
+	schema, err := FromJSONSchema(strings.NewReader(`{"properties": {"at": {"type": "string", "format": "date-time"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := schema.Coerce(map[string]interface{}{"at": "2021-06-01T12:00:00Z"})
+	if at, ok := tree.(map[string]interface{})["at"].(time.Time); !ok || at.Hour() != 12 {
+		t.Errorf("expected date-time to be coerced, have %#v", tree)
+	}
+}
@@ -0,0 +1,108 @@
+package ntschema
+
+import "strings"
+
+// --- Schemas from examples -------------------------------------------------
+
+// FromExample infers a schema from an example document, as returned by
+// nestext.Parse. This allows to bootstrap a schema, or Go types (see GoTypes), from
+// an existing configuration file.
+//
+// Strings are of type int, float, bool or date if they may be converted to the
+// respective type, and of type string otherwise. Keys present in a dict are required.
+// Items of lists are merged into a single schema: keys missing from some dicts are
+// optional, and items of differing types result in the most specific common type,
+// e.g. float for ints and floats.
+func FromExample(tree interface{}) *Schema {
+	if items, keys, ok := dictItems(tree); ok {
+		s := &Schema{Type: Dict, Keys: make(map[string]*Schema, len(keys))}
+		for _, k := range keys {
+			ks := FromExample(items[k])
+			ks.Required = true
+			s.Keys[k] = ks
+		}
+		return s
+	}
+	switch t := tree.(type) {
+	case []interface{}:
+		s := &Schema{Type: List}
+		for i, item := range t {
+			if i == 0 {
+				s.Items = FromExample(item)
+			} else {
+				s.Items = merge(s.Items, FromExample(item))
+			}
+		}
+		return s
+	case string:
+		return &Schema{Type: inferType(t)}
+	}
+	return &Schema{}
+}
+
+// inferType returns the most specific type for string `s`.
+func inferType(s string) Type {
+	if !strings.ContainsAny(s, "0123456789") {
+		if strings.EqualFold(s, "true") || strings.EqualFold(s, "false") {
+			return Bool
+		}
+		return String // excludes "Inf" and "NaN" from floats
+	}
+	for _, t := range []Type{Int, Float, Date} {
+		if _, err := convert(s, t); err == nil {
+			return t
+		}
+	}
+	return String
+}
+
+// merge combines schemas inferred for different items of a list.
+func merge(a, b *Schema) *Schema {
+	switch {
+	case a.Type == b.Type && a.Type == Dict:
+		s := &Schema{Type: Dict, Keys: make(map[string]*Schema)}
+		for k, ks := range a.Keys {
+			if other, ok := b.Keys[k]; ok {
+				s.Keys[k] = merge(ks, other)
+				s.Keys[k].Required = ks.Required && other.Required
+			} else {
+				s.Keys[k] = optional(ks)
+			}
+		}
+		for k, ks := range b.Keys {
+			if _, ok := a.Keys[k]; !ok {
+				s.Keys[k] = optional(ks)
+			}
+		}
+		return s
+	case a.Type == b.Type && a.Type == List:
+		if a.Items == nil || b.Items == nil { // empty lists do not tell about items
+			if a.Items == nil {
+				return b
+			}
+			return a
+		}
+		return &Schema{Type: List, Items: merge(a.Items, b.Items)}
+	case a.Type == b.Type:
+		return &Schema{Type: a.Type}
+	case isNumber(a.Type) && isNumber(b.Type):
+		return &Schema{Type: Float}
+	case isScalar(a.Type) && isScalar(b.Type):
+		return &Schema{Type: String}
+	}
+	return &Schema{}
+}
+
+func optional(s *Schema) *Schema {
+	c := *s
+	c.Required = false
+	return &c
+}
+
+func isNumber(t Type) bool {
+	return t == Int || t == Float
+}
+
+func isScalar(t Type) bool {
+	return t == String || t == Int || t == Float || t == Bool || t == Date
+}
@@ -0,0 +1,36 @@
+package ntschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromExample(t *testing.T) {
+	input := "name: app\nport: 8080\nratio: 0.5\ndebug: True\nsince: 2021-06-01\nmode: f\nservers:\n" +
+		"  -\n    host: a\n    port: 1\n  -\n    host: b\n    port: 1.5\n    tls: on\nmixed:\n  - 1\n  - x\n  -\n    - y\n"
+	schema := FromExample(parse(t, input))
+	types := map[string]Type{}
+	for k, s := range schema.Keys {
+		types[k] = s.Type
+		if !s.Required {
+			t.Errorf("expected key %q of example to be required", k)
+		}
+	}
+	expected := map[string]Type{
+		"name": String, "port": Int, "ratio": Float, "debug": Bool, "since": Date, "mode": String,
+		"servers": List, "mixed": List,
+	}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected %v, have %v", expected, types)
+	}
+	server := schema.Keys["servers"].Items
+	if server.Keys["port"].Type != Float || !server.Keys["host"].Required || server.Keys["tls"].Required {
+		t.Errorf("expected list items to be merged, have %+v", server.Keys)
+	}
+	if items := schema.Keys["mixed"].Items; items.Type != "" {
+		t.Errorf("expected items of mixed list to allow any value, have %v", items.Type)
+	}
+	if violations := schema.Validate(parse(t, input)); len(violations) != 0 {
+		t.Errorf("expected example to conform to inferred schema, have %v", violations)
+	}
+}
@@ -0,0 +1,211 @@
+package ntschema
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Schemas from Go types -------------------------------------------------
+
+// FromType derives a schema from Go type `t`, describing the documents nestext.Decoder
+// may decode into a value of type t. This keeps validation in sync with the code
+// consuming a document.
+//
+// Keys of structs are determined like nestext.Decoder does, i.e. from field tags
+// `nt:"key"` or from field names. Additional constraints for a field are given by a
+// field tag `ntschema`, holding a comma-separated list of options:
+//
+//     type Config struct {
+//         Name  string `nt:"name" ntschema:"required,pattern=[a-z]+"`
+//         Port  int    `nt:"port" ntschema:"min=1,max=65535"`
+//         Mode  string `nt:"mode" ntschema:"enum=fast|safe,desc=Processing mode"`
+//     }
+//
+// Options are "required", "closed", "enum=a|b|…", "min=n", "max=n", "desc=text" and
+// "pattern=re", with the meaning described for Parse. As patterns may contain commas,
+// "pattern" has to be the last option.
+//
+// Strings, numbers and booleans map to types String, Int, Float and Bool, time.Time to
+// Date. Types implementing encoding.TextUnmarshaler, as well as time.Duration, map to
+// String; types implementing nestext.Unmarshaler and interfaces allow any value.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func FromType(t reflect.Type) (*Schema, error) {
+	if t == nil {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeUsage, "cannot derive schema from nil type")
+	}
+	return fromType(t, map[reflect.Type]bool{})
+}
+
+// FromValue derives a schema from the type of `v`, as described for FromType.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func FromValue(v interface{}) (*Schema, error) {
+	return FromType(reflect.TypeOf(v))
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*nestext.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// fromType derives a schema from `t`. `visiting` holds the struct types currently
+// being derived, to stop at recursive types.
+func fromType(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	pt := reflect.PtrTo(t)
+	switch {
+	case pt.Implements(unmarshalerType):
+		return &Schema{}, nil
+	case t == timeType:
+		return &Schema{Type: Date}, nil
+	case t == durationType || pt.Implements(textUnmarshalerType):
+		return &Schema{Type: String}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: String}, nil
+	case reflect.Bool:
+		return &Schema{Type: Bool}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Int}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Float}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := fromType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: List, Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, typeError(t, "map keys have to be strings")
+		}
+		return &Schema{Type: Dict}, nil
+	case reflect.Struct:
+		if visiting[t] { // recursive type: do not descend any further
+			return &Schema{Type: Dict}, nil
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		return structSchema(t, visiting)
+	}
+	return nil, typeError(t, "type cannot be decoded from NestedText")
+}
+
+// structSchema derives a schema for a struct type, with fields collected like
+// nestext.Decoder does, including fields of embedded structs.
+func structSchema(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	s := &Schema{Type: Dict, Keys: make(map[string]*Schema)}
+	var collect func(t reflect.Type, embedded bool) error
+	collect = func(t reflect.Type, embedded bool) error {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("nt")
+			if j := strings.IndexByte(tag, ','); j >= 0 {
+				tag = tag[:j]
+			}
+			if tag == "-" {
+				continue
+			}
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+				if err := collect(ft, true); err != nil {
+					return err
+				}
+				continue
+			}
+			if sf.PkgPath != "" { // unexported
+				continue
+			}
+			if tag == "" {
+				tag = sf.Name
+			}
+			if _, exists := s.Keys[tag]; exists && embedded {
+				continue // fields of the outer struct take precedence
+			}
+			fs, err := fromType(sf.Type, visiting)
+			if err != nil {
+				return err
+			}
+			if err = applyTag(fs, sf.Tag.Get("ntschema")); err != nil {
+				return typeError(t, fmt.Sprintf("field %s: %s", sf.Name, err.Error()))
+			}
+			s.Keys[tag] = fs
+		}
+		return nil
+	}
+	if err := collect(t, false); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// applyTag sets the options of an `ntschema` field tag.
+func applyTag(s *Schema, tag string) error {
+	for tag != "" {
+		var opt string
+		if strings.HasPrefix(tag, "pattern=") {
+			opt, tag = tag, ""
+		} else if j := strings.IndexByte(tag, ','); j >= 0 {
+			opt, tag = tag[:j], tag[j+1:]
+		} else {
+			opt, tag = tag, ""
+		}
+		name, value := opt, ""
+		if j := strings.IndexByte(opt, '='); j >= 0 {
+			name, value = opt[:j], opt[j+1:]
+		}
+		switch name {
+		case "required":
+			s.Required = true
+		case "closed":
+			s.Closed = true
+		case "enum":
+			s.Allowed = strings.Split(value, "|")
+		case "desc":
+			s.Description = value
+		case "min", "max":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("option %s must be a number", name)
+			}
+			if name == "min" {
+				s.Min = &f
+			} else {
+				s.Max = &f
+			}
+		case "pattern":
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q", value)
+			}
+			s.Pattern = re
+		case "":
+		default:
+			return fmt.Errorf("unknown option %q", name)
+		}
+	}
+	return nil
+}
+
+func typeError(t reflect.Type, msg string) error {
+	return nestext.MakeNestedTextError(nestext.ErrCodeUsage, fmt.Sprintf("schema for type %v: %s", t, msg))
+}
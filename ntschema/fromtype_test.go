@@ -0,0 +1,96 @@
+package ntschema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID   string `nt:"id" ntschema:"required"`
+	Name string `nt:"name"`
+}
+
+type node struct {
+	Value    string  `nt:"value"`
+	Children []*node `nt:"children"`
+}
+
+type config struct {
+	base
+	Name    string            `nt:"name" ntschema:"required,pattern=[a-z]+,?"`
+	Port    int               `nt:"port" ntschema:"min=1,max=65535,desc=Port to listen on"`
+	Mode    string            `ntschema:"enum=fast|safe"`
+	Ratio   float64           `nt:"ratio"`
+	Debug   *bool             `nt:"debug"`
+	Since   time.Time         `nt:"since"`
+	Timeout time.Duration     `nt:"timeout"`
+	Tags    []string          `nt:"tags"`
+	Labels  map[string]string `nt:"labels"`
+	Extra   interface{}       `nt:"extra"`
+	Tree    node              `nt:"tree"`
+	Ignored string            `nt:"-"`
+	hidden  string
+}
+
+func TestFromType(t *testing.T) {
+	schema, err := FromValue(&config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]Type{}
+	for k, s := range schema.Keys {
+		types[k] = s.Type
+	}
+	expected := map[string]Type{
+		"id": String, "name": String, "port": Int, "Mode": String, "ratio": Float, "debug": Bool,
+		"since": Date, "timeout": String, "tags": List, "labels": Dict, "extra": "", "tree": Dict,
+	}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected %v, have %v", expected, types)
+	}
+	if s := schema.Keys["port"]; *s.Min != 1 || *s.Max != 65535 || s.Description != "Port to listen on" {
+		t.Errorf("expected bounds and description for port, have %+v", s)
+	}
+	if !schema.Keys["id"].Required || !schema.Keys["name"].Required || schema.Keys["ratio"].Required {
+		t.Error("expected id and name to be required")
+	}
+	if tree := schema.Keys["tree"]; tree.Keys["children"].Items.Type != Dict {
+		t.Errorf("expected recursive type to be cut off, have %+v", tree.Keys["children"])
+	}
+	input := "id: 1\nname: a,\nport: 0\nMode: slow\nsince: 2021-06-01\ntags:\n  - x\n"
+	var messages []string
+	for _, v := range schema.Validate(parse(t, input)) {
+		messages = append(messages, v.String())
+	}
+	expectedMessages := []string{
+		`Mode: value "slow" is not one of fast, safe`,
+		`port: value 0 is less than minimum 1`,
+	}
+	if !reflect.DeepEqual(messages, expectedMessages) {
+		t.Errorf("expected\n%s\nhave\n%s", strings.Join(expectedMessages, "\n"), strings.Join(messages, "\n"))
+	}
+}
+
+func TestFromTypeErrors(t *testing.T) {
+	inputs := []struct {
+		v   interface{}
+		msg string
+	}{
+		{nil, "cannot derive schema from nil type"},
+		{make(chan int), "type cannot be decoded"},
+		{map[int]string{}, "map keys have to be strings"},
+		{struct {
+			A int `ntschema:"min=x"`
+		}{}, "field A: option min must be a number"},
+		{struct {
+			A int `ntschema:"optional"`
+		}{}, `field A: unknown option "optional"`},
+	}
+	for _, input := range inputs {
+		if _, err := FromValue(input.v); err == nil || !strings.Contains(err.Error(), input.msg) {
+			t.Errorf("expected error %q for %T, have %v", input.msg, input.v, err)
+		}
+	}
+}
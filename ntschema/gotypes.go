@@ -0,0 +1,156 @@
+package ntschema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Go types from schemas -------------------------------------------------
+
+// GoTypes generates the source of a Go file for package `pkg`, defining a struct type
+// `name` for documents conforming to schema s, together with struct types for
+// nested dicts. Struct fields carry `nt` tags, thus documents may be decoded with
+// nestext.Decoder:
+//
+//     schema := ntschema.FromExample(tree)
+//     src, err := schema.GoTypes("config", "Config")
+//
+// Types of nested dicts are named after their path, e.g. `ConfigServer` for key
+// "server" of `Config`. Types int, float and bool map to int, float64 and bool; dates
+// map to string, as time.Time decodes from RFC 3339 timestamps only. Dicts without
+// known keys map to map[string]interface{}. Descriptions become comments of the
+// fields. The schema for the top-level value has to be of type dict.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (s *Schema) GoTypes(pkg, name string) ([]byte, error) {
+	if s.Type != Dict || len(s.Keys) == 0 {
+		return nil, nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+			"Go types require a schema for a dict with known keys")
+	}
+	g := &goGenerator{names: map[string]bool{}}
+	g.structType(name, s)
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	for _, def := range g.defs {
+		out.WriteString(def)
+	}
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, nestext.WrapError(nestext.ErrCodeUsage, "cannot generate Go types", err)
+	}
+	return src, nil
+}
+
+type goGenerator struct {
+	defs  []string        // type definitions, in order of appearance
+	names map[string]bool // type names in use
+}
+
+// structType generates a struct type for dict schema `s` and returns its name.
+func (g *goGenerator) structType(name string, s *Schema) string {
+	name = g.unique(name)
+	index := len(g.defs)
+	g.defs = append(g.defs, "") // reserve the position, as nested types are added first
+	var b strings.Builder
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	keys := make([]string, 0, len(s.Keys))
+	for k := range s.Keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := map[string]bool{}
+	for _, k := range keys {
+		ks := s.Keys[k]
+		field := goName(k)
+		for i := 2; fields[field]; i++ {
+			field = fmt.Sprintf("%s%d", goName(k), i)
+		}
+		fields[field] = true
+		if ks.Description != "" {
+			for _, line := range strings.Split(ks.Description, "\n") {
+				fmt.Fprintf(&b, "\t// %s\n", line)
+			}
+		}
+		fmt.Fprintf(&b, "\t%s %s %s\n", field, g.typeExpr(name+field, ks), fieldTag(k))
+	}
+	b.WriteString("}\n\n")
+	g.defs[index] = b.String()
+	return name
+}
+
+// typeExpr returns the Go type for schema `s`, generating a struct type named `name`
+// for dicts with known keys.
+func (g *goGenerator) typeExpr(name string, s *Schema) string {
+	if s == nil {
+		return "interface{}"
+	}
+	switch s.Type {
+	case String, Date:
+		return "string"
+	case Int:
+		return "int"
+	case Float:
+		return "float64"
+	case Bool:
+		return "bool"
+	case List:
+		return "[]" + g.typeExpr(name, s.Items)
+	case Dict:
+		if len(s.Keys) == 0 {
+			return "map[string]interface{}"
+		}
+		return g.structType(name, s)
+	}
+	return "interface{}"
+}
+
+// unique returns `name`, or `name` with a numeric suffix if it is already in use.
+func (g *goGenerator) unique(name string) string {
+	candidate := name
+	for i := 2; g.names[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	g.names[candidate] = true
+	return candidate
+}
+
+// goName converts a key into an exported Go identifier, e.g. "max-depth" into
+// "MaxDepth".
+func goName(key string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteByte('X')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
+
+// fieldTag returns the struct tag for key `key`.
+func fieldTag(key string) string {
+	tag := "nt:" + strconv.Quote(key)
+	if strings.ContainsRune(tag, '`') {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
@@ -0,0 +1,46 @@
+package ntschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoTypes(t *testing.T) {
+	input := "name: app\nmax-depth: 3\n2fa: true\nservers:\n  -\n    host: a\n    tls:\n      cert: c.pem\n" +
+		"labels:\n  {}\nsince: 2021-06-01\n"
+	schema := FromExample(parse(t, input))
+	schema.Keys["name"].Description = "Name of the application"
+	src, err := schema.GoTypes("config", "Config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "package config\n\n" +
+		"type Config struct {\n" +
+		"\tX2fa     bool                   `nt:\"2fa\"`\n" +
+		"\tLabels   map[string]interface{} `nt:\"labels\"`\n" +
+		"\tMaxDepth int                    `nt:\"max-depth\"`\n" +
+		"\t// Name of the application\n" +
+		"\tName    string          `nt:\"name\"`\n" +
+		"\tServers []ConfigServers `nt:\"servers\"`\n" +
+		"\tSince   string          `nt:\"since\"`\n" +
+		"}\n\n" +
+		"type ConfigServers struct {\n" +
+		"\tHost string           `nt:\"host\"`\n" +
+		"\tTls  ConfigServersTls `nt:\"tls\"`\n" +
+		"}\n\n" +
+		"type ConfigServersTls struct {\n" +
+		"\tCert string `nt:\"cert\"`\n" +
+		"}\n"
+	if string(src) != expected {
+		t.Errorf("expected\n%s\nhave\n%s", expected, src)
+	}
+	if _, err = FromExample(parse(t, "- a\n")).GoTypes("config", "Config"); err == nil {
+		t.Error("expected list at top-level to be rejected")
+	}
+}
+
+func TestFieldTag(t *testing.T) {
+	if tag := fieldTag("a`b"); !strings.HasPrefix(tag, `"nt:\"a`+"`") {
+		t.Errorf("expected interpreted string literal for key with backquote, have %s", tag)
+	}
+}
@@ -0,0 +1,233 @@
+package ntschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- JSON Schema -----------------------------------------------------------
+
+// FromJSONSchema converts a JSON Schema (draft 2020-12) into a schema, thus allowing
+// to validate NestedText documents against existing JSON Schemas. As leaf values of
+// NestedText documents are strings, JSON types "integer", "number" and "boolean"
+// require strings convertible to the respective type; enum values of these types are
+// compared in their JSON notation.
+//
+// The following keywords are supported:
+//
+//     type (a single type), enum, const, description, pattern,
+//     minimum, maximum, minLength, maxLength, minItems, maxItems,
+//     minProperties, maxProperties, properties, required,
+//     additionalProperties (false only), items, format
+//
+// Bounds apply to a single type, which has to be given by keyword "type": minimum and
+// maximum to "integer" and "number", minLength and maxLength to "string", minItems
+// and maxItems to "array", minProperties and maxProperties to "object". At most one
+// lower and one upper bound may be given.
+//
+// Strings of format "date" or "date-time" are of type Date; other formats are
+// ignored, as JSON Schema treats them as annotations by default. Annotations like
+// "$schema", "$id", "title", "default" or "examples" are ignored as well. Other
+// keywords, e.g. "$ref" or "allOf", result in an error, as ignoring them would accept
+// documents the JSON Schema rejects.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func FromJSONSchema(r io.Reader) (*Schema, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, nestext.WrapError(nestext.ErrCodeUsage, "cannot read JSON Schema", err)
+	}
+	return fromJSONSchema(tree, nil)
+}
+
+var jsonTypes = map[string]Type{
+	"string":  String,
+	"integer": Int,
+	"number":  Float,
+	"boolean": Bool,
+	"array":   List,
+	"object":  Dict,
+}
+
+func fromJSONSchema(tree interface{}, trail []nestext.PathSegment) (*Schema, error) {
+	if b, ok := tree.(bool); ok && b { // schema `true` accepts anything
+		return &Schema{}, nil
+	}
+	obj, ok := tree.(map[string]interface{})
+	if !ok {
+		return nil, schemaError(trail, "JSON Schema must be an object or true")
+	}
+	s := &Schema{}
+	var format, typeName string
+	var minKey, maxKey string // keywords of the bounds given
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // "properties" has to be handled before "required"
+	for _, key := range keys {
+		value := obj[key]
+		switch key {
+		case "$schema", "$id", "$comment", "title", "default", "examples", "deprecated", "readOnly", "writeOnly":
+		case "type":
+			name, _ := value.(string)
+			t, ok := jsonTypes[name]
+			if !ok {
+				return nil, schemaError(trail, fmt.Sprintf("unsupported type %v", value))
+			}
+			s.Type, typeName = t, name
+		case "description":
+			s.Description, _ = value.(string)
+		case "format":
+			format, _ = value.(string)
+		case "enum", "const":
+			values, ok := value.([]interface{})
+			if key == "const" {
+				values, ok = []interface{}{value}, true
+			}
+			if !ok {
+				return nil, schemaError(trail, "enum must be an array")
+			}
+			for _, v := range values {
+				a, err := jsonScalar(v)
+				if err != nil {
+					return nil, schemaError(trail, key+": "+err.Error())
+				}
+				s.Allowed = append(s.Allowed, a)
+			}
+		case "pattern":
+			str, _ := value.(string)
+			re, err := regexp.Compile(str)
+			if err != nil {
+				return nil, schemaError(trail, fmt.Sprintf("invalid pattern %v", value))
+			}
+			s.Pattern = re // JSON Schema patterns are not anchored
+		case "minimum", "minLength", "minItems", "minProperties":
+			if minKey != "" {
+				return nil, schemaError(trail, fmt.Sprintf("%s and %s are both lower bounds", minKey, key))
+			}
+			if s.Min, ok = jsonNumber(value); !ok {
+				return nil, schemaError(trail, key+" must be a number")
+			}
+			minKey = key
+		case "maximum", "maxLength", "maxItems", "maxProperties":
+			if maxKey != "" {
+				return nil, schemaError(trail, fmt.Sprintf("%s and %s are both upper bounds", maxKey, key))
+			}
+			if s.Max, ok = jsonNumber(value); !ok {
+				return nil, schemaError(trail, key+" must be a number")
+			}
+			maxKey = key
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, schemaError(trail, "properties must be an object")
+			}
+			s.Keys = make(map[string]*Schema, len(props))
+			for k, p := range props {
+				ks, err := fromJSONSchema(p, appendSegment(trail, nestext.PathSegment{Key: k}))
+				if err != nil {
+					return nil, err
+				}
+				s.Keys[k] = ks
+			}
+		case "required":
+			names, ok := value.([]interface{})
+			if !ok {
+				return nil, schemaError(trail, "required must be an array of strings")
+			}
+			for _, n := range names {
+				name, ok := n.(string)
+				if !ok {
+					return nil, schemaError(trail, "required must be an array of strings")
+				}
+				if s.Keys == nil {
+					s.Keys = make(map[string]*Schema)
+				}
+				if s.Keys[name] == nil {
+					s.Keys[name] = &Schema{}
+				}
+				s.Keys[name].Required = true
+			}
+		case "additionalProperties":
+			if b, ok := value.(bool); ok && !b {
+				s.Closed = true
+			} else if !ok && !isTrueSchema(value) { // true allows additional keys, which is the default
+				return nil, schemaError(trail, "only false is supported for additionalProperties")
+			}
+		case "items":
+			is, err := fromJSONSchema(value, appendSegment(trail, nestext.PathSegment{Wildcard: true}))
+			if err != nil {
+				return nil, err
+			}
+			s.Items = is
+		default:
+			return nil, schemaError(trail, fmt.Sprintf("unsupported JSON Schema keyword %q", key))
+		}
+	}
+	for _, key := range []string{minKey, maxKey} {
+		if key != "" && !fitsBound(key, typeName) {
+			return nil, schemaError(trail, fmt.Sprintf("%s requires type %s", key,
+				strings.Join(boundTypes[key], " or ")))
+		}
+	}
+	if (format == "date" || format == "date-time") && (s.Type == String || s.Type == "") {
+		s.Type = Date
+	}
+	return s, nil
+}
+
+// boundTypes holds the JSON types the keywords for bounds apply to.
+var boundTypes = map[string][]string{
+	"minimum": {"integer", "number"}, "maximum": {"integer", "number"},
+	"minLength": {"string"}, "maxLength": {"string"},
+	"minItems": {"array"}, "maxItems": {"array"},
+	"minProperties": {"object"}, "maxProperties": {"object"},
+}
+
+// fitsBound is true if bound keyword `key` applies to JSON type `typeName`.
+func fitsBound(key, typeName string) bool {
+	for _, t := range boundTypes[key] {
+		if t == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonScalar returns the JSON notation of a scalar, which is what a NestedText
+// document would contain for it.
+func jsonScalar(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case json.Number:
+		return t.String(), nil
+	case bool:
+		return fmt.Sprint(t), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+func jsonNumber(v interface{}) (*float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, false
+	}
+	f, err := n.Float64()
+	return &f, err == nil
+}
+
+// isTrueSchema is true for an empty object, which accepts anything.
+func isTrueSchema(v interface{}) bool {
+	obj, ok := v.(map[string]interface{})
+	return ok && len(obj) == 0
+}
@@ -0,0 +1,78 @@
+package ntschema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+const jsonSchemaInput = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "config",
+  "type": "object",
+  "required": ["name", "port"],
+  "additionalProperties": false,
+  "properties": {
+    "name": {"type": "string", "minLength": 2},
+    "port": {"type": "integer", "minimum": 1, "maximum": 65535},
+    "ratio": {"type": "number"},
+    "debug": {"type": "boolean"},
+    "level": {"enum": [1, 2, 3]},
+    "tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "pattern": "^[a-z]+$"}}
+  }
+}`
+
+func TestFromJSONSchema(t *testing.T) {
+	schema, err := FromJSONSchema(strings.NewReader(jsonSchemaInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := "name: app\nport: 8080\nratio: 0.5\ndebug: false\nlevel: 2\ntags:\n  - a\n"
+	if violations := schema.Validate(parse(t, valid)); len(violations) != 0 {
+		t.Errorf("expected document to be valid, have %v", violations)
+	}
+	invalid := "port: x\nratio: half\ndebug: 1.0\nlevel: 4\nother: y\ntags:\n  - a\n  - B\n  - c\n"
+	var messages []string
+	for _, v := range schema.Validate(parse(t, invalid)) {
+		messages = append(messages, v.String())
+	}
+	expected := []string{
+		`top-level value: missing required key "name"`,
+		`debug: expected bool, have "1.0"`,
+		`level: value "4" is not one of 1, 2, 3`,
+		`top-level value: unknown key "other"`,
+		`port: expected int, have "x"`,
+		`ratio: expected float, have "half"`,
+		`tags: count of items 3 is greater than maximum 2`,
+		`tags[1]: value "B" does not match pattern ^[a-z]+$`,
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("expected\n%s\nhave\n%s", strings.Join(expected, "\n"), strings.Join(messages, "\n"))
+	}
+}
+
+func TestFromJSONSchemaErrors(t *testing.T) {
+	inputs := map[string]string{
+		`[]`:                                   `schema for "": JSON Schema must be an object or true`,
+		`{"type": ["string", "null"]}`:         `unsupported type`,
+		`{"$ref": "#/$defs/x"}`:                `unsupported JSON Schema keyword "$ref"`,
+		`{"properties": {"a": {"allOf": []}}}`: `schema for "a": unsupported JSON Schema keyword "allOf"`,
+		`{"additionalProperties": {"type": "string"}}`: `only false is supported`,
+		`{"items": {"enum": [null]}}`:                  `schema for "*": enum: unsupported value`,
+		`{`:                                            `cannot read JSON Schema`,
+		`{"type": "string", "minLength": 1, "minimum": 0}`:     `minLength and minimum are both lower bounds`,
+		`{"type": "array", "maxItems": 3, "maxProperties": 3}`: `maxItems and maxProperties are both upper bounds`,
+		`{"type": "string", "minItems": 1}`:                    `minItems requires type array`,
+		`{"type": "object", "maximum": 10}`:                    `maximum requires type integer or number`,
+		`{"minLength": 1}`:                                     `minLength requires type string`,
+	}
+	for input, msg := range inputs {
+		_, err := FromJSONSchema(strings.NewReader(input))
+		nterr, ok := err.(nestext.NestedTextError)
+		if !ok || nterr.Code != nestext.ErrCodeUsage || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected error %q for %s, have %v", msg, input, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package ntschema
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Validation reports ----------------------------------------------------
+
+// Report collects all violations of a document, allowing users to fix a document in
+// one pass instead of one violation at a time. Report implements the error interface,
+// with a message listing every violation on a line of its own.
+type Report struct {
+	Violations []Violation
+}
+
+func (r *Report) Error() string {
+	lines := make([]string, len(r.Violations))
+	for i, v := range r.Violations {
+		if v.Line > 0 {
+			lines[i] = fmt.Sprintf("line %d: %s", v.Line, v)
+		} else {
+			lines[i] = v.String()
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Locate sets the source lines of the violations of r from positions `pos`, as reported
+// by nestext.ReportPositions. Violations of missing keys are located at their dict,
+// values without a position of their own (e.g. items of inline lists) at the closest
+// enclosing item.
+func (r *Report) Locate(pos nestext.Positions) {
+	for i, v := range r.Violations {
+		ptr, err := nestext.PathToPointer(v.Path)
+		if err != nil {
+			continue
+		}
+		for {
+			if p, ok := pos[ptr]; ok {
+				r.Violations[i].Line = p.Line
+				break
+			}
+			if ptr == "" {
+				break
+			}
+			ptr = ptr[:strings.LastIndexByte(ptr, '/')]
+		}
+	}
+}
+
+// ValidateDocument parses a document from `r` with parser options `opts` and validates
+// it against schema s. All violations are collected into a report, located at the lines
+// of the offending values. The report is nil if the document conforms to the schema.
+//
+// Use as:
+//     report, err := schema.ValidateDocument(reader)
+//     if err != nil {
+//         log.Fatalf("config.nt: %v", err) // document is not valid NestedText
+//     }
+//     if report != nil {
+//         for _, v := range report.Violations {
+//             log.Printf("config.nt:%d: %s", v.Line, v)
+//         }
+//     }
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func (s *Schema) ValidateDocument(r io.Reader, opts ...nestext.Option) (*Report, error) {
+	_, report, err := s.validateDocument(r, opts)
+	return report, err
+}
+
+// validateDocument parses and validates a document, returning the parsed tree as well.
+func (s *Schema) validateDocument(r io.Reader, opts []nestext.Option) (interface{}, *Report, error) {
+	var pos nestext.Positions
+	tree, err := nestext.Parse(r, append(opts[:len(opts):len(opts)], nestext.ReportPositions(&pos))...)
+	if err != nil {
+		return nil, nil, err
+	}
+	violations := s.Validate(tree)
+	if len(violations) == 0 {
+		return tree, nil, nil
+	}
+	report := &Report{Violations: violations}
+	report.Locate(pos)
+	return tree, report, nil
+}
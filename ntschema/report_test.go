@@ -0,0 +1,68 @@
+package ntschema
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const reportSchema = `
+type: dict
+keys:
+    name:
+        type: string
+        required: true
+    port:
+        type: int
+    hosts:
+        type: list
+        items:
+            pattern: [a-z]+
+`
+
+func TestValidateDocument(t *testing.T) {
+	schema, err := Parse(strings.NewReader(reportSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := schema.ValidateDocument(strings.NewReader("port: http\nhosts:\n  [a, B]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report == nil {
+		t.Fatal("expected a report, have none")
+	}
+	lines := []int{}
+	for _, v := range report.Violations {
+		lines = append(lines, v.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{1, 2, 1}) {
+		t.Errorf("expected violations at lines [1 2 1], have %v\n%s", lines, report)
+	}
+	if msg := report.Error(); !strings.HasPrefix(msg, "line 1: top-level value: missing required key \"name\"\n") {
+		t.Errorf("unexpected report\n%s", msg)
+	}
+	report, err = schema.ValidateDocument(strings.NewReader("name: app\n"))
+	if report != nil || err != nil {
+		t.Errorf("expected neither report nor error, have %v, %v", report, err)
+	}
+	if _, err = schema.ValidateDocument(strings.NewReader("a: 1\n  b: 2\n")); err == nil {
+		t.Error("expected invalid document to produce an error; didn't")
+	}
+}
+
+func TestDecodeReport(t *testing.T) {
+	schema, err := Parse(strings.NewReader(reportSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = schema.Decode(strings.NewReader("name: app\nport: http\nhosts:\n  - B\n"))
+	var report *Report
+	if !errors.As(err, &report) || len(report.Violations) != 2 {
+		t.Fatalf("expected error to wrap a report of 2 violations, have %v", err)
+	}
+	if report.Violations[0].Line != 4 {
+		t.Errorf("expected violation of hosts[0] at line 4, have %d", report.Violations[0].Line)
+	}
+}
@@ -0,0 +1,408 @@
+// Package ntschema validates parsed NestedText documents against a schema, which is
+// itself written in NestedText:
+//
+//     type: dict
+//     keys:
+//         name:
+//             type: string
+//             required: true
+//         port:
+//             type: int
+//             min: 1
+//             max: 65535
+//         mode:
+//             allowed:
+//                 - fast
+//                 - safe
+//         servers:
+//             type: list
+//             items:
+//                 type: dict
+//                 keys:
+//                     host:
+//                         type: string
+//                         pattern: [a-z.]+
+//
+// Use as:
+//     schema, err := ntschema.Parse(schemaReader)
+//     …
+//     config, err := nestext.Parse(reader)
+//     …
+//     for _, v := range schema.Validate(config) {
+//         log.Printf("config.nt: %s", v)
+//     }
+//
+// Violations are addressed by path expressions (see nestext.ParsePath). Existing
+// JSON Schemas may be used as well, see FromJSONSchema.
+//
+package ntschema
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/npillmayer/nestext"
+)
+
+// --- Schemas ---------------------------------------------------------------
+
+// Type is the type of a value, as required by a schema. As all leaf values of a
+// NestedText document are strings, types int, float, bool and date require strings
+// which may be converted to the respective Go type (see Coerce). Dates are written
+// as "2006-01-02" or in RFC 3339 format.
+type Type string
+
+// Types of values
+const (
+	Any    Type = "any"
+	String Type = "string"
+	Int    Type = "int"
+	Float  Type = "float"
+	Bool   Type = "bool"
+	Date   Type = "date"
+	List   Type = "list"
+	Dict   Type = "dict"
+)
+
+var types = []Type{Any, String, Int, Float, Bool, Date, List, Dict}
+
+// Schema describes the values allowed at some place of a document. The zero value
+// allows any value.
+type Schema struct {
+	Type        Type               // type of the value; empty is the same as Any
+	Description string             // human readable description, not used for validation
+	Required    bool               // is this a required key of its dict?
+	Allowed     []string           // if non-empty, the values allowed for strings
+	Pattern     *regexp.Regexp     // if set, strings have to match it (Parse anchors patterns)
+	Min, Max    *float64           // bounds of numbers, or of the length of strings, lists and dicts
+	Keys        map[string]*Schema // schemas of the known keys of dicts
+	Closed      bool               // are keys of dicts restricted to Keys?
+	Items       *Schema            // schema of the items of lists
+}
+
+// Parse reads a schema written in NestedText. Items of a schema are:
+//
+//     type:         one of any, string, int, float, bool, date, list, dict
+//     description:  human readable description
+//     required:     true, if a key has to be present in its dict
+//     allowed:      list of allowed values
+//     pattern:      regular expression which has to match strings in full
+//     min, max:     bounds of numbers, or of the length of strings, lists and dicts
+//     keys:         dict of schemas for the keys of a dict
+//     closed:       true, if a dict may not have other keys than the ones of `keys`
+//     items:        schema for the items of a list
+//
+// Boolean flags accept the values understood by strconv.ParseBool.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func Parse(r io.Reader) (*Schema, error) {
+	tree, err := nestext.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return FromTree(tree)
+}
+
+// FromTree creates a schema from a parsed NestedText document, as described for
+// Parse.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func FromTree(tree interface{}) (*Schema, error) {
+	return fromTree(tree, nil)
+}
+
+func fromTree(tree interface{}, trail []nestext.PathSegment) (*Schema, error) {
+	items, keys, ok := dictItems(tree)
+	if !ok {
+		return nil, schemaError(trail, "schema must be a dict")
+	}
+	s := &Schema{}
+	for _, key := range keys {
+		value := items[key]
+		str, isString := value.(string)
+		switch key {
+		case "type":
+			s.Type = Type(str)
+			if !isString || !knownType(s.Type) {
+				return nil, schemaError(trail, fmt.Sprintf("unknown type %v", value))
+			}
+		case "description":
+			s.Description = str
+		case "required", "closed":
+			b, err := strconv.ParseBool(str)
+			if !isString || err != nil {
+				return nil, schemaError(trail, fmt.Sprintf("%s must be true or false", key))
+			}
+			if key == "required" {
+				s.Required = b
+			} else {
+				s.Closed = b
+			}
+		case "allowed":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, schemaError(trail, "allowed must be a list of strings")
+			}
+			for _, v := range list {
+				a, ok := v.(string)
+				if !ok {
+					return nil, schemaError(trail, "allowed must be a list of strings")
+				}
+				s.Allowed = append(s.Allowed, a)
+			}
+		case "pattern":
+			re, err := regexp.Compile("^(?:" + str + ")$")
+			if !isString || err != nil {
+				return nil, schemaError(trail, fmt.Sprintf("invalid pattern %v", value))
+			}
+			s.Pattern = re
+		case "min", "max":
+			f, err := strconv.ParseFloat(str, 64)
+			if !isString || err != nil {
+				return nil, schemaError(trail, fmt.Sprintf("%s must be a number", key))
+			}
+			if key == "min" {
+				s.Min = &f
+			} else {
+				s.Max = &f
+			}
+		case "keys":
+			kitems, knames, ok := dictItems(value)
+			if !ok {
+				return nil, schemaError(trail, "keys must be a dict of schemas")
+			}
+			s.Keys = make(map[string]*Schema, len(knames))
+			for _, k := range knames {
+				ks, err := fromTree(kitems[k], appendSegment(trail, nestext.PathSegment{Key: k}))
+				if err != nil {
+					return nil, err
+				}
+				s.Keys[k] = ks
+			}
+		case "items":
+			is, err := fromTree(value, appendSegment(trail, nestext.PathSegment{Wildcard: true}))
+			if err != nil {
+				return nil, err
+			}
+			s.Items = is
+		default:
+			return nil, schemaError(trail, fmt.Sprintf("unknown schema item %q", key))
+		}
+	}
+	return s, nil
+}
+
+func knownType(t Type) bool {
+	for _, known := range types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func schemaError(trail []nestext.PathSegment, msg string) error {
+	return nestext.MakeNestedTextError(nestext.ErrCodeUsage,
+		fmt.Sprintf("schema for %q: %s", nestext.JoinPath(trail), msg))
+}
+
+// --- Validation ------------------------------------------------------------
+
+// Violation is a value of a document which does not conform to a schema.
+type Violation struct {
+	Path    string // path expression of the value; "" for the top-level value
+	Message string // human readable description
+	Line    int    // line of the value in the source, if known (see Report.Locate)
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return "top-level value: " + v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Validate checks `tree`, as returned by nestext.Parse, against schema s and returns
+// all violations found. Dicts may be of type map[string]interface{} or
+// *nestext.OrderedMap. Violations are ordered by path, with items of dicts ordered by
+// key, except for ordered maps.
+func (s *Schema) Validate(tree interface{}) []Violation {
+	var violations []Violation
+	s.validate(tree, nil, &violations)
+	return violations
+}
+
+func (s *Schema) validate(tree interface{}, trail []nestext.PathSegment, violations *[]Violation) {
+	report := func(format string, args ...interface{}) {
+		*violations = append(*violations, Violation{
+			Path:    nestext.JoinPath(trail),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	items, keys, isDict := dictItems(tree)
+	list, isList := tree.([]interface{})
+	str, isString := tree.(string)
+	if !isDict && !isList && !isString && tree != nil {
+		str, isString = fmt.Sprint(tree), true
+	}
+	switch s.Type {
+	case String, Int, Float, Bool, Date:
+		if !isString {
+			report("expected %s, have %s", s.Type, kind(tree))
+			return
+		}
+		if _, err := convert(str, s.Type); err != nil {
+			report("expected %s, have %q", s.Type, str)
+			return
+		}
+	case List:
+		if !isList {
+			report("expected list, have %s", kind(tree))
+			return
+		}
+	case Dict:
+		if !isDict {
+			report("expected dict, have %s", kind(tree))
+			return
+		}
+	}
+	switch {
+	case isString:
+		if len(s.Allowed) > 0 && !contains(s.Allowed, str) {
+			report("value %q is not one of %s", str, strings.Join(s.Allowed, ", "))
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString(str) {
+			report("value %q does not match pattern %s", str, s.patternSource())
+		}
+		if s.Type == Int || s.Type == Float {
+			f, _ := strconv.ParseFloat(str, 64)
+			if s.Type == Int {
+				n, _ := strconv.ParseInt(str, 0, 64)
+				f = float64(n)
+			}
+			s.checkBounds(f, "value", report)
+		} else {
+			s.checkBounds(float64(len([]rune(str))), "length", report)
+		}
+	case isList:
+		s.checkBounds(float64(len(list)), "count of items", report)
+		if s.Items != nil {
+			for i, item := range list {
+				s.Items.validate(item, appendSegment(trail, nestext.PathSegment{Index: i, IsIndex: true}), violations)
+			}
+		}
+	case isDict:
+		s.checkBounds(float64(len(keys)), "count of keys", report)
+		for _, k := range sortedKeys(s.Keys) {
+			if _, ok := items[k]; !ok && s.Keys[k].Required {
+				report("missing required key %q", k)
+			}
+		}
+		for _, k := range keys {
+			if ks, ok := s.Keys[k]; ok {
+				ks.validate(items[k], appendSegment(trail, nestext.PathSegment{Key: k}), violations)
+			} else if s.Closed {
+				report("unknown key %q", k)
+			}
+		}
+	}
+}
+
+func (s *Schema) checkBounds(f float64, what string, report func(string, ...interface{})) {
+	if s.Min != nil && f < *s.Min {
+		report("%s %v is less than minimum %v", what, f, *s.Min)
+	}
+	if s.Max != nil && f > *s.Max {
+		report("%s %v is greater than maximum %v", what, f, *s.Max)
+	}
+}
+
+// patternSource returns the pattern as written in the schema, without the anchors
+// added by Parse.
+func (s *Schema) patternSource() string {
+	p := s.Pattern.String()
+	if strings.HasPrefix(p, "^(?:") && strings.HasSuffix(p, ")$") {
+		return p[4 : len(p)-2]
+	}
+	return p
+}
+
+// convert converts string `s` to the Go type for type `t`: int, float64, bool or
+// time.Time. Strings of other types are returned unchanged.
+func convert(s string, t Type) (interface{}, error) {
+	switch t {
+	case Int:
+		n, err := strconv.ParseInt(s, 0, 0)
+		return int(n), err
+	case Float:
+		return strconv.ParseFloat(s, 64)
+	case Bool:
+		return strconv.ParseBool(s)
+	case Date:
+		if d, err := time.Parse("2006-01-02", s); err == nil {
+			return d, nil
+		}
+		return time.Parse(time.RFC3339, s)
+	}
+	return s, nil
+}
+
+func kind(tree interface{}) string {
+	if _, _, ok := dictItems(tree); ok {
+		return "dict"
+	}
+	switch tree.(type) {
+	case []interface{}:
+		return "list"
+	case nil:
+		return "nothing"
+	}
+	return "string"
+}
+
+// dictItems returns the items of a dict and its keys, sorted for maps and in
+// insertion order for ordered maps.
+func dictItems(tree interface{}) (map[string]interface{}, []string, bool) {
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return t, keys, true
+	case *nestext.OrderedMap:
+		return t.Map(), t.Keys(), true
+	}
+	return nil, nil, false
+}
+
+func sortedKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, a := range list {
+		if a == s {
+			return true
+		}
+	}
+	return false
+}
+
+// appendSegment appends to a copy of `trail`, as trails are shared between branches.
+func appendSegment(trail []nestext.PathSegment, seg nestext.PathSegment) []nestext.PathSegment {
+	t := make([]nestext.PathSegment, len(trail), len(trail)+1)
+	copy(t, trail)
+	return append(t, seg)
+}
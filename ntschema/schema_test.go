@@ -0,0 +1,111 @@
+package ntschema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+const schemaInput = `type: dict
+closed: true
+keys:
+    name:
+        type: string
+        required: true
+        min: 2
+    port:
+        type: int
+        min: 1
+        max: 65535
+    mode:
+        allowed:
+            - fast
+            - safe
+    servers:
+        type: list
+        max: 2
+        items:
+            type: dict
+            keys:
+                host:
+                    type: string
+                    required: true
+                    pattern: [a-z.]+
+                debug:
+                    type: bool
+`
+
+func parse(t *testing.T, input string) interface{} {
+	tree, err := nestext.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := Parse(strings.NewReader(schemaInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := "name: app\nport: 0x1F90\nmode: safe\nservers:\n  -\n    host: a.example.com\n    debug: true\n"
+	if violations := schema.Validate(parse(t, valid)); len(violations) != 0 {
+		t.Errorf("expected document to be valid, have %v", violations)
+	}
+	invalid := "port: 70000\nmode: slow\nextra: x\nservers:\n  -\n    host: A\n    debug: maybe\n  -\n" +
+		"    - x\n  -\n    debug: false\n"
+	var messages []string
+	for _, v := range schema.Validate(parse(t, invalid)) {
+		messages = append(messages, v.String())
+	}
+	expected := []string{
+		`top-level value: missing required key "name"`,
+		`top-level value: unknown key "extra"`,
+		`mode: value "slow" is not one of fast, safe`,
+		`port: value 70000 is greater than maximum 65535`,
+		`servers: count of items 3 is greater than maximum 2`,
+		`servers[0].debug: expected bool, have "maybe"`,
+		`servers[0].host: value "A" does not match pattern [a-z.]+`,
+		`servers[1]: expected dict, have list`,
+		`servers[2]: missing required key "host"`,
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("expected\n%s\nhave\n%s", strings.Join(expected, "\n"), strings.Join(messages, "\n"))
+	}
+}
+
+func TestValidateOrderedDicts(t *testing.T) {
+	schema := &Schema{Type: Dict, Keys: map[string]*Schema{"a": {Type: Int}, "b": {Type: Int}}}
+	tree, err := nestext.Parse(strings.NewReader("b: x\na: y\n"), nestext.OrderedDicts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations := schema.Validate(tree)
+	if len(violations) != 2 || violations[0].Path != "b" || violations[1].Path != "a" {
+		t.Errorf("expected violations in document order, have %v", violations)
+	}
+	if violations := (&Schema{}).Validate(tree); len(violations) != 0 {
+		t.Errorf("expected zero schema to accept anything, have %v", violations)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	inputs := map[string]string{
+		"- type: string\n":                   `schema for "": schema must be a dict`,
+		"type: number\n":                     `schema for "": unknown type number`,
+		"keys:\n  a:\n    required: maybe\n": `schema for "a": required must be true or false`,
+		"items:\n  pattern: (\n":             `schema for "*": invalid pattern (`,
+		"min: low\n":                         `schema for "": min must be a number`,
+		"keys:\n  a:\n    allowed: x\n":      `schema for "a": allowed must be a list of strings`,
+		"keys:\n  a:\n    default: x\n":      `schema for "a": unknown schema item "default"`,
+	}
+	for input, msg := range inputs {
+		_, err := Parse(strings.NewReader(input))
+		nterr, ok := err.(nestext.NestedTextError)
+		if !ok || nterr.Code != nestext.ErrCodeUsage || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected error %q for %q, have %v", msg, input, err)
+		}
+	}
+}
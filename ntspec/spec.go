@@ -0,0 +1,86 @@
+// Package ntspec embeds examples of the NestedText language, as documented on
+// nestedtext.org, together with their expected results. It serves as a small
+// conformance check which is independent of the official test-suite and does not
+// need any files besides the Go module:
+//
+//     go test -run SpecExamples github.com/npillmayer/nestext/ntspec
+//
+// Applications may run the examples from their own tests as well, e.g. to confirm
+// that a vendored copy of the parser behaves as documented, with
+// nttest.RunSpecExamples.
+//
+// The examples follow the documentation of the NestedText language by Ken Kundert,
+// which is released under the MIT license. They are distributed under that license,
+// not under the license of this module; see examples/README.md for the copyright and
+// permission notice.
+//
+package ntspec
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/npillmayer/nestext"
+)
+
+//go:embed examples/*.nt examples/*.json
+var examples embed.FS
+
+// Example is a NestedText document together with its expected result.
+type Example struct {
+	Name     string      // name of the example, without file extension
+	Input    []byte      // the NestedText document
+	Expected interface{} // the expected tree, as decoded from JSON
+}
+
+// Examples returns the embedded examples, sorted by name.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func Examples() ([]Example, error) {
+	files, err := examples.ReadDir("examples")
+	if err != nil {
+		return nil, nestext.WrapError(nestext.ErrCodeIO, "cannot read embedded examples", err)
+	}
+	var list []Example
+	for _, f := range files {
+		if path.Ext(f.Name()) != ".nt" {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), ".nt")
+		ex := Example{Name: name}
+		if ex.Input, err = examples.ReadFile(path.Join("examples", f.Name())); err != nil {
+			return nil, nestext.WrapError(nestext.ErrCodeIO, "cannot read example "+name, err)
+		}
+		expected, err := examples.ReadFile(path.Join("examples", name+".json"))
+		if err != nil {
+			return nil, nestext.WrapError(nestext.ErrCodeIO, "cannot read expected result of example "+name, err)
+		}
+		if err = json.Unmarshal(expected, &ex.Expected); err != nil {
+			return nil, nestext.WrapError(nestext.ErrCodeSchema, "invalid expected result of example "+name, err)
+		}
+		list = append(list, ex)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Check parses the example with options `opts` and compares the result to the
+// expected tree with nestext.Equal, thus nestext.OrderedDicts is fine. Options which
+// change the shape of the result (e.g. nestext.TopLevel) will make the check fail.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func (ex Example) Check(opts ...nestext.Option) error {
+	tree, err := nestext.Parse(strings.NewReader(string(ex.Input)), opts...)
+	if err != nil {
+		return err
+	}
+	if !nestext.Equal(tree, ex.Expected) {
+		return nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+			fmt.Sprintf("example %s: expected %#v, have %#v", ex.Name, ex.Expected, tree))
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package ntspec
+
+import (
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+func TestSpecExamples(t *testing.T) {
+	list, err := Examples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ex := range list {
+		if err := ex.Check(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCheckMismatch(t *testing.T) {
+	list, err := Examples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) == 0 {
+		t.Fatal("expected embedded examples")
+	}
+	if err = list[0].Check(nestext.OrderedDicts()); err != nil {
+		t.Errorf("expected ordered dicts to pass the check, have %v", err)
+	}
+	err = list[0].Check(nestext.TopLevel("dict.wrapped"))
+	if nterr, ok := err.(nestext.NestedTextError); !ok || nterr.Code != nestext.ErrCodeSchema {
+		t.Errorf("expected wrapped result to fail the check, have %v", err)
+	}
+}
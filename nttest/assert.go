@@ -0,0 +1,197 @@
+// Package nttest provides helpers for tests checking parsed NestedText documents,
+// sparing them type assertions and reflect.DeepEqual on interface{} trees:
+//
+//     func TestConfig(t *testing.T) {
+//         config, err := nestext.Parse(reader)
+//         …
+//         nttest.AssertPathEquals(t, config, "server.port", "8080")
+//         nttest.AssertSubset(t, config, map[string]interface{}{
+//             "server": map[string]interface{}{"host": "localhost"},
+//         })
+//     }
+//
+// Dicts may be of type map[string]interface{} or *nestext.OrderedMap, on either side
+// of a comparison; the order of keys is not significant.
+//
+// RunSpecExamples checks the parser against the examples of the NestedText language
+// embedded in package ntspec.
+//
+package nttest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntspec"
+)
+
+// --- Assertions ------------------------------------------------------------
+
+// AssertSubset checks that tree `got` contains tree `want`: dicts of `got` have to
+// contain every key of the corresponding dict of `want`, with values containing the
+// value for the key in `want`. Lists have to be of the same length, with items
+// containing the items of `want`. All other values have to be equal. Every mismatch
+// is reported as an error of `t`, addressed by its path expression. The result tells
+// if `got` contains `want`.
+func AssertSubset(t testing.TB, got, want interface{}) bool {
+	t.Helper()
+	mismatches := compare(got, want, "", true)
+	for _, m := range mismatches {
+		t.Error(m)
+	}
+	return len(mismatches) == 0
+}
+
+// AssertPathEquals checks that the value at path expression `path` within `tree`
+// (see nestext.ParsePath) equals `want`. Dicts and lists have to be equal in full,
+// disregarding the order of keys. A missing value or a mismatch is reported as an
+// error of `t`. The result tells if the value equals `want`.
+func AssertPathEquals(t testing.TB, tree interface{}, path string, want interface{}) bool {
+	t.Helper()
+	got, err := nestext.Get(tree, path)
+	if err != nil {
+		t.Error(err)
+		return false
+	}
+	mismatches := compare(got, want, path, false)
+	for _, m := range mismatches {
+		t.Error(m)
+	}
+	return len(mismatches) == 0
+}
+
+// compare returns descriptions of the differences between `got` and `want`, found at
+// path `path`. If `subset` is set, dicts of `got` may have additional keys.
+func compare(got, want interface{}, path string, subset bool) []string {
+	if wantDict, ok := dictOf(want); ok {
+		gotDict, ok := dictOf(got)
+		if !ok {
+			return []string{mismatch(path, "expected dict, have %s", describe(got))}
+		}
+		var mismatches []string
+		for _, k := range keysOf(want) {
+			p := join(path, nestext.PathSegment{Key: k})
+			v, ok := gotDict[k]
+			if !ok {
+				mismatches = append(mismatches, mismatch(p, "missing key"))
+				continue
+			}
+			mismatches = append(mismatches, compare(v, wantDict[k], p, subset)...)
+		}
+		if !subset {
+			for _, k := range keysOf(got) {
+				if _, ok := wantDict[k]; !ok {
+					mismatches = append(mismatches, mismatch(join(path, nestext.PathSegment{Key: k}), "unexpected key"))
+				}
+			}
+		}
+		return mismatches
+	}
+	if wantList, ok := want.([]interface{}); ok {
+		gotList, ok := got.([]interface{})
+		if !ok {
+			return []string{mismatch(path, "expected list, have %s", describe(got))}
+		}
+		if len(gotList) != len(wantList) {
+			return []string{mismatch(path, "expected %d items, have %d", len(wantList), len(gotList))}
+		}
+		var mismatches []string
+		for i := range wantList {
+			p := join(path, nestext.PathSegment{Index: i, IsIndex: true})
+			mismatches = append(mismatches, compare(gotList[i], wantList[i], p, subset)...)
+		}
+		return mismatches
+	}
+	if !reflect.DeepEqual(got, want) {
+		return []string{mismatch(path, "expected %s, have %s", describe(want), describe(got))}
+	}
+	return nil
+}
+
+func mismatch(path string, format string, args ...interface{}) string {
+	if path == "" {
+		path = "top-level value"
+	}
+	return path + ": " + fmt.Sprintf(format, args...)
+}
+
+// describe returns a short description of a value for messages.
+func describe(v interface{}) string {
+	if _, ok := dictOf(v); ok {
+		return "dict"
+	}
+	switch t := v.(type) {
+	case []interface{}:
+		return "list"
+	case string:
+		return strconv.Quote(t)
+	case nil:
+		return "nothing"
+	}
+	return fmt.Sprintf("%v (%T)", v, v)
+}
+
+func join(path string, seg nestext.PathSegment) string {
+	if path == "" {
+		return nestext.JoinPath([]nestext.PathSegment{seg})
+	}
+	if seg.IsIndex {
+		return path + seg.String()
+	}
+	return path + "." + seg.String()
+}
+
+func dictOf(tree interface{}) (map[string]interface{}, bool) {
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		return t, true
+	case *nestext.OrderedMap:
+		return t.Map(), true
+	}
+	return nil, false
+}
+
+// keysOf returns the keys of a dict, sorted for maps and in insertion order for
+// ordered maps.
+func keysOf(tree interface{}) []string {
+	if m, ok := tree.(*nestext.OrderedMap); ok {
+		return m.Keys()
+	}
+	d, _ := dictOf(tree)
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// --- Language examples -----------------------------------------------------
+
+// RunSpecExamples checks every example of package ntspec in a sub-test of `t`,
+// parsing the examples with options `opts`.
+//
+// Use as:
+//     func TestSpecExamples(t *testing.T) {
+//         nttest.RunSpecExamples(t)
+//     }
+//
+func RunSpecExamples(t *testing.T, opts ...nestext.Option) {
+	t.Helper()
+	list, err := ntspec.Examples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ex := range list {
+		ex := ex
+		t.Run(ex.Name, func(t *testing.T) {
+			if err := ex.Check(opts...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
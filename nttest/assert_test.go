@@ -0,0 +1,87 @@
+package nttest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/npillmayer/nestext"
+)
+
+// recorder collects the errors reported by assertions.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+const input = `server:
+  host: localhost
+  port: 8080
+  aliases:
+    - a
+    - b
+name: app
+`
+
+func parse(t *testing.T, opts ...nestext.Option) interface{} {
+	tree, err := nestext.Parse(strings.NewReader(input), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestAssertSubset(t *testing.T) {
+	tree := parse(t, nestext.OrderedDicts())
+	want := map[string]interface{}{
+		"server": map[string]interface{}{"port": "8080", "aliases": []interface{}{"a", "b"}},
+	}
+	if !AssertSubset(t, tree, want) {
+		t.Error("expected subset to match")
+	}
+	r := &recorder{}
+	want = map[string]interface{}{
+		"server": map[string]interface{}{"port": "80", "tls": "on", "aliases": []interface{}{"a"}},
+		"name":   []interface{}{"app"},
+	}
+	if AssertSubset(r, tree, want) {
+		t.Error("expected subset not to match")
+	}
+	expected := []string{
+		`name: expected list, have "app"`,
+		`server.aliases: expected 1 items, have 2`,
+		`server.port: expected "80", have "8080"`,
+		`server.tls: missing key`,
+	}
+	if !reflect.DeepEqual(r.errors, expected) {
+		t.Errorf("expected\n%s\nhave\n%s", strings.Join(expected, "\n"), strings.Join(r.errors, "\n"))
+	}
+}
+
+func TestAssertPathEquals(t *testing.T) {
+	tree := parse(t)
+	AssertPathEquals(t, tree, "server.aliases[1]", "b")
+	AssertPathEquals(t, tree, "server.aliases", []interface{}{"a", "b"})
+	r := &recorder{}
+	AssertPathEquals(r, tree, "server", map[string]interface{}{"host": "localhost", "port": 8080})
+	AssertPathEquals(r, tree, "server.user", "x")
+	expected := []string{
+		`server.port: expected 8080 (int), have "8080"`,
+		`server.aliases: unexpected key`,
+		`[0,0] path "server.user": no such item`,
+	}
+	if !reflect.DeepEqual(r.errors, expected) {
+		t.Errorf("expected\n%s\nhave\n%s", strings.Join(expected, "\n"), strings.Join(r.errors, "\n"))
+	}
+}
+
+func TestRunSpecExamples(t *testing.T) {
+	RunSpecExamples(t, nestext.OrderedDicts())
+}
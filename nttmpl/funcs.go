@@ -0,0 +1,83 @@
+// Package nttmpl provides template functions for creating NestedText documents with
+// package text/template. Plain templates like
+//
+//     name: {{.Name}}
+//
+// produce broken or even manipulated documents for values containing line breaks or
+// characters with special meaning to NestedText, e.g. a name of "x\nadmin: true".
+// The functions of this package check keys and values and lay out values which may
+// not be given on a single line:
+//
+//   - ntKey returns a dict key for a "key: value" line, failing for keys which
+//     would have to be written as a multi-line key.
+//   - ntValue returns a value following a dict key or a list tag on the same line,
+//     failing for values containing line breaks.
+//   - ntBlock lays out any value as a nested value, indented by a given number of
+//     spaces. The result starts with a line break and has to follow a dict key or a
+//     list tag ("-") immediately.
+//
+// Use as:
+//     tmpl := template.Must(template.New("config").Funcs(nttmpl.Funcs()).Parse(
+//         "{{ntKey .Key}}: {{ntValue .Value}}\n" +
+//         "description:{{ntBlock 2 .Description}}\n"))
+//     err := tmpl.Execute(w, data)
+//
+// Template execution fails with an error of type nestext.NestedTextError wrapped by
+// package text/template if a value cannot be represented as requested.
+//
+package nttmpl
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/npillmayer/nestext"
+	"github.com/npillmayer/nestext/ntenc"
+)
+
+// Funcs returns the template functions of this package, to be added to templates
+// with template.Funcs.
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"ntKey":   Key,
+		"ntValue": Value,
+		"ntBlock": Block,
+	}
+}
+
+// Key returns `key` if it may be written as the key of a "key: value" line.
+// Otherwise it returns an error of code ErrCodeSchema.
+func Key(key interface{}) (string, error) {
+	k := fmt.Sprint(key)
+	if nestext.NeedsMultilineKey(k) {
+		return "", nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+			fmt.Sprintf("key %q has to be written as a multi-line key", k))
+	}
+	return k, nil
+}
+
+// Value returns the string representation of `value` if it may be written on the line
+// of a dict key or list tag. Otherwise it returns an error of code ErrCodeSchema.
+func Value(value interface{}) (string, error) {
+	v := fmt.Sprint(value)
+	if nestext.NeedsMultiline(v) {
+		return "", nestext.MakeNestedTextError(nestext.ErrCodeSchema,
+			fmt.Sprintf("value %q has to be written as a multi-line string", v))
+	}
+	return v, nil
+}
+
+// Block encodes `value` with ntenc.Encode, indenting all lines by `indent` spaces.
+// The result starts with a line break, but does not end with one.
+//
+// If a non-nil error is returned, it will be of type nestext.NestedTextError.
+func Block(indent int, value interface{}) (string, error) {
+	var b strings.Builder
+	if _, err := ntenc.Encode(value, &b); err != nil {
+		return "", err
+	}
+	prefix := "\n" + strings.Repeat(" ", indent)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	return prefix + strings.Join(lines, prefix), nil
+}
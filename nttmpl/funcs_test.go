@@ -0,0 +1,56 @@
+package nttmpl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/npillmayer/nestext"
+)
+
+const configTemplate = `{{ntKey .Key}}: {{ntValue .Value}}
+description:{{ntBlock 2 .Description}}
+hosts:
+{{- range .Hosts}}
+  -{{ntBlock 4 .}}
+{{- end}}
+`
+
+func TestTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("config").Funcs(Funcs()).Parse(configTemplate))
+	data := map[string]interface{}{
+		"Key":         "name",
+		"Value":       "x: y",
+		"Description": "first line\nadmin: true",
+		"Hosts":       []interface{}{"alpha", map[string]interface{}{"name": "beta"}},
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := nestext.Parse(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	expected := map[string]interface{}{
+		"name":        "x: y",
+		"description": "first line\nadmin: true",
+		"hosts":       []interface{}{"alpha", map[string]interface{}{"name": "beta"}},
+	}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("expected %#v, have %#v\n%s", expected, tree, out.String())
+	}
+}
+
+func TestTemplateRejects(t *testing.T) {
+	tmpl := template.Must(template.New("config").Funcs(Funcs()).Parse(configTemplate))
+	for _, data := range []map[string]interface{}{
+		{"Key": "- a", "Value": "v"},
+		{"Key": "k", "Value": "x\nadmin: true"},
+	} {
+		if err := tmpl.Execute(&strings.Builder{}, data); err == nil {
+			t.Errorf("expected %v to be rejected", data)
+		}
+	}
+}
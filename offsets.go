@@ -0,0 +1,91 @@
+package nestext
+
+import "unicode/utf8"
+
+// --- Byte offsets of errors ------------------------------------------------
+
+// lineText is a line of input as seen by the scanner, together with its byte offsets.
+type lineText struct {
+	line  int    // line number
+	start int64  // byte offset of the line
+	next  int64  // byte offset of the following line
+	text  string // text of the line, without line terminator
+}
+
+// lineWindow holds the most recent item lines read, plus the line read last, if it is
+// a blank line or a comment line. Errors are reported while their lines are still in
+// the window, or are located when they are recorded (see record); thus memory does not
+// grow with the size of the input.
+type lineWindow [8]lineText
+
+// locate sets the byte offsets of error `e` from its line and column, and from its end
+// line and end column, if present. `dedent` is the amount of indentation added to the
+// columns of `e` for option AutoDedent. Offsets located already are left unchanged, as
+// are offsets of lines no longer held by the line window.
+func (buf *lineBuffer) locate(e NestedTextError, dedent int) NestedTextError {
+	if e.Line < 1 {
+		return e
+	}
+	if off, ok := buf.offset(e.Line, e.Column-dedent); ok && e.Offset == 0 {
+		e.Offset = off
+	}
+	if off, ok := buf.offset(e.EndLine, e.EndColumn-dedent); ok && e.EndLine > 0 && e.EndOffset == 0 {
+		e.EndOffset = off
+	}
+	return e
+}
+
+// offset returns the byte offset of column `col` of line number `line`, counting
+// characters. Columns beyond the end of the line are located at its end, lines beyond
+// the lines read at the end of the input. Column 0 of a line may be located by the line
+// preceding it.
+func (buf *lineBuffer) offset(line, col int) (int64, bool) {
+	if line > buf.Meta.Lines {
+		return buf.Consumed, true
+	}
+	for _, l := range buf.recent {
+		if l.line == line {
+			i := 0
+			for ; col > 0 && i < len(l.text); col-- {
+				_, n := utf8.DecodeRuneInString(l.text[i:])
+				i += n
+			}
+			return l.start + int64(i), true
+		} else if l.line > 0 && l.line == line-1 && col <= 0 {
+			return l.next, true
+		}
+	}
+	return 0, false
+}
+
+// record collects error `e` for option ContinueOnError. Its byte offsets are set at once,
+// as the line window will have moved on when the errors are returned.
+func (p *nestedTextParser) record(e NestedTextError) {
+	p.errors = append(p.errors, p.sc.Buf.locate(e, 0))
+}
+
+// finish completes error or warning `e` before it is reported, setting its byte offsets
+// and formatting its message.
+func (p *nestedTextParser) finish(e NestedTextError, dedent int) NestedTextError {
+	if p.sc != nil {
+		e = p.sc.Buf.locate(e, dedent)
+	}
+	return p.formatMessage(e)
+}
+
+// finishError completes `err` before it is returned by the parser, if it is
+// a NestedTextError or an ErrorList. Columns of returned errors include the indentation
+// removed by option AutoDedent.
+func (p *nestedTextParser) finishError(err error) error {
+	switch e := err.(type) {
+	case NestedTextError:
+		return p.finish(e, p.dedent)
+	case ErrorList:
+		errs := make(ErrorList, len(e))
+		for i := range e {
+			errs[i] = p.finish(e[i], p.dedent)
+		}
+		return errs
+	}
+	return err
+}
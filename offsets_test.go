@@ -0,0 +1,107 @@
+package nestext
+
+import (
+	"strings"
+	"testing"
+)
+
+// offsetOf returns the byte offset of column `col` of line number `line` of `input`,
+// counting characters.
+func offsetOf(input string, line, col int) int64 {
+	lines := splitLinesKeepEOL([]byte(input))
+	offset := 0
+	for i := 0; i < line-1 && i < len(lines); i++ {
+		offset += len(lines[i])
+	}
+	if line > len(lines) {
+		return int64(offset)
+	}
+	return int64(offset + len(string([]rune(string(lines[line-1]))[:col])))
+}
+
+func TestErrorOffsets(t *testing.T) {
+	inputs := []struct {
+		text         string
+		line, column int
+	}{
+		{"a: 1\n  b: 2\n", 2, 2},
+		{"a: 1\r\n  b: 2\r\n", 2, 2},
+		{"a: 1\rb:\r  {x: [}\r", 3, 7},
+		{"\u00e4: 1\n\u00f6:\n  [\u00fc, x]]\n", 3, 8},
+		{"a:\n  - x\n  y\n", 3, 1},
+		{"[a, b\n", 1, 4},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text))
+		eerr := ParseEvents(strings.NewReader(input.text), &Handler{})
+		for _, err := range []error{err, eerr} {
+			nterr, ok := err.(NestedTextError)
+			if !ok || nterr.Line != input.line || nterr.Column != input.column {
+				t.Errorf("%d: expected error at [%d,%d], have %v", i, input.line, input.column, err)
+				continue
+			}
+			if offset := offsetOf(input.text, input.line, input.column); nterr.Offset != offset {
+				t.Errorf("%d: expected error at offset %d, have %d", i, offset, nterr.Offset)
+			}
+		}
+	}
+}
+
+func TestErrorOffsetsSpan(t *testing.T) {
+	input := "a: 1\nb: 2\n- x\n# c\n  - y\nz\n# end\n"
+	_, err := Parse(strings.NewReader(input))
+	nterr, ok := err.(NestedTextError)
+	if !ok || nterr.Offset != 10 || nterr.EndOffset != int64(strings.Index(input, "# end")) {
+		t.Errorf("expected error spanning bytes 10-%d, have %#v", strings.Index(input, "# end"), err)
+	}
+	input = "\ufeffa: 1\nb: x\u202ey\n"
+	var warnings []NestedTextError
+	_, err = Parse(strings.NewReader(input), ReportWarnings(func(w NestedTextError) { warnings = append(warnings, w) }))
+	if err != nil || len(warnings) != 1 || warnings[0].Offset != int64(strings.Index(input, "\u202e")) {
+		t.Errorf("expected warning at offset %d, have %v, %#v", strings.Index(input, "\u202e"), err, warnings)
+	}
+	_, err = Parse(strings.NewReader("a: \x07\nb: 2\nc: \x07\n"), OnControlChars(ControlReject), ContinueOnError())
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 2 || errs[0].Offset != 3 || errs[1].Offset != 13 {
+		t.Errorf("expected errors at offsets 3 and 13, have %#v", err)
+	}
+	long := "a: \x07\n" + strings.Repeat("b: 2\n", 100) + "c: \x07\n"
+	_, err = Parse(strings.NewReader(long), OnControlChars(ControlReject), ContinueOnError())
+	errs, ok = err.(ErrorList)
+	if !ok || len(errs) != 2 || errs[0].Offset != 3 || errs[1].Offset != int64(len(long)-2) {
+		t.Errorf("expected errors at offsets 3 and %d of a long input, have %#v", len(long)-2, err)
+	}
+	_, err = Parse(strings.NewReader("  a: 1\n    b: 2\n"), AutoDedent())
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Column != 4 || nterr.Offset != 7 {
+		t.Errorf("expected error at column 4 and offset 7 of the dedented input, have %#v", err)
+	}
+}
+
+func TestTokenizerOffsets(t *testing.T) {
+	input := "# c\r\na: 1\r\n\r\n  - x\r\n  y\n[z]"
+	tz := NewTokenizer(strings.NewReader(input))
+	type span struct{ offset, end int64 }
+	var spans []span
+	for {
+		token, err := tz.Next()
+		if err != nil {
+			if nterr, ok := err.(NestedTextError); !ok || nterr.Line != 5 || nterr.Offset != 21 {
+				t.Errorf("expected error at line 5 and offset 21, have %#v", err)
+			}
+			continue
+		}
+		if token.Type == TokenEOF {
+			break
+		}
+		spans = append(spans, span{token.Offset, token.EndOffset})
+	}
+	expected := []span{{5, 9}, {13, 18}, {24, 27}}
+	if len(spans) != len(expected) {
+		t.Fatalf("expected %v, have %v", expected, spans)
+	}
+	for i, s := range spans {
+		if s != expected[i] {
+			t.Errorf("expected token %d at %v, have %v", i, expected[i], s)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package nestext
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// --- Ordered dicts ---------------------------------------------------------
+
+// OrderedMap is a dict which remembers the order in which keys have been inserted.
+// The parser creates ordered maps instead of map[string]interface{} if option
+// OrderedDicts is set; encoding with ntenc.Encode keeps the order of keys.
+//
+// The zero value is an empty map ready to use.
+//
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewOrderedMap creates an empty ordered map.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]interface{})}
+}
+
+// Len returns the number of entries of m.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys of m in insertion order. The slice must not be modified.
+func (m *OrderedMap) Keys() []string {
+	return m.keys
+}
+
+// Get returns the value for key and a flag signalling if key is present.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set sets the value for key. New keys are appended to the keys of m, while
+// existing keys keep their position.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes key from m, if present.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Map returns the entries of m as an unordered map. The map is shared with m and
+// must not be modified.
+func (m *OrderedMap) Map() map[string]interface{} {
+	if m.values == nil {
+		return map[string]interface{}{}
+	}
+	return m.values
+}
+
+// String formats m like fmt formats maps, but with keys in insertion order.
+func (m *OrderedMap) String() string {
+	var b strings.Builder
+	b.WriteString("map[")
+	for i, k := range m.keys {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%s:%v", k, m.values[k])
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// MarshalJSON encodes m as a JSON object with keys in insertion order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// OrderedDicts requests the parser to return dicts as *OrderedMap instead of
+// map[string]interface{}, keeping the order of keys in the input. For duplicate keys,
+// the last value is kept at the position of the first occurence.
+//
+// Use as:
+//     result, err := nestext.Parse(reader, nestext.OrderedDicts())
+//     …
+//     for _, key := range result.(*nestext.OrderedMap).Keys() {
+//         …
+//     }
+//
+func OrderedDicts() Option {
+	return func(p *nestedTextParser) (err error) {
+		p.ordered, p.inline.ordered = true, true
+		return nil
+	}
+}
+
+// asDict returns the entries of a dict, which may be either a map or an ordered map.
+func asDict(tree interface{}) (map[string]interface{}, bool) {
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		return t, true
+	case *OrderedMap:
+		return t.Map(), true
+	}
+	return nil, false
+}
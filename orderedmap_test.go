@@ -0,0 +1,69 @@
+package nestext
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOrderedDicts(t *testing.T) {
+	input := "zeta: 1\nalpha:\n  {y: 2, x: 3}\nzeta: 4\nmid:\n  - [a]\n"
+	result, err := Parse(strings.NewReader(input), OrderedDicts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := result.(*OrderedMap)
+	if !ok {
+		t.Fatalf("expected result to be an ordered map, is %T", result)
+	}
+	if !reflect.DeepEqual(m.Keys(), []string{"zeta", "alpha", "mid"}) {
+		t.Errorf("unexpected order of keys %v", m.Keys())
+	}
+	if v, _ := m.Get("zeta"); v != "4" {
+		t.Errorf("expected last value of duplicate key, have %v", v)
+	}
+	inline, _ := m.Get("alpha")
+	if om, ok := inline.(*OrderedMap); !ok || !reflect.DeepEqual(om.Keys(), []string{"y", "x"}) {
+		t.Errorf("expected inline dict to be ordered, have %v", inline)
+	}
+	if s := m.String(); s != "map[zeta:4 alpha:map[y:2 x:3] mid:[[a]]]" {
+		t.Errorf("unexpected string representation %s", s)
+	}
+	b, err := json.Marshal(m)
+	if err != nil || string(b) != `{"zeta":"4","alpha":{"y":"2","x":"3"},"mid":["[a]"]}` {
+		t.Errorf("unexpected JSON %s, %v", b, err)
+	}
+	result, err = Parse(strings.NewReader("- a\n"), OrderedDicts(), TopLevel("dict.config"))
+	if m, ok := result.(*OrderedMap); !ok || m.Len() != 1 || err != nil {
+		t.Errorf("expected wrapped result to be an ordered map, have %#v, %v", result, err)
+	}
+}
+
+func TestOrderedMap(t *testing.T) {
+	var m OrderedMap
+	m.Set("a", "1")
+	m.Set("b", "2")
+	m.Set("c", "3")
+	m.Set("a", "4")
+	m.Delete("b")
+	m.Delete("x")
+	if !reflect.DeepEqual(m.Keys(), []string{"a", "c"}) || m.Len() != 2 {
+		t.Errorf("unexpected keys %v", m.Keys())
+	}
+	if !reflect.DeepEqual(m.Map(), map[string]interface{}{"a": "4", "c": "3"}) {
+		t.Errorf("unexpected entries %v", m.Map())
+	}
+}
+
+func TestDecodeOrderedDicts(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("name: x\nphone:\n  cell: 1\n"))
+	dec.SetOptions(OrderedDicts())
+	var person testPerson
+	if err := dec.Decode(&person); err != nil {
+		t.Fatal(err)
+	}
+	if person.Name != "x" || person.Phones["cell"] != "1" {
+		t.Errorf("unexpected result %+v", person)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // === Top-level API =========================================================
@@ -13,7 +15,10 @@ import (
 // Values are stored as strings, []interface{} or map[string]interface{} respectively.
 // The concrete resulting top-level type depends on the top-level NestedText input type.
 //
-// If a non-nil error is returned, it will be of type NestedTextError.
+// Parse does not close r, even if it implements io.Closer (see ParseAndClose).
+//
+// If a non-nil error is returned, it will be of type NestedTextError, or of type
+// ErrorList with option ContinueOnError.
 //
 func Parse(r io.Reader, opts ...Option) (interface{}, error) {
 	p := newParser()
@@ -25,6 +30,23 @@ func Parse(r io.Reader, opts ...Option) (interface{}, error) {
 	return p.Parse(r)
 }
 
+// ParseSnippet parses a fragment of a NestedText document, as pasted into REPLs, tests
+// or documentation examples. Snippets may be indented uniformly (see option AutoDedent),
+// may lack a final line break and may consist of a single inline list or dict.
+// Options `opts` are applied in addition to AutoDedent.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+//
+// Use as:
+//     config, err := nestext.ParseSnippet(`
+//         server:
+//           port: 8080`)
+//
+func ParseSnippet(s string, opts ...Option) (interface{}, error) {
+	opts = append([]Option{AutoDedent()}, opts...)
+	return Parse(strings.NewReader(s), opts...)
+}
+
 // --- Parser options --------------------------------------------------------
 
 // Option is a type to influence the behaviour of the parsing process.
@@ -72,15 +94,124 @@ func TopLevel(top string) Option {
 	}
 }
 
-// KeepLegacyBidi requests the parser to keep Unicode LTR and RTL markers.
+// ProgressFn is a callback type for reporting progress while reading the input.
+// It receives the number of bytes and the number of lines consumed so far.
+type ProgressFn func(bytes int64, lines int)
+
+// ReportProgress installs a callback which will be called repeatedly while the
+// parser proceeds through the input, reporting bytes and lines consumed so far.
+// This is intended for displaying progress bars when loading large documents.
+// The callback is called synchronously, so it should return quickly.
+//
+// Use as:
+//     nestext.Parse(reader, nestext.ReportProgress(func(bytes int64, lines int) {
+//         bar.Set(bytes)
+//     }))
+//
+func ReportProgress(f ProgressFn) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.progress = f
+		return nil
+	}
+}
+
+// RequireFinalNewline requests the parser to reject documents whose last line is not
+// terminated by a line break, with an error of code ErrCodeFormatNoFinalNewline.
+// Empty documents are accepted. This avoids spurious diffs from tools which differ in
+// their handling of final line breaks.
+//
+func RequireFinalNewline() Option {
+	return func(p *nestedTextParser) (err error) {
+		p.finalNewline = true
+		return nil
+	}
+}
+
+// RequireUniformNewlines requests the parser to reject documents which employ more
+// than one kind of line terminator, i.e. mix CR LF, CR and LF. The error is of code
+// ErrCodeFormatMixedNewlines and is located at the first line terminated differently
+// from the first line. The spec allows mixing line terminators, but tools converting
+// line endings, e.g. on checkout, may render such documents unstable.
 //
-// Attention: This option is not yet functional!
-func KeepLegacyBidi(keep bool) Option {
-	// Default behaviour should be to strip LTR and RTL legacy control characters.
-	// For security reasons applications should usually treat LTR/RTL cautiously when read
-	// in from external sources. You can find various sources on the internet discussion
-	// this problem, including a policy in place at GitHub.
+func RequireUniformNewlines() Option {
 	return func(p *nestedTextParser) (err error) {
+		p.uniformEOL = true
+		return nil
+	}
+}
+
+// WarningFn is a callback type for reporting warnings, i.e. findings which do not
+// prevent a document from being parsed. Warnings are of type NestedTextError,
+// carrying a code and the position of the finding.
+type WarningFn func(w NestedTextError)
+
+// ReportWarnings installs a callback which will be called for every warning issued
+// while parsing. Without this option, warnings are dropped silently.
+//
+// Use as:
+//     nestext.Parse(reader, nestext.AutoDedent(), nestext.ReportWarnings(func(w nestext.NestedTextError) {
+//         log.Printf("warning: %v", w)
+//     }))
+//
+func ReportWarnings(f WarningFn) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.warn = f
+		return nil
+	}
+}
+
+// KeepBlankLinesInStrings requests the parser to keep blank lines between the lines of
+// a multi-line string as empty lines of the string. The spec ignores blank lines
+// everywhere, requiring empty lines of strings to be written as a lone ">" tag;
+// hand-edited documents, however, often contain strings like
+//
+//     description:
+//         > First paragraph.
+//
+//         > Second paragraph.
+//
+// where the blank line is meant to be part of the string. Blank lines before or after
+// a string, as well as comment lines, are still ignored.
+//
+// This option is off by default, as it deviates from the spec.
+//
+func KeepBlankLinesInStrings() Option {
+	return func(p *nestedTextParser) (err error) {
+		p.keepBlanks = true
+		return nil
+	}
+}
+
+// NoMixedLists requests the parser to check that all items of a list are of the
+// same type, i.e., either all strings, all lists or all dicts. Lists mixing types
+// are often the result of an indentation mistake while hand-editing a document.
+// An offending list will result in an error of code ErrCodeSchema, reporting the
+// line numbers of all items which differ in type from the first item of the list.
+//
+// This check is off by default, as mixed lists are perfectly valid NestedText.
+//
+func NoMixedLists() Option {
+	return func(p *nestedTextParser) (err error) {
+		p.noMixedLists = true
+		return nil
+	}
+}
+
+// MaxDepth limits the nesting of lists and dicts, with the top-level list or dict
+// being on level 1. This includes inline lists and dicts. Documents nesting deeper
+// result in an error of code ErrCodeTooDeep, positioned at the offending item.
+// Applications parsing untrusted input should set a limit, as the parser recurses for
+// every level of nesting. A depth of 0 (the default) means no limit.
+//
+// Use as:
+//     nestext.Parse(reader, nestext.MaxDepth(32))
+//
+func MaxDepth(depth int) Option {
+	return func(p *nestedTextParser) (err error) {
+		if depth < 0 {
+			return MakeNestedTextError(ErrCodeUsage, "option MaxDepth requires a depth >= 0")
+		}
+		p.maxDepth, p.inline.maxDepth = depth, depth
 		return nil
 	}
 }
@@ -90,11 +221,55 @@ func KeepLegacyBidi(keep bool) Option {
 // nestedTextParser is a recursive-descend parser working on a grammar on input lines.
 // The scanner is expected to return line by line wrapped into `parserToken`.
 type nestedTextParser struct {
-	sc       *scanner          // line level scanner
-	token    *parserToken      // the current token from the scanner
-	inline   *inlineItemParser // sub-parser for inline lists/dicts
-	toplevel string            // type of top-level item
-	stack    pstack            // parser stack
+	sc           *scanner          // line level scanner
+	token        *parserToken      // the current token from the scanner
+	inline       *inlineItemParser // sub-parser for inline lists/dicts
+	toplevel     string            // type of top-level item
+	stack        pstack            // parser stack
+	progress     ProgressFn        // progress callback, may be nil
+	noMixedLists bool              // flag lists mixing strings, lists and dicts
+	noDuplicates bool              // warn about duplicate list items
+	finalNewline bool              // require the last line to be terminated
+	uniformEOL   bool              // require a single kind of line terminator
+	meta         *Metadata         // if set, receives document metadata
+	indentWidth  int               // indentation of the first nested value, relative to its item
+	comments     *Comments         // if set, receives the comments of the document
+	layout       *Layout           // if set, receives the layout of the document
+	onSkipped    SkippedLineFn     // callback for comment lines and blank lines, may be nil
+	commentLines []sourceComment   // comment lines of the input, if comments are captured
+	anchors      []commentAnchor   // lines of items, if comments are captured
+	path         string            // path of the current container, if comments are captured
+	warn         WarningFn         // warning callback, may be nil
+	autoDedent   bool              // remove common indentation of the input
+	dedent       int               // amount of indentation removed
+	removed      int64             // count of bytes removed by dedenting
+	ordered      bool              // create dicts as *OrderedMap
+	positions    *Positions        // if set, receives the positions of items
+	lineSpans    []lineSpan        // extents of input lines, if positions are reported
+	selectors    [][]PathSegment   // if set, paths of items to select
+	trail        []PathSegment     // path of the current item, if items are selected
+	selectAll    int               // nesting depth within a selected item
+	nestedLine   int               // line of the last nested value, if positions are reported
+	nestedIndent int               // indentation of the last nested value
+	keepBlanks   bool              // keep blank lines within multi-line strings
+	strict       bool              // reject input the spec frowns upon
+	lenient      bool              // accept and normalize input the spec frowns upon, with warnings
+	tabStop      int               // distance of tab stops for expanding tabs, 0 for the default
+	keepBidi     bool              // keep bidi control characters
+	controls     ControlPolicy     // policy for control characters
+	maxDepth     int               // maximum nesting of lists and dicts, 0 for no limit
+	limits       inputLimits       // limits for the size of the input
+	items        int               // count of items parsed, if items are limited
+	validKey     func(string) bool // if set, predicate for dict keys
+	keyRule      string            // description of the rule of validKey, for messages
+	required     []string          // paths of items the document has to contain
+	collect      bool              // record format errors and continue parsing
+	resumeTop    bool              // continue at the next top-level item after errors
+	errors       []NestedTextError // format errors recorded so far
+	formatter    MessageFormatter  // if set, formats the messages of errors and warnings
+	validators   []pathValidator   // functions checking values at paths
+	segments     []PathSegment     // path of the current container, if values are validated
+	fieldKeys    *FieldKeys        // maps Go field names to dict keys, for decoding
 	//stack    []parserStackEntry // result stack
 }
 
@@ -107,17 +282,102 @@ func newParser() *nestedTextParser {
 }
 
 func (p *nestedTextParser) Parse(r io.Reader) (result interface{}, err error) {
-	p.sc, err = newScanner(r)
-	if err != nil {
+	defer func() { err = p.finishError(err) }()
+	if r, err = p.dedentInput(p.limit(r)); err != nil {
+		return
+	}
+	if p.layout != nil {
+		*p.layout = Layout{KeyPadding: make(map[string]string)}
+	}
+	if p.positions != nil {
+		*p.positions = make(Positions)
+	}
+	if p.sc, err = newScannerWithHooks(r, p.hooks(), p.limits.lineLength); err != nil {
 		return
 	}
+	p.sc.Progress = p.progress
+	if p.controls == ControlReject {
+		p.sc.Check = checkControls
+	}
+	if p.collect {
+		p.sc.Recover = p.skipLine
+	}
 	result, err = p.parseDocument()
+	if p.collect && recoverable(err) {
+		p.record(err.(NestedTextError))
+		err = nil
+	}
+	if err == nil {
+		for _, nterr := range p.lineBreakErrors() {
+			if !p.collect {
+				return nil, nterr
+			}
+			p.record(nterr)
+		}
+	}
+	if p.collect && err != nil {
+		return nil, p.collectedErrors(err)
+	}
+	if err == nil && p.required != nil {
+		if err = p.checkRequired(result); err != nil {
+			if p.collect {
+				return nil, p.collectedErrors(err)
+			}
+			return nil, err
+		}
+	}
 	if err == nil {
 		result = p.wrapResult(result)
+		if p.meta != nil {
+			p.reportMetadata()
+		}
+		if p.comments != nil {
+			p.attachComments()
+		}
+		if p.collect {
+			err = p.collectedErrors(nil)
+		}
+	} else {
+		err = p.originalColumns(err)
 	}
 	return
 }
 
+// lineBreakErrors checks the line terminators of the input, after it has been read
+// completely. Lone CRs are reported as a warning by a lenient parser.
+func (p *nestedTextParser) lineBreakErrors() (errs []NestedTextError) {
+	if p.finalNewline && p.sc.Buf.Meta.Lines > 0 && !p.sc.Buf.Meta.FinalNewline {
+		nterr := MakeNestedTextError(ErrCodeFormatNoFinalNewline, "last line is not terminated by a line break")
+		nterr.Line = p.sc.Buf.Meta.Lines
+		errs = append(errs, nterr)
+	}
+	if p.uniformEOL && p.sc.Buf.Mixed > 0 {
+		nterr := MakeNestedTextError(ErrCodeFormatMixedNewlines, fmt.Sprintf(
+			"line terminated by %s, while the first line is terminated by %s",
+			newlineNames[p.sc.Buf.MixedEOL], newlineNames[p.sc.Buf.Meta.Newline]))
+		nterr.Line, nterr.Offset = p.sc.Buf.Mixed, p.sc.Buf.MixedStart
+		errs = append(errs, nterr)
+	}
+	if p.sc.Buf.LoneCR > 0 && (p.strict || p.lenient) {
+		nterr := MakeNestedTextError(ErrCodeFormat, "line terminated by a lone CR")
+		nterr.Line, nterr.Offset = p.sc.Buf.LoneCR, p.sc.Buf.LoneCRStart
+		if p.lenient {
+			p.warning(nterr)
+		} else {
+			errs = append(errs, nterr)
+		}
+	}
+	return errs
+}
+
+// warning reports a warning to the warning callback, if present.
+func (p *nestedTextParser) warning(w NestedTextError) {
+	if p.warn != nil {
+		w.Severity = SeverityWarning
+		p.warn(p.finish(w, 0))
+	}
+}
+
 func (p *nestedTextParser) parseDocument() (result interface{}, err error) {
 	// initial token from scanner is a health check for the input source
 	if p.token = p.sc.NextToken(); p.token.Error != nil {
@@ -130,14 +390,53 @@ func (p *nestedTextParser) parseDocument() (result interface{}, err error) {
 	if p.token = p.sc.NextToken(); p.token.Error != nil {
 		return nil, p.token.Error
 	}
+	if p.token.TokenType == eof { // all item lines have been skipped as erroneous
+		return nil, nil
+	}
+	first := p.token
 	result, err = p.parseAny(0)
-	if err == nil && p.token.TokenType != eof { // TODO this test is not sufficient
-		err = makeParsingError(p.token, ErrCodeFormat,
-			"unused content following valid input")
+	if err == nil && p.positions != nil {
+		p.recordPosition("", first.LineNo, 0, nil)
+	}
+	if p.comments != nil && (first.TokenType == stringMultiline || first.TokenType == inlineList ||
+		first.TokenType == inlineDict) {
+		p.anchors = append(p.anchors, commentAnchor{line: first.LineNo})
+	}
+	if err == nil && p.token.TokenType != eof {
+		err = p.unusedContent()
 	}
 	return
 }
 
+// unusedContent creates an error for the lines following a complete top-level item,
+// starting with the current token. Such lines are either not indented, i.e. start a
+// second top-level item, or are indented less than the lines of the nested item they
+// follow. The remaining input is read to report the range of the lines, which includes
+// lines which cannot be recognized as items, but excludes trailing comments.
+func (p *nestedTextParser) unusedContent() error {
+	err := p.sc.Buf.locate(indentError(p.token, "unused content following valid input"), 0)
+	first, last := p.token.LineNo, p.token.LineNo
+	for p.token.TokenType != eof {
+		if p.token.Error != nil && !recoverable(p.token.Error) {
+			return p.token.Error
+		}
+		last = p.token.LineNo
+		p.token = p.sc.NextToken()
+	}
+	if last > first {
+		err.Message = fmt.Sprintf("%s in lines %d-%d", err.Message, first, last)
+	}
+	err.EndLine = last + 1
+	return p.sc.Buf.locate(err, 0)
+}
+
+// indentError creates an error of code ErrCodeFormat for the indentation of an item.
+func indentError(token *parserToken, msg string) NestedTextError {
+	err := makeParsingError(token, ErrCodeFormat, msg)
+	err.Column = token.Indent
+	return err
+}
+
 func (p *nestedTextParser) parseAny(indent int) (result interface{}, err error) {
 	if p.token.Indent < indent {
 		return nil, nil
@@ -146,16 +445,34 @@ func (p *nestedTextParser) parseAny(indent int) (result interface{}, err error)
 	case stringMultiline:
 		result, err = p.parseMultiString(p.token.Indent)
 	case inlineList:
-		p.inline.LineNo = p.token.LineNo
+		p.inline.LineNo, p.inline.Column, p.inline.depth = p.token.LineNo, p.token.Indent, len(p.stack)
 		result, err = p.inline.parse(_S2, p.token.Content[0])
+		if err == nil && p.limits.items > 0 {
+			err = p.countItems(inlineItemCount(result), p.token.LineNo)
+		}
+		if err == nil {
+			err = p.checkInlineKeys(result, p.path, p.token.LineNo)
+		}
+		if err == nil {
+			err = p.validateInline(result, p.token.LineNo, p.token.ColNo)
+		}
 		if err == nil {
 			if p.token = p.sc.NextToken(); p.token.Error != nil {
 				return nil, p.token.Error
 			}
 		}
 	case inlineDict:
-		p.inline.LineNo = p.token.LineNo
+		p.inline.LineNo, p.inline.Column, p.inline.depth = p.token.LineNo, p.token.Indent, len(p.stack)
 		result, err = p.inline.parse(_S1, p.token.Content[0])
+		if err == nil && p.limits.items > 0 {
+			err = p.countItems(inlineItemCount(result), p.token.LineNo)
+		}
+		if err == nil {
+			err = p.checkInlineKeys(result, p.path, p.token.LineNo)
+		}
+		if err == nil {
+			err = p.validateInline(result, p.token.LineNo, p.token.ColNo)
+		}
 		if err == nil {
 			if p.token = p.sc.NextToken(); p.token.Error != nil {
 				return nil, p.token.Error
@@ -166,12 +483,16 @@ func (p *nestedTextParser) parseAny(indent int) (result interface{}, err error)
 	case inlineDictKeyValue, inlineDictKey, dictKeyMultiline:
 		result, err = p.parseDict(indent)
 	default:
-		panic(fmt.Sprintf("unknown item type: %d/%s", p.token.TokenType, p.token.TokenType))
+		err = makeParsingError(p.token, ErrCodeFormat,
+			fmt.Sprintf("unexpected %s, expected an item", p.token.TokenType))
 	}
 	return
 }
 
 func (p *nestedTextParser) parseList(indent int) (result interface{}, err error) {
+	if p.maxDepth > 0 && len(p.stack) >= p.maxDepth {
+		return nil, depthError(p.token.LineNo, p.token.ColNo, p.maxDepth)
+	}
 	p.pushNonterm(false)
 	_, err = p.parseListItems(p.token.Indent)
 	if err != nil {
@@ -184,26 +505,108 @@ func (p *nestedTextParser) parseList(indent int) (result interface{}, err error)
 
 func (p *nestedTextParser) parseListItems(indent int) (result interface{}, err error) {
 	var value interface{}
-	for p.token.TokenType == listItem || p.token.TokenType == listItemMultiline {
-		if p.token.TokenType == listItem {
-			value, err = p.parseListItem(indent)
+	var lines []int // line numbers of items, including placeholders
+	for index := 0; p.token.TokenType == listItem || p.token.TokenType == listItemMultiline; index++ {
+		line := p.token.LineNo
+		if p.token.Indent == indent {
+			if err = p.countItems(1, line); err != nil {
+				return
+			}
+		}
+		start, depth, errs := p.token, len(p.stack), len(p.errors)
+		if p.selecting() && p.token.Indent == indent {
+			if !p.selectItem(PathSegment{Index: index, IsIndex: true}) {
+				if err = p.skipItem(indent); err != nil {
+					return
+				}
+				continue
+			}
+			value, err = p.parseListItemAny(indent)
+			p.leaveItem()
 		} else {
-			value, err = p.parseListItemMultiline(indent)
+			value, err = p.parseListItemAny(indent)
+		}
+		if err != nil && p.collect {
+			if err = p.recoverItem(err, start, depth, indent); err != nil {
+				return
+			}
+			if ph, _ := p.placeholder(start, depth, errs); ph != nil {
+				p.stack.pushKV(nil, ph, line)
+				lines = append(lines, line)
+			}
+			continue
+		}
+		if ph, _ := p.placeholder(start, depth, errs); ph != nil && value != nil {
+			p.stack.pushKV(nil, ph, line)
+			lines = append(lines, line)
+			continue
 		}
 		if value != nil && err == nil {
-			p.stack.pushKV(nil, value)
+			if err = p.validate(PathSegment{Index: len(p.stack.tos().Values), IsIndex: true}, value, line, indent); err != nil {
+				return
+			}
+			p.stack.pushKV(nil, value, line)
+			if p.tracking() {
+				p.anchor(line, indent, strconv.Itoa(len(p.stack.tos().Values)-1), value)
+			}
+			lines = append(lines, line)
 		} else if err != nil {
 			return
 		} else if value == nil {
 			break
 		}
 	}
+	if p.noDuplicates {
+		p.checkDuplicateItems(p.stack.tos().Values, lines, indent)
+	}
+	if p.noMixedLists {
+		err = checkMixedList(p.stack.tos().Values, lines, indent)
+	}
 	return p.stack.tos().Values, err
 }
 
+// checkMixedList checks if all items of a list are of the same type (string, list or dict).
+// If not, an error is returned which reports the positions of all the items which differ
+// from the type of the first item. Placeholders of items which failed to parse (see
+// ResumeAtTopLevel) are of no type and are not checked.
+func checkMixedList(items []interface{}, lines []int, indent int) error {
+	first := ""
+	var offending []int
+	for i, item := range items {
+		if _, ok := item.(*Placeholder); ok {
+			continue
+		}
+		if first == "" {
+			first = kindOf(item)
+		} else if kindOf(item) != first {
+			offending = append(offending, lines[i])
+		}
+	}
+	if len(offending) == 0 {
+		return nil
+	}
+	at := make([]string, len(offending))
+	for i, line := range offending {
+		at[i] = strconv.Itoa(line)
+	}
+	err := MakeNestedTextError(ErrCodeSchema,
+		fmt.Sprintf("list of %ss contains items of different type at line(s) %s",
+			first, strings.Join(at, ", ")))
+	err.Line, err.Column = offending[0], indent
+	return err
+}
+
+// parseListItemAny parses a list item with its value on the same line or nested.
+func (p *nestedTextParser) parseListItemAny(indent int) (result interface{}, err error) {
+	if p.token.TokenType == listItem {
+		return p.parseListItem(indent)
+	}
+	return p.parseListItemMultiline(indent)
+}
+
 func (p *nestedTextParser) parseListItem(indent int) (result interface{}, err error) {
 	if p.token.Indent > indent {
-		return nil, MakeNestedTextError(ErrCodeFormat,
+		return nil, indentError(p.token,
 			"invalid indent: may only follow an item that does not already have a value")
 	}
 	if p.token.Indent < indent {
@@ -226,15 +629,21 @@ func (p *nestedTextParser) parseListItemMultiline(indent int) (result interface{
 	if p.token.Indent <= indent {
 		return "", nil
 	}
-	result, err = p.parseAny(p.token.Indent)
+	result, err = p.parseNested(strconv.Itoa(len(p.stack.tos().Values)), indent, p.token.Indent)
+	if err != nil {
+		return nil, err
+	}
 	if p.token.Indent > indent {
-		return nil, MakeNestedTextError(ErrCodeFormat,
+		return nil, indentError(p.token,
 			"invalid indent: may only follow an item that does not already have a value")
 	}
 	return
 }
 
 func (p *nestedTextParser) parseDict(indent int) (result interface{}, err error) {
+	if p.maxDepth > 0 && len(p.stack) >= p.maxDepth {
+		return nil, depthError(p.token.LineNo, p.token.ColNo, p.maxDepth)
+	}
 	p.pushNonterm(true)
 	_, err = p.parseDictKeyValuePairs(p.token.Indent)
 	if err != nil {
@@ -243,7 +652,7 @@ func (p *nestedTextParser) parseDict(indent int) (result interface{}, err error)
 	result, err = p.stack.tos().ReduceToItem()
 	p.stack.pop()
 	if p.token.Indent > indent {
-		err = MakeNestedTextError(ErrCodeFormat, "partial dedent")
+		err = indentError(p.token, "partial dedent")
 	}
 	return
 }
@@ -259,19 +668,50 @@ func (p *nestedTextParser) parseDictKeyValuePairs(indent int) (result interface{
 	for p.token.TokenType == inlineDictKeyValue || p.token.TokenType == inlineDictKey ||
 		p.token.TokenType == dictKeyMultiline {
 		//
-		switch p.token.TokenType {
-		case inlineDictKeyValue:
-			kv, err = p.parseDictKeyValuePair(indent)
-		case inlineDictKey:
-			kv, err = p.parseDictKeyAnyValuePair(indent)
-		case dictKeyMultiline:
-			kv, err = p.parseDictKeyValuePairWithMultilineKey(indent)
+		line, padding := p.token.LineNo, p.token.Padding
+		if p.token.Indent == indent {
+			if err = p.countItems(1, line); err != nil {
+				return
+			}
+		}
+		start, depth, errs := p.token, len(p.stack), len(p.errors)
+		if p.selecting() && p.token.Indent == indent {
+			if kv, err = p.parseSelectedDictItem(indent); err == nil && kv.value == nil && kv.key != nil {
+				continue // skipped
+			}
+		} else {
+			kv, err = p.parseDictItem(indent)
+		}
+		if err != nil && p.collect {
+			if err = p.recoverItem(err, start, depth, indent); err != nil {
+				return
+			}
+			if ph, ok := p.placeholder(start, depth, errs); ph != nil && ok {
+				key := start.Content[0]
+				p.stack.pushKV(&key, ph, line)
+			}
+			continue
+		}
+		if ph, ok := p.placeholder(start, depth, errs); ph != nil && kv.value != nil {
+			if ok {
+				p.stack.pushKV(kv.key, ph, line)
+			}
+			continue
 		}
 		if kv.value != nil {
 			if err != nil {
 				return
 			}
-			p.stack.pushKV(kv.key, kv.value)
+			if err = p.validate(PathSegment{Key: *kv.key}, kv.value, line, indent); err != nil {
+				return
+			}
+			p.stack.pushKV(kv.key, kv.value, line)
+			if p.tracking() {
+				path := p.anchor(line, indent, *kv.key, kv.value)
+				if p.layout != nil && padding != "" {
+					p.layout.KeyPadding[path] = padding
+				}
+			}
 		} else {
 			break
 		}
@@ -279,12 +719,54 @@ func (p *nestedTextParser) parseDictKeyValuePairs(indent int) (result interface{
 	return p.stack.tos().Keys, err
 }
 
+// parseDictItem parses a dict item of any kind.
+func (p *nestedTextParser) parseDictItem(indent int) (kv keyValuePair, err error) {
+	switch p.token.TokenType {
+	case inlineDictKeyValue:
+		kv, err = p.parseDictKeyValuePair(indent)
+	case inlineDictKey:
+		kv, err = p.parseDictKeyAnyValuePair(indent)
+	case dictKeyMultiline:
+		kv, err = p.parseDictKeyValuePairWithMultilineKey(indent)
+	}
+	return
+}
+
+// parseSelectedDictItem parses a dict item if it is selected, and skips it otherwise.
+// For skipped items, the key is set, but the value is nil. Items with multi-line keys
+// are parsed completely before checking their key.
+func (p *nestedTextParser) parseSelectedDictItem(indent int) (kv keyValuePair, err error) {
+	if p.token.TokenType == dictKeyMultiline {
+		p.selectAll++
+		kv, err = p.parseDictItem(indent)
+		p.selectAll--
+		if err == nil && kv.key != nil {
+			if p.selectItem(PathSegment{Key: *kv.key}) {
+				p.leaveItem()
+			} else {
+				kv.value = nil
+			}
+		}
+		return
+	}
+	key := p.token.Content[0]
+	if !p.selectItem(PathSegment{Key: key}) {
+		return keyValuePair{key: &key}, p.skipItem(indent)
+	}
+	kv, err = p.parseDictItem(indent)
+	p.leaveItem()
+	return
+}
+
 func (p *nestedTextParser) parseDictKeyValuePair(indent int) (kv keyValuePair, err error) {
 	if p.token.Indent != indent {
 		return
 	}
 	key := p.token.Content[0]
 	value := p.token.Content[1]
+	if err = p.checkKey(key, p.token.LineNo, indent); err != nil {
+		return
+	}
 	if p.token = p.sc.NextToken(); p.token.Error != nil {
 		return kv, p.token.Error
 	}
@@ -296,6 +778,9 @@ func (p *nestedTextParser) parseDictKeyAnyValuePair(indent int) (kv keyValuePair
 		return
 	}
 	kv.key = &p.token.Content[0]
+	if err = p.checkKey(*kv.key, p.token.LineNo, indent); err != nil {
+		return
+	}
 	if p.token = p.sc.NextToken(); p.token.Error != nil {
 		return kv, p.token.Error
 	}
@@ -303,7 +788,7 @@ func (p *nestedTextParser) parseDictKeyAnyValuePair(indent int) (kv keyValuePair
 		kv.value = ""
 		return
 	}
-	kv.value, err = p.parseAny(p.token.Indent)
+	kv.value, err = p.parseNested(*kv.key, indent, p.token.Indent)
 	return
 }
 
@@ -318,6 +803,7 @@ func (p *nestedTextParser) parseDictKeyValuePairWithMultilineKey(indent int) (kv
 	if p.token.Indent != indent {
 		return
 	}
+	line := p.token.LineNo
 	builder := strings.Builder{}
 	builder.WriteString(allowVoid(p.token.Content, 0))
 	for err == nil {
@@ -332,11 +818,14 @@ func (p *nestedTextParser) parseDictKeyValuePairWithMultilineKey(indent int) (kv
 		builder.WriteString(allowVoid(p.token.Content, 0))
 	}
 	key := builder.String()
+	if err = p.checkKey(key, line, indent); err != nil {
+		return
+	}
 	kv.key = &key
 	if p.token.Indent <= indent {
 		return keyValuePair{key: &key, value: ""}, nil
 	}
-	kv.value, err = p.parseAny(p.token.Indent)
+	kv.value, err = p.parseNested(key, indent, p.token.Indent)
 	return
 }
 
@@ -354,18 +843,29 @@ func (p *nestedTextParser) parseMultiString(indent int) (result interface{}, err
 		if p.token.TokenType != stringMultiline || p.token.Indent != indent {
 			break
 		}
+		if p.keepBlanks {
+			builder.WriteString(strings.Repeat("\n", p.token.Blanks))
+		}
 		builder.WriteRune('\n')
 		builder.WriteString(allowVoid(p.token.Content, 0))
 	}
 	return builder.String(), nil
 }
 
+// depthError creates an error for an item exceeding the maximum nesting depth.
+func depthError(line, col, maxDepth int) NestedTextError {
+	t := parserToken{LineNo: line, ColNo: col}
+	return makeParsingError(&t, ErrCodeTooDeep,
+		fmt.Sprintf("lists and dicts nest deeper than %d levels", maxDepth))
+}
+
 func (p *nestedTextParser) pushNonterm(isDict bool) {
 	entry := parserStackEntry{
 		Values: make([]interface{}, 0, 16),
 	}
 	if isDict { // dict
 		entry.Keys = make([]string, 0, 16)
+		entry.Ordered = p.ordered
 	}
 	p.stack.push(&entry)
 }
@@ -381,20 +881,25 @@ func (p *nestedTextParser) wrapResult(result interface{}) interface{} {
 			result = []interface{}{result}
 		}
 	case "dict":
-		v := reflect.ValueOf(result)
-		if v.Kind() != reflect.Map {
-			result = map[string]interface{}{
-				"nestedtext": result,
-			}
+		if _, ok := asDict(result); !ok {
+			result = p.wrapInDict("nestedtext", result)
 		}
 	default:
-		result = map[string]interface{}{
-			p.toplevel: result,
-		}
+		result = p.wrapInDict(p.toplevel, result)
 	}
 	return result
 }
 
+// wrapInDict creates a dict with a single entry.
+func (p *nestedTextParser) wrapInDict(key string, value interface{}) interface{} {
+	if p.ordered {
+		m := NewOrderedMap()
+		m.Set(key, value)
+		return m
+	}
+	return map[string]interface{}{key: value}
+}
+
 // === Inline item parser ====================================================
 
 // Inline items are lists or dicts as one-liners. Examples would be
@@ -423,7 +928,13 @@ type inlineItemParser struct {
 	Marker       int             // positional marker for start of key or value
 	Input        *strings.Reader // reader for Text
 	LineNo       int             // current input line number
+	Column       int             // column of the start of Text within its line
 	stack        pstack          // parser stack
+	ordered      bool            // create dicts as *OrderedMap
+	uniqueKeys   bool            // reject duplicate keys
+	depth        int             // nesting depth of the line containing the inline item
+	maxDepth     int             // maximum nesting depth, 0 for no limit
+	warn         WarningFn       // if set, content following the item is dropped with a warning
 	//stack        []parserStackEntry // parse stack
 }
 
@@ -440,19 +951,26 @@ func (p *inlineItemParser) parse(initial inlineParserState, input string) (resul
 	p.stack = p.stack[:0]
 	p.TextPosition, p.Marker = 0, 0
 	//
+	if p.maxDepth > 0 && p.depth >= p.maxDepth {
+		return nil, depthError(p.LineNo, p.column(), p.maxDepth)
+	}
 	p.pushNonterm(initial)
 	var oldState, state inlineParserState = 0, initial
 	for len(p.stack) > 0 {
 		ch, w, err := p.Input.ReadRune()
 		if err != nil {
-			err = WrapError(ErrCodeIO, "I/O-error reading inline item", err)
-			return nil, err
+			nterr := WrapError(ErrCodeIO, "I/O-error reading inline item", err)
+			nterr.Line, nterr.Column = p.LineNo, p.column()
+			return nil, nterr
 		}
 		chType := inlineTokenFor(ch)
 		oldState, state = state, inlineStateMachine[state][chType]
 		if isErrorState(state) {
 			break
 		} else if isNonterm(state) {
+			if p.maxDepth > 0 && p.depth+len(p.stack) >= p.maxDepth {
+				return nil, depthError(p.LineNo, p.column(), p.maxDepth)
+			}
 			nonterm := state
 			p.pushNonterm(state)
 			state = inlineStateMachine[state][chType]
@@ -473,20 +991,54 @@ func (p *inlineItemParser) parse(initial inlineParserState, input string) (resul
 			state = p.stack.tos().NontermState
 			p.stack.pop()
 			if len(p.stack) > 0 {
-				p.stack.pushKV(p.stack.tos().Key, result)
+				p.stack.pushKV(p.stack.tos().Key, result, p.LineNo)
 			}
 		}
 		p.TextPosition += w
 	}
+	if !isErrorState(state) && p.TextPosition < len(p.Text) {
+		err = p.checkTrailing()
+	}
 	if isErrorState(state) {
+		t := parserToken{ColNo: p.column(), LineNo: p.LineNo}
 		if err = p.stack[len(p.stack)-1].Error; err == nil {
-			t := parserToken{ColNo: p.TextPosition, LineNo: p.LineNo}
 			err = makeParsingError(&t, ErrCodeFormat, "format error")
+		} else if nterr, ok := err.(NestedTextError); ok {
+			nterr.Line, nterr.Column = t.LineNo, t.ColNo
+			err = nterr
 		}
 	}
 	return
 }
 
+// checkTrailing checks for content following the closing bracket of an inline item,
+// other than white space.
+func (p *inlineItemParser) checkTrailing() error {
+	rest := p.Text[p.TextPosition:]
+	trimmed := strings.TrimLeft(rest, " \t")
+	if trimmed == "" {
+		return nil
+	}
+	p.TextPosition += len(rest) - len(trimmed)
+	t := parserToken{ColNo: p.column(), LineNo: p.LineNo}
+	err := makeParsingError(&t, ErrCodeFormat, fmt.Sprintf("content %q following inline item", trimmed))
+	if p.warn != nil {
+		p.warn(err)
+		return nil
+	}
+	return err
+}
+
+// column returns the column of the current character within the input line, counting
+// characters, not bytes.
+func (p *inlineItemParser) column() int {
+	pos := p.TextPosition
+	if pos > len(p.Text) {
+		pos = len(p.Text)
+	}
+	return p.Column + utf8.RuneCountInString(p.Text[:pos])
+}
+
 // pushNonterm pushes a new (empyt) stack entry onto the parser stack. Depending on wether
 // the non-terminal represents a list item or a dict item, the .Keys slice will be initialized.
 // This function will be called for every non-terminal encounterd during the parse run, i.e.,
@@ -497,6 +1049,8 @@ func (p *inlineItemParser) pushNonterm(state inlineParserState) {
 	}
 	if state == _S1 { // dict
 		entry.Keys = make([]string, 0, 16)
+		entry.Ordered = p.ordered
+		entry.Unique = p.uniqueKeys
 	}
 	p.stack.push(&entry)
 }
@@ -636,10 +1190,10 @@ func (p *inlineItemParser) appendStringValue(isAccept bool) {
 	// and [,] a list with two empty string values.
 	if p.stack.tos().Key != nil {
 		value = strings.TrimSpace(value)
-		p.stack.pushKV(p.stack.tos().Key, value)
+		p.stack.pushKV(p.stack.tos().Key, value, p.LineNo)
 	} else if !isAccept || len(value) > 0 || len(p.stack.tos().Values) > 0 {
 		value = strings.TrimSpace(value)
-		p.stack.pushKV(p.stack.tos().Key, value)
+		p.stack.pushKV(p.stack.tos().Key, value, p.LineNo)
 	}
 }
 
@@ -688,22 +1242,26 @@ func (s *pstack) push(e *parserStackEntry) (tos *parserStackEntry) {
 }
 
 // pushKV will push a value and an option key onto the stack by appending it to the
-// top-most stack entry.
+// top-most stack entry. `line` is the input line of the value, used for diagnostics.
 // The containing stack-entry has to be provided by a non-term (pushNonterm).
-func (s *pstack) pushKV(str *string, val interface{}) bool {
-	// if val != nil && str != nil {
-	// 	fmt.Printf("# push( %s, %#v )\n", *str, val)
-	// } else if val != nil {
-	// 	fmt.Printf("# push( %#v )\n", val)
-	// }
+func (s *pstack) pushKV(str *string, val interface{}, line int) bool {
+	if tracing && val != nil {
+		if str != nil {
+			tracef("push(%q, %#v)", *str, val)
+		} else {
+			tracef("push(%#v)", val)
+		}
+	}
 	if s == nil || len(*s) == 0 {
 		panic("use of un-initialized parser stack")
 	}
 	tos := &(*s)[len(*s)-1]
 	tos.Values = append(tos.Values, val)
+	if (str != nil) != (tos.Keys != nil) && tos.MixedLine == 0 { // key for a list or value without key
+		tos.MixedLine = line
+	}
 	if str != nil {
 		if tos.Keys == nil {
-			//panic("top-most stack entry should not contain keys")
 			return false
 		}
 		tos.Keys = append(tos.Keys, *str)
@@ -721,22 +1279,57 @@ type parserStackEntry struct {
 	Key          *string           // current key to set value for, if in a dict
 	Error        error             // if error occured: remember it
 	NontermState inlineParserState // sub-nonterm, or 0 for root entry (used for inline-parser only)
+	Ordered      bool              // reduce dict to *OrderedMap
+	Unique       bool              // reject duplicate keys (used for inline-parser only)
+	MixedLine    int               // line of the first value inconsistent with the item's type, if any
 }
 
 func (entry parserStackEntry) ReduceToItem() (interface{}, error) {
-	if entry.Keys == nil {
+	if entry.Keys == nil && entry.MixedLine == 0 {
 		return entry.Values, nil
 	}
-	dict := make(map[string]interface{}, len(entry.Values))
-	if len(entry.Keys) > 0 && len(entry.Values) != len(entry.Keys) {
+	if entry.MixedLine > 0 || len(entry.Values) != len(entry.Keys) {
 		// error: mixed content = uneven count of keys and values
-		// fmt.Printf("@ entry.keys   = %#v\n", entry.Keys)
-		// fmt.Printf("@ entry.values = %#v\n", entry.Values)
-		panic(fmt.Sprintf("mixed item: number of keys (%d) not equal to number of values (%d)",
-			len(entry.Keys), len(entry.Values)))
+		return nil, entry.mixedItemError()
+	}
+	dict := make(map[string]interface{}, len(entry.Values))
+	if entry.Unique {
+		seen := make(map[string]bool, len(entry.Keys))
+		for _, key := range entry.Keys {
+			if seen[key] {
+				return nil, MakeNestedTextError(ErrCodeFormat, "duplicate key: "+key)
+			}
+			seen[key] = true
+		}
+	}
+	if entry.Ordered {
+		m := &OrderedMap{keys: make([]string, 0, len(entry.Keys)), values: dict}
+		for i, key := range entry.Keys {
+			if _, ok := dict[key]; !ok {
+				m.keys = append(m.keys, key)
+			}
+			dict[key] = entry.Values[i]
+		}
+		return m, nil
 	}
 	for i, key := range entry.Keys {
 		dict[key] = entry.Values[i]
 	}
 	return dict, nil
 }
+
+// mixedItemError reports the first line of a stack entry which is inconsistent with
+// the entry's type.
+func (entry parserStackEntry) mixedItemError() error {
+	item := "dict"
+	if entry.Keys == nil {
+		item = "list"
+	}
+	if entry.MixedLine == 0 {
+		return MakeNestedTextError(ErrCodeFormat, item+" item mixes list and key-value entries")
+	}
+	err := MakeNestedTextError(ErrCodeFormat,
+		fmt.Sprintf("%s item at line %d mixes list and key-value entries", item, entry.MixedLine))
+	err.Line = entry.MixedLine
+	return err
+}
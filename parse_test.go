@@ -3,8 +3,10 @@ package nestext
 import (
 	"fmt"
 	"log"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestParserUsageError(t *testing.T) {
@@ -24,15 +26,37 @@ func TestParserUsageError(t *testing.T) {
 func TestParserStack(t *testing.T) {
 	p := newParser()
 	p.pushNonterm(false)
-	if ok := p.stack.pushKV(nil, "one"); !ok {
+	if ok := p.stack.pushKV(nil, "one", 1); !ok {
 		t.Fatal("pushing a value onto the stack failed")
 	}
 	two := "2"
-	if ok := p.stack.pushKV(&two, "two"); ok {
+	if ok := p.stack.pushKV(&two, "two", 2); ok {
 		t.Fatal("pushing a key onto the stack should fail, didn't")
 	}
 }
 
+func TestReduceMixedItem(t *testing.T) {
+	key := "a"
+	var stack pstack
+	stack.push(&parserStackEntry{Keys: []string{}})
+	stack.pushKV(&key, "1", 3)
+	stack.pushKV(nil, "x", 4)
+	stack.pushKV(nil, "y", 5)
+	_, err := stack.tos().ReduceToItem()
+	if err == nil || err.Error() != "[4,0] dict item at line 4 mixes list and key-value entries" {
+		t.Errorf("expected mixed dict to be reported at line 4, have %v", err)
+	}
+	stack.push(&parserStackEntry{})
+	stack.pushKV(nil, "x", 7)
+	if ok := stack.pushKV(&key, "1", 8); ok {
+		t.Error("expected key to be rejected for list")
+	}
+	_, err = stack.tos().ReduceToItem()
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeFormat || nterr.Line != 8 {
+		t.Errorf("expected mixed list to be reported at line 8, have %v", err)
+	}
+}
+
 func TestSimpleDict(t *testing.T) {
 	input := `
 # Example for a dict
@@ -142,6 +166,168 @@ additional roles:
 	dump(" ", result.(map[string]interface{}))
 }
 
+func TestParseReportProgress(t *testing.T) {
+	input := "# progress\na: Hello\nb: World\n"
+	var bytes int64
+	var lines, calls int
+	_, err := Parse(strings.NewReader(input), ReportProgress(func(b int64, l int) {
+		if b < bytes || l < lines {
+			t.Errorf("progress must not decrease: %d/%d after %d/%d", b, l, bytes, lines)
+		}
+		bytes, lines = b, l
+		calls++
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls == 0 {
+		t.Fatal("expected progress callback to be called, wasn't")
+	}
+	if bytes != int64(len(input)) {
+		t.Errorf("expected %d bytes to be consumed, have %d", len(input), bytes)
+	}
+	t.Logf("%d calls, %d bytes, %d lines", calls, bytes, lines)
+}
+
+func TestParseNoMixedLists(t *testing.T) {
+	input := `
+- a
+- b
+-
+  x: 1
+-
+  - c
+`
+	if _, err := Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("expected mixed list to be valid by default, have %v", err)
+	}
+	_, err := Parse(strings.NewReader(input), NoMixedLists())
+	if err == nil {
+		t.Fatal("expected mixed list to be flagged, wasn't")
+	}
+	t.Logf("got expected error: %v", err)
+	nterr := err.(NestedTextError)
+	if nterr.Code != ErrCodeSchema || nterr.Line != 4 || !strings.Contains(nterr.Error(), "4, 6") {
+		t.Errorf("expected schema error reporting lines 4 and 6, have %v", err)
+	}
+	if _, err = Parse(strings.NewReader("- a\n- b\n"), NoMixedLists()); err != nil {
+		t.Errorf("expected homogenous list to pass, have %v", err)
+	}
+}
+
+func TestParseLineTooLong(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	inputs := []struct {
+		text string
+		line int
+	}{
+		{"a: " + long + "\n", 1},
+		{"a: 1\nb: " + long + "\n", 2},
+		{"- 1\n# comment\n- 2\n- " + long, 4},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text))
+		t.Logf("[%d] error = %v", i, err)
+		nterr, ok := err.(NestedTextError)
+		if !ok || nterr.Code != ErrCodeLineTooLong || nterr.Line != input.line {
+			t.Errorf("[%d] expected error for line too long at line %d, have %v", i, input.line, err)
+		}
+	}
+}
+
+func TestParseRequireFinalNewline(t *testing.T) {
+	inputs := map[string]bool{"a: 1\n": true, "": true, "a: 1": false, "a: 1\n# comment": false}
+	for input, ok := range inputs {
+		_, err := Parse(strings.NewReader(input), RequireFinalNewline())
+		if ok && err != nil {
+			t.Errorf("expected %q to be accepted, have %v", input, err)
+		} else if nterr, isNT := err.(NestedTextError); !ok && (!isNT || nterr.Code != ErrCodeFormatNoFinalNewline) {
+			t.Errorf("expected %q to be rejected, have %v", input, err)
+		}
+	}
+}
+
+func TestParseRequireUniformNewlines(t *testing.T) {
+	inputs := map[string]int{ // line of the error, 0 for no error
+		"a: 1\r\nb: 2\r\n": 0, "a: 1\rb: 2": 0, "": 0,
+		"a: 1\nb: 2\r\n": 2, "# c\r\n\r\nb: 2\n": 3, "- a\n- b\n- c\r": 3,
+	}
+	for input, line := range inputs {
+		_, err := Parse(strings.NewReader(input), RequireUniformNewlines())
+		if line == 0 && err != nil {
+			t.Errorf("expected %q to be accepted, have %v", input, err)
+		} else if nterr, isNT := err.(NestedTextError); line != 0 && (!isNT ||
+			nterr.Code != ErrCodeFormatMixedNewlines || nterr.Line != line) {
+			t.Errorf("expected %q to be rejected at line %d, have %v", input, line, err)
+		}
+	}
+	_, err := Parse(strings.NewReader("a: 1\nb: 2\r\n"), RequireUniformNewlines())
+	if err == nil || !strings.Contains(err.Error(), "terminated by CR LF, while the first line is terminated by LF") {
+		t.Errorf("expected error message naming line terminators, have %v", err)
+	}
+}
+
+func TestParseTrailingWhitespace(t *testing.T) {
+	inputs := map[string]interface{}{
+		"> a  \n> b\t\n>  \t\n":        "a  \nb\t\n \t",
+		"> a \t\r\n> \r\n":             "a \t\n",
+		"k:\n  > v \n  > \t\n":         map[string]interface{}{"k": "v \n\t"},
+		"- \n- x\n":                    []interface{}{"", "x"},
+		"- x  \n-\n    > y\t\n":        []interface{}{"x  ", "y\t"},
+		": key  \n: \t\n  > value  \n": map[string]interface{}{"key  \n\t": "value  "},
+	}
+	for input, expected := range inputs {
+		tree, err := Parse(strings.NewReader(input))
+		if err != nil || !reflect.DeepEqual(tree, expected) {
+			t.Errorf("expected %q to parse to %#v, have %#v, %v", input, expected, tree, err)
+		}
+	}
+}
+
+func TestParseKeepBlankLinesInStrings(t *testing.T) {
+	input := "a:\n\n  > one\n\n  \n  # comment\n  > two\n  >\n  > three\n\nb: x\n"
+	tree, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := tree.(map[string]interface{})["a"]; s != "one\ntwo\n\nthree" {
+		t.Errorf("expected blank lines to be ignored by default, have %q", s)
+	}
+	tree, err = Parse(strings.NewReader(input), KeepBlankLinesInStrings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"a": "one\n\n\ntwo\n\nthree", "b": "x"}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("expected %q, have %q", expected, tree)
+	}
+}
+
+func TestParseSnippet(t *testing.T) {
+	inputs := []struct {
+		text   string
+		result interface{}
+	}{
+		{"[a, b]", []interface{}{"a", "b"}},
+		{"  {a: 1}  ", map[string]interface{}{"a": "1"}},
+		{"\n    a: 1\n    b:\n      - x", map[string]interface{}{"a": "1", "b": []interface{}{"x"}}},
+		{"> text", "text"},
+	}
+	for i, input := range inputs {
+		result, err := ParseSnippet(input.text)
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, input.result) {
+			t.Errorf("[%d] expected %#v, have %#v", i, input.result, result)
+		}
+	}
+	if _, err := ParseSnippet("  a: 1\n b: 2"); err == nil {
+		t.Errorf("expected snippet with inconsistent indentation to be rejected")
+	}
+}
+
 // ----------------------------------------------------------------------
 
 func dump(space string, v interface{}) {
@@ -173,3 +359,110 @@ func _dump(space string, v interface{}) {
 		fmt.Printf("%v%v\n", space, v)
 	}
 }
+
+func TestParseMaxDepth(t *testing.T) {
+	inputs := []struct {
+		text     string
+		maxDepth int
+		line     int // line of the error, 0 for no error
+	}{
+		{"a:\n  b:\n    - x\n", 3, 0},
+		{"a:\n  b:\n    - x\n", 2, 3},
+		{"a:\n  [x, {y: z}]\n", 3, 0},
+		{"a:\n  [x, {y: z}]\n", 2, 2},
+		{"a:\n  [x, {y: z}]\n", 1, 2},
+		{"[[[x]]]\n", 2, 1},
+		{"> text\n", 1, 0},
+		{"a:\n  b:\n    - x\n", 0, 0},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text), MaxDepth(input.maxDepth))
+		eerr := ParseEvents(strings.NewReader(input.text), &Handler{}, MaxDepth(input.maxDepth))
+		for _, err := range []error{err, eerr} {
+			if input.line == 0 {
+				if err != nil {
+					t.Errorf("%d: expected no error, have %v", i, err)
+				}
+				continue
+			}
+			nterr, ok := err.(NestedTextError)
+			if !ok || nterr.Code != ErrCodeTooDeep || nterr.Line != input.line {
+				t.Errorf("%d: expected error of code ErrCodeTooDeep at line %d, have %v", i, input.line, err)
+			}
+		}
+	}
+	if _, err := Parse(strings.NewReader("a: b\n"), MaxDepth(-1)); err == nil {
+		t.Error("expected negative depth to produce an error; didn't")
+	}
+}
+
+func TestParseErrorLocation(t *testing.T) {
+	inputs := []struct {
+		text      string
+		line, col int
+		endLine   int
+		msg       string // expected prefix of the message
+	}{
+		{"a: 1\nb: 2\n- x\n# c\n  - y\nz\n# end\n", 3, 0, 7, "unused content following valid input in lines 3-6"},
+		{"- a\n- b\nc: d\n", 3, 0, 4, "unused content following valid input"},
+		{"a: 1\n  b: 2\n", 2, 2, 0, ""},
+		{"- a\n  - b\n", 2, 2, 0, ""},
+		{"a:\n  b: 1\n c: 2\n", 3, 1, 0, ""},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text))
+		eerr := ParseEvents(strings.NewReader(input.text), &Handler{})
+		for _, err := range []error{err, eerr} {
+			nterr, ok := err.(NestedTextError)
+			if !ok {
+				t.Errorf("%d: expected parsing error, have %v", i, err)
+				continue
+			}
+			if nterr.Line != input.line || nterr.Column != input.col {
+				t.Errorf("%d: expected error at [%d,%d], have %v", i, input.line, input.col, err)
+			}
+			if input.endLine != 0 && nterr.EndLine != input.endLine {
+				t.Errorf("%d: expected error to end at line %d, have %d", i, input.endLine, nterr.EndLine)
+			}
+			if !strings.HasPrefix(nterr.Message, input.msg) {
+				t.Errorf("%d: expected message %q, have %q", i, input.msg, nterr.Message)
+			}
+		}
+	}
+}
+
+func TestParseByteOrderMark(t *testing.T) {
+	inputs := []struct {
+		text   string
+		result interface{}
+		code   int // error code, if any
+	}{
+		{"\ufeffa: 1\n", map[string]interface{}{"a": "1"}, 0},
+		{"\ufeff# comment\n- x\n", []interface{}{"x"}, 0},
+		{"\ufeff", nil, 0},
+		{"a: \ufeff\n", map[string]interface{}{"a": "\ufeff"}, 0},
+		{"\xfe\xff\x00a\x00:", nil, ErrCodeEncoding},
+		{"\xff\xfea\x00:\x00", nil, ErrCodeEncoding},
+	}
+	for i, input := range inputs {
+		var meta Metadata
+		r := iotest.OneByteReader(strings.NewReader(input.text))
+		result, err := Parse(r, ReportMetadata(&meta))
+		if input.code != 0 {
+			if nterr, ok := err.(NestedTextError); !ok || nterr.Code != input.code || nterr.Line != 1 {
+				t.Errorf("%d: expected error of code %d at line 1, have %v", i, input.code, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: expected no error, have %v", i, err)
+		}
+		if !reflect.DeepEqual(result, input.result) {
+			t.Errorf("%d: expected %#v, have %#v", i, input.result, result)
+		}
+		bom := strings.HasPrefix(input.text, "\ufeff")
+		if meta.BOM != bom || meta.Bytes != int64(len(input.text)) {
+			t.Errorf("%d: expected BOM=%v and %d bytes, have %+v", i, bom, len(input.text), meta)
+		}
+	}
+}
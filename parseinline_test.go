@@ -3,6 +3,7 @@ package nestext
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -12,7 +13,7 @@ func TestInlineParseEOF(t *testing.T) {
 	if err != nil {
 		nterr := &NestedTextError{}
 		if errors.As(err, nterr) {
-			t.Logf("error code = %d, error = %q", nterr.Code, nterr.msg)
+			t.Logf("error code = %d, error = %q", nterr.Code, nterr.Message)
 			t.Logf("   wrapped = %q", nterr.Unwrap().Error())
 		} else {
 			t.Errorf("expected empty input to result in I/O error; didn't:")
@@ -57,3 +58,28 @@ func TestInlineParseItemsTable(t *testing.T) {
 		t.Logf("------------------------------------------")
 	}
 }
+
+func TestInlineErrorColumns(t *testing.T) {
+	inputs := []struct {
+		text         string
+		line, column int
+	}{
+		{"{a: b, c}\n", 1, 8},
+		{"a:\n  {a: b, c}\n", 2, 10},
+		{"- x\n-\n  {a: [}\n", 3, 7},
+		{"a:\n  [ä, ö, {]\n", 2, 10}, // columns count characters
+		{"a:\n  [a, [b, [c]]\n", 2, 14},
+		{"a:\n    [x, }\n", 2, 8},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text))
+		nterr, ok := err.(NestedTextError)
+		if !ok || nterr.Line != input.line || nterr.Column != input.column {
+			t.Errorf("%d: expected error at [%d,%d], have %v", i, input.line, input.column, err)
+		}
+	}
+	_, err := Parse(strings.NewReader("  a:\n    {a: b, c}\n"), AutoDedent())
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Column != 12 {
+		t.Errorf("expected error at column 12 of dedented input, have %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package nestext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --- Path expressions ------------------------------------------------------
+
+// PathSegment is a segment of a path expression, as returned by ParsePath.
+type PathSegment struct {
+	Key       string // key of a dict item; "*" for wildcards, a pattern for globs
+	Index     int    // index of a list item, if IsIndex is set
+	IsIndex   bool   // does the segment select a list item?
+	Wildcard  bool   // does the segment select all items of a list or dict?
+	Glob      bool   // is Key a pattern, with '*' matching any characters and '\' escaping?
+	Recursive bool   // does the segment select any number of levels of items ("**")?
+}
+
+func (s PathSegment) String() string {
+	switch {
+	case s.Recursive:
+		return "**"
+	case s.Wildcard:
+		return "*"
+	case s.IsIndex:
+		return "[" + strconv.Itoa(s.Index) + "]"
+	case s.Glob:
+		return globToPathKey(s.Key)
+	}
+	return escapePathKey(s.Key)
+}
+
+// MatchKey returns true if s selects the dict item with key `key`. It is false for
+// list indices and recursive segments.
+func (s PathSegment) MatchKey(key string) bool {
+	switch {
+	case s.IsIndex || s.Recursive:
+		return false
+	case s.Wildcard:
+		return true
+	case s.Glob:
+		return matchGlob(s.Key, key)
+	}
+	return s.Key == key
+}
+
+// IsPattern returns true if s may select more than one item.
+func (s PathSegment) IsPattern() bool {
+	return s.Wildcard || s.Glob || s.Recursive
+}
+
+// ParsePath splits a path expression into its segments. Path expressions address
+// values within trees returned by Parse, in the same way the decoder reports the
+// location of errors:
+//
+//     president.address     item "address" of dict item "president"
+//     roles[2]              third item of list "roles"
+//     servers.*.port        item "port" of every item of "servers"
+//     **.port               item "port" of any dict, at any level
+//     hosts.server-*        items of "hosts" with keys starting with "server-"
+//
+// Keys are separated by '.', list indices are enclosed in brackets. A segment "*"
+// or "[*]" matches all items of a list or dict, a segment "**" matches any number of
+// levels of items, including none. Within other keys, '*' matches any sequence of
+// characters. Characters '.', '[', ']', '*' and '\' are escaped within keys by a
+// preceding '\'. The empty expression addresses the top-level value.
+//
+// If a non-nil error is returned, it will be of type NestedTextError with code
+// ErrCodeUsage, with Column set to the position of the error within `expr`.
+func ParsePath(expr string) ([]PathSegment, error) {
+	var segments []PathSegment
+	i := 0
+	for i < len(expr) {
+		if expr[i] == '[' {
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return nil, pathError(expr, i, "missing ']'")
+			}
+			inner := expr[i+1 : i+end]
+			if inner == "*" {
+				segments = append(segments, PathSegment{Key: "*", Wildcard: true})
+			} else if n, err := strconv.Atoi(inner); err == nil && n >= 0 {
+				segments = append(segments, PathSegment{Index: n, IsIndex: true})
+			} else {
+				return nil, pathError(expr, i+1, fmt.Sprintf("invalid list index %q", inner))
+			}
+			i += end + 1
+		} else {
+			if len(segments) > 0 {
+				if expr[i] != '.' {
+					return nil, pathError(expr, i, "expected '.' or '['")
+				}
+				i++
+			}
+			key, pattern, n, err := scanPathKey(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			seg := PathSegment{Key: key}
+			switch {
+			case expr[i:i+n] == "*":
+				seg.Wildcard = true
+			case expr[i:i+n] == "**":
+				seg.Key, seg.Recursive = "**", true
+			case pattern != "":
+				seg.Key, seg.Glob = pattern, true
+			}
+			segments = append(segments, seg)
+			i += n
+		}
+	}
+	return segments, nil
+}
+
+// scanPathKey scans the key starting at position `start` of `expr`, resolving escapes.
+// It returns the key, a pattern for matchGlob if the key contains unescaped '*',
+// and the count of bytes consumed.
+func scanPathKey(expr string, start int) (string, string, int, error) {
+	var key, pattern strings.Builder
+	glob := false
+	i := start
+	for ; i < len(expr) && expr[i] != '.' && expr[i] != '['; i++ {
+		switch expr[i] {
+		case '\\':
+			if i+1 == len(expr) {
+				return "", "", 0, pathError(expr, i, "incomplete escape")
+			}
+			i++
+		case ']':
+			return "", "", 0, pathError(expr, i, "unexpected ']'")
+		case '*':
+			glob = true
+			pattern.WriteByte('*')
+			key.WriteByte('*')
+			continue
+		}
+		if strings.IndexByte(`*?[]\`, expr[i]) >= 0 {
+			pattern.WriteByte('\\')
+		}
+		pattern.WriteByte(expr[i])
+		key.WriteByte(expr[i])
+	}
+	if i == start {
+		return "", "", 0, pathError(expr, i, "empty key")
+	}
+	if !glob {
+		return key.String(), "", i - start, nil
+	}
+	return key.String(), pattern.String(), i - start, nil
+}
+
+// matchGlob reports whether `key` matches `pattern`, as created by scanPathKey. Unlike
+// with path.Match, '*' matches any sequence of characters, including '/', as keys are
+// not file paths.
+func matchGlob(pattern, key string) bool {
+	p, k := 0, 0
+	star, next := -1, 0 // position after the last '*' and of the key to retry it from
+	for k < len(key) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			p++
+			star, next = p, k
+			continue
+		case p < len(pattern):
+			c := pattern[p]
+			n := 1
+			if c == '\\' && p+1 < len(pattern) {
+				c, n = pattern[p+1], 2
+			}
+			if c == key[k] {
+				p += n
+				k++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		next++
+		p, k = star, next
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// globToPathKey converts a pattern created by scanPathKey back to path syntax.
+func globToPathKey(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+			b.WriteString(escapePathKey(pattern[i : i+1]))
+			continue
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+// escapePathKey escapes characters with special meaning in path expressions.
+func escapePathKey(key string) string {
+	if !strings.ContainsAny(key, `.[]*\`) {
+		return key
+	}
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(`.[]*\`, key[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(key[i])
+	}
+	return b.String()
+}
+
+// JoinPath creates a path expression from segments, escaping keys as necessary.
+// It is the inverse of ParsePath.
+func JoinPath(segments []PathSegment) string {
+	var b strings.Builder
+	for i, s := range segments {
+		if i > 0 && !s.IsIndex {
+			b.WriteByte('.')
+		}
+		b.WriteString(s.String())
+	}
+	return b.String()
+}
+
+func pathError(expr string, pos int, msg string) error {
+	err := MakeNestedTextError(ErrCodeUsage, fmt.Sprintf("path %q: %s", expr, msg))
+	err.Column = pos
+	return err
+}
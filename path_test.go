@@ -0,0 +1,93 @@
+package nestext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	inputs := []struct {
+		expr     string
+		segments []PathSegment
+		joined   string
+	}{
+		{"", nil, ""},
+		{"president.address", []PathSegment{{Key: "president"}, {Key: "address"}}, ""},
+		{"roles[2]", []PathSegment{{Key: "roles"}, {Index: 2, IsIndex: true}}, ""},
+		{"[0][1].a", []PathSegment{{Index: 0, IsIndex: true}, {Index: 1, IsIndex: true}, {Key: "a"}}, ""},
+		{"servers.*.port", []PathSegment{{Key: "servers"}, {Key: "*", Wildcard: true}, {Key: "port"}}, ""},
+		{"list[*]", []PathSegment{{Key: "list"}, {Key: "*", Wildcard: true}}, "list.*"},
+		{`a\.b.c\[d\]\*`, []PathSegment{{Key: "a.b"}, {Key: "c[d]*"}}, ""},
+		{"additional roles", []PathSegment{{Key: "additional roles"}}, ""},
+		{"**.port", []PathSegment{{Key: "**", Recursive: true}, {Key: "port"}}, ""},
+		{`server-*.a\*b*`, []PathSegment{{Key: "server-*", Glob: true}, {Key: `a\*b*`, Glob: true}}, ""},
+	}
+	for i, input := range inputs {
+		segments, err := ParsePath(input.expr)
+		if err != nil {
+			t.Fatalf("[%d] %v", i, err)
+		}
+		if !reflect.DeepEqual(segments, input.segments) {
+			t.Errorf("[%d] expected %v, have %v", i, input.segments, segments)
+		}
+		joined := input.joined
+		if joined == "" {
+			joined = input.expr
+		}
+		if JoinPath(segments) != joined {
+			t.Errorf("[%d] expected joined path %q, have %q", i, joined, JoinPath(segments))
+		}
+	}
+}
+
+func TestPathSegmentMatchKey(t *testing.T) {
+	segments, err := ParsePath(`server-*.a\*b*[0].*.x?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []struct {
+		seg   int
+		key   string
+		match bool
+	}{
+		{0, "server-", true}, {0, "server-alpha", true}, {0, "server", false},
+		{1, "a*b", true}, {1, "a*bc", true}, {1, "ab", false}, {1, "axb", false},
+		{2, "0", false}, {3, "any", true}, {4, "x?", true}, {4, "xy", false},
+	}
+	for _, input := range inputs {
+		if segments[input.seg].MatchKey(input.key) != input.match {
+			t.Errorf("expected %v matching %q against %v", input.match, input.key, segments[input.seg])
+		}
+	}
+}
+
+func TestPathSegmentMatchKeySlashes(t *testing.T) {
+	// keys are no file paths, thus '*' matches '/' as well
+	segments, err := ParsePath(`routes.api*.*/v1.a*b*c`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []struct {
+		seg   int
+		key   string
+		match bool
+	}{
+		{1, "api/v1", true}, {1, "api/v1/users", true}, {1, "/api", false},
+		{2, "api/v1", true}, {2, "/v1", true}, {2, "api/v2", false},
+		{3, "a/b/c", true}, {3, "abbc", true}, {3, "a/c/b", false},
+	}
+	for _, input := range inputs {
+		if segments[input.seg].MatchKey(input.key) != input.match {
+			t.Errorf("expected %v matching %q against %v", input.match, input.key, segments[input.seg])
+		}
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	for _, expr := range []string{"a..b", ".a", "a[", "a[x]", "a[-1]", "a]", "a.", `a\`, "a[0]b"} {
+		_, err := ParsePath(expr)
+		if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeUsage {
+			t.Errorf("expected %q to be rejected, have %v", expr, err)
+		}
+	}
+}
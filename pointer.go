@@ -0,0 +1,102 @@
+package nestext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --- Pointers --------------------------------------------------------------
+
+// Pointers address values like JSON pointers (RFC 6901) do: reference tokens, each
+// prefixed by '/', with '~' escaped as "~0" and '/' as "~1". They are used for the
+// paths of types Comments and Positions, and allow interoperation with tools
+// emitting JSON pointers. Unlike path expressions, pointers do not distinguish list
+// indices from dict keys, so converting them to path expressions requires a tree.
+
+// ParsePointer splits a pointer into its reference tokens, resolving escapes. The
+// empty pointer addresses the top-level value and has no tokens.
+//
+// If a non-nil error is returned, it will be of type NestedTextError with code
+// ErrCodeUsage, with Column set to the position of the error within `ptr`.
+func ParsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if ptr[0] != '/' {
+		return nil, pointerError(ptr, 0, "must start with '/'")
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	pos := 1 // position of the current token within ptr
+	for i, t := range tokens {
+		for j := 0; j < len(t); j++ {
+			if t[j] == '~' && (j+1 == len(t) || t[j+1] != '0' && t[j+1] != '1') {
+				return nil, pointerError(ptr, pos+j, "invalid escape")
+			}
+		}
+		pos += len(t) + 1
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// PointerToPath converts a pointer to a path expression (see ParsePath), consulting
+// `tree` for the type of containers: tokens selecting items of lists become list
+// indices, all others become keys. This includes tokens below values missing from
+// `tree`, such that the result may be used for Set.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func PointerToPath(tree interface{}, ptr string) (string, error) {
+	tokens, err := ParsePointer(ptr)
+	if err != nil {
+		return "", err
+	}
+	segments := make([]PathSegment, len(tokens))
+	node, found := tree, true
+	for i, t := range tokens {
+		segments[i] = PathSegment{Key: t}
+		if l, ok := node.([]interface{}); ok && found {
+			n, err := strconv.Atoi(t)
+			if err != nil || n < 0 || strconv.Itoa(n) != t {
+				return "", MakeNestedTextError(ErrCodeSchema,
+					fmt.Sprintf("pointer %q: invalid list index %q", ptr, t))
+			}
+			segments[i] = PathSegment{Index: n, IsIndex: true}
+			found = n < len(l)
+			if found {
+				node = l[n]
+			}
+			continue
+		}
+		if found {
+			node, found = childAt(node, segments[i])
+		}
+	}
+	return JoinPath(segments), nil
+}
+
+// PathToPointer converts a path expression to a pointer. Paths must not contain
+// wildcards or globs.
+//
+// If a non-nil error is returned, it will be of type NestedTextError.
+func PathToPointer(path string) (string, error) {
+	segments, err := accessPath(path)
+	if err != nil {
+		return "", err
+	}
+	ptr := ""
+	for _, seg := range segments {
+		if seg.IsIndex {
+			ptr = AppendPath(ptr, strconv.Itoa(seg.Index))
+		} else {
+			ptr = AppendPath(ptr, seg.Key)
+		}
+	}
+	return ptr, nil
+}
+
+func pointerError(ptr string, pos int, msg string) error {
+	err := MakeNestedTextError(ErrCodeUsage, fmt.Sprintf("pointer %q: %s", ptr, msg))
+	err.Column = pos
+	return err
+}
@@ -0,0 +1,61 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	inputs := []struct {
+		ptr    string
+		tokens []string
+	}{
+		{"", nil},
+		{"/", []string{""}},
+		{"/president/roles/0", []string{"president", "roles", "0"}},
+		{"/a~1b/c~0d/~01", []string{"a/b", "c~d", "~1"}},
+	}
+	for _, input := range inputs {
+		tokens, err := ParsePointer(input.ptr)
+		if err != nil || !reflect.DeepEqual(tokens, input.tokens) {
+			t.Errorf("%q: expected %q, have %q, %v", input.ptr, input.tokens, tokens, err)
+		}
+	}
+	for _, ptr := range []string{"a", "/a~", "/a~2"} {
+		if _, err := ParsePointer(ptr); err == nil {
+			t.Errorf("expected %q to be rejected", ptr)
+		}
+	}
+	if _, err := ParsePointer("/ab/c~x"); err == nil || err.(NestedTextError).Column != 5 {
+		t.Errorf("expected error at column 5, have %v", err)
+	}
+}
+
+func TestPointerToPath(t *testing.T) {
+	tree, err := Parse(strings.NewReader("roles:\n  - a\n  -\n    x.y: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string]string{
+		"":                "",
+		"/roles/1/x.y":    `roles[1].x\.y`,
+		"/roles/5/a":      "roles[5].a",
+		"/missing/0/a~1b": "missing.0.a/b",
+	}
+	for ptr, expected := range inputs {
+		path, err := PointerToPath(tree, ptr)
+		if err != nil || path != expected {
+			t.Errorf("%q: expected %q, have %q, %v", ptr, expected, path, err)
+		}
+		if back, err := PathToPointer(path); err != nil || back != ptr {
+			t.Errorf("%q: expected pointer for %q to round-trip, have %q, %v", ptr, path, back, err)
+		}
+	}
+	if _, err := PointerToPath(tree, "/roles/first"); err == nil {
+		t.Error("expected invalid list index to be rejected")
+	}
+	if _, err := PathToPointer("roles.*"); err == nil {
+		t.Error("expected wildcard to be rejected")
+	}
+}
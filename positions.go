@@ -0,0 +1,195 @@
+package nestext
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// --- Source positions ------------------------------------------------------
+
+// Position is the location of an item within the source of a document. Positions of
+// list items and dict items span the item's tag or key, up to the end of its value,
+// including nested values.
+type Position struct {
+	Line      int   // first line of the item, starting at 1
+	Column    int   // column of the item's tag or key, i.e. its indentation, starting at 0
+	EndLine   int   // last line of the item, including nested values
+	Offset    int64 // byte offset of the item's tag or key
+	EndOffset int64 // byte offset following the item's last line, excluding the line terminator
+
+	// ValueOffset and ValueEndOffset delimit the source of the item's value. For values
+	// on the line of their key or tag, this is the text of the value itself; for nested
+	// values, it spans all of their lines, including tags and keys, starting at the
+	// first line's indentation. Empty values have an empty range at the end of the item.
+	// Tools may rewrite the bytes of a value in place, leaving the rest of the source
+	// untouched.
+	ValueOffset    int64
+	ValueEndOffset int64
+}
+
+// Positions maps paths of items to their positions. Paths are formed as described for
+// type Comments; the empty path "" denotes the top-level value.
+type Positions map[string]Position
+
+// ReportPositions requests the parser to store the source positions of all items of
+// the document into `pos`, after the document has been parsed successfully. This
+// allows applications to refer to the source when reporting problems with values.
+// Byte offsets refer to the input as read, even for lines the parser rewrites (see
+// KeepLegacyBidi, ExpandTabs and OnControlChars); with option AutoDedent, they refer
+// to the dedented input. ParseEvents does not support this option.
+//
+// Use as:
+//     var pos nestext.Positions
+//     config, err := nestext.Parse(reader, nestext.ReportPositions(&pos))
+//     …
+//     if !valid(port) {
+//         log.Printf("config.nt:%d: invalid port", pos["/server/port"].Line)
+//     }
+//
+func ReportPositions(pos *Positions) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.positions = pos
+		return nil
+	}
+}
+
+// lineSpan is the extent of an input line, excluding the line terminator.
+type lineSpan struct {
+	start   int64  // byte offset of the line
+	length  int    // length of the line in bytes
+	skipped bool   // is this a blank line or comment line?
+	raw     string // text of the input line, if it has been rewritten
+	text    string // text of the rewritten line, if it has been rewritten
+}
+
+// rawColumn translates byte column `col` of the rewritten text of line `span` to the
+// byte column of the input line, undoing the removal of bidi control characters, the
+// escaping of control characters and the expansion of tabs.
+func (p *nestedTextParser) rawColumn(span lineSpan, col int) int {
+	if span.raw == "" {
+		return col
+	}
+	tabStop := p.tabStop
+	if tabStop == 0 {
+		tabStop = defaultTabStop
+	}
+	i, j := 0, 0 // byte offsets into the input line and into the rewritten line
+	for j < col && i < len(span.raw) {
+		r, n := utf8.DecodeRuneInString(span.raw[i:])
+		switch {
+		case strings.HasPrefix(span.text[j:], span.raw[i:i+n]):
+			j += n
+		case isBidiControl(r): // removed
+		case r == '\t':
+			j += tabStop - j%tabStop
+		default:
+			j += len(escapeControl(r))
+		}
+		i += n
+	}
+	return i
+}
+
+// lineRead records the extent of a line, as reported by the line buffer.
+func (p *nestedTextParser) lineRead(start int64, length int) {
+	p.lineSpans = append(p.lineSpans, lineSpan{start: start, length: length})
+}
+
+// tracking is true if paths of items have to be tracked.
+func (p *nestedTextParser) tracking() bool {
+	return p.comments != nil || p.positions != nil
+}
+
+// hooks returns the line buffer callbacks needed for tracking comments or positions,
+// for reporting skipped lines, for stripping bidi control characters, or for expanding
+// tabs.
+func (p *nestedTextParser) hooks() lineHooks {
+	var hooks lineHooks
+	if p.tracking() || p.onSkipped != nil {
+		hooks.Skipped = p.skipped
+	}
+	if p.positions != nil {
+		hooks.Read = p.lineRead
+	}
+	if p.expanding() || p.sanitizing() {
+		hooks.Rewrite = p.rewrite
+	}
+	return hooks
+}
+
+// sanitizing checks if lines are rewritten for bidi control characters or for control
+// characters.
+func (p *nestedTextParser) sanitizing() bool {
+	return !p.keepBidi || p.controls == ControlEscape
+}
+
+// sanitize strips bidi control characters from line number `line`, unless they are
+// kept, and escapes control characters, if requested.
+func (p *nestedTextParser) sanitize(line int, text string) string {
+	if !p.keepBidi {
+		text = p.stripBidi(line, text)
+	}
+	if p.controls == ControlEscape {
+		text = p.escapeControls(line, text)
+	}
+	return text
+}
+
+// rewrite is the Rewrite callback for the line buffer. It sanitizes line number `line`
+// and expands tabs, if requested.
+func (p *nestedTextParser) rewrite(line int, text string) string {
+	raw := text
+	text = p.sanitize(line, text)
+	if p.expanding() {
+		text = p.expandTabs(line, text)
+	}
+	if p.positions != nil && text != raw && line <= len(p.lineSpans) {
+		p.lineSpans[line-1].raw, p.lineSpans[line-1].text = raw, text
+	}
+	return text
+}
+
+// markNested records the start of a nested value, which has been parsed completely.
+func (p *nestedTextParser) markNested(line, indent int) {
+	p.nestedLine, p.nestedIndent = line, indent
+}
+
+// recordPosition records the position of the item starting at `line` with indentation
+// `indent` and value `value`. The item ends with the last item line preceding the current
+// token. A nil value denotes the top-level value, which is its own value.
+func (p *nestedTextParser) recordPosition(path string, line, indent int, value interface{}) {
+	end := p.token.LineNo - 1
+	if end > len(p.lineSpans) {
+		end = len(p.lineSpans)
+	}
+	for end > line && p.lineSpans[end-1].skipped {
+		end--
+	}
+	if line < 1 || end < line {
+		return
+	}
+	first, last := p.lineSpans[line-1], p.lineSpans[end-1]
+	pos := Position{
+		Line:      line,
+		Column:    indent + p.dedent,
+		EndLine:   end,
+		Offset:    first.start + int64(p.rawColumn(first, indent)),
+		EndOffset: last.start + int64(last.length),
+	}
+	pos.ValueEndOffset = pos.EndOffset
+	if value == nil {
+		pos.ValueOffset = pos.Offset
+	} else if p.nestedLine > line && p.nestedLine <= end {
+		// nested values of previous items end before `line`, thus cannot be mistaken
+		nested := p.lineSpans[p.nestedLine-1]
+		pos.ValueOffset = nested.start + int64(p.rawColumn(nested, p.nestedIndent))
+	} else if s, ok := value.(string); ok && end == line && first.raw != "" {
+		// the value is the rest of the rewritten line
+		pos.ValueOffset = first.start + int64(p.rawColumn(first, len(first.text)-len(s)))
+	} else if s, ok := value.(string); ok && end == line {
+		pos.ValueOffset = pos.EndOffset - int64(len(s))
+	} else {
+		pos.ValueOffset = pos.EndOffset
+	}
+	(*p.positions)[path] = pos
+}
@@ -0,0 +1,77 @@
+package nestext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReportPositions(t *testing.T) {
+	input := "# servers\nserver:\n  host: example.com\n  port: 80\n\n  aliases:\n    - a\n    -\n      > b\n      > c\n\n# end\n"
+	var pos Positions
+	if _, err := Parse(strings.NewReader(input), ReportPositions(&pos)); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Position{
+		"":                  {Line: 2, Column: 0, EndLine: 10, Offset: 10, EndOffset: 94, ValueOffset: 10, ValueEndOffset: 94},
+		"/server":           {Line: 2, Column: 0, EndLine: 10, Offset: 10, EndOffset: 94, ValueOffset: 20, ValueEndOffset: 94},
+		"/server/port":      {Line: 4, Column: 2, EndLine: 4, Offset: 40, EndOffset: 48, ValueOffset: 46, ValueEndOffset: 48},
+		"/server/aliases":   {Line: 6, Column: 2, EndLine: 10, Offset: 52, EndOffset: 94, ValueOffset: 65, ValueEndOffset: 94},
+		"/server/aliases/0": {Line: 7, Column: 4, EndLine: 7, Offset: 65, EndOffset: 68, ValueOffset: 67, ValueEndOffset: 68},
+		"/server/aliases/1": {Line: 8, Column: 4, EndLine: 10, Offset: 73, EndOffset: 94, ValueOffset: 81, ValueEndOffset: 94},
+	}
+	for path, p := range expected {
+		if pos[path] != p {
+			t.Errorf("expected position of %q to be %+v, have %+v", path, p, pos[path])
+		}
+	}
+	if len(pos) != 7 {
+		t.Errorf("expected 7 positions, have %d", len(pos))
+	}
+	if span := input[pos["/server/port"].Offset:pos["/server/port"].EndOffset]; span != "port: 80" {
+		t.Errorf("unexpected span of port %q", span)
+	}
+	if span := input[pos["/server/host"].ValueOffset:pos["/server/host"].ValueEndOffset]; span != "example.com" {
+		t.Errorf("unexpected value span of host %q", span)
+	}
+}
+
+func TestParseReportPositionsEmptyValues(t *testing.T) {
+	input := "a:\nb:\n  > x\n: c\n  > d\n"
+	var pos Positions
+	if _, err := Parse(strings.NewReader(input), ReportPositions(&pos)); err != nil {
+		t.Fatal(err)
+	}
+	if p := pos["/a"]; p.ValueOffset != 2 || p.ValueEndOffset != 2 {
+		t.Errorf("expected empty value range at end of item, have %+v", p)
+	}
+	if p := pos["/b"]; input[p.ValueOffset:p.ValueEndOffset] != "> x" {
+		t.Errorf("unexpected value span of multi-line string %+v", p)
+	}
+	if p := pos["/c"]; input[p.ValueOffset:p.ValueEndOffset] != "> d" {
+		t.Errorf("unexpected value span of item with multi-line key %+v", p)
+	}
+}
+
+func TestParseReportPositionsRewrittenLines(t *testing.T) {
+	inputs := []struct {
+		input, path, value string
+	}{
+		{"a: x\u200ey\nb: z\n", "/a", "x\u200ey"},
+		{"k\u200e: xy\n", "/k", "xy"},
+		{"a:\n\t- x\n", "/a", "- x"},
+		{"a:\n\t- x\n", "/a/0", "x"},
+		{"a: x\x01y\nb: z\n", "/a", "x\x01y"},
+	}
+	for _, in := range inputs {
+		var pos Positions
+		_, err := Parse(strings.NewReader(in.input), ReportPositions(&pos), ExpandTabs(4),
+			OnControlChars(ControlEscape))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := pos[in.path]
+		if span := in.input[p.ValueOffset:p.ValueEndOffset]; span != in.value {
+			t.Errorf("%q: expected value span %q for %s, have %q", in.input, in.value, in.path, span)
+		}
+	}
+}
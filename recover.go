@@ -0,0 +1,177 @@
+package nestext
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// --- Continue on errors ----------------------------------------------------
+
+// ContinueOnError requests the parser to record format errors and to continue
+// parsing, instead of stopping at the first error. Editors and linters may thus
+// report all the problems of a document at once.
+//
+// Lines which cannot be recognized are skipped. If an item of a list or dict is
+// malformed, the parser skips it, including all lines nested under it, and continues
+// with the next item of the list or dict. Skipped items are missing from the result.
+//
+// If any errors have been recorded, Parse returns the partial result together with
+// an error of type ErrorList, holding all the errors in order of their lines:
+//
+//     tree, err := nestext.Parse(reader, nestext.ContinueOnError())
+//     if errs, ok := err.(nestext.ErrorList); ok {
+//         for _, e := range errs {
+//             log.Printf("line %d: %v", e.Line, e)
+//         }
+//     }
+//
+// Errors other than format errors, e.g. I/O errors or errors for exceeded limits,
+// stop parsing. They are returned as the last error of the list, with a nil result.
+// ParseEvents does not support this option.
+//
+func ContinueOnError() Option {
+	return func(p *nestedTextParser) (err error) {
+		p.collect = true
+		return nil
+	}
+}
+
+// ResumeAtTopLevel is like ContinueOnError, but recovers from errors at the granularity
+// of top-level items, as needed for editor tooling on half-written documents. If a
+// top-level item is malformed anywhere within its subtree, the parser skips to the next
+// line without indentation and continues with the next top-level item. The value of the
+// malformed item is replaced by a *Placeholder, holding the first error of the item:
+//
+//     tree, err := nestext.Parse(reader, nestext.ResumeAtTopLevel())
+//     for key, value := range tree.(map[string]interface{}) {
+//         if p, ok := value.(*nestext.Placeholder); ok {
+//             log.Printf("item %q at line %d is broken: %v", key, p.Line, p.Err)
+//         }
+//     }
+//
+// Items with multi-line keys, for which the key is unknown, and lines which cannot be
+// recognized as items are left out of the result. Further errors within a malformed
+// top-level item are not reported. Errors are returned as described for option
+// ContinueOnError. ParseEvents does not support this option.
+//
+func ResumeAtTopLevel() Option {
+	return func(p *nestedTextParser) (err error) {
+		p.collect, p.resumeTop = true, true
+		return nil
+	}
+}
+
+// Placeholder replaces the value of a malformed top-level item in results of Parse with
+// option ResumeAtTopLevel.
+type Placeholder struct {
+	Line int             // line of the item
+	Err  NestedTextError // first error of the item
+}
+
+func (ph *Placeholder) String() string {
+	return fmt.Sprintf("<error: %v>", ph.Err)
+}
+
+// ErrorList is a list of errors, returned by Parse if option ContinueOnError is set.
+type ErrorList []NestedTextError
+
+// Error returns the messages of all the errors, separated by newlines.
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of the list, thus errors.Is and errors.As (as of Go 1.20)
+// check each of them.
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// recoverable checks if parsing may continue after error `err`, i.e. if it is a format
+// error of a line or an item. The inline parser reports unterminated inline items as
+// I/O errors wrapping io.EOF.
+func recoverable(err error) bool {
+	nterr, ok := err.(NestedTextError)
+	if ok && nterr.Code == ErrCodeIO {
+		return errors.Is(err, io.EOF)
+	}
+	return ok && nterr.Code >= ErrCodeFormat && nterr.Code != ErrCodeFormatNoInput
+}
+
+// skipLine is the Recover callback for the scanner, recording format errors of lines.
+func (p *nestedTextParser) skipLine(err error) bool {
+	if !recoverable(err) {
+		return false
+	}
+	p.record(err.(NestedTextError))
+	return true
+}
+
+// recoverItem is called if parsing an item of the container at `indent` failed with
+// error `err`. If the error is recoverable, it is recorded and the parser skips to the
+// next item, restoring the parser stack to `depth` entries. `start` is the token the
+// item started with. Otherwise the error is returned.
+func (p *nestedTextParser) recoverItem(err error, start *parserToken, depth, indent int) error {
+	if !p.collect || !recoverable(err) || p.resumeTop && depth > 1 {
+		return err // for top-level resumption, the error travels up to the top-level item
+	}
+	nterr := err.(NestedTextError)
+	if nterr.Line == 0 { // position the error at the offending token
+		nterr.Line, nterr.Column = p.token.LineNo, p.token.Indent
+	}
+	p.record(nterr)
+	p.stack = p.stack[:depth]
+	if p.token == start && p.token.TokenType != eof { // make progress
+		p.token = p.sc.NextToken()
+	}
+	for p.token.Error == nil && p.token.TokenType != eof && p.token.Indent > indent {
+		p.sc.SkipIndented(p.token.Indent)
+		p.token = p.sc.NextToken()
+	}
+	return p.token.Error
+}
+
+// placeholder returns the value of the top-level item which started with token `start`
+// and was parsed with errors since the count of errors was `errs`, or nil if the item is
+// not a top-level item, or if it has been parsed without errors. For items with
+// multi-line keys, `ok` is false.
+func (p *nestedTextParser) placeholder(start *parserToken, depth, errs int) (ph *Placeholder, ok bool) {
+	if !p.resumeTop || depth > 1 || len(p.errors) == errs {
+		return nil, true
+	}
+	return &Placeholder{Line: start.LineNo, Err: p.finish(p.errors[errs], 0)}, start.TokenType != dictKeyMultiline
+}
+
+// collectedErrors returns the errors recorded so far, followed by `err`, if present.
+// Column numbers refer to the original input, if it has been dedented.
+func (p *nestedTextParser) collectedErrors(err error) error {
+	if err != nil {
+		nterr, ok := err.(NestedTextError)
+		if !ok {
+			nterr = WrapError(ErrCodeFormat, err.Error(), err)
+		}
+		p.record(nterr)
+	}
+	if len(p.errors) == 0 {
+		return nil
+	}
+	errs := make(ErrorList, len(p.errors))
+	copy(errs, p.errors)
+	for i := range errs {
+		if p.dedent > 0 && errs[i].Line > 0 {
+			errs[i].Column += p.dedent
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+	return errs
+}
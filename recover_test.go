@@ -0,0 +1,152 @@
+package nestext
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseContinueOnError(t *testing.T) {
+	inputs := []struct {
+		text   string
+		result interface{}
+		lines  []int // lines of errors
+	}{
+		{"a: 1\nb: 2\n", map[string]interface{}{"a": "1", "b": "2"}, nil},
+		{"a: 1\nbad line\nc: 3\n", map[string]interface{}{"a": "1", "c": "3"}, []int{2}},
+		{"- a\n    - b\n    - c\n- d\n", []interface{}{"a", "d"}, []int{2}},
+		{"a:\n  [x, y\nb: 2\n", map[string]interface{}{"a": "", "b": "2"}, []int{2}},
+		{"a:\n  [x, [y]\nb: 2\n", map[string]interface{}{"b": "2"}, []int{2}},
+		{"a:\n  - x\n  oops\n  - y\nb:\n  c: 1\n  d\n", map[string]interface{}{
+			"a": []interface{}{"x", "y"},
+			"b": map[string]interface{}{"c": "1"},
+		}, []int{3, 7}},
+		{"a:\n  b:\n    c: 1\n      d: 2\n    e: 3\nf: 4\n", map[string]interface{}{
+			"a": map[string]interface{}{},
+			"f": "4",
+		}, []int{4}},
+	}
+	for i, input := range inputs {
+		result, err := Parse(strings.NewReader(input.text), ContinueOnError())
+		if !reflect.DeepEqual(result, input.result) {
+			t.Errorf("%d: expected partial result %#v, have %#v", i, input.result, result)
+		}
+		if input.lines == nil {
+			if err != nil {
+				t.Errorf("%d: expected no error, have %v", i, err)
+			}
+			continue
+		}
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != len(input.lines) {
+			t.Errorf("%d: expected %d errors, have %v", i, len(input.lines), err)
+			continue
+		}
+		for j, line := range input.lines {
+			if errs[j].Line != line {
+				t.Errorf("%d: expected error at line %d, have %v", i, line, errs[j])
+			}
+		}
+	}
+}
+
+func TestParseContinueOnErrorFatal(t *testing.T) {
+	input := "a: 1\nbad line\nb:\n  [[[x]]]\n"
+	result, err := Parse(strings.NewReader(input), ContinueOnError(), MaxDepth(2))
+	if result != nil {
+		t.Errorf("expected no result, have %#v", result)
+	}
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 2 || errs[1].Code != ErrCodeTooDeep {
+		t.Fatalf("expected format error followed by depth error, have %v", err)
+	}
+	var nterr NestedTextError
+	if !errors.As(errs[0], &nterr) || nterr.Line != 2 {
+		t.Errorf("expected first error at line 2, have %v", errs[0])
+	}
+	if !strings.Contains(err.Error(), "\n") {
+		t.Errorf("expected one line per error, have %q", err.Error())
+	}
+}
+
+func TestParseContinueOnErrorOnlyErrors(t *testing.T) {
+	result, err := Parse(strings.NewReader("[a, b\n"), ContinueOnError())
+	if result != nil {
+		t.Errorf("expected no result, have %#v", result)
+	}
+	if errs, ok := err.(ErrorList); !ok || len(errs) != 1 || errs[0].Line != 1 {
+		t.Errorf("expected a single error at line 1, have %v", err)
+	}
+}
+
+func TestParseResumeAtTopLevel(t *testing.T) {
+	inputs := []struct {
+		text   string
+		broken string // key or index of the placeholder
+		lines  []int  // lines of placeholder and errors
+		result string
+	}{
+		{"a: 1\nb:\n  c:\n    - x\n    y\n  d: 2\ne: 3\n", "b", []int{2, 5},
+			"map[a:1 b:<error> e:3]"},
+		{"- a\n-\n  x: 1\n  - y\n  z: 2\n- c\n", "1", []int{2, 4},
+			"[a <error> c]"},
+		{"a:\n  b: 1\n  bad line\n  c: 2\nd: 3\n", "a", []int{1, 3},
+			"map[a:<error> d:3]"},
+		{"a:\n  b:\n    {x: [}\nc:\n  - y\n", "a", []int{1, 3},
+			"map[a:<error> c:[y]]"},
+		{": k\n: l\n  - x\n  y\ne: 1\n", "", []int{4},
+			"map[e:1]"},
+	}
+	for i, input := range inputs {
+		result, err := Parse(strings.NewReader(input.text), ResumeAtTopLevel())
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 || errs[0].Line != input.lines[len(input.lines)-1] {
+			t.Errorf("%d: expected a single error at line %d, have %v", i, input.lines[len(input.lines)-1], err)
+			continue
+		}
+		var ph interface{}
+		switch r := result.(type) {
+		case map[string]interface{}:
+			ph = r[input.broken]
+		case []interface{}:
+			index, _ := strconv.Atoi(input.broken)
+			ph = r[index]
+		}
+		if input.broken != "" {
+			if p, ok := ph.(*Placeholder); !ok || p.Line != input.lines[0] || p.Err != errs[0] {
+				t.Errorf("%d: expected placeholder for item at line %d, have %#v", i, input.lines[0], ph)
+			}
+		}
+		out := regexp.MustCompile(`<error: [^>]*>`).ReplaceAllString(fmt.Sprint(result), "<error>")
+		if out != input.result {
+			t.Errorf("%d: expected %s, have %s", i, input.result, out)
+		}
+	}
+}
+
+func TestParseResumeWithListChecks(t *testing.T) {
+	// placeholders must not disturb the lines of the items checked
+	for _, recovery := range []Option{ResumeAtTopLevel(), ContinueOnError()} {
+		_, err := Parse(strings.NewReader("- a\n-\n  \t> x\n"), recovery, NoMixedLists())
+		if errs, ok := err.(ErrorList); !ok || len(errs) != 1 || errs[0].Line != 3 {
+			t.Errorf("expected a single error at line 3, have %v", err)
+		}
+		_, err = Parse(strings.NewReader("- a\n-\n  x: 1\n  - y\n-\n  - b\n"), recovery, NoMixedLists())
+		if err == nil || !strings.Contains(err.Error(), "different type at line(s) 5") {
+			t.Errorf("expected mixed item at line 5, have %v", err)
+		}
+		var warnings []NestedTextError
+		_, err = Parse(strings.NewReader("- a\n-\n  x: 1\n  - y\n- b\n- a\n"), recovery, WarnDuplicateItems(),
+			ReportWarnings(func(w NestedTextError) { warnings = append(warnings, w) }))
+		if _, ok := err.(ErrorList); !ok {
+			t.Errorf("expected list of errors, have %v", err)
+		}
+		if len(warnings) != 1 || warnings[0].Line != 6 || !strings.Contains(warnings[0].Error(), "at line 1") {
+			t.Errorf("expected duplicate at line 6 of item at line 1, have %v", warnings)
+		}
+	}
+}
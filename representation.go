@@ -0,0 +1,54 @@
+package nestext
+
+import "strings"
+
+// --- Representability ------------------------------------------------------
+
+// The following functions check how strings may be represented in NestedText,
+// following the rules of the spec. They are used by the encoder of package ntenc and
+// are intended for clients creating NestedText documents by other means, e.g. from
+// templates.
+
+// NeedsMultiline checks if a string value has to be represented as a multi-line
+// string ("> " lines), because it contains line breaks. Other values may follow a
+// list tag ("- ") or a dict key on the same line.
+func NeedsMultiline(value string) bool {
+	return strings.ContainsAny(value, "\n\r")
+}
+
+// NeedsMultilineKey checks if a dict key has to be represented as a multi-line key
+// (": " lines), instead of in front of the value ("key: value"). This is the case for
+// keys which
+//
+//   - are empty or contain line breaks,
+//   - have leading or trailing white space,
+//   - start like a tag ("- ", "> ", ": "), an inline list or dict, or a comment,
+//   - contain ": ".
+//
+func NeedsMultilineKey(key string) bool {
+	if key == "" || NeedsMultiline(key) || strings.TrimSpace(key) != key {
+		return true
+	}
+	switch key[0] {
+	case '[', '{', '#':
+		return true
+	}
+	for _, tag := range []string{"- ", "> ", ": "} {
+		if strings.HasPrefix(key, tag) {
+			return true
+		}
+	}
+	return strings.Contains(key, ": ")
+}
+
+// IsValidInlineValue checks if a string may be given as a key or value of an inline
+// dict ("{key: value}") or as an item of an inline list ("[a, b]"). Such strings may
+// not contain line breaks, any of the characters "[]{},:" and leading or trailing
+// white space. Empty strings are not considered valid, as they may be represented in
+// some positions only.
+//
+// Items of inline lists may contain ':', so this check is stricter than necessary
+// for them.
+func IsValidInlineValue(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "[]{},:\n\r") && strings.TrimSpace(s) == s
+}
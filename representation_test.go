@@ -0,0 +1,49 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNeedsMultilineKey(t *testing.T) {
+	keys := map[string]bool{
+		"name": false, "a:b": false, "a:": false, "-": false, "-x": false, ">": false,
+		"key with spaces": false, "": true, "a\nb": true, " a": true, "a ": true,
+		"- a": true, "> a": true, ": a": true, "[a]": true, "{a}": true, "# a": true, "a: b": true,
+	}
+	for key, multiline := range keys {
+		if NeedsMultilineKey(key) != multiline {
+			t.Errorf("expected NeedsMultilineKey(%q) to be %v", key, multiline)
+		}
+		if multiline {
+			continue
+		}
+		tree, err := Parse(strings.NewReader(key + ": x\n"))
+		if err != nil || !reflect.DeepEqual(tree, map[string]interface{}{key: "x"}) {
+			t.Errorf("expected key %q to be valid on a key-value line, have %#v, %v", key, tree, err)
+		}
+	}
+}
+
+func TestIsValidInlineValue(t *testing.T) {
+	values := map[string]bool{
+		"a": true, "a b": true, "#x": true, "": false, " a": false, "a,b": false,
+		"a:b": false, "[": false, "}": false, "a\nb": false,
+	}
+	for value, valid := range values {
+		if IsValidInlineValue(value) != valid {
+			t.Errorf("expected IsValidInlineValue(%q) to be %v", value, valid)
+		}
+		if !valid {
+			continue
+		}
+		tree, err := Parse(strings.NewReader("{" + value + ": " + value + "}"))
+		if err != nil || !reflect.DeepEqual(tree, map[string]interface{}{value: value}) {
+			t.Errorf("expected %q to be valid in inline dicts, have %#v, %v", value, tree, err)
+		}
+	}
+	if !NeedsMultiline("a\r\nb") || NeedsMultiline("a b") {
+		t.Errorf("expected NeedsMultiline to detect line breaks")
+	}
+}
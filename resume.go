@@ -0,0 +1,204 @@
+package nestext
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// --- Resumable parsing of list documents -----------------------------------
+
+// Checkpoint marks a position in a NestedText document with a top-level list,
+// located between two list items. Checkpoints are used for resuming parsing
+// of append-only documents (e.g., log-like lists of records), after new items have
+// been appended to the document.
+//
+// The zero value denotes the start of a document.
+type Checkpoint struct {
+	Offset int64 // byte offset of the first line not yet consumed
+	Line   int   // count of lines consumed up to Offset
+}
+
+// ParseListItems reads top-level list items from r, calling `yield` for every
+// complete item. r has to be positioned at byte offset `from.Offset` of the document,
+// e.g., by seeking an os.File. Options are applied to the parsing of each item.
+// Options which change the structure of the result (TopLevel("dict"), SelectPaths)
+// are rejected with an error of code ErrCodeUsage.
+//
+// It returns a checkpoint located after the last item handed to `yield`, which may
+// be used to resume parsing after more items have been appended to the document:
+//
+//     cp := nestext.Checkpoint{}
+//     for {
+//         f.Seek(cp.Offset, io.SeekStart)
+//         cp, err = nestext.ParseListItems(f, cp, handleItem)
+//         …  // wait for the file to grow
+//     }
+//
+// An item counts as complete if either it is followed by the next top-level item,
+// or if it is a single-line item terminated by a newline. Items at the end of the
+// input which possibly are not yet completely written are left for the next call.
+//
+// If `yield` returns an error, parsing stops and the error is returned, together
+// with a checkpoint located after the last item successfully yielded.
+// If a non-nil error is returned by the parser, it will be of type NestedTextError.
+//
+func ParseListItems(r io.Reader, from Checkpoint, yield func(item interface{}) error,
+	opts ...Option) (Checkpoint, error) {
+	//
+	if r == nil {
+		return from, makeParsingError(nil, ErrCodeFormatNoInput, "no input present")
+	}
+	if err := checkListItemOptions(opts); err != nil {
+		return from, err
+	}
+	lr := newRawLineReader(r, maxLineLength(opts))
+	cp, pos := from, from
+	var item bytes.Buffer // lines of the current list item
+	var itemLine int      // line number of the current item's first line
+	var lines int         // count of non-comment lines in item
+	flush := func() error {
+		if item.Len() == 0 {
+			return nil
+		}
+		result, err := Parse(bytes.NewReader(item.Bytes()), opts...)
+		if err != nil {
+			return shiftErrorPosition(err, itemLine-1)
+		}
+		items, ok := result.([]interface{})
+		if !ok || len(items) != 1 {
+			return MakeNestedTextError(ErrCodeUsage, "options must not change the structure of list items")
+		}
+		if err = yield(items[0]); err != nil {
+			return err
+		}
+		item.Reset()
+		cp = pos
+		return nil
+	}
+	for lr.next() {
+		if !lr.terminated { // line is not completely written yet
+			break
+		}
+		line := lr.line
+		if !isBlankOrComment(line) && line[0] != ' ' { // new top-level item
+			if err := flush(); err != nil {
+				return cp, err
+			}
+			if line != "-" && !strings.HasPrefix(line, "- ") {
+				err := NestedTextError{Code: ErrCodeFormat, Line: pos.Line + 1, Column: 0,
+					Message: "top-level item is not a list item"}
+				return cp, err
+			}
+			itemLine, lines = pos.Line+1, 0
+		}
+		if item.Len() > 0 || !isBlankOrComment(line) {
+			item.WriteString(line)
+			item.WriteByte('\n')
+			if !isBlankOrComment(line) {
+				lines++
+			}
+		} else { // skip comments between items
+			cp.Offset, cp.Line = pos.Offset+lr.consumed, pos.Line+1
+		}
+		pos.Offset += lr.consumed
+		pos.Line++
+	}
+	if lr.err != nil {
+		return cp, readError(lr.err, pos.Line+1, lr.maxLine)
+	}
+	// at end of input: an item spanning more than one line might still be growing
+	if lines == 1 && strings.HasPrefix(item.String(), "- ") {
+		return cp, flush()
+	}
+	return cp, nil
+}
+
+// checkListItemOptions rejects options for ParseListItems which change the structure
+// of the result, as every item is parsed as a single-item list document.
+func checkListItemOptions(opts []Option) error {
+	p := newParser()
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return err
+		}
+	}
+	if p.toplevel != "" && p.toplevel != "list" {
+		return MakeNestedTextError(ErrCodeUsage, "option TopLevel is not supported for list items")
+	}
+	if p.selectors != nil {
+		return MakeNestedTextError(ErrCodeUsage, "option SelectPaths is not supported for list items")
+	}
+	return nil
+}
+
+// shiftErrorPosition adjusts the line numbers of a NestedTextError by a given amount
+// of lines. Byte offsets are reset, as they refer to the lines re-assembled for parsing,
+// which may differ from the input in their line terminators.
+func shiftErrorPosition(err error, lines int) error {
+	if nterr, ok := err.(NestedTextError); ok && nterr.Line > 0 {
+		nterr.Line += lines
+		if nterr.EndLine > 0 {
+			nterr.EndLine += lines
+		}
+		nterr.Offset, nterr.EndOffset = 0, 0
+		return nterr
+	}
+	return err
+}
+
+// isBlankOrComment is a predicate for lines which are ignored by the parser.
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimLeft(line, " \t")
+	return trimmed == "" || trimmed[0] == '#'
+}
+
+// rawLineReader reads lines from an input, splitting them according to the spec,
+// and remembers the number of bytes consumed for each line.
+type rawLineReader struct {
+	input      *bufio.Scanner
+	line       string // current line, without line terminator
+	consumed   int64  // bytes consumed by the current line, including line terminator
+	terminated bool   // has the current line been terminated by a line break?
+	err        error  // I/O error, if any
+	maxLine    int    // maximum length of a line in bytes, 0 for the default
+	started    bool   // has the start of the input been checked for a byte order mark?
+	bom        int64  // length of a byte order mark, counted with the first line
+}
+
+func newRawLineReader(r io.Reader, maxLine int) *rawLineReader {
+	lr := &rawLineReader{input: bufio.NewScanner(r), maxLine: maxLine}
+	limitLineLength(lr.input, maxLine)
+	lr.input.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if !lr.started {
+			n, err := byteOrderMark(data, atEOF)
+			if n < 0 || err != nil {
+				return 0, nil, err
+			}
+			lr.started, lr.bom = true, int64(n)
+			if n > 0 {
+				return n, nil, nil
+			}
+		}
+		advance, token, err := splitLines(data, atEOF)
+		if maxLine > 0 && len(token) > maxLine {
+			return 0, nil, bufio.ErrTooLong
+		}
+		if token != nil {
+			lr.consumed, lr.bom = int64(advance)+lr.bom, 0
+			lr.terminated = advance > len(token)
+		}
+		return advance, token, err
+	})
+	return lr
+}
+
+func (lr *rawLineReader) next() bool {
+	if !lr.input.Scan() {
+		lr.err = lr.input.Err()
+		return false
+	}
+	lr.line = lr.input.Text()
+	return true
+}
@@ -0,0 +1,94 @@
+package nestext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseListItemsResume(t *testing.T) {
+	doc := "# audit log\n- first\n-\n  who: me\n  what: something\n"
+	var items []interface{}
+	collect := func(item interface{}) error {
+		items = append(items, item)
+		return nil
+	}
+	cp, err := ParseListItems(strings.NewReader(doc), Checkpoint{}, collect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0] != "first" {
+		t.Fatalf("expected only first item to be complete, have %#v", items)
+	}
+	t.Logf("checkpoint = %+v", cp)
+	// now the writer appends more items, one of them incomplete
+	doc += "- third\n- four"
+	cp, err = ParseListItems(strings.NewReader(doc[cp.Offset:]), cp, collect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[2] != "third" {
+		t.Fatalf("expected three items, have %#v", items)
+	}
+	if m, ok := items[1].(map[string]interface{}); !ok || m["who"] != "me" {
+		t.Errorf("expected second item to be a dict, is %#v", items[1])
+	}
+	if doc[cp.Offset:] != "- four" || cp.Line != 6 {
+		t.Errorf("expected checkpoint to be located at line 6 before '- four', is %+v", cp)
+	}
+}
+
+func TestParseListItemsErrorPosition(t *testing.T) {
+	doc := "- first\n- second\n-\n  > ok\n  - bad\n- last\n"
+	cp, err := ParseListItems(strings.NewReader(doc), Checkpoint{}, func(interface{}) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected error for mixed item, didn't get one")
+	}
+	t.Logf("error = %v", err)
+	if cp.Line != 2 {
+		t.Errorf("expected checkpoint after second item, is %+v", cp)
+	}
+	doc = "key: value\n"
+	if _, err = ParseListItems(strings.NewReader(doc), Checkpoint{}, nil); err == nil {
+		t.Error("expected dict document to be rejected, wasn't")
+	}
+}
+
+func TestParseListItemsOptions(t *testing.T) {
+	doc := "- a: 1\n- b: 2\n"
+	yield := func(item interface{}) error { return nil }
+	for _, opt := range []Option{TopLevel("dict"), SelectPaths("/x")} {
+		_, err := ParseListItems(strings.NewReader(doc), Checkpoint{}, yield, opt)
+		if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeUsage {
+			t.Errorf("expected usage error, have %v", err)
+		}
+	}
+	var items []interface{}
+	_, err := ParseListItems(strings.NewReader(doc), Checkpoint{}, func(item interface{}) error {
+		items = append(items, item)
+		return nil
+	}, TopLevel("list"))
+	if err != nil || len(items) != 2 {
+		t.Errorf("expected two items, have %v, %#v", err, items)
+	}
+}
+
+func TestParseListItemsByteOrderMark(t *testing.T) {
+	doc := "\ufeff- first\n- second\n"
+	var items []interface{}
+	cp, err := ParseListItems(strings.NewReader(doc), Checkpoint{}, func(item interface{}) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil || len(items) != 2 || items[0] != "first" {
+		t.Fatalf("expected two items, have %#v, %v", items, err)
+	}
+	if cp.Offset != int64(len(doc)) || cp.Line != 2 {
+		t.Errorf("expected checkpoint at the end of the document, is %+v", cp)
+	}
+	_, err = ParseListItems(strings.NewReader("\xff\xfe- x\n"), Checkpoint{}, func(interface{}) error { return nil })
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Code != ErrCodeEncoding {
+		t.Errorf("expected encoding error, have %v", err)
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// TODO: set new ScanLines function which will break on 'CR' without following 'LF' (see spec)
-
 // We will be using two different scanners:
 //
 // - a line-level scanner, concerned with recognizing lines of input as tokens.
@@ -24,9 +24,12 @@ import (
 // subsequent step function. Step functions may consume input characters ("match(…)").
 //
 type scanner struct {
-	Buf       *lineBuffer // line buffer abstracts away properties of input readers
-	Step      scannerStep // the next scanner step to execute in a chain
-	LastError error       // last error, if any
+	Buf       *lineBuffer                       // line buffer abstracts away properties of input readers
+	Step      scannerStep                       // the next scanner step to execute in a chain
+	LastError error                             // last error, if any
+	Progress  ProgressFn                        // if set, will be called after each token
+	Recover   func(err error) (skip bool)       // if set, decides if erroneous lines are skipped
+	Check     func(line int, text string) error // if set, checks every item line before scanning it
 }
 
 // We're buiding up a scanner from chains of scanner step functions.
@@ -37,10 +40,16 @@ type scannerStep func(*parserToken) (*parserToken, scannerStep)
 
 // newScanner creates a scanner for an input reader.
 func newScanner(inputReader io.Reader) (*scanner, error) {
+	return newScannerWithHooks(inputReader, lineHooks{}, 0)
+}
+
+// newScannerWithHooks creates a scanner for an input reader, which reports lines read
+// to callbacks `hooks`. Lines may be up to `maxLine` bytes long, 0 selecting the default.
+func newScannerWithHooks(inputReader io.Reader, hooks lineHooks, maxLine int) (*scanner, error) {
 	if inputReader == nil {
 		return nil, makeParsingError(nil, ErrCodeFormatNoInput, "no input present")
 	}
-	buf := newLineBuffer(inputReader)
+	buf := newLineBuffer(inputReader, hooks, maxLine)
 	sc := &scanner{Buf: buf}
 	sc.Step = sc.ScanFileStart
 	return sc, nil
@@ -55,14 +64,41 @@ func newScanner(inputReader io.Reader) (*scanner, error) {
 // step function, meaning there is no further step applicable in this chain.
 //
 // If a step function returns an error-signalling token, the chaining stops as well.
+// If the scanner has a Recover callback, which decides to skip the erroneous line,
+// scanning continues with the next line instead.
 //
 func (sc *scanner) NextToken() *parserToken {
+	for {
+		token := sc.nextToken()
+		if token.Error == nil || sc.Recover == nil || token.TokenType == docRoot || !sc.Recover(token.Error) {
+			return token
+		}
+		sc.LastError = nil
+	}
+}
+
+// nextToken scans a single line of input.
+func (sc *scanner) nextToken() *parserToken {
 	token := newParserToken(sc.Buf.CurrentLine, int(sc.Buf.Cursor))
+	token.Offset, token.EndOffset = sc.Buf.LineStart, sc.Buf.LineEnd
+	token.Blanks = sc.Buf.Blanks
+	if err := sc.Buf.LastError; err != nil && err != errAtEof { // input failed while reading ahead
+		token.Error = err
+		sc.LastError = err
+		return token
+	}
 	if sc.Buf.IsEof() {
 		token.TokenType = eof
+		token.Offset, token.EndOffset = sc.Buf.Consumed, sc.Buf.Consumed
 		return token
 	}
-	if sc.Step == nil {
+	if sc.Step == nil && sc.Check != nil {
+		if token.Error = sc.Check(sc.Buf.CurrentLine, sc.Buf.Text); token.Error != nil {
+			sc.LastError = token.Error
+			sc.Buf.AdvanceLine() // the step chain is skipped
+		}
+	}
+	if sc.Step == nil && token.Error == nil {
 		sc.Step = sc.ScanItem
 	}
 	for sc.Step != nil {
@@ -73,14 +109,35 @@ func (sc *scanner) NextToken() *parserToken {
 			break
 		}
 		if sc.Buf.Line.Size() == 0 {
-			//fmt.Printf("===> line empty\n")
 			break
 		}
 	}
-	//fmt.Printf("# new %s\n", token)
+	if tracing {
+		tracef("token %s", token)
+	}
+	if sc.Progress != nil {
+		sc.Progress(sc.Buf.Consumed, sc.Buf.CurrentLine)
+	}
 	return token
 }
 
+// SkipIndented skips lines of input which are indented by more than `indent` spaces,
+// without recognizing them as tokens. It has to be called at the start of a line.
+func (sc *scanner) SkipIndented(indent int) {
+	for !sc.Buf.IsEof() && sc.Buf.LastError == nil && indentation(sc.Buf.Text) > indent {
+		sc.Buf.LastError = sc.Buf.AdvanceLine()
+	}
+}
+
+// indentation counts the leading spaces of a line.
+func indentation(line string) int {
+	n := 0
+	for n < len(line) && line[n] == ' ' {
+		n++
+	}
+	return n
+}
+
 // ScanFileStart matches a valid start of a NestedText document input. This is always the
 // first step function to call.
 //
@@ -108,10 +165,12 @@ func (sc *scanner) ScanFileStart(token *parserToken) (*parserToken, scannerStep)
 
 // StepItem is a step function to start recognizing a line-level item.
 func (sc *scanner) ScanItem(token *parserToken) (*parserToken, scannerStep) {
-	//fmt.Println("---> ScanItem")
 	if sc.Buf.Lookahead == ' ' {
 		return token, sc.ScanIndentation
 	}
+	if sc.Buf.Lookahead == '\t' {
+		return tabError(token), nil
+	}
 	return token, sc.ScanItemBody
 }
 
@@ -122,15 +181,29 @@ func (sc *scanner) ScanIndentation(token *parserToken) (*parserToken, scannerSte
 		token.Indent++
 		return token, sc.ScanIndentation
 	}
+	if sc.Buf.Lookahead == '\t' {
+		return tabError(token), nil
+	}
 	return token, sc.ScanItemBody
 }
 
+// tabError flags a tab character in the indentation of an item line. From the spec:
+// Leading spaces on a line represent indentation. Only ASCII spaces are allowed in the
+// indentation. Specifically, tabs and the various Unicode spaces are not allowed.
+// The error is located at the column of the tab.
+func tabError(token *parserToken) *parserToken {
+	err := makeParsingError(token, ErrCodeFormatTabIndent,
+		"tab character in indentation; indentation must consist of spaces")
+	err.Column = token.Indent
+	token.Error = err
+	return token
+}
+
 // ScanItemBody is a step function to recognize the main part of an item, starting at
 // the item's tag (e.g., ':', '>', etc.). The only exception are inline keys and inline key-value-pairs,
 // which start with the key's string.
 //
 func (sc *scanner) ScanItemBody(token *parserToken) (*parserToken, scannerStep) {
-	//fmt.Printf("---> ScanItemBody, LA = '%#U'\n", sc.Buf.Lookahead)
 	switch sc.Buf.Lookahead {
 	case '-': // list value, either single-line or multi-line. From the spec:
 		// If the first non-space character on a line is a dash followed immediately by a space (-␣) or
@@ -176,21 +249,19 @@ func (sc *scanner) ScanItemBody(token *parserToken) (*parserToken, scannerStep)
 func (sc *scanner) ScanInlineKey(token *parserToken) (*parserToken, scannerStep) {
 	switch sc.Buf.Lookahead { // consume characters; stop on ': ', ':\n' or EOL
 	case ':':
-		//fmt.Printf("@ LA = %#U, line = %q, at %d\n", sc.Buf.Lookahead, sc.Buf.Text, sc.Buf.Cursor)
 		sc.Buf.match(singleRune(':'))
-		//fmt.Printf("LA = %#U, line = %q, at %d\n", sc.Buf.Lookahead, sc.Buf.Text, sc.Buf.Cursor)
 		switch sc.Buf.Lookahead {
 		case ' ': // yes, this is a valid dict-key tag
-			//fmt.Printf("LA = %#U, line = %q, at %d\n", sc.Buf.Lookahead, sc.Buf.Text, sc.Buf.Cursor)
-			//fmt.Println("should fork --->")
 			// remove trailing whitespace from key (=> Content[0])
 			key := sc.Buf.Text[token.Indent : sc.Buf.ByteCursor-2]
 			token.Content = append(token.Content, strings.TrimSpace(key))
+			token.Padding = key[len(strings.TrimRightFunc(key, unicode.IsSpace)):]
 			token = sc.recognizeItemTag(':', inlineDictKeyValue, inlineDictKey, token)
 		case eolMarker: // yes, this is a valid dict-key tag
 			// remove trailing whitespace from key (=> Content[0])
 			key := sc.Buf.Text[token.Indent : sc.Buf.ByteCursor-1]
 			token.Content = append(token.Content, strings.TrimSpace(key))
+			token.Padding = key[len(strings.TrimRightFunc(key, unicode.IsSpace)):]
 			token = sc.recognizeItemTag(':', inlineDictKeyValue, inlineDictKey, token)
 		default: // rare case: ':' inside a dict key
 			//sc.Buf.match(anything())
@@ -201,7 +272,6 @@ func (sc *scanner) ScanInlineKey(token *parserToken) (*parserToken, scannerStep)
 		key := sc.Buf.Text[token.Indent : sc.Buf.ByteCursor-1]
 		token.Error = makeParsingError(token, ErrCodeFormatIllegalTag,
 			fmt.Sprintf("dict key item %q not properly terminated by ':'", key))
-		//fmt.Printf("LA = %#U, line = %q, at %d\n", sc.Buf.Lookahead, sc.Buf.Text, sc.Buf.Cursor)
 	default: // recognize everything as either part of the key or trailing whitespace
 		sc.Buf.match(anything())
 		return token, sc.ScanInlineKey
@@ -214,7 +284,6 @@ func (sc *scanner) ScanInlineKey(token *parserToken) (*parserToken, scannerStep)
 // depending on this character, select the continuation call.
 //
 func (sc *scanner) recognizeItemTag(tag rune, single, multi parserTokenType, token *parserToken) *parserToken {
-	//fmt.Printf("forked: LA = %#U, line = %q, at %d\n", sc.Buf.Lookahead, sc.Buf.Text, sc.Buf.Cursor)
 	// sc.Buf.match(singleRune(tag)) // changed: now already match by calling party
 	if sc.Buf.Lookahead != ' ' && sc.Buf.Lookahead != eolMarker {
 		token.Error = makeParsingError(token, ErrCodeFormatIllegalTag,
@@ -233,15 +302,18 @@ func (sc *scanner) recognizeItemTag(tag rune, single, multi parserTokenType, tok
 }
 
 func (sc *scanner) recognizeInlineItem(toktype parserTokenType, token *parserToken) *parserToken {
+	token.TokenType = toktype
 	trimmed := strings.TrimSpace(sc.Buf.Text)
 	closing := trimmed[len(trimmed)-1]
 	//closing := sc.Buf.Text[len(sc.Buf.Text)-1]
 	if !isMatchingBracket(sc.Buf.Lookahead, rune(closing)) {
-		token.Error = makeParsingError(token, ErrCodeFormatIllegalTag,
+		err := makeParsingError(token, ErrCodeFormatIllegalTag,
 			fmt.Sprintf("inline-item does not match opening tag: %#U vs %#U",
 				sc.Buf.Lookahead, rune(closing)))
+		err.Column = token.Indent + utf8.RuneCountInString(trimmed) - 1 // at the closing character
+		token.Error = err
+		return token // NextToken will advance to the next line
 	}
-	token.TokenType = toktype
 	token.Content = append(token.Content, sc.Buf.ReadLineRemainder())
 	return token
 }
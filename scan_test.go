@@ -1,13 +1,17 @@
 package nestext
 
 import (
+	"bufio"
+	"io"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestLineBufferSplitter(t *testing.T) {
 	inputDoc := strings.NewReader("Hello\nWorld\r?!\n")
-	buf := newLineBuffer(inputDoc)
+	buf := newLineBuffer(inputDoc, lineHooks{}, 0)
 	buf.AdvanceCursor()
 	r := buf.ReadLineRemainder()
 	t.Logf("line: %q\n", r)
@@ -28,9 +32,74 @@ func TestLineBufferSplitter(t *testing.T) {
 	}
 }
 
+// TestSplitLines compares the lines produced by splitLines to the lines of
+// splitLinesKeepEOL for all inputs of up to 7 characters of 'a', CR and LF, read in
+// one piece as well as byte by byte, thus splitting CR LF at buffer boundaries.
+func TestSplitLines(t *testing.T) {
+	inputs := []string{""}
+	for n := 0; n < 7; n++ {
+		for _, input := range inputs[len(inputs)-pow(3, n):] {
+			for _, c := range []string{"a", "\r", "\n"} {
+				inputs = append(inputs, input+c)
+			}
+		}
+	}
+	for _, input := range inputs {
+		var expected []string
+		for _, line := range splitLinesKeepEOL([]byte(input)) {
+			expected = append(expected, string(line))
+		}
+		for _, r := range []io.Reader{strings.NewReader(input), iotest.OneByteReader(strings.NewReader(input))} {
+			var lines []string
+			sc := bufio.NewScanner(r)
+			sc.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+				advance, token, err := splitLines(data, atEOF)
+				if token != nil {
+					lines = append(lines, string(data[:advance]))
+				}
+				return advance, token, err
+			})
+			for sc.Scan() {
+			}
+			if !reflect.DeepEqual(lines, expected) {
+				t.Errorf("%q: expected lines %q, have %q", input, expected, lines)
+			}
+		}
+	}
+}
+
+func pow(x, n int) int {
+	if n == 0 {
+		return 1
+	}
+	return x * pow(x, n-1)
+}
+
+func TestLineBufferMixedNewlines(t *testing.T) {
+	inputs := []struct {
+		text  string
+		mixed int
+		eol   string
+	}{
+		{"a\nb\nc", 0, ""},
+		{"a\r\nb\r\n", 0, ""},
+		{"a\rb\r\nc\n", 2, "\r\n"},
+		{"a\n\n\r\n\r", 3, "\r\n"},
+	}
+	for i, input := range inputs {
+		buf := newLineBuffer(iotest.OneByteReader(strings.NewReader(input.text)), lineHooks{}, 0)
+		for buf.AdvanceLine() == nil {
+		}
+		if buf.Mixed != input.mixed || buf.MixedEOL != input.eol {
+			t.Errorf("%d: expected line %d terminated by %q, have %d and %q", i, input.mixed, input.eol,
+				buf.Mixed, buf.MixedEOL)
+		}
+	}
+}
+
 func TestLineBufferRemainder(t *testing.T) {
 	inputDoc := strings.NewReader("Hello World\nHow are you?")
-	buf := newLineBuffer(inputDoc)
+	buf := newLineBuffer(inputDoc, lineHooks{}, 0)
 	for i := 0; i < 6; i++ {
 		buf.AdvanceCursor()
 	}
@@ -241,3 +310,17 @@ func logToken(token *parserToken, t *testing.T) {
 		t.Logf("      + error:  %v", token.Error)
 	}
 }
+
+func TestLineBufferLoneCRs(t *testing.T) {
+	// CR-terminated lines must not be buffered up to a LF, which may exceed the
+	// maximum size of a line
+	input := strings.Repeat("- a\r", 20000)
+	buf := newLineBuffer(strings.NewReader(input), lineHooks{}, 0)
+	lines := 0
+	for err := error(nil); err == nil; err = buf.AdvanceLine() {
+		lines++
+	}
+	if buf.LastError != nil || buf.Input.Err() != nil || lines != 20000 {
+		t.Errorf("expected 20000 lines, have %d and error %v", lines, buf.Input.Err())
+	}
+}
@@ -0,0 +1,107 @@
+package nestext
+
+import "fmt"
+
+// --- Selective parsing -----------------------------------------------------
+
+// SelectPaths requests the parser to materialize only the values at the given path
+// expressions (see ParsePath), together with the dicts and lists containing them.
+// All other items are skipped line by line, without recognizing their structure or
+// creating values for them. This saves time and memory if an application needs a
+// few values out of a large document.
+//
+// Use as:
+//     config, err := nestext.Parse(reader, nestext.SelectPaths("database.host", "servers.*.port"))
+//
+// Paths may contain wildcards and globs, but no recursive segments "**". Lists of
+// the result contain the selected items only, so indices may differ from the ones of
+// the document. Inline lists and dicts, as well as items with multi-line keys, are
+// selected as a whole. Skipped items are not checked for errors. ParseEvents does not
+// support this option.
+//
+func SelectPaths(paths ...string) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.selectors = make([][]PathSegment, 0, len(paths))
+		for _, path := range paths {
+			segments, err := ParsePath(path)
+			if err != nil {
+				return err
+			}
+			for _, seg := range segments {
+				if seg.Recursive {
+					return MakeNestedTextError(ErrCodeUsage,
+						fmt.Sprintf("path %q: recursive segments not allowed for selection", path))
+				}
+			}
+			p.selectors = append(p.selectors, segments)
+		}
+		return nil
+	}
+}
+
+// selecting is true if items have to be checked against selectors.
+func (p *nestedTextParser) selecting() bool {
+	return p.selectors != nil && p.selectAll == 0
+}
+
+// selectItem checks if the item with path segment `seg` of the current container is
+// selected. If it is, the caller has to call leaveItem after parsing the item.
+func (p *nestedTextParser) selectItem(seg PathSegment) bool {
+	if p.selectAll > 0 {
+		p.selectAll++
+		return true
+	}
+	depth := len(p.trail) + 1
+	selected := false
+	for _, sel := range p.selectors {
+		if len(sel) < depth || !matchSegment(sel[depth-1:], seg) {
+			continue
+		}
+		matches := true
+		for i, s := range p.trail {
+			matches = matches && matchSegment(sel[i:], s)
+		}
+		if !matches {
+			continue
+		}
+		selected = true
+		if len(sel) == depth {
+			p.selectAll = 1
+			break
+		}
+	}
+	if selected {
+		p.trail = append(p.trail, seg)
+	}
+	return selected
+}
+
+// leaveItem has to be called after parsing an item selected by selectItem.
+func (p *nestedTextParser) leaveItem() {
+	if p.selectAll > 0 {
+		if p.selectAll--; p.selectAll > 0 {
+			return
+		}
+	}
+	p.trail = p.trail[:len(p.trail)-1]
+}
+
+// matchSegment checks if the first segment of selector `sel` matches the segment `seg`
+// of an item. An empty selector does not match.
+func matchSegment(sel []PathSegment, seg PathSegment) bool {
+	if len(sel) == 0 {
+		return false
+	}
+	if seg.IsIndex {
+		return sel[0].Wildcard || sel[0].IsIndex && sel[0].Index == seg.Index
+	}
+	return sel[0].MatchKey(seg.Key)
+}
+
+// skipItem skips the item of the current token, which is indented by `indent`, and
+// reads the token following it.
+func (p *nestedTextParser) skipItem(indent int) error {
+	p.sc.SkipIndented(indent)
+	p.token = p.sc.NextToken()
+	return p.token.Error
+}
@@ -0,0 +1,80 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const selectInput = `database:
+  primary:
+    host: db1.example.com
+    port: 5432
+  replicas:
+    - db2.example.com
+    - db3.example.com
+servers:
+  -
+    name: alpha
+    port: 8080
+  -
+    name: beta
+    port: 8081
+: multi-line
+: key
+  > skipped
+logging:
+  level: info
+  targets:
+    [stdout, file]
+`
+
+func TestParseSelectPaths(t *testing.T) {
+	inputs := []struct {
+		paths    []string
+		expected interface{}
+	}{
+		{[]string{"database.primary.host"}, map[string]interface{}{
+			"database": map[string]interface{}{
+				"primary": map[string]interface{}{"host": "db1.example.com"},
+			},
+		}},
+		{[]string{"servers.*.port", "logging.targets"}, map[string]interface{}{
+			"servers": []interface{}{
+				map[string]interface{}{"port": "8080"},
+				map[string]interface{}{"port": "8081"},
+			},
+			"logging": map[string]interface{}{"targets": []interface{}{"stdout", "file"}},
+		}},
+		{[]string{"database.replicas[1]", "serv*[0].name"}, map[string]interface{}{
+			"database": map[string]interface{}{"replicas": []interface{}{"db3.example.com"}},
+			"servers":  []interface{}{map[string]interface{}{"name": "alpha"}},
+		}},
+		{[]string{"multi-line\nkey"}, map[string]interface{}{"multi-line\nkey": "skipped"}},
+		{[]string{"missing"}, map[string]interface{}{}},
+	}
+	for i, input := range inputs {
+		result, err := Parse(strings.NewReader(selectInput), SelectPaths(input.paths...))
+		if err != nil {
+			t.Fatalf("[%d] %v", i, err)
+		}
+		if !reflect.DeepEqual(result, input.expected) {
+			t.Errorf("[%d] expected %v, have %v", i, input.expected, result)
+		}
+	}
+}
+
+func TestParseSelectPathsErrors(t *testing.T) {
+	if _, err := Parse(strings.NewReader(selectInput), SelectPaths("**.port")); err == nil {
+		t.Error("expected recursive selector to be rejected")
+	}
+	// errors within skipped items go unnoticed, others are reported
+	input := "a:\n  b: 1\n   c: 2\nd:\n  e: 1\n   f: 2\n"
+	if _, err := Parse(strings.NewReader(input), SelectPaths("d")); err == nil {
+		t.Error("expected error in selected item to be reported")
+	}
+	result, err := Parse(strings.NewReader(input), SelectPaths("x"))
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{}) {
+		t.Errorf("expected empty result, have %v, %v", result, err)
+	}
+}
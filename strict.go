@@ -0,0 +1,100 @@
+package nestext
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- Strict and lenient parsing --------------------------------------------
+
+// Strict selects how the parser treats input which the spec frowns upon, but which may
+// be understood unambiguously:
+//
+//   - tab characters in the indentation of items,
+//   - content following the closing bracket of an inline list or dict,
+//   - lines terminated by a lone CR, as created by classic Mac OS.
+//
+// With Strict(true), the parser rejects all of them with an error. With Strict(false),
+// the parser is lenient: it accepts and normalizes them, and reports a warning for each
+// of them (see ReportWarnings). Tabs in indentation are expanded to tab stops every 8
+// columns (see ExpandTabs), thus column numbers refer to the expanded lines, and content
+// following inline items is dropped. Without this option, tabs and content following
+// inline items are errors, while lone CRs are accepted, as the spec allows them.
+// Errors and warnings for tabs are of code ErrCodeFormatTabIndent, the others of code
+// ErrCodeFormat.
+//
+// Use as:
+//     config, err := nestext.Parse(reader, nestext.Strict(false), nestext.ReportWarnings(func(w nestext.NestedTextError) {
+//         log.Printf("warning: %v", w)
+//     }))
+//
+func Strict(strict bool) Option {
+	return func(p *nestedTextParser) (err error) {
+		p.strict, p.lenient = strict, !strict
+		p.inline.warn = nil
+		if p.lenient {
+			p.inline.warn = p.warning
+		}
+		return nil
+	}
+}
+
+// ExpandTabs expands tab characters in the indentation of items to spaces, up to the
+// next tab stop, with tab stops every `tabStop` columns. Without this option, tabs in
+// indentation are errors of code ErrCodeFormatTabIndent, located at the column of the
+// first tab, as the spec allows spaces only. Column numbers of errors and positions refer
+// to the expanded lines. A warning is reported for every line with tabs expanded (see
+// ReportWarnings), allowing applications to suggest fixing the input.
+//
+// Tabs within comments, blank lines and values are left unchanged. ExpandTabs takes
+// precedence over Strict(true) and overrides the tab stop of Strict(false).
+//
+// Use as:
+//     config, err := nestext.Parse(reader, nestext.ExpandTabs(4))
+//
+// A tab stop less than 1 results in an error returned by Parse(…).
+//
+func ExpandTabs(tabStop int) Option {
+	return func(p *nestedTextParser) (err error) {
+		if tabStop < 1 {
+			return MakeNestedTextError(ErrCodeUsage, fmt.Sprintf("invalid tab stop %d", tabStop))
+		}
+		p.tabStop = tabStop
+		return nil
+	}
+}
+
+// defaultTabStop is the distance of tab stops for expanding tabs in lenient mode.
+const defaultTabStop = 8
+
+// expanding checks if tabs in indentation are expanded, either in lenient mode or
+// by option ExpandTabs.
+func (p *nestedTextParser) expanding() bool {
+	return p.lenient || p.tabStop > 0
+}
+
+// expandTabs is the Rewrite callback for the line buffer if tabs are expanded. It replaces
+// tabs in the indentation of line number `line` by spaces, up to the next tab stop.
+func (p *nestedTextParser) expandTabs(line int, text string) string {
+	tabStop := p.tabStop
+	if tabStop == 0 {
+		tabStop = defaultTabStop
+	}
+	indent := len(text) - len(strings.TrimLeft(text, " \t"))
+	if strings.IndexByte(text[:indent], '\t') < 0 || indent == len(text) || text[indent] == '#' {
+		return text
+	}
+	var b strings.Builder
+	for _, c := range text[:indent] {
+		if c == ' ' {
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteString(strings.Repeat(" ", tabStop-b.Len()%tabStop))
+	}
+	w := MakeNestedTextError(ErrCodeFormatTabIndent,
+		fmt.Sprintf("tab characters in indentation expanded to %d spaces", b.Len()))
+	w.Line = line
+	p.warning(w)
+	return b.String() + text[indent:]
+}
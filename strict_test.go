@@ -0,0 +1,98 @@
+package nestext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStrict(t *testing.T) {
+	inputs := []struct {
+		text         string
+		line, column int // position of the error in strict mode
+		code         int // code of the error in strict mode
+		warnings     int // count of warnings in lenient mode
+		result       interface{}
+	}{
+		{"a:\n\t- x\n", 2, 0, ErrCodeFormatTabIndent, 1, map[string]interface{}{"a": []interface{}{"x"}}},
+		{"a:\n  \t- x\n  \t- y\n", 2, 2, ErrCodeFormatTabIndent, 2, map[string]interface{}{"a": []interface{}{"x", "y"}}},
+		{"a:\n  [x, y]]\n", 2, 8, ErrCodeFormat, 1, map[string]interface{}{"a": []interface{}{"x", "y"}}},
+		{"{a: b} }\n", 1, 7, ErrCodeFormat, 1, map[string]interface{}{"a": "b"}},
+		{"a: 1\rb: 2\r", 1, 0, ErrCodeFormat, 1, map[string]interface{}{"a": "1", "b": "2"}},
+		{"a:\n\t# comment\n  - x\n", 0, 0, 0, 0, map[string]interface{}{"a": []interface{}{"x"}}},
+		{"a: x\ty\n", 0, 0, 0, 0, map[string]interface{}{"a": "x\ty"}},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text), Strict(true))
+		if input.line == 0 {
+			if err != nil {
+				t.Errorf("%d: expected strict mode to accept input, have %v", i, err)
+			}
+		} else if nterr, ok := err.(NestedTextError); !ok || nterr.Code != input.code ||
+			nterr.Line != input.line || nterr.Column != input.column {
+			t.Errorf("%d: expected error at [%d,%d], have %v", i, input.line, input.column, err)
+		}
+		var warnings []NestedTextError
+		result, err := Parse(strings.NewReader(input.text), Strict(false),
+			ReportWarnings(func(w NestedTextError) { warnings = append(warnings, w) }))
+		if err != nil {
+			t.Errorf("%d: expected lenient mode to accept input, have %v", i, err)
+		}
+		if !reflect.DeepEqual(result, input.result) {
+			t.Errorf("%d: expected %#v, have %#v", i, input.result, result)
+		}
+		if len(warnings) != input.warnings {
+			t.Errorf("%d: expected %d warnings, have %v", i, input.warnings, warnings)
+		}
+	}
+}
+
+func TestParseDefaultStrictness(t *testing.T) {
+	if _, err := Parse(strings.NewReader("a: 1\rb: 2\r")); err != nil {
+		t.Errorf("expected lone CRs to be accepted, have %v", err)
+	}
+	if _, err := Parse(strings.NewReader("a:\n\t- x\n")); err == nil ||
+		!strings.Contains(err.Error(), "tab character") {
+		t.Errorf("expected error for tab, have %v", err)
+	}
+	if _, err := Parse(strings.NewReader("[x, ]]\n")); err == nil {
+		t.Error("expected error for content following inline list; didn't")
+	}
+}
+
+func TestParseExpandTabs(t *testing.T) {
+	inputs := []struct {
+		text    string
+		tabStop int
+		result  interface{}
+		column  int // column of the error at line 3, if any
+	}{
+		{"a:\n\t- x\n\t- y\n", 4, map[string]interface{}{"a": []interface{}{"x", "y"}}, 0},
+		{"a:\n  \tb: 1\n    c: 2\n", 4, map[string]interface{}{"a": map[string]interface{}{"b": "1", "c": "2"}}, 0},
+		{"a:\n  \tb: 1\n    c: 2\n", 8, nil, 4},
+		{"a:\n\t- x\n  - y\n", 2, map[string]interface{}{"a": []interface{}{"x", "y"}}, 0},
+	}
+	for i, input := range inputs {
+		var warnings []NestedTextError
+		result, err := Parse(strings.NewReader(input.text), Strict(true), ExpandTabs(input.tabStop),
+			ReportWarnings(func(w NestedTextError) { warnings = append(warnings, w) }))
+		if input.column > 0 {
+			if nterr, ok := err.(NestedTextError); !ok || nterr.Line != 3 || nterr.Column != input.column {
+				t.Errorf("%d: expected error at [3,%d], have %v", i, input.column, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: expected tabs to be expanded, have %v", i, err)
+		}
+		if !reflect.DeepEqual(result, input.result) {
+			t.Errorf("%d: expected %#v, have %#v", i, input.result, result)
+		}
+		if len(warnings) == 0 || warnings[0].Code != ErrCodeFormatTabIndent || warnings[0].Line != 2 {
+			t.Errorf("%d: expected warning for line 2, have %v", i, warnings)
+		}
+	}
+	if _, err := Parse(strings.NewReader("a: 1\n"), ExpandTabs(0)); err == nil {
+		t.Error("expected tab stop 0 to produce an error; didn't")
+	}
+}
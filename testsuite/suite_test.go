@@ -3,6 +3,7 @@ package testsuite_test
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
@@ -23,13 +24,19 @@ import (
 // to be a stable method. All tests pass.
 //
 // Encoding-tests are trickier, as for many structures there are more than one correct
-// NT representations. Moreover, stability of map elements is a challenge: we sort
-// them alphabetically, as Go does not make any guarantees about the sequence.
-// All in all we are currently not testing encoding-cases to full depth, but in a
-// sufficient manner.
+// NT representations. Encoding outputs have to parse back to the input. Encoder option
+// ntenc.ReferenceLayout is meant to make the same choices as the reference
+// implementation, but has not been verified against the official cases yet. Thus
+// byte-for-byte differences to the expected output are logged only, unless flag
+// -exact-encoding is given. JSON inputs are decoded into *nestext.OrderedMap dicts,
+// keeping the order of keys as the reference implementation does. See README.md for
+// checking out the test cases; without them, the suite is skipped.
 
 var suitePath = filepath.Join(".", "official_tests", "test_cases")
 
+var exactEncoding = flag.Bool("exact-encoding", false,
+	"fail encoding-tests if the output differs from the expected output byte-for-byte")
+
 func casePath(c *ntTestCase) string {
 	return filepath.Join(suitePath, c.name)
 }
@@ -73,22 +80,6 @@ func (c *ntTestCase) bytes(f string) []byte {
 	return b
 }
 
-func ttype(name string) string {
-	if strings.Contains(name, "string") {
-		return "string"
-	}
-	if strings.Contains(name, "list") {
-		return "list"
-	}
-	if strings.Contains(name, "dict") {
-		return "dict"
-	}
-	if strings.Contains(name, "holistic") {
-		return "dict"
-	}
-	return ""
-}
-
 var skipped = []string{
 	//"inline_dict_01",
 }
@@ -185,21 +176,13 @@ func testEncodeCase(c *ntTestCase, t *testing.T) {
 	//t.Logf("encoding-test %q", c.name)
 	if c.isDump {
 		b := c.data["dump_in.json"]
-		var r interface{}
-		switch ttype(c.name) {
-		case "dict":
-			r = make(map[string]interface{})
-		case "list":
-			r = make([]interface{}, 10)
-		case "string":
-			r = ""
-		}
-		if err := json.Unmarshal(b, &r); err != nil {
+		r, err := decodeOrdered(json.NewDecoder(bytes.NewReader(b)))
+		if err != nil {
 			c.statusE = fmt.Sprintf("error: %s", err.Error())
 			return
 		}
 		buf := &bytes.Buffer{}
-		_, err := ntenc.Encode(r, buf, ntenc.IndentBy(4))
+		_, err = ntenc.Encode(r, buf, ntenc.ReferenceLayout())
 		if err != nil {
 			if c.contains("dump_err.json") {
 				c.statusE = "ok"
@@ -210,10 +193,16 @@ func testEncodeCase(c *ntTestCase, t *testing.T) {
 			return
 		}
 		c.statusE = "parsed"
-		if compareJson(buf, c, t) {
+		if !roundTrips(buf, r, c, t) {
+			c.statusE = "failed"
+			c.isFail = true
+		} else if compareJson(buf, c, t) {
 			c.statusE = "ok"
+		} else if *exactEncoding {
+			c.statusE = "failed"
+			c.isFail = true
 		} else {
-			c.statusE = "?"
+			c.statusE = "differs"
 		}
 	}
 }
@@ -229,38 +218,129 @@ func compareOutput(any interface{}, c *ntTestCase, t *testing.T) bool {
 	if err != nil {
 		return true
 	}
-	//fmt.Printf("nt:     %#v\n", any)
-	//fmt.Printf("target: %#v\n", target)
-	r := fmt.Sprintf("%#v", any)
-	o := fmt.Sprintf("%#v", target)
-	if r != o {
+	if !nestext.Equal(any, target) {
 		t.Logf("input:\n%s", nt)
-		t.Logf("nt   : %s", r)
-		t.Logf("json : %s", o)
+		t.Logf("nt   : %#v", nestext.Normalize(any))
+		t.Logf("json : %#v", nestext.Normalize(target))
+		for _, d := range nestext.Diff(any, target) {
+			t.Logf("diff : %s", d)
+		}
 		t.Fatalf("output comparison failed!\n--------------------------")
 	}
 	return true
 }
 
+// roundTrips checks that the encoding output parses back to the input. Inputs holding
+// values other than strings, e.g. booleans, cannot survive a round trip and are not
+// checked.
+func roundTrips(buf *bytes.Buffer, input interface{}, c *ntTestCase, t *testing.T) bool {
+	if !onlyStrings(input) {
+		return true
+	}
+	tree, err := nestext.Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Errorf("%s: NT output cannot be parsed: %v\noutput NT:\n%q", c.name, err, buf.String())
+		return false
+	}
+	if tree == nil { // empty document
+		tree = ""
+	}
+	if !nestext.Equal(tree, input) {
+		t.Errorf("%s: NT output does not parse back to the input: %v", c.name, nestext.Diff(tree, input))
+		return false
+	}
+	return true
+}
+
+// onlyStrings checks if all values of a tree are strings.
+func onlyStrings(tree interface{}) bool {
+	switch t := tree.(type) {
+	case string:
+		return true
+	case []interface{}:
+		for _, item := range t {
+			if !onlyStrings(item) {
+				return false
+			}
+		}
+		return true
+	case *nestext.OrderedMap:
+		for _, key := range t.Keys() {
+			if item, _ := t.Get(key); !onlyStrings(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compareJson compares the encoding output to the expected output byte-for-byte.
+// Differences are errors only with flag -exact-encoding.
 func compareJson(buf *bytes.Buffer, c *ntTestCase, t *testing.T) bool {
 	// kill excessive newlines at end
 	n := strings.TrimRight(string(c.data["dump_out.nt"]), "\n")
 	m := strings.TrimRight(buf.String(), "\n")
 	if n != m {
-		// t.Logf("target NT:\n%q\n\n", n+"\n")
-		// t.Logf("output NT:\n%q\n\n", m+"\n")
-		// b := c.data["dump_in.json"]
-		// t.Logf("input JSON:\n\n%s\n", string(b))
-		// t.Logf("NT output does not match target")
+		report := t.Logf
+		if *exactEncoding {
+			report = t.Errorf
+		}
+		report("%s: NT output does not match target\ntarget NT:\n%q\noutput NT:\n%q", c.name, n, m)
 		return false
 	}
 	return true
 }
 
+// decodeOrdered decodes the next JSON value of dec, with objects decoded as
+// *nestext.OrderedMap and numbers as strings.
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			list := []interface{}{}
+			for dec.More() {
+				item, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			_, err = dec.Token()
+			return list, err
+		}
+		dict := nestext.NewOrderedMap()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			dict.Set(key.(string), value)
+		}
+		_, err = dec.Token()
+		return dict, err
+	case json.Number:
+		return t.String(), nil
+	}
+	return tok, nil // strings, booleans and null
+}
+
 func listTestCases(t *testing.T) []ntTestCase {
 	list, err := ioutil.ReadDir(suitePath)
+	if os.IsNotExist(err) {
+		t.Skipf("test-suite dir %s not checked out, see README.md", suitePath)
+	}
 	if err != nil {
-		t.Fatalf("unable to read test-suite dir")
+		t.Fatalf("unable to read test-suite dir: %v", err)
 	}
 	t.Logf("%d test cases in NestedText Suite", len(list))
 	cases := make([]ntTestCase, len(list))
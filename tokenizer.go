@@ -0,0 +1,153 @@
+package nestext
+
+import (
+	"fmt"
+	"io"
+)
+
+// --- Public tokenizer ------------------------------------------------------
+
+// TokenType classifies the lines of a NestedText document.
+type TokenType int8
+
+// Types of tokens delivered by a Tokenizer.
+const (
+	TokenEOF           TokenType = iota // end of input
+	TokenListItem                       // "- value"
+	TokenListItemEmpty                  // "-" on its own, value is nested or empty
+	TokenString                         // "> text", line of a multi-line string
+	TokenKey                            // ": text", line of a multi-line key
+	TokenDictItem                       // "key: value"
+	TokenDictItemEmpty                  // "key:", value is nested or empty
+	TokenInlineList                     // "[ … ]"
+	TokenInlineDict                     // "{ … }"
+)
+
+var tokenTypeNames = []string{"EOF", "ListItem", "ListItemEmpty", "String", "Key", "DictItem",
+	"DictItemEmpty", "InlineList", "InlineDict"}
+
+func (tt TokenType) String() string {
+	if tt < 0 || int(tt) >= len(tokenTypeNames) {
+		return fmt.Sprintf("TokenType(%d)", int(tt))
+	}
+	return tokenTypeNames[tt]
+}
+
+// Token is a line-level token of a NestedText document. Blank lines and comment
+// lines are not reported as tokens.
+type Token struct {
+	Type      TokenType // type of the item line
+	Line      int       // line number, starting at 1
+	Indent    int       // count of spaces before the item tag
+	Key       string    // key for dict items (TokenDictItem and TokenDictItemEmpty)
+	Value     string    // text following the item tag, or source text for inline items
+	Offset    int64     // byte offset of the line
+	EndOffset int64     // byte offset of the end of the line, excluding the line terminator
+}
+
+func (t Token) String() string {
+	if t.Type == TokenDictItem || t.Type == TokenDictItemEmpty {
+		return fmt.Sprintf("%s[%d,%d] %q: %q", t.Type, t.Line, t.Indent, t.Key, t.Value)
+	}
+	return fmt.Sprintf("%s[%d,%d] %q", t.Type, t.Line, t.Indent, t.Value)
+}
+
+// A Tokenizer breaks up a NestedText document into line-level tokens. It is the
+// scanner underlying Parse, exposed for tools which are concerned with the lines of
+// a document rather than with its values, e.g. formatters or syntax highlighters.
+//
+// The tokenizer does not check the nesting structure of the document, nor does it
+// parse inline lists and dicts.
+//
+// Use as:
+//     tz := nestext.NewTokenizer(reader)
+//     for {
+//         token, err := tz.Next()
+//         if err != nil { … }
+//         if token.Type == nestext.TokenEOF {
+//             break
+//         }
+//         …
+//     }
+//
+type Tokenizer struct {
+	sc      *scanner
+	started bool
+}
+
+// NewTokenizer creates a tokenizer reading from r.
+func NewTokenizer(r io.Reader) *Tokenizer {
+	sc, _ := newScanner(r) // we will report a nil reader with the first call to Next
+	return &Tokenizer{sc: sc}
+}
+
+// Next returns the next token. At the end of input, tokens of type TokenEOF are
+// returned.
+//
+// If the current line is not a valid NestedText item, an error of type NestedTextError
+// is returned, with byte offsets set. The line is skipped, so that clients may choose to continue with the
+// next line, as long as the error is not an I/O error (ErrCodeIO).
+//
+func (tz *Tokenizer) Next() (Token, error) {
+	if tz.sc == nil {
+		return Token{}, MakeNestedTextError(ErrCodeFormatNoInput, "no input present")
+	}
+	if !tz.started { // initial token from scanner is a health check for the input source
+		tz.started = true
+		token := tz.sc.NextToken()
+		if token.TokenType == emptyDocument || token.TokenType == eof {
+			return Token{Type: TokenEOF, Line: token.LineNo}, nil
+		}
+		if token.Error != nil {
+			// top-level indentation: report error, but continue with first line
+			tz.sc.Step = tz.sc.ScanItem
+			return Token{Line: token.LineNo}, tz.locate(token.Error)
+		}
+	}
+	if err := tz.sc.Buf.LastError; err != nil && err != errAtEof {
+		return Token{Line: tz.sc.Buf.CurrentLine}, tz.locate(err)
+	}
+	token := tz.sc.NextToken()
+	t := Token{Line: token.LineNo, Indent: token.Indent, Offset: token.Offset, EndOffset: token.EndOffset}
+	if token.Error != nil {
+		tz.sc.Step = nil // restart with next line
+		return t, tz.locate(token.Error)
+	}
+	switch token.TokenType {
+	case eof:
+		t.Type = TokenEOF
+	case listItem:
+		t.Type = TokenListItem
+	case listItemMultiline:
+		t.Type = TokenListItemEmpty
+	case stringMultiline:
+		t.Type = TokenString
+	case dictKeyMultiline:
+		t.Type = TokenKey
+	case inlineDictKeyValue:
+		t.Type = TokenDictItem
+	case inlineDictKey:
+		t.Type = TokenDictItemEmpty
+	case inlineList:
+		t.Type = TokenInlineList
+	case inlineDict:
+		t.Type = TokenInlineDict
+	}
+	if t.Type == TokenDictItem || t.Type == TokenDictItemEmpty {
+		t.Key = token.Content[0]
+		if len(token.Content) > 1 {
+			t.Value = token.Content[1]
+		}
+	} else if len(token.Content) > 0 {
+		t.Value = token.Content[0]
+	}
+	return t, nil
+}
+
+// locate sets the byte offsets of `err`, if it is a NestedTextError.
+func (tz *Tokenizer) locate(err error) error {
+	if nterr, ok := err.(NestedTextError); ok {
+		return tz.sc.Buf.locate(nterr, 0)
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package nestext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenizer(t *testing.T) {
+	doc := `# comment
+name: Alice
+tags:
+  - a
+  -
+    > line 1
+    >
+  [x, y]
+: multi
+:   key
+  {k: v}
+`
+	expected := []string{
+		`DictItem[2,0] "name": "Alice"`,
+		`DictItemEmpty[3,0] "tags": ""`,
+		`ListItem[4,2] "a"`,
+		`ListItemEmpty[5,2] ""`,
+		`String[6,4] "line 1"`,
+		`String[7,4] ""`,
+		`InlineList[8,2] "[x, y]"`,
+		`Key[9,0] "multi"`,
+		`Key[10,0] "  key"`,
+		`InlineDict[11,2] "{k: v}"`,
+		`EOF[12,0] ""`,
+	}
+	tz := NewTokenizer(strings.NewReader(doc))
+	for i, exp := range expected {
+		token, err := tz.Next()
+		if err != nil {
+			t.Fatalf("unexpected error for token #%d: %v", i, err)
+		}
+		t.Logf("token = %s", token)
+		if token.String() != exp {
+			t.Errorf("expected token #%d to be %s, is %s", i, exp, token)
+		}
+	}
+}
+
+func TestTokenizerEmpty(t *testing.T) {
+	tz := NewTokenizer(strings.NewReader("# nothing here\n\n"))
+	token, err := tz.Next()
+	if err != nil || token.Type != TokenEOF {
+		t.Errorf("expected EOF for empty document, have %s, %v", token, err)
+	}
+}
+
+func TestTokenizerContinueAfterError(t *testing.T) {
+	tz := NewTokenizer(strings.NewReader("a: 1\nnot a key\nb: 2\n"))
+	tz.Next()
+	token, err := tz.Next()
+	if err == nil {
+		t.Fatalf("expected error for line 2, have %s", token)
+	}
+	t.Logf("error = %v", err)
+	if token.Line != 2 {
+		t.Errorf("expected error token to be at line 2, is at %d", token.Line)
+	}
+	token, err = tz.Next()
+	if err != nil || token.Type != TokenDictItem || token.Key != "b" {
+		t.Errorf("expected tokenizer to continue with line 3, have %s, %v", token, err)
+	}
+}
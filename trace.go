@@ -0,0 +1,11 @@
+//go:build !nestextdebug
+// +build !nestextdebug
+
+package nestext
+
+// tracing tells if the package has been built with tag `nestextdebug`. Trace output is
+// guarded by this constant, thus without the tag the compiler removes the calls of
+// tracef altogether, including the evaluation and boxing of their arguments.
+const tracing = false
+
+func tracef(format string, args ...interface{}) {}
@@ -0,0 +1,21 @@
+//go:build nestextdebug
+// +build nestextdebug
+
+package nestext
+
+import (
+	"fmt"
+	"os"
+)
+
+// tracing tells if the package has been built with tag `nestextdebug`, enabling trace
+// output of the scanner and the parser:
+//
+//     go test -tags nestextdebug -run TestParseSimpleDict .
+//
+const tracing = true
+
+// tracef writes a line of trace output to stderr.
+func tracef(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "nestext: "+format+"\n", args...)
+}
@@ -0,0 +1,137 @@
+package nestext
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// --- Value validation ------------------------------------------------------
+
+// Validate registers a function checking the values at path expression `path` (see
+// ParsePath), while the document is parsed. This allows to check single values without
+// a complete schema:
+//
+//     port := func(v interface{}) error {
+//         s, _ := v.(string)
+//         if n, err := strconv.Atoi(s); err != nil || n < 1 || n > 65535 {
+//             return errors.New("must be a port number between 1 and 65535")
+//         }
+//         return nil
+//     }
+//     config, err := nestext.Parse(reader, nestext.Validate("servers.*.port", port))
+//
+// Paths may contain wildcards, globs and recursive segments, e.g. "**.port" checks items
+// "port" at any level. `check` receives the value of every matching item, i.e. a string,
+// a list or a dict, as soon as the item has been parsed completely; items of inline
+// lists and dicts are checked as well. The option may be given more than once, and
+// all matching functions are applied.
+//
+// The first failing check stops parsing with an error of code ErrCodeSchema, reporting
+// the line of the item and its path, and wrapping the error returned by `check`.
+// ParseEvents does not support this option.
+//
+func Validate(path string, check func(value interface{}) error) Option {
+	return func(p *nestedTextParser) (err error) {
+		if check == nil {
+			return MakeNestedTextError(ErrCodeUsage, "option Validate requires a function")
+		}
+		segments, err := ParsePath(path)
+		if err != nil {
+			return err
+		}
+		if len(segments) == 0 {
+			return MakeNestedTextError(ErrCodeUsage, "option Validate requires a non-empty path")
+		}
+		p.validators = append(p.validators, pathValidator{pattern: segments, check: check})
+		return nil
+	}
+}
+
+// pathValidator is a validation function for values at paths matching a pattern.
+type pathValidator struct {
+	pattern []PathSegment
+	check   func(value interface{}) error
+}
+
+// validate applies the validators to the value of the item with path segment `seg` of
+// the current container, found at `line` and `indent`.
+func (p *nestedTextParser) validate(seg PathSegment, value interface{}, line, indent int) error {
+	if p.validators == nil {
+		return nil
+	}
+	segments := append(p.segments[:len(p.segments):len(p.segments)], seg)
+	for _, v := range p.validators {
+		if !matchPath(v.pattern, segments) {
+			continue
+		}
+		if err := v.check(value); err != nil {
+			nterr := WrapError(ErrCodeSchema, fmt.Sprintf("%s: %s", JoinPath(segments), err.Error()), err)
+			nterr.Line, nterr.Column = line, indent
+			return nterr
+		}
+	}
+	return nil
+}
+
+// validateInline applies the validators to the items of an inline list or dict at
+// `line` and `column`, which is the value of the current container.
+func (p *nestedTextParser) validateInline(tree interface{}, line, column int) error {
+	if p.validators == nil {
+		return nil
+	}
+	var segs []PathSegment
+	var values []interface{}
+	if list, ok := tree.([]interface{}); ok {
+		for i, item := range list {
+			segs = append(segs, PathSegment{Index: i, IsIndex: true})
+			values = append(values, item)
+		}
+	} else if d, ok := asDict(tree); ok {
+		for _, k := range keysOf(tree) {
+			segs = append(segs, PathSegment{Key: k})
+			values = append(values, d[k])
+		}
+	}
+	for i, seg := range segs {
+		p.segments = append(p.segments, seg)
+		err := p.validateInline(values[i], line, column)
+		p.segments = p.segments[:len(p.segments)-1]
+		if err == nil {
+			err = p.validate(seg, values[i], line, column)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enterNested appends the segment for key or index `key` of the current container to
+// the path of values to validate.
+func (p *nestedTextParser) enterNested(key string) {
+	seg := PathSegment{Key: key}
+	if p.stack.tos().Keys == nil {
+		seg.Index, _ = strconv.Atoi(key)
+		seg.IsIndex = true
+	}
+	p.segments = append(p.segments, seg)
+}
+
+// matchPath checks if path expression `pattern` matches the path `segments` of an item.
+func matchPath(pattern, segments []PathSegment) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0].Recursive {
+		for i := 0; i <= len(segments); i++ {
+			if matchPath(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 || !matchSegment(pattern, segments[0]) {
+		return false
+	}
+	return matchPath(pattern[1:], segments[1:])
+}
@@ -0,0 +1,95 @@
+package nestext
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var errPort = errors.New("must be a port number between 1 and 65535")
+
+func checkPort(v interface{}) error {
+	s, _ := v.(string)
+	if n, err := strconv.Atoi(s); err != nil || n < 1 || n > 65535 {
+		return errPort
+	}
+	return nil
+}
+
+func TestParseValidate(t *testing.T) {
+	inputs := []struct {
+		text string
+		path string
+		line int    // line of the error, 0 for no error
+		at   string // path of the offending value
+	}{
+		{"port: 80\n", "port", 0, ""},
+		{"port: 0\n", "port", 1, "port"},
+		{"a:\n  port: 80\nb:\n  port: x\n", "*.port", 4, "b.port"},
+		{"a:\n  port: 99999\n", "port", 0, ""},
+		{"a:\n  -\n    b:\n      port: -1\n", "**.port", 4, "a[0].b.port"},
+		{"servers:\n  -\n    {port: 80}\n  -\n    {port: 70000}\n", "servers.*.port", 5, "servers[1].port"},
+		{"- 8080\n- 0\n", "[*]", 2, "[1]"},
+		{"ports:\n  - 1\n  - 2\n  -\n    > 3x\n", "ports[2]", 4, "ports[2]"},
+		{"web-port: 1\ndb-port: 0\n", "*-port", 2, "db-port"},
+	}
+	for i, input := range inputs {
+		_, err := Parse(strings.NewReader(input.text), Validate(input.path, checkPort))
+		if input.line == 0 {
+			if err != nil {
+				t.Errorf("%d: expected no error, have %v", i, err)
+			}
+			continue
+		}
+		nterr, ok := err.(NestedTextError)
+		if !ok || nterr.Code != ErrCodeSchema || nterr.Line != input.line ||
+			!strings.Contains(nterr.Error(), "] "+input.at+": ") {
+			t.Errorf("%d: expected error for %s at line %d, have %v", i, input.at, input.line, err)
+		}
+		if !errors.Is(err, errPort) {
+			t.Errorf("%d: expected error to wrap the validator's error", i)
+		}
+	}
+}
+
+func TestParseValidateMultiple(t *testing.T) {
+	var seen []string
+	record := func(v interface{}) error {
+		if s, ok := v.(string); ok {
+			seen = append(seen, s)
+		}
+		return nil
+	}
+	_, err := Parse(strings.NewReader("a: 1\nb:\n  a: 2\n  c: 3\n"),
+		Validate("**.a", record), Validate("b.c", record), Validate("b", record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(seen, ",") != "1,2,3" {
+		t.Errorf("expected values 1, 2 and 3 to be checked, have %v", seen)
+	}
+}
+
+func TestParseValidateUsage(t *testing.T) {
+	if _, err := Parse(strings.NewReader("a: 1\n"), Validate("a", nil)); err == nil {
+		t.Error("expected nil function to produce an error; didn't")
+	}
+	if _, err := Parse(strings.NewReader("a: 1\n"), Validate("", checkPort)); err == nil {
+		t.Error("expected empty path to produce an error; didn't")
+	}
+	if _, err := Parse(strings.NewReader("a: 1\n"), Validate("a[", checkPort)); err == nil {
+		t.Error("expected invalid path to produce an error; didn't")
+	}
+}
+
+func TestDecodeValidate(t *testing.T) {
+	var config struct {
+		Port int `nt:"port"`
+	}
+	dec := NewDecoder(strings.NewReader("# config\nport: 123456\n"), Validate("port", checkPort))
+	err := dec.Decode(&config)
+	if nterr, ok := err.(NestedTextError); !ok || nterr.Line != 2 {
+		t.Errorf("expected error at line 2, have %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package nestext
+
+import (
+	"runtime/debug"
+)
+
+// --- Version information ---------------------------------------------------
+
+// specVersion is the version of the NestedText specification this package
+// implements, as verified by the official test suite.
+const specVersion = "3.1.0"
+
+const modulePath = "github.com/npillmayer/nestext"
+
+// SpecVersionSupported returns the version of the NestedText specification
+// which is implemented by this package.
+func SpecVersionSupported() string {
+	return specVersion
+}
+
+// Version returns the version of this module as recorded in the build information
+// of the running binary, or "(devel)" if it is unknown, e.g. when running tests.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
+
+// Names of features, as reported by Features.
+const (
+	FeatureInlineLists      = "inline-lists"      // "[ … ]" items
+	FeatureInlineDicts      = "inline-dicts"      // "{ … }" items
+	FeatureMultilineKeys    = "multiline-keys"    // ": key" items
+	FeatureMultilineStrings = "multiline-strings" // "> text" items
+	FeatureLegacyBidi       = "keep-legacy-bidi"  // extension: option KeepLegacyBidi
+	FeatureNoMixedLists     = "no-mixed-lists"    // extension: option NoMixedLists
+	FeatureTopLevel         = "top-level"         // extension: option TopLevel
+	FeatureAutoDedent       = "auto-dedent"       // extension: option AutoDedent
+)
+
+// Features returns the features of the NestedText dialect this package accepts.
+// Features of the specification are listed together with extensions, i.e. options
+// which go beyond the specification. A feature mapping to false is known but not
+// (yet) available.
+//
+// The map is created for every call, so clients are free to modify it.
+//
+func Features() map[string]bool {
+	return map[string]bool{
+		FeatureInlineLists:      true,
+		FeatureInlineDicts:      true,
+		FeatureMultilineKeys:    true,
+		FeatureMultilineStrings: true,
+		FeatureLegacyBidi:       true,
+		FeatureNoMixedLists:     true,
+		FeatureTopLevel:         true,
+		FeatureAutoDedent:       true,
+	}
+}
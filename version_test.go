@@ -0,0 +1,18 @@
+package nestext
+
+import (
+	"testing"
+)
+
+func TestSpecVersion(t *testing.T) {
+	if SpecVersionSupported() != "3.1.0" {
+		t.Errorf("unexpected spec version %s", SpecVersionSupported())
+	}
+	if v := Version(); v == "" {
+		t.Errorf("expected module version to be non-empty")
+	}
+	features := Features()
+	if !features[FeatureInlineDicts] || !features[FeatureLegacyBidi] {
+		t.Errorf("unexpected feature set %v", features)
+	}
+}